@@ -16,11 +16,33 @@ type ScratchStorage struct {
 	LocalPath string `yaml:"localpath"`
 }
 
+/**
+selects the backend that runs job steps. Type is either "kubernetes" (the default) or "local".
+WrapperPath and WebappBase are only used by the local executor.
+*/
+type ExecutorConfig struct {
+	Type        string `yaml:"type"`
+	WrapperPath string `yaml:"wrapperpath"`
+	WebappBase  string `yaml:"webappbase"`
+}
+
+/**
+optional limits on the number of job steps that can run at once, on top of MaxJobs.
+StepTypes is keyed by step type (analysis, thumbnail, transcode, custom) and Templates by job template id.
+*/
+type ConcurrencyConfig struct {
+	StepTypes map[string]int `yaml:"steptypes"`
+	Templates map[string]int `yaml:"templates"`
+}
+
 type Config struct {
-	Redis        RedisConfig    `yaml:"redis"`
-	Scratch      ScratchStorage `yaml:"scratch"`
-	SettingsPath string         `yaml:"settingspath"`
-	MaxJobs      int            `yaml:"maxjobs"`
+	Redis            RedisConfig       `yaml:"redis"`
+	Scratch          ScratchStorage    `yaml:"scratch"`
+	SettingsPath     string            `yaml:"settingspath"`
+	MaxJobs          int               `yaml:"maxjobs"`
+	Executor         ExecutorConfig    `yaml:"executor"`
+	Concurrency      ConcurrencyConfig `yaml:"concurrency"`
+	StrictValidation bool              `yaml:"strictvalidation"` //if true then the server won't start if there are problems with the templates or settings, the same as -strict
 }
 
 func ReadConfig(configFile string) (*Config, error) {
@@ -17,4 +17,9 @@ type JobStep interface {
 	RunnerDesc() *JobRunnerDesc
 	WithNewMediaFile(newMediaFile string) JobStep
 	DeleteAssociatedItems(redisClient redis.Cmdable) []error
+	RetryPolicy() *RetryPolicy
+	Attempts() []StepAttempt
+	WithAttemptRecorded(attempt StepAttempt) JobStep
+	WithAttemptHistory(history []StepAttempt) JobStep
+	StepConditions() *StepConditions
 }
@@ -4,22 +4,31 @@ import (
 	"errors"
 	"fmt"
 	"github.com/davecgh/go-spew/spew"
+	"github.com/go-redis/redis/v7"
 	"github.com/google/uuid"
 	"github.com/guardian/mediaflipper/common/helpers"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"log"
+	"sync"
 	"time"
 )
 
 type JobStepTemplateDefinition struct {
-	Id                     uuid.UUID         `yaml:"Id"`
-	PredeterminedType      string            `yaml:"PredeterminedType"`
-	KubernetesTemplateFile string            `yaml:"KubernetesTemplateFile"`
-	InProgressLabel        string            `yaml:"InProgressLabel"`
-	TranscodeSettingsId    string            `yaml:"TranscodeSettingsId"`
-	ThumbnailFrameSeconds  float64           `yaml:"ThumbnailFrameSeconds"`
-	CustomArguments        map[string]string `yaml:"CustomArguments"`
+	Id                     uuid.UUID              `yaml:"Id"`
+	PredeterminedType      string                 `yaml:"PredeterminedType"`
+	KubernetesTemplateFile string                 `yaml:"KubernetesTemplateFile"`
+	InProgressLabel        string                 `yaml:"InProgressLabel"`
+	TranscodeSettingsId    string                 `yaml:"TranscodeSettingsId"`
+	ThumbnailFrameSeconds  float64                `yaml:"ThumbnailFrameSeconds"`
+	ThumbnailMode          string                 `yaml:"ThumbnailMode"` //optional, thumbnail steps only. one of the THUMBNAIL_MODE values, defaults to fixed
+	CustomArguments        map[string]string      `yaml:"CustomArguments"`
+	Retry                  *RetryPolicy           `yaml:"Retry"`          //optional, if not set then a failed step fails the job
+	Conditions             *StepConditions        `yaml:"Conditions"`     //optional, if not set then the step always runs
+	DependsOn              []uuid.UUID            `yaml:"DependsOn"`      //ids of the steps in this template that must finish before this one can start
+	Sprites                *SpriteSheetSettings   `yaml:"Sprites"`        //optional, thumbnail steps only. if set then sprite sheets are made instead of a single thumbnail
+	QC                     *QCSettings            `yaml:"QC"`             //qc steps only, which detectors to run and what to do when their limits are exceeded
+	QualityMetrics         *QualityMetricSettings `yaml:"QualityMetrics"` //optional, transcode steps only. if set then the output is compared with the source when the transcode finishes
 }
 
 type JobTemplateDefinition struct {
@@ -27,6 +36,73 @@ type JobTemplateDefinition struct {
 	JobTypeName string                      `yaml:"Name"`
 	Steps       []JobStepTemplateDefinition `yaml:"Steps"`
 	OutputPath  string                      `yaml:"OutputPath"`
+	MediaType   helpers.BulkItemType        `yaml:"MediaType"` //optional, if set then any transcode settings must be for this type of media
+	Version     int64                       `yaml:"-"`         //0 for the templates in the template file, which can't be changed
+	Retired     bool                        `yaml:"-"`         //retired templates are kept for the jobs that used them but can't be used for new ones
+	UpdatedAt   *time.Time                  `yaml:"-"`
+}
+
+/**
+returns true if any of the steps declare dependencies. In this case steps without dependencies start straight away and
+independent steps can run at the same time; otherwise each step runs after the one before it.
+*/
+func (t JobTemplateDefinition) HasBranches() bool {
+	for _, stepTemplate := range t.Steps {
+		if len(stepTemplate.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+checks that the step dependencies refer to steps within the template and don't form a loop
+*/
+func (t JobTemplateDefinition) CheckDependencies() error {
+	stepIndex := make(map[uuid.UUID]int, len(t.Steps))
+	for idx, stepTemplate := range t.Steps {
+		if _, isDuplicate := stepIndex[stepTemplate.Id]; isDuplicate {
+			return errors.New(fmt.Sprintf("template %s has more than one step with id %s", t.Id, stepTemplate.Id))
+		}
+		stepIndex[stepTemplate.Id] = idx
+	}
+	for _, stepTemplate := range t.Steps {
+		for _, depId := range stepTemplate.DependsOn {
+			if _, haveDep := stepIndex[depId]; !haveDep {
+				return errors.New(fmt.Sprintf("step %s of template %s depends on %s which is not in the template", stepTemplate.Id, t.Id, depId))
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(t.Steps))
+	var visit func(idx int) error
+	visit = func(idx int) error {
+		switch state[idx] {
+		case visiting:
+			return errors.New(fmt.Sprintf("the dependencies of step %s of template %s form a loop", t.Steps[idx].Id, t.Id))
+		case visited:
+			return nil
+		}
+		state[idx] = visiting
+		for _, depId := range t.Steps[idx].DependsOn {
+			if depErr := visit(stepIndex[depId]); depErr != nil {
+				return depErr
+			}
+		}
+		state[idx] = visited
+		return nil
+	}
+	for idx := range t.Steps {
+		if loopErr := visit(idx); loopErr != nil {
+			return loopErr
+		}
+	}
+	return nil
 }
 
 type TemplateManagerIF interface {
@@ -35,9 +111,16 @@ type TemplateManagerIF interface {
 	GetJob(jobId uuid.UUID) (JobTemplateDefinition, bool)
 }
 
+/**
+keeps track of the job templates. The templates from the template file are read-only; if a datastore is in use then
+templates made through the API are kept there alongside them
+*/
 type JobTemplateManager struct {
-	loadedTemplates      map[uuid.UUID]JobTemplateDefinition
+	loadedTemplates      map[uuid.UUID]JobTemplateDefinition //the templates from the template file
 	transcodeSettingsMgr *TranscodeSettingsManager
+	redisClient          redis.Cmdable               //nil if there is no datastore for stored templates
+	templateFileCheck    func(fileName string) error //checks that a KubernetesTemplateFile can be used, when validating templates
+	lock                 sync.RWMutex                //guards loadedTemplates, which is replaced when the template file is reloaded
 }
 
 /**
@@ -69,7 +152,40 @@ func NewJobTemplateManager(fromFilePath string, transcodeSettingsMgr *TranscodeS
 	return &mgr, nil
 }
 
-func (mgr JobTemplateManager) getTranscodeSettings(transcodeSettingsId string) (TranscodeTypeSettings, error) {
+/**
+keep templates that are made through the API in the given datastore, as well as the ones from the template file.
+templateFileCheck is used to make sure that the KubernetesTemplateFile of each step can be loaded when a template is saved
+*/
+func (mgr *JobTemplateManager) UseDatastore(redisClient redis.Cmdable, templateFileCheck func(fileName string) error) {
+	mgr.redisClient = redisClient
+	mgr.templateFileCheck = templateFileCheck
+}
+
+/**
+swap in the templates from another manager, i.e. one that has just been loaded from the changed template file. Stored
+templates are not affected, and jobs that have already been made keep the steps they were made with
+*/
+func (mgr *JobTemplateManager) ReplaceFileTemplates(from *JobTemplateManager) {
+	from.lock.RLock()
+	newTemplates := from.loadedTemplates
+	from.lock.RUnlock()
+
+	mgr.lock.Lock()
+	mgr.loadedTemplates = newTemplates
+	mgr.lock.Unlock()
+}
+
+/**
+returns the given template from the template file, and false if it is not there
+*/
+func (mgr *JobTemplateManager) fileTemplate(templateId uuid.UUID) (JobTemplateDefinition, bool) {
+	mgr.lock.RLock()
+	defer mgr.lock.RUnlock()
+	template, exists := mgr.loadedTemplates[templateId]
+	return template, exists
+}
+
+func (mgr *JobTemplateManager) getTranscodeSettings(transcodeSettingsId string) (TranscodeTypeSettings, error) {
 	var s TranscodeTypeSettings
 	//spew.Dump(stepTemplate)
 	if transcodeSettingsId != "" {
@@ -91,11 +207,20 @@ func (mgr JobTemplateManager) getTranscodeSettings(transcodeSettingsId string) (
 	}
 }
 
-func (mgr JobTemplateManager) NewJobContainer(templateId uuid.UUID, itemType helpers.BulkItemType) (*JobContainer, error) {
-	tplEntry, tplExists := mgr.loadedTemplates[templateId]
+func (mgr *JobTemplateManager) NewJobContainer(templateId uuid.UUID, itemType helpers.BulkItemType) (*JobContainer, error) {
+	tplEntry, tplExists := mgr.GetJob(templateId)
 	if !tplExists {
 		return nil, errors.New(fmt.Sprintf("Request for non-existent template with id %s", templateId))
 	}
+	if tplEntry.Retired {
+		return nil, errors.New(fmt.Sprintf("Template %s has been retired and can't be used for new jobs", templateId))
+	}
+	if tplEntry.HasBranches() {
+		depErr := tplEntry.CheckDependencies()
+		if depErr != nil {
+			return nil, depErr
+		}
+	}
 
 	newContainerId := uuid.New()
 	steps := make([]JobStep, len(tplEntry.Steps))
@@ -112,6 +237,8 @@ func (mgr JobTemplateManager) NewJobContainer(templateId uuid.UUID, itemType hel
 				MediaFile:              "",
 				KubernetesTemplateFile: stepTemplate.KubernetesTemplateFile,
 				ItemType:               itemType,
+				Retry:                  stepTemplate.Retry,
+				Conditions:             stepTemplate.Conditions,
 			}
 			steps[idx] = newStep
 		case "thumbnail":
@@ -128,12 +255,16 @@ func (mgr JobTemplateManager) NewJobContainer(templateId uuid.UUID, itemType hel
 				ContainerData:          nil,
 				StatusValue:            JOB_PENDING,
 				ThumbnailFrameSeconds:  stepTemplate.ThumbnailFrameSeconds,
+				ThumbnailMode:          stepTemplate.ThumbnailMode,
+				Sprites:                stepTemplate.Sprites,
 				ResultId:               nil,
 				TimeTakenValue:         0,
 				MediaFile:              "",
 				KubernetesTemplateFile: stepTemplate.KubernetesTemplateFile,
 				TranscodeSettings:      s,
 				ItemType:               itemType,
+				Retry:                  stepTemplate.Retry,
+				Conditions:             stepTemplate.Conditions,
 			}
 			steps[idx] = newStep
 		case "transcode":
@@ -142,6 +273,12 @@ func (mgr JobTemplateManager) NewJobContainer(templateId uuid.UUID, itemType hel
 				log.Printf("WARNING: Could not get transocde settings for %s: %s", spew.Sdump(stepTemplate), settingsErr)
 				s = nil
 			}
+			if stepTemplate.QualityMetrics != nil && !stepTemplate.QualityMetrics.IsValid() {
+				return nil, errors.New(fmt.Sprintf("transcode step %s of template %s has invalid quality metric settings", stepTemplate.Id, tplEntry.Id))
+			}
+			if qualityCantBeChecked(stepTemplate.QualityMetrics, s) {
+				return nil, errors.New(fmt.Sprintf("transcode step %s of template %s sets minimum quality scores for streaming packages, which can't be measured", stepTemplate.Id, tplEntry.Id))
+			}
 
 			newStep := JobStepTranscode{
 				JobStepType:            "transcode",
@@ -155,6 +292,9 @@ func (mgr JobTemplateManager) NewJobContainer(templateId uuid.UUID, itemType hel
 				KubernetesTemplateFile: stepTemplate.KubernetesTemplateFile,
 				TranscodeSettings:      s,
 				ItemType:               itemType,
+				Retry:                  stepTemplate.Retry,
+				Conditions:             stepTemplate.Conditions,
+				QualitySettings:        stepTemplate.QualityMetrics,
 			}
 			steps[idx] = newStep
 		case "custom":
@@ -170,6 +310,29 @@ func (mgr JobTemplateManager) NewJobContainer(templateId uuid.UUID, itemType hel
 				KubernetesTemplateFile: stepTemplate.KubernetesTemplateFile,
 				ItemType:               itemType,
 				CustomArguments:        stepTemplate.CustomArguments,
+				Retry:                  stepTemplate.Retry,
+				Conditions:             stepTemplate.Conditions,
+			}
+			steps[idx] = newStep
+		case "qc":
+			if stepTemplate.QC == nil {
+				return nil, errors.New(fmt.Sprintf("qc step %s of template %s has no QC settings", stepTemplate.Id, tplEntry.Id))
+			}
+			if validationErr := stepTemplate.QC.Validate(); validationErr != nil {
+				return nil, errors.New(fmt.Sprintf("qc step %s of template %s has invalid settings: %s", stepTemplate.Id, tplEntry.Id, validationErr))
+			}
+			newStep := JobStepQC{
+				JobStepType:            "qc",
+				JobStepId:              uuid.New(),
+				JobContainerId:         newContainerId,
+				ContainerData:          nil,
+				StatusValue:            JOB_PENDING,
+				QCSettings:             stepTemplate.QC,
+				MediaFile:              "",
+				KubernetesTemplateFile: stepTemplate.KubernetesTemplateFile,
+				ItemType:               itemType,
+				Retry:                  stepTemplate.Retry,
+				Conditions:             stepTemplate.Conditions,
 			}
 			steps[idx] = newStep
 		default:
@@ -177,29 +340,205 @@ func (mgr JobTemplateManager) NewJobContainer(templateId uuid.UUID, itemType hel
 		}
 	}
 
+	var dependencies map[uuid.UUID][]uuid.UUID
+	if tplEntry.HasBranches() {
+		//the template refers to its own step ids, the job needs the ids of the steps that were just created
+		stepIdFor := make(map[uuid.UUID]uuid.UUID, len(steps))
+		for idx, stepTemplate := range tplEntry.Steps {
+			if steps[idx] != nil {
+				stepIdFor[stepTemplate.Id] = steps[idx].StepId()
+			}
+		}
+		dependencies = make(map[uuid.UUID][]uuid.UUID, len(steps))
+		for idx, stepTemplate := range tplEntry.Steps {
+			if steps[idx] == nil {
+				continue
+			}
+			deps := make([]uuid.UUID, 0, len(stepTemplate.DependsOn))
+			for _, depId := range stepTemplate.DependsOn {
+				if newId, haveId := stepIdFor[depId]; haveId {
+					deps = append(deps, newId)
+				}
+			}
+			dependencies[steps[idx].StepId()] = deps
+		}
+	}
+
 	startTime := time.Now()
 	return &JobContainer{
-		Id:             newContainerId,
-		JobTemplateId:  templateId,
-		Steps:          steps,
-		CompletedSteps: 0,
-		Status:         JOB_PENDING,
-		StartTime:      &startTime,
-		OutputPath:     tplEntry.OutputPath,
+		Id:                 newContainerId,
+		JobTemplateId:      templateId,
+		JobTemplateVersion: tplEntry.Version,
+		Steps:              steps,
+		CompletedSteps:     0,
+		Status:             JOB_PENDING,
+		StartTime:          &startTime,
+		OutputPath:         tplEntry.OutputPath,
+		Dependencies:       dependencies,
 	}, nil
 }
 
-func (mgr JobTemplateManager) ListTemplates() []JobTemplateDefinition {
-	rtn := make([]JobTemplateDefinition, len(mgr.loadedTemplates))
-	i := 0
-	for _, templateDef := range mgr.loadedTemplates {
-		rtn[i] = templateDef
-		i += 1
+/**
+returns the templates that can be used for new jobs, the ones from the template file followed by any stored ones
+*/
+func (mgr *JobTemplateManager) ListTemplates() []JobTemplateDefinition {
+	allTemplates := mgr.ListAllTemplates()
+	rtn := make([]JobTemplateDefinition, 0, len(allTemplates))
+	for _, templateDef := range allTemplates {
+		if !templateDef.Retired {
+			rtn = append(rtn, templateDef)
+		}
 	}
 	return rtn
 }
 
-func (mgr JobTemplateManager) GetJob(jobId uuid.UUID) (JobTemplateDefinition, bool) {
-	template, exists := mgr.loadedTemplates[jobId]
-	return template, exists
+/**
+returns every template, including retired ones
+*/
+func (mgr *JobTemplateManager) ListAllTemplates() []JobTemplateDefinition {
+	mgr.lock.RLock()
+	rtn := make([]JobTemplateDefinition, 0, len(mgr.loadedTemplates))
+	for _, templateDef := range mgr.loadedTemplates {
+		rtn = append(rtn, templateDef)
+	}
+	mgr.lock.RUnlock()
+	if mgr.redisClient == nil {
+		return rtn
+	}
+
+	stored, listErr := ListStoredJobTemplates(mgr.redisClient)
+	if listErr != nil {
+		log.Printf("ERROR: could not list stored templates, only the ones from the template file will be returned: %s", listErr)
+		return rtn
+	}
+	return append(rtn, stored...)
+}
+
+/**
+returns the current version of the given template, and false if it does not exist
+*/
+func (mgr *JobTemplateManager) GetJob(jobId uuid.UUID) (JobTemplateDefinition, bool) {
+	template, exists := mgr.fileTemplate(jobId)
+	if exists || mgr.redisClient == nil {
+		return template, exists
+	}
+
+	stored, getErr := GetStoredJobTemplate(jobId, mgr.redisClient)
+	if getErr != nil || stored == nil {
+		return JobTemplateDefinition{}, false
+	}
+	return *stored, true
+}
+
+/**
+returns a specific version of the given template, so that a job can find the template it was made from even if it has
+since been changed. Version 0 is the template from the template file
+*/
+func (mgr *JobTemplateManager) GetJobVersion(jobId uuid.UUID, version int64) (JobTemplateDefinition, bool) {
+	if version == 0 {
+		return mgr.fileTemplate(jobId)
+	}
+	if mgr.redisClient == nil {
+		return JobTemplateDefinition{}, false
+	}
+
+	stored, getErr := GetStoredJobTemplateVersion(jobId, version, mgr.redisClient)
+	if getErr != nil || stored == nil {
+		return JobTemplateDefinition{}, false
+	}
+	return *stored, true
+}
+
+/**
+returns true if the given template is from the template file, which can't be changed through the API
+*/
+func (mgr *JobTemplateManager) IsReadOnly(templateId uuid.UUID) bool {
+	_, isFromFile := mgr.fileTemplate(templateId)
+	return isFromFile
+}
+
+/**
+validate and save the given template as a new version. Templates from the template file can't be saved over.
+returns the saved template, with its new version number
+*/
+func (mgr *JobTemplateManager) SaveTemplate(tpl JobTemplateDefinition) (*JobTemplateDefinition, error) {
+	if mgr.redisClient == nil {
+		return nil, errors.New("there is no datastore to save templates to")
+	}
+	if mgr.IsReadOnly(tpl.Id) {
+		return nil, errors.New(fmt.Sprintf("template %s is from the template file and can't be changed", tpl.Id))
+	}
+	if validationErr := mgr.ValidateTemplate(tpl); validationErr != nil {
+		return nil, validationErr
+	}
+	return PutStoredJobTemplate(tpl, mgr.redisClient)
+}
+
+/**
+make a copy of the given template with a new id and name, and save it. Any template can be cloned, including the ones
+from the template file, so this is the way to make a changed version of one of those
+*/
+func (mgr *JobTemplateManager) CloneTemplate(templateId uuid.UUID, newName string) (*JobTemplateDefinition, error) {
+	source, exists := mgr.GetJob(templateId)
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("there is no template with id %s", templateId))
+	}
+
+	clone := source
+	clone.Id = uuid.New()
+	clone.Retired = false
+	clone.Steps = append([]JobStepTemplateDefinition{}, source.Steps...)
+	if newName != "" {
+		clone.JobTypeName = newName
+	} else {
+		clone.JobTypeName = "Copy of " + source.JobTypeName
+	}
+	return mgr.SaveTemplate(clone)
+}
+
+/**
+mark the given template as retired by saving a new version of it. Jobs that were made from it are unaffected but no new
+jobs can be made from it
+*/
+func (mgr *JobTemplateManager) RetireTemplate(templateId uuid.UUID) (*JobTemplateDefinition, error) {
+	if mgr.redisClient == nil {
+		return nil, errors.New("there is no datastore to retire templates in")
+	}
+	if mgr.IsReadOnly(templateId) {
+		return nil, errors.New(fmt.Sprintf("template %s is from the template file and can't be retired", templateId))
+	}
+	current, exists := mgr.GetJob(templateId)
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("there is no template with id %s", templateId))
+	}
+	current.Retired = true
+	return PutStoredJobTemplate(current, mgr.redisClient)
+}
+
+/**
+returns every version of the given stored template, oldest first. Templates from the template file have no history
+*/
+func (mgr *JobTemplateManager) TemplateHistory(templateId uuid.UUID) ([]JobTemplateDefinition, error) {
+	if mgr.redisClient == nil {
+		return []JobTemplateDefinition{}, nil
+	}
+	return StoredJobTemplateHistory(templateId, mgr.redisClient)
+}
+
+/**
+returns the templates that have a step using the given transcode settings, so that settings which are in use are not
+removed from under them. Retired templates are not included
+*/
+func (mgr *JobTemplateManager) TemplatesUsingSettings(settingsId uuid.UUID) []JobTemplateDefinition {
+	rtn := make([]JobTemplateDefinition, 0)
+	for _, templateDef := range mgr.ListTemplates() {
+		for _, stepTemplate := range templateDef.Steps {
+			stepSettingsId, parseErr := uuid.Parse(stepTemplate.TranscodeSettingsId)
+			if parseErr == nil && stepSettingsId == settingsId {
+				rtn = append(rtn, templateDef)
+				break
+			}
+		}
+	}
+	return rtn
 }
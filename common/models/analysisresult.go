@@ -12,8 +12,37 @@ type FormatAnalysis struct {
 	ProbeScore     int32   `json:"probe_score"`
 }
 
+/**
+the analysis of a single stream in the file. Fields that don't apply to the type of stream are left empty,
+e.g. an audio stream has no width or height
+*/
+type StreamAnalysis struct {
+	Index         int32   `json:"index"`
+	CodecType     string  `json:"codec_type"` //video, audio, subtitle, data etc.
+	CodecName     string  `json:"codec_name"`
+	CodecLongName string  `json:"codec_long_name"`
+	Profile       string  `json:"profile"`
+	Width         int32   `json:"width"`
+	Height        int32   `json:"height"`
+	FrameRate     float64 `json:"frame_rate"`   //in frames per second, e.g. 29.97
+	RawFrameRate  string  `json:"r_frame_rate"` //as a fraction, e.g. 30000/1001
+	FieldOrder    string  `json:"field_order"`  //progressive, tt, bb, tb or bt
+	PixFmt        string  `json:"pix_fmt"`
+	Channels      int32   `json:"channels"`
+	ChannelLayout string  `json:"channel_layout"`
+	SampleRate    int32   `json:"sample_rate"`
+	BitRate       float64 `json:"bit_rate"`
+	Duration      float64 `json:"duration"`
+	Language      string  `json:"language"`
+	Timecode      string  `json:"timecode"`
+	Rotation      float64 `json:"rotation"` //in degrees
+}
+
 type AnalysisResult struct {
-	Success      bool           `json:"successful"`
-	Format       FormatAnalysis `json:"format"`
-	ErrorMessage *string        `json:"errorMessage"`
+	Success      bool              `json:"successful"`
+	Format       FormatAnalysis    `json:"format"`
+	Streams      []StreamAnalysis  `json:"streams"`
+	Loudness     *LoudnessAnalysis `json:"loudness"`  //nil if the file has no audio or it could not be measured
+	Checksums    *Checksums        `json:"checksums"` //of the original media
+	ErrorMessage *string           `json:"errorMessage"`
 }
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+)
+
+/**
+fixity information for a file. Both sums are lower-case hex strings
+*/
+type Checksums struct {
+	MD5    string `json:"md5" mapstructure:"md5"`
+	SHA256 string `json:"sha256" mapstructure:"sha256"`
+}
+
+/**
+the checksums of a set of files, keyed by the path of each file
+*/
+type FileChecksums map[string]Checksums
+
+/**
+works out the checksums of everything written to it, so that they can be calculated in the same pass as some other
+operation on the data (e.g. with io.MultiWriter or io.TeeReader)
+*/
+type ChecksumWriter struct {
+	md5Hash    hash.Hash
+	sha256Hash hash.Hash
+}
+
+func NewChecksumWriter() *ChecksumWriter {
+	return &ChecksumWriter{
+		md5Hash:    md5.New(),
+		sha256Hash: sha256.New(),
+	}
+}
+
+func (c *ChecksumWriter) Write(p []byte) (int, error) {
+	c.md5Hash.Write(p) //hash.Hash never returns an error from Write
+	c.sha256Hash.Write(p)
+	return len(p), nil
+}
+
+/**
+returns the checksums of the data written so far
+*/
+func (c *ChecksumWriter) Checksums() Checksums {
+	return Checksums{
+		MD5:    hex.EncodeToString(c.md5Hash.Sum(nil)),
+		SHA256: hex.EncodeToString(c.sha256Hash.Sum(nil)),
+	}
+}
+
+/**
+works out the checksums of the content of the reader. The content is streamed rather than loaded into memory, so this
+is fine for large media files
+*/
+func ComputeChecksums(from io.Reader) (Checksums, error) {
+	writer := NewChecksumWriter()
+	_, copyErr := io.Copy(writer, from)
+	if copyErr != nil {
+		return Checksums{}, copyErr
+	}
+	return writer.Checksums(), nil
+}
+
+func ChecksumFile(filePath string) (Checksums, error) {
+	f, openErr := os.Open(filePath)
+	if openErr != nil {
+		return Checksums{}, openErr
+	}
+	defer f.Close()
+	return ComputeChecksums(f)
+}
+
+/**
+returns true if the given checksums are the same as these ones. Only the sums that are present in both are compared,
+and there must be at least one of them
+*/
+func (c Checksums) Matches(other Checksums) bool {
+	compared := false
+	if c.MD5 != "" && other.MD5 != "" {
+		if c.MD5 != other.MD5 {
+			return false
+		}
+		compared = true
+	}
+	if c.SHA256 != "" && other.SHA256 != "" {
+		if c.SHA256 != other.SHA256 {
+			return false
+		}
+		compared = true
+	}
+	return compared
+}
+
+/**
+returns the checksums for the given path, or nil if there are none
+*/
+func (f FileChecksums) For(filePath string) *Checksums {
+	if sums, haveSums := f[filePath]; haveSums {
+		return &sums
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+/**
+ComputeChecksums should give the MD5 and SHA-256 of the content
+*/
+func TestComputeChecksums(t *testing.T) {
+	result, err := ComputeChecksums(strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatal("ComputeChecksums failed: ", err)
+	}
+	if result.MD5 != "5eb63bbbe01eeed093cb22bb8f5acdc3" {
+		t.Errorf("Got wrong md5 %s", result.MD5)
+	}
+	if result.SHA256 != "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9" {
+		t.Errorf("Got wrong sha256 %s", result.SHA256)
+	}
+}
+
+/**
+a ChecksumWriter used alongside another writer should give the same sums as ComputeChecksums, and ChecksumFile should
+agree with both
+*/
+func TestChecksumWriter(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 100000)
+	expected, _ := ComputeChecksums(bytes.NewReader(content))
+
+	var copied bytes.Buffer
+	writer := NewChecksumWriter()
+	_, copyErr := io.Copy(io.MultiWriter(&copied, writer), bytes.NewReader(content))
+	if copyErr != nil {
+		t.Fatal("copy failed: ", copyErr)
+	}
+	if writer.Checksums() != expected {
+		t.Errorf("ChecksumWriter gave %v, expected %v", writer.Checksums(), expected)
+	}
+	if !bytes.Equal(copied.Bytes(), content) {
+		t.Error("content was changed by the copy")
+	}
+
+	tempFile, _ := ioutil.TempFile("", "checksumtest")
+	defer os.Remove(tempFile.Name())
+	tempFile.Write(content)
+	tempFile.Close()
+	fromFile, fileErr := ChecksumFile(tempFile.Name())
+	if fileErr != nil {
+		t.Fatal("ChecksumFile failed: ", fileErr)
+	}
+	if fromFile != expected {
+		t.Errorf("ChecksumFile gave %v, expected %v", fromFile, expected)
+	}
+
+	_, missingErr := ChecksumFile("/path/that/does/not/exist")
+	if missingErr == nil {
+		t.Error("ChecksumFile should fail for a file that does not exist")
+	}
+}
+
+/**
+Matches should compare the sums present in both, and fail if there is nothing to compare
+*/
+func TestChecksumsMatches(t *testing.T) {
+	full := Checksums{MD5: "aaa", SHA256: "bbb"}
+	if !full.Matches(Checksums{MD5: "aaa", SHA256: "bbb"}) {
+		t.Error("identical checksums should match")
+	}
+	if !full.Matches(Checksums{SHA256: "bbb"}) {
+		t.Error("checksums should match on sha256 alone")
+	}
+	if full.Matches(Checksums{MD5: "aaa", SHA256: "ccc"}) {
+		t.Error("checksums with a different sha256 should not match")
+	}
+	if full.Matches(Checksums{}) {
+		t.Error("empty checksums should not match anything")
+	}
+	if (Checksums{MD5: "aaa"}).Matches(Checksums{SHA256: "bbb"}) {
+		t.Error("checksums with nothing in common should not match")
+	}
+}
+
+/**
+FileChecksums.For should return the sums for a path, or nil if there are none
+*/
+func TestFileChecksumsFor(t *testing.T) {
+	sums := FileChecksums{"/path/to/file.mp4": {MD5: "aaa", SHA256: "bbb"}}
+	found := sums.For("/path/to/file.mp4")
+	if found == nil || found.SHA256 != "bbb" {
+		t.Errorf("expected to find checksums for the file, got %v", found)
+	}
+	if sums.For("/path/to/other.mp4") != nil {
+		t.Error("expected nil for a file with no checksums")
+	}
+	var noSums FileChecksums
+	if noSums.For("/path/to/file.mp4") != nil {
+		t.Error("expected nil from an empty map")
+	}
+}
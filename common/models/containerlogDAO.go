@@ -25,6 +25,30 @@ func GetContainerLogContent(forStepId uuid.UUID, redisClient redis.Cmdable) (str
 	return redisClient.Get(dbKey).Result()
 }
 
+func containerLogAttemptKey(forStepId uuid.UUID, attempt int) string {
+	return fmt.Sprintf("mediaflipper:containerlog:%s:attempt:%d", forStepId, attempt)
+}
+
+/**
+keeps a copy of the current logs for the given step as the logs for the given attempt, so that they are not
+overwritten when the step is retried. it's not an error if there are no logs to copy.
+*/
+func ArchiveContainerLogForAttempt(forStepId uuid.UUID, attempt int, redisClient redis.Cmdable) error {
+	content, getErr := GetContainerLogContent(forStepId, redisClient)
+	if getErr == redis.Nil {
+		return nil
+	} else if getErr != nil {
+		return getErr
+	}
+
+	_, setErr := redisClient.Set(containerLogAttemptKey(forStepId, attempt), content, -1).Result()
+	return setErr
+}
+
+func GetContainerLogContentForAttempt(forStepId uuid.UUID, attempt int, redisClient redis.Cmdable) (string, error) {
+	return redisClient.Get(containerLogAttemptKey(forStepId, attempt)).Result()
+}
+
 func GetContainerLogContentStream(forStepId uuid.UUID, client redis.Cmdable) (io.Reader, error) {
 	str, err := GetContainerLogContent(forStepId, client)
 
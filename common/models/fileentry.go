@@ -8,8 +8,11 @@ import (
 	"github.com/go-redis/redis/v7"
 	"github.com/google/uuid"
 	"github.com/h2non/filetype"
+	"io/ioutil"
 	"log"
 	"os"
+	"path"
+	"strings"
 )
 
 type FileType string
@@ -19,15 +22,22 @@ const (
 	TYPE_ORIGINAL  FileType = "original"
 	TYPE_TRANSCODE FileType = "transcode"
 	TYPE_SIDECAR   FileType = "sidecar"
+	//streaming packages. the entry points to the manifest, and the segments are the other files in the same directory
+	TYPE_STREAM_HLS  FileType = "stream_hls"
+	TYPE_STREAM_DASH FileType = "stream_dash"
+	//scrubbing thumbnails. the index entry points to the WebVTT file and the sheets are in the same directory
+	TYPE_SPRITE_SHEET FileType = "sprite_sheet"
+	TYPE_SPRITE_INDEX FileType = "sprite_index"
 )
 
 type FileEntry struct {
 	Id             uuid.UUID `json:"fileId"`
 	ServerPath     string
-	JobContainerId uuid.UUID `json:"forJob"`
-	FileType       FileType  `json:"type"`
-	MimeType       string    `json:"mimeType"`
-	Size           int64     `json:"size"`
+	JobContainerId uuid.UUID  `json:"forJob"`
+	FileType       FileType   `json:"type"`
+	MimeType       string     `json:"mimeType"`
+	Size           int64      `json:"size"`
+	Checksums      *Checksums `json:"checksums"` //worked out by the wrapper when the file was made. For packages this is the manifest or index only
 }
 
 func NewFileEntry(forPath string, jobContainerId uuid.UUID, fileType FileType) (FileEntry, error) {
@@ -60,6 +70,117 @@ func NewFileEntry(forPath string, jobContainerId uuid.UUID, fileType FileType) (
 	}, nil
 }
 
+/**
+create a new file entry for a streaming package, given the path to its manifest. The package must have been written into
+a directory of its own, as everything in that directory is treated as a segment of it.
+the size is the size of the whole package
+*/
+func NewStreamFileEntry(manifestPath string, jobContainerId uuid.UUID, packageFormat string) (FileEntry, error) {
+	var fileType FileType
+	switch packageFormat {
+	case PACKAGE_HLS:
+		fileType = TYPE_STREAM_HLS
+	case PACKAGE_DASH:
+		fileType = TYPE_STREAM_DASH
+	default:
+		return FileEntry{}, errors.New(fmt.Sprintf("%s is not a known package format", packageFormat))
+	}
+	return newPackageFileEntry(manifestPath, jobContainerId, fileType)
+}
+
+/**
+create a new file entry for the WebVTT index of a set of sprite sheets. As with streaming packages, the sheets must be in
+a directory of their own alongside the index
+*/
+func NewSpriteIndexFileEntry(indexPath string, jobContainerId uuid.UUID) (FileEntry, error) {
+	return newPackageFileEntry(indexPath, jobContainerId, TYPE_SPRITE_INDEX)
+}
+
+func newPackageFileEntry(indexPath string, jobContainerId uuid.UUID, fileType FileType) (FileEntry, error) {
+	_, statErr := os.Stat(indexPath)
+	if statErr != nil {
+		return FileEntry{}, statErr
+	}
+
+	dirContent, readErr := ioutil.ReadDir(path.Dir(indexPath))
+	if readErr != nil {
+		return FileEntry{}, readErr
+	}
+	var totalSize int64
+	for _, info := range dirContent {
+		if !info.IsDir() {
+			totalSize += info.Size()
+		}
+	}
+
+	return FileEntry{
+		Id:             uuid.New(),
+		ServerPath:     indexPath,
+		JobContainerId: jobContainerId,
+		FileType:       fileType,
+		MimeType:       StreamContentType(indexPath),
+		Size:           totalSize,
+	}, nil
+}
+
+/**
+returns true if this entry is for a streaming package rather than a single file
+*/
+func (f FileEntry) IsStream() bool {
+	return f.FileType == TYPE_STREAM_HLS || f.FileType == TYPE_STREAM_DASH
+}
+
+/**
+returns true if this entry points to the index of a directory of files that belong together, i.e. a streaming package
+or a set of sprite sheets
+*/
+func (f FileEntry) IsPackage() bool {
+	return f.IsStream() || f.FileType == TYPE_SPRITE_INDEX
+}
+
+/**
+returns the server path of a file within a package, given its path relative to the manifest or index.
+an empty relative path gives the manifest itself. returns an error if the entry is not a package or the path would
+point outside of it
+*/
+func (f FileEntry) PackageFilePath(relativePath string) (string, error) {
+	if !f.IsPackage() {
+		return "", errors.New(fmt.Sprintf("file %s is not a package", f.Id))
+	}
+	if relativePath == "" {
+		return f.ServerPath, nil
+	}
+	cleaned := path.Clean("/" + relativePath)
+	if cleaned == "/" {
+		return "", errors.New("no filename was given")
+	}
+	return path.Join(path.Dir(f.ServerPath), cleaned), nil
+}
+
+/**
+returns the MIME type to serve a file from a package with, based on its extension
+*/
+func StreamContentType(fileName string) string {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".mpd":
+		return "application/dash+xml"
+	case ".ts":
+		return "video/mp2t"
+	case ".m4s":
+		return "video/iso.segment"
+	case ".mp4":
+		return "video/mp4"
+	case ".vtt":
+		return "text/vtt"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	default:
+		return "application/octet-stream"
+	}
+}
+
 func (f FileEntry) Store(redisClient *redis.Client) error {
 	dbKey := fmt.Sprintf("mediaflipper:fileentry:%s", f.Id)
 
@@ -87,7 +208,14 @@ func (f FileEntry) Store(redisClient *redis.Client) error {
 }
 
 func (f FileEntry) Delete(removeFromDisk bool, redisClient redis.Cmdable) error {
-	if removeFromDisk {
+	if removeFromDisk && f.IsPackage() {
+		//the package directory holds the manifest or index and all of the files it refers to
+		packageDir := path.Dir(f.ServerPath)
+		deleteErr := os.RemoveAll(packageDir)
+		if deleteErr != nil {
+			log.Printf("WARNING: Could not delete package directory %s for entry %s: %s", packageDir, f.Id, deleteErr)
+		}
+	} else if removeFromDisk {
 		deleteErr := os.Remove(f.ServerPath)
 		if deleteErr != nil {
 			log.Printf("WARNING: Could not delete file %s for entry %s: %s", f.ServerPath, f.Id, deleteErr)
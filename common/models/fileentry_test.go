@@ -7,7 +7,9 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/go-redis/redis/v7"
 	"github.com/google/uuid"
+	"io/ioutil"
 	"os"
+	"path"
 	"reflect"
 	"testing"
 )
@@ -238,3 +240,85 @@ func TestFilesForJobContainer(t *testing.T) {
 		}
 	}
 }
+
+/**
+NewStreamFileEntry should point to the manifest and count the size of the whole package, and PackageFilePath should only
+give paths inside the package
+*/
+func TestNewStreamFileEntry(t *testing.T) {
+	packageDir, _ := ioutil.TempDir("", "fileentry_test")
+	defer os.RemoveAll(packageDir)
+	manifestPath := path.Join(packageDir, "index.m3u8")
+	ioutil.WriteFile(manifestPath, []byte("#EXTM3U\n"), 0644)
+	ioutil.WriteFile(path.Join(packageDir, "segment_00000.ts"), make([]byte, 1000), 0644)
+
+	ent, err := NewStreamFileEntry(manifestPath, uuid.New(), PACKAGE_HLS)
+	if err != nil {
+		t.Error("NewStreamFileEntry failed unexpectedly: ", err)
+		t.FailNow()
+	}
+	if ent.FileType != TYPE_STREAM_HLS || !ent.IsStream() {
+		t.Errorf("got wrong file type %s", ent.FileType)
+	}
+	if ent.MimeType != "application/vnd.apple.mpegurl" {
+		t.Errorf("got wrong MIME type %s", ent.MimeType)
+	}
+	if ent.Size != 1008 {
+		t.Errorf("expected the size of the whole package, 1008, got %d", ent.Size)
+	}
+
+	segmentPath, _ := ent.PackageFilePath("segment_00000.ts")
+	if segmentPath != path.Join(packageDir, "segment_00000.ts") {
+		t.Errorf("got wrong segment path %s", segmentPath)
+	}
+	escapedPath, _ := ent.PackageFilePath("../../etc/passwd")
+	if escapedPath != path.Join(packageDir, "etc/passwd") {
+		t.Errorf("package paths should not be able to leave the package directory, got %s", escapedPath)
+	}
+
+	_, unknownErr := NewStreamFileEntry(manifestPath, uuid.New(), "smooth")
+	if unknownErr == nil {
+		t.Error("NewStreamFileEntry should fail for an unknown package format")
+	}
+
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	deleteErr := ent.Delete(true, testClient)
+	if deleteErr != nil {
+		t.Errorf("Delete failed unexpectedly: %s", deleteErr)
+	}
+	if _, statErr := os.Stat(packageDir); !os.IsNotExist(statErr) {
+		t.Errorf("deleting a package should remove its directory")
+	}
+}
+
+func TestNewSpriteIndexFileEntry(t *testing.T) {
+	spriteDir, _ := ioutil.TempDir("", "fileentry_test")
+	defer os.RemoveAll(spriteDir)
+	indexPath := path.Join(spriteDir, SPRITE_INDEX_NAME)
+	ioutil.WriteFile(indexPath, []byte("WEBVTT\n"), 0644)
+	ioutil.WriteFile(path.Join(spriteDir, "sprites_001.jpg"), make([]byte, 500), 0644)
+
+	ent, err := NewSpriteIndexFileEntry(indexPath, uuid.New())
+	if err != nil {
+		t.Error("NewSpriteIndexFileEntry failed unexpectedly: ", err)
+		t.FailNow()
+	}
+	if ent.FileType != TYPE_SPRITE_INDEX || ent.IsStream() || !ent.IsPackage() {
+		t.Errorf("got wrong file type %s", ent.FileType)
+	}
+	if ent.MimeType != "text/vtt" {
+		t.Errorf("got wrong MIME type %s", ent.MimeType)
+	}
+	sheetPath, _ := ent.PackageFilePath("sprites_001.jpg")
+	if sheetPath != path.Join(spriteDir, "sprites_001.jpg") || StreamContentType(sheetPath) != "image/jpeg" {
+		t.Errorf("got wrong sheet path %s", sheetPath)
+	}
+}
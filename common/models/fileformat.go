@@ -5,6 +5,20 @@ import (
 )
 
 type FileFormatInfo struct {
-	Id             uuid.UUID      `json:"id"`
-	FormatAnalysis FormatAnalysis `json:"formatAnalysis"`
+	Id             uuid.UUID         `json:"id"`
+	FormatAnalysis FormatAnalysis    `json:"formatAnalysis"`
+	Streams        []StreamAnalysis  `json:"streams"`
+	Loudness       *LoudnessAnalysis `json:"loudness"`
+}
+
+/**
+returns the first stream of the given codec type (e.g. "video" or "audio"), or nil if there is none
+*/
+func (f *FileFormatInfo) FirstStreamOfType(codecType string) *StreamAnalysis {
+	for i := range f.Streams {
+		if f.Streams[i].CodecType == codecType {
+			return &f.Streams[i]
+		}
+	}
+	return nil
 }
@@ -24,9 +24,10 @@ const (
 	JOB_ABORTED
 	JOB_NOT_QUEUED
 	JOB_LOST
+	JOB_SKIPPED
 )
 
-var ALL_JOB_STATUS = []JobStatus{JOB_PENDING, JOB_STARTED, JOB_COMPLETED, JOB_FAILED, JOB_ABORTED, JOB_NOT_QUEUED, JOB_LOST}
+var ALL_JOB_STATUS = []JobStatus{JOB_PENDING, JOB_STARTED, JOB_COMPLETED, JOB_FAILED, JOB_ABORTED, JOB_NOT_QUEUED, JOB_LOST, JOB_SKIPPED}
 
 func JobStatusFromString(incoming string) JobStatus {
 	switch strings.ToLower(incoming) {
@@ -44,11 +45,39 @@ func JobStatusFromString(incoming string) JobStatus {
 		return JOB_NOT_QUEUED
 	case "lost":
 		return JOB_LOST
+	case "skipped":
+		return JOB_SKIPPED
 	default:
 		return JOB_PENDING
 	}
 }
 
+/**
+the opposite of JobStatusFromString
+*/
+func JobStatusToString(status JobStatus) string {
+	switch status {
+	case JOB_PENDING:
+		return "pending"
+	case JOB_STARTED:
+		return "active"
+	case JOB_COMPLETED:
+		return "completed"
+	case JOB_FAILED:
+		return "failed"
+	case JOB_ABORTED:
+		return "aborted"
+	case JOB_NOT_QUEUED:
+		return "notqueued"
+	case JOB_LOST:
+		return "lost"
+	case JOB_SKIPPED:
+		return "skipped"
+	default:
+		return "unknown"
+	}
+}
+
 type JobSort int
 
 const (
@@ -63,6 +92,27 @@ const (
 	JOBIDX_BULKITEMASSOCIATION = "mediaflipper:jobcontainer:bulkassociation:item" //hash-table index. Key is the uuid of the bulk item we are associated with and value is the id of the job
 )
 
+/**
+jobs with a higher priority are dispatched first, jobs with the same priority are dispatched in the order they were enqueued
+*/
+const (
+	JOB_PRIORITY_NORMAL int32 = 0
+	JOB_PRIORITY_MAX    int32 = 100
+)
+
+/**
+ensure that the given priority value is within the allowed range
+*/
+func ClampJobPriority(prio int32) int32 {
+	if prio < JOB_PRIORITY_NORMAL {
+		return JOB_PRIORITY_NORMAL
+	}
+	if prio > JOB_PRIORITY_MAX {
+		return JOB_PRIORITY_MAX
+	}
+	return prio
+}
+
 type BulkAssociation struct {
 	Item uuid.UUID `json:"item"`
 	List uuid.UUID `json:"list"`
@@ -70,20 +120,28 @@ type BulkAssociation struct {
 
 //containers are initiated from JobTemplateManager, so there is no New function
 type JobContainer struct {
-	Id                uuid.UUID            `json:"id"`
-	Steps             []JobStep            `json:"steps"`
-	CompletedSteps    int                  `json:"completed_steps"`
-	Status            JobStatus            `json:"status"`
-	JobTemplateId     uuid.UUID            `json:"templateId"`
-	ErrorMessage      string               `json:"error_message"`
-	IncomingMediaFile string               `json:"incoming_media_file"`
-	StartTime         *time.Time           `json:"start_time"`
-	EndTime           *time.Time           `json:"end_time"`
-	AssociatedBulk    *BulkAssociation     `json:"associated_bulk"`
-	ItemType          helpers.BulkItemType `json:"item_type"`
-	ThumbnailId       *uuid.UUID           `json:"thumbnail_id"`
-	TranscodedMediaId *uuid.UUID           `json:"transcoded_media_id"`
-	OutputPath        string               `json:"output_path"` //optional output location
+	Id                 uuid.UUID                 `json:"id"`
+	Steps              []JobStep                 `json:"steps"`
+	CompletedSteps     int                       `json:"completed_steps"`
+	Status             JobStatus                 `json:"status"`
+	JobTemplateId      uuid.UUID                 `json:"templateId"`
+	JobTemplateVersion int64                     `json:"templateVersion"` //the version of the template that the job was made from, 0 for templates from the template file
+	ErrorMessage       string                    `json:"error_message"`
+	IncomingMediaFile  string                    `json:"incoming_media_file"`
+	StartTime          *time.Time                `json:"start_time"`
+	EndTime            *time.Time                `json:"end_time"`
+	AssociatedBulk     *BulkAssociation          `json:"associated_bulk"`
+	ItemType           helpers.BulkItemType      `json:"item_type"`
+	ThumbnailId        *uuid.UUID                `json:"thumbnail_id"`
+	TranscodedMediaId  *uuid.UUID                `json:"transcoded_media_id"`
+	OutputPath         string                    `json:"output_path"`     //optional output location
+	Priority           int32                     `json:"priority"`        //higher priority jobs are taken from the request queue first
+	Dependencies       map[uuid.UUID][]uuid.UUID `json:"dependencies"`    //step id => ids of the steps that must finish before it can start. nil means the steps run one after another
+	Renditions         []RenditionOutput         `json:"renditions"`      //every rendition output by a multi-rendition transcode
+	SourceLoudness     *LoudnessAnalysis         `json:"source_loudness"` //loudness of the incoming media, if it has been measured
+	SpriteIndexId      *uuid.UUID                `json:"sprite_index_id"` //WebVTT index of the scrubbing thumbnails, if they have been made
+	QCFlagged          bool                      `json:"qc_flagged"`      //set if a QC step found problems that should be looked at but did not fail the job
+	QueuedAtMillis     float64                   `json:"-"`               //when the job went onto the request queue, set when it is taken off so that it keeps its place if it has to go back
 }
 
 /**
@@ -179,16 +237,61 @@ func (c *JobContainer) InitialStep() JobStep {
 	return c.Steps[0]
 }
 
-func (c *JobContainer) FailCurrentStep(msg string) {
-	c.Status = JOB_FAILED
+/**
+returns true if the job is waiting or running, i.e. it has not already finished one way or another
+*/
+func (c *JobContainer) CanCancel() bool {
+	return c.Status == JOB_PENDING || c.Status == JOB_STARTED || c.Status == JOB_NOT_QUEUED || c.Status == JOB_LOST
+}
+
+/**
+marks the job as aborted, along with any steps that are running or were about to be started. the other steps are left as they are.
+*/
+func (c *JobContainer) AbortActiveSteps(msg string) {
+	toAbort := append(c.StepsWithStatus(JOB_STARTED), c.ReadySteps()...)
+	c.Status = JOB_ABORTED
 	nowTime := time.Now()
 	c.EndTime = &nowTime
 	c.ErrorMessage = msg
-	if c.CompletedSteps >= len(c.Steps) {
-		log.Printf("ERROR: Trying to fail current step when all steps have already been processed? Offending data was %s", spew.Sdump(c))
-	} else {
-		c.Steps[c.CompletedSteps] = c.Steps[c.CompletedSteps].WithNewStatus(JOB_FAILED, &msg)
+	for _, step := range toAbort {
+		c.UpdateStepById(step.StepId(), step.WithNewStatus(JOB_ABORTED, &msg))
+	}
+}
+
+/**
+returns true if the job has been stopped part-way through, i.e. no more of its steps should be started
+*/
+func (c *JobContainer) HasStopped() bool {
+	return c.Status == JOB_FAILED || c.Status == JOB_ABORTED || c.Status == JOB_LOST
+}
+
+/**
+returns true if the job has stopped part-way through and can be started again from the step that did not complete
+*/
+func (c *JobContainer) CanResume() bool {
+	return (c.Status == JOB_FAILED || c.Status == JOB_LOST) && c.CompletedSteps < len(c.Steps)
+}
+
+/**
+resets every step that did not complete to pending so that the job can be run again from that point.
+completed and skipped steps are left alone so their outputs are kept. The attempts that the reset steps have already
+had are marked as being from before the resume, so they don't count towards the retry limit any more
+*/
+func (c *JobContainer) ResetIncompleteSteps() {
+	noError := ""
+	for i, step := range c.Steps {
+		if !stepIsFinished(step) {
+			if attempts := step.Attempts(); len(attempts) > 0 {
+				history := append([]StepAttempt{}, attempts...)
+				history[len(history)-1].Resumed = true
+				step = step.WithAttemptHistory(history)
+			}
+			c.Steps[i] = step.WithNewStatus(JOB_PENDING, &noError)
+		}
 	}
+	c.Status = JOB_PENDING
+	c.ErrorMessage = ""
+	c.EndTime = nil
 }
 
 /**
@@ -218,6 +321,25 @@ func (c *JobContainer) UpdateStepById(stepId uuid.UUID, updatedStep JobStep) err
 	return errors.New("No step found for that ID")
 }
 
+/**
+swap the renditions that a step previously recorded on the job for the ones it has just made. A transcode step that
+is retried or resumed reports a whole new set of renditions, so the old ones must go rather than being listed twice
+*/
+func (c *JobContainer) ReplaceRenditions(previous []RenditionOutput, updated []RenditionOutput) {
+	previousIds := make(map[uuid.UUID]bool, len(previous))
+	for _, rendition := range previous {
+		previousIds[rendition.FileId] = true
+	}
+
+	remaining := make([]RenditionOutput, 0, len(c.Renditions)+len(updated))
+	for _, rendition := range c.Renditions {
+		if !previousIds[rendition.FileId] {
+			remaining = append(remaining, rendition)
+		}
+	}
+	c.Renditions = append(remaining, updated...)
+}
+
 /**
 sets the media file on the job and any job steps that need it
 */
@@ -301,6 +423,13 @@ func (c *JobContainer) UnmarshalJSON(data []byte) error {
 				return decErr
 			}
 			steps[i] = decodedStep
+		case "qc":
+			decodedStep, decErr := JobStepQCFromMap(rawStep)
+			if decErr != nil {
+				log.Printf("decoding ERROR: %s for %s", decErr, spew.Sdump(rawStep))
+				return decErr
+			}
+			steps[i] = decodedStep
 		default:
 			log.Printf("WARNING: Did not recognise job step type %s", rawStep["stepType"].(string))
 		}
@@ -313,14 +442,41 @@ func (c *JobContainer) UnmarshalJSON(data []byte) error {
 	c.ErrorMessage = rawDataMap["error_message"].(string)
 	c.Id = uuid.MustParse(rawDataMap["id"].(string))
 	c.JobTemplateId = uuid.MustParse(rawDataMap["templateId"].(string))
+	if templateVersion, haveTemplateVersion := rawDataMap["templateVersion"].(float64); haveTemplateVersion {
+		c.JobTemplateVersion = int64(templateVersion)
+	}
 	c.StartTime = TimeFromOptionalString(rawDataMap["start_time"])
 	c.EndTime = TimeFromOptionalString(rawDataMap["end_time"])
 	c.ThumbnailId = optionalUuid(rawDataMap, "thumbnail_id", rawDataMap["id"].(string))
 	c.TranscodedMediaId = optionalUuid(rawDataMap, "transcoded_media_id", rawDataMap["id"].(string))
+	c.SpriteIndexId = optionalUuid(rawDataMap, "sprite_index_id", rawDataMap["id"].(string))
 	outputPath, haveOutputPath := rawDataMap["output_path"]
 	if haveOutputPath {
 		c.OutputPath = outputPath.(string)
 	}
+	priority, havePriority := rawDataMap["priority"].(float64)
+	if havePriority {
+		c.Priority = int32(priority)
+	}
+	if qcFlagged, haveQcFlagged := rawDataMap["qc_flagged"].(bool); haveQcFlagged {
+		c.QCFlagged = qcFlagged
+	}
+	c.Dependencies = dependenciesFromMap(rawDataMap["dependencies"], rawDataMap["id"].(string))
+	if rawRenditions, haveRenditions := rawDataMap["renditions"]; haveRenditions && rawRenditions != nil {
+		decodeErr := CustomisedMapStructureDecode(rawRenditions, &c.Renditions)
+		if decodeErr != nil {
+			log.Printf("ERROR: could not decode renditions for job %s: %s", rawDataMap["id"].(string), decodeErr)
+		}
+	}
+	if rawLoudness, haveLoudness := rawDataMap["source_loudness"]; haveLoudness && rawLoudness != nil {
+		var loudness LoudnessAnalysis
+		decodeErr := CustomisedMapStructureDecode(rawLoudness, &loudness)
+		if decodeErr != nil {
+			log.Printf("ERROR: could not decode source loudness for job %s: %s", rawDataMap["id"].(string), decodeErr)
+		} else {
+			c.SourceLoudness = &loudness
+		}
+	}
 
 	_, haveAssocBulk := rawDataMap["associated_bulk"]
 	if haveAssocBulk && rawDataMap["associated_bulk"] != nil {
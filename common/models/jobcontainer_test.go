@@ -5,6 +5,7 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/go-redis/redis/v7"
 	"github.com/google/uuid"
+	"reflect"
 	"testing"
 )
 
@@ -42,7 +43,7 @@ func TestJobContainer_InitialStep(t *testing.T) {
 	}
 
 	result := container.InitialStep()
-	if result != steps[0] {
+	if !reflect.DeepEqual(result, steps[0]) {
 		t.Errorf("Got %s for initial step, expected %s", spew.Sprint(result), spew.Sprint(steps[0]))
 	}
 	if container.Status != JOB_PENDING {
@@ -114,7 +115,7 @@ func TestJobContainer_CompleteStepAndMoveOn(t *testing.T) {
 	}
 
 	result := container.CompleteStepAndMoveOn()
-	if result != steps[1] {
+	if !reflect.DeepEqual(result, steps[1]) {
 		t.Errorf("Expected step 1, got %s", spew.Sprint(result))
 	}
 
@@ -309,3 +310,198 @@ func TestIndexLuaRemove(t *testing.T) {
 		t.Errorf("indexLuaConcat did not write correct value, expected %s got %s", nopExpected, nopResult)
 	}
 }
+
+/**
+SkipUnmetSteps should mark steps whose conditions are not met against the analysis results as skipped, and return the
+steps that should run next
+*/
+func TestJobContainer_SkipUnmetSteps(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	analysisResult := FileFormatInfo{
+		Id: uuid.New(),
+		FormatAnalysis: FormatAnalysis{
+			FormatName: "mov,mp4,m4a,3gp,3g2,mj2",
+			BitRate:    2500000,
+		},
+	}
+	putErr := PutFileFormat(&analysisResult, testClient)
+	if putErr != nil {
+		t.Fatalf("could not set up analysis result: %s", putErr)
+	}
+
+	containerId := uuid.New()
+	container := JobContainer{
+		Id: containerId,
+		Steps: []JobStep{
+			&JobStepAnalysis{
+				JobStepId:      uuid.New(),
+				JobContainerId: containerId,
+				StatusValue:    JOB_COMPLETED,
+				ResultId:       analysisResult.Id,
+			},
+			&JobStepThumbnail{
+				JobStepId:      uuid.New(),
+				JobContainerId: containerId,
+				StatusValue:    JOB_PENDING,
+				Conditions: &StepConditions{
+					RunIf: []StepCondition{{Field: "duration", Operator: CONDITION_GT, Value: "0"}},
+				},
+			},
+			&JobStepTranscode{
+				JobStepId:      uuid.New(),
+				JobContainerId: containerId,
+				StatusValue:    JOB_PENDING,
+				Conditions: &StepConditions{
+					SkipIf: []StepCondition{{Field: "bit_rate", Operator: CONDITION_GT, Value: "5000000"}},
+				},
+			},
+		},
+		CompletedSteps: 1,
+		Status:         JOB_STARTED,
+	}
+
+	nextSteps := container.SkipUnmetSteps(testClient)
+	if len(nextSteps) != 1 || nextSteps[0].StepId() != container.Steps[2].StepId() {
+		t.Fatalf("expected the transcode step to be next, got %v", nextSteps)
+	}
+	if container.CompletedSteps != 2 {
+		t.Errorf("expected the skipped step to be counted as done, got %d completed steps", container.CompletedSteps)
+	}
+	if container.Steps[1].Status() != JOB_SKIPPED {
+		t.Errorf("expected thumbnail step to be skipped, got status %d", container.Steps[1].Status())
+	}
+	if container.Status != JOB_STARTED {
+		t.Errorf("job status should not have changed, got %d", container.Status)
+	}
+
+	//if every remaining step is skipped then the job is completed
+	container.Steps[2] = &JobStepTranscode{
+		JobStepId:      uuid.New(),
+		JobContainerId: containerId,
+		StatusValue:    JOB_PENDING,
+		Conditions: &StepConditions{
+			SkipIf: []StepCondition{{Field: "format_name", Operator: CONDITION_CONTAINS, Value: "mp4"}},
+		},
+	}
+	lastSteps := container.SkipUnmetSteps(testClient)
+	if lastSteps != nil {
+		t.Errorf("expected no more steps, got %v", lastSteps)
+	}
+	if container.CompletedSteps != 3 || container.Status != JOB_COMPLETED || container.EndTime == nil {
+		t.Errorf("expected job to be completed, got %d steps status %d", container.CompletedSteps, container.Status)
+	}
+}
+
+/**
+ReadySteps should return the pending steps whose dependencies have all finished, and CompleteStepById should only
+complete the job once every branch has finished
+*/
+func TestJobContainer_ReadySteps(t *testing.T) {
+	containerId := uuid.New()
+	analysis := JobStepAnalysis{JobStepId: uuid.New(), JobContainerId: containerId, StatusValue: JOB_PENDING}
+	thumb := JobStepThumbnail{JobStepId: uuid.New(), JobContainerId: containerId, StatusValue: JOB_PENDING}
+	transcode := JobStepTranscode{JobStepId: uuid.New(), JobContainerId: containerId, StatusValue: JOB_PENDING}
+
+	linear := JobContainer{Id: containerId, Steps: []JobStep{analysis, thumb, transcode}}
+	ready := linear.ReadySteps()
+	if len(ready) != 1 || ready[0].StepId() != analysis.JobStepId {
+		t.Errorf("expected only the first step to be ready in a job without branches, got %v", ready)
+	}
+	linear.CompleteStepById(analysis.JobStepId)
+	ready = linear.ReadySteps()
+	if len(ready) != 1 || ready[0].StepId() != thumb.JobStepId {
+		t.Errorf("expected only the second step to be ready after the first completed, got %v", ready)
+	}
+
+	branched := JobContainer{
+		Id:    containerId,
+		Steps: []JobStep{analysis, thumb, transcode},
+		Dependencies: map[uuid.UUID][]uuid.UUID{
+			analysis.JobStepId:  {},
+			thumb.JobStepId:     {analysis.JobStepId},
+			transcode.JobStepId: {analysis.JobStepId},
+		},
+		Status: JOB_STARTED,
+	}
+	ready = branched.ReadySteps()
+	if len(ready) != 1 || ready[0].StepId() != analysis.JobStepId {
+		t.Errorf("expected only the analysis to be ready, got %v", ready)
+	}
+
+	completeErr := branched.CompleteStepById(analysis.JobStepId)
+	if completeErr != nil {
+		t.Fatalf("CompleteStepById failed unexpectedly: %s", completeErr)
+	}
+	ready = branched.ReadySteps()
+	if len(ready) != 2 || ready[0].StepId() != thumb.JobStepId || ready[1].StepId() != transcode.JobStepId {
+		t.Errorf("expected both branches to be ready, got %v", ready)
+	}
+
+	//the receiver for a step's results marks it as completed before the runner gets to it, it must still be counted
+	branched.UpdateStepById(transcode.JobStepId, transcode.WithNewStatus(JOB_COMPLETED, nil))
+	branched.CompleteStepById(transcode.JobStepId)
+	if branched.CompletedSteps != 2 {
+		t.Errorf("expected 2 completed steps, got %d", branched.CompletedSteps)
+	}
+	if branched.Status == JOB_COMPLETED {
+		t.Error("job should not complete while a branch is still outstanding")
+	}
+	branched.CompleteStepById(thumb.JobStepId)
+	if branched.Status != JOB_COMPLETED || branched.CompletedSteps != 3 || branched.EndTime == nil {
+		t.Errorf("expected job to be completed once every branch has finished, got status %d with %d steps", branched.Status, branched.CompletedSteps)
+	}
+
+	if branched.CompleteStepById(uuid.New()) == nil {
+		t.Error("CompleteStepById should fail for a step that is not in the job")
+	}
+}
+
+/**
+ReplaceRenditions should drop the renditions that the step made before and keep those from other steps
+*/
+func TestJobContainer_ReplaceRenditions(t *testing.T) {
+	otherStep := RenditionOutput{Name: "other", FileId: uuid.New()}
+	firstAttempt := []RenditionOutput{
+		{Name: "720p", FileId: uuid.New()},
+		{Name: "360p", FileId: uuid.New()},
+	}
+	container := JobContainer{Renditions: append([]RenditionOutput{otherStep}, firstAttempt...)}
+
+	retry := []RenditionOutput{
+		{Name: "720p", FileId: uuid.New()},
+		{Name: "360p", FileId: uuid.New()},
+	}
+	container.ReplaceRenditions(firstAttempt, retry)
+
+	expected := []RenditionOutput{otherStep, retry[0], retry[1]}
+	if !reflect.DeepEqual(container.Renditions, expected) {
+		t.Errorf("Got wrong renditions after a retry: %s", spew.Sdump(container.Renditions))
+	}
+
+	empty := JobContainer{}
+	empty.ReplaceRenditions(nil, firstAttempt)
+	if !reflect.DeepEqual(empty.Renditions, firstAttempt) {
+		t.Errorf("Got wrong renditions for the first attempt: %s", spew.Sdump(empty.Renditions))
+	}
+}
+
+/**
+JobStatusToString should give back the name that JobStatusFromString understands
+*/
+func TestJobStatusToString(t *testing.T) {
+	for _, status := range ALL_JOB_STATUS {
+		name := JobStatusToString(status)
+		if JobStatusFromString(name) != status {
+			t.Errorf("status %d became '%s', which was read back as %d", status, name, JobStatusFromString(name))
+		}
+	}
+}
@@ -16,9 +16,10 @@ type QueueName string
 const (
 	REQUEST_QUEUE QueueName = "jobrequestqueue"
 	RUNNING_QUEUE QueueName = "jobrunningqueue"
+	RETRY_QUEUE   QueueName = "jobretryqueue" //jobs waiting for a failed step to be retried, scored by when the retry is due
 )
 
-var ALL_QUEUES = []QueueName{REQUEST_QUEUE, RUNNING_QUEUE}
+var ALL_QUEUES = []QueueName{REQUEST_QUEUE, RUNNING_QUEUE, RETRY_QUEUE}
 
 /** -----------------
 queue entry data
@@ -63,9 +64,22 @@ func UnmarshalJobQueueEntry(from string) (JobQueueEntry, error) {
 queue manipulation
 ----------------
 */
-func GetQueueLength(client redis.Cmdable, queueName QueueName) (int64, error) {
+
+/**
+the request and retry queues are sorted sets (ordered by priority and due time) while the running queue is a plain list.
+returns the command to get the length of the given queue, which could be from a pipeline
+*/
+func queueLengthCmd(client redis.Cmdable, queueName QueueName) *redis.IntCmd {
 	jobKey := fmt.Sprintf("mediaflipper:%s", queueName)
-	result := client.LLen(jobKey)
+	if queueName == REQUEST_QUEUE || queueName == RETRY_QUEUE {
+		return client.ZCard(jobKey)
+	} else {
+		return client.LLen(jobKey)
+	}
+}
+
+func GetQueueLength(client redis.Cmdable, queueName QueueName) (int64, error) {
+	result := queueLengthCmd(client, queueName)
 
 	count, err := result.Result()
 	if err != nil {
@@ -79,8 +93,7 @@ func AllQueuesLength(client redis.Cmdable) (map[QueueName]int64, error) {
 	defer pipe.Close()
 
 	for _, qName := range ALL_QUEUES {
-		dbKey := fmt.Sprintf("mediaflipper:%s", qName)
-		pipe.LLen(dbKey)
+		queueLengthCmd(pipe, qName)
 	}
 
 	results, _ := pipe.Exec()
@@ -290,15 +290,10 @@ func TestAllQueuesLength(t *testing.T) {
 	s.Lpush(runningKey, "a")
 	s.Lpush(runningKey, "a")
 
-	waitingKey := fmt.Sprintf("mediaflipper:%s", REQUEST_QUEUE)
-	s.Lpush(waitingKey, "a")
-	s.Lpush(waitingKey, "a")
-	s.Lpush(waitingKey, "a")
-	s.Lpush(waitingKey, "a")
-	s.Lpush(waitingKey, "a")
-	s.Lpush(waitingKey, "a")
-	s.Lpush(waitingKey, "a")
-	s.Lpush(waitingKey, "a")
+	waitingKey := fmt.Sprintf("mediaflipper:%s", REQUEST_QUEUE) //the request queue is a sorted set, so members must be unique
+	for i := 0; i < 8; i++ {
+		s.ZAdd(waitingKey, float64(i), fmt.Sprintf("a%d", i))
+	}
 
 	result, reqErr := AllQueuesLength(testClient)
 	if reqErr != nil {
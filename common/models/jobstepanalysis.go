@@ -20,6 +20,10 @@ type JobStepAnalysis struct {
 	StartTime              *time.Time           `json:"startTime" mapstructure:"startTime"`
 	EndTime                *time.Time           `json:"endTime" mapstructure:"startTime"`
 	ItemType               helpers.BulkItemType `json:"itemType"`
+	Retry                  *RetryPolicy         `json:"retryPolicy" mapstructure:"retryPolicy"`
+	AttemptHistory         []StepAttempt        `json:"attempts" mapstructure:"attempts"`
+	Conditions             *StepConditions      `json:"conditions" mapstructure:"conditions"`
+	OriginalFileId         *uuid.UUID           `json:"originalFile" mapstructure:"originalFile"` //records the checksums of the media that was analysed
 }
 
 func JobStepAnalysisFromMap(mapData map[string]interface{}) (*JobStepAnalysis, error) {
@@ -28,18 +32,25 @@ func JobStepAnalysisFromMap(mapData map[string]interface{}) (*JobStepAnalysis, e
 	return &rtn, err
 }
 
+/**
+removes the analysis results. The entry for the original media is removed too, but the media itself is never deleted
+*/
 func (j JobStepAnalysis) DeleteAssociatedItems(redisClient redis.Cmdable) []error {
+	errorList := make([]error, 0)
 	blankId := uuid.UUID{}
 	if j.ResultId != blankId {
 		removeErr := RemoveFileFormat(j.ResultId, redisClient)
 		if removeErr != nil {
-			return []error{removeErr}
-		} else {
-			return []error{}
+			errorList = append(errorList, removeErr)
 		}
-	} else {
-		return []error{}
 	}
+	if j.OriginalFileId != nil {
+		removeErr := RemoveFileEntry(*j.OriginalFileId, redisClient)
+		if removeErr != nil {
+			errorList = append(errorList, removeErr)
+		}
+	}
+	return errorList
 }
 
 func (j JobStepAnalysis) StepId() uuid.UUID {
@@ -99,3 +110,25 @@ func (j JobStepAnalysis) WithNewMediaFile(newMediaFile string) JobStep {
 	j.MediaFile = newMediaFile
 	return j
 }
+
+func (j JobStepAnalysis) RetryPolicy() *RetryPolicy {
+	return j.Retry
+}
+
+func (j JobStepAnalysis) Attempts() []StepAttempt {
+	return j.AttemptHistory
+}
+
+func (j JobStepAnalysis) WithAttemptRecorded(attempt StepAttempt) JobStep {
+	j.AttemptHistory = append(append([]StepAttempt{}, j.AttemptHistory...), attempt)
+	return j
+}
+
+func (j JobStepAnalysis) WithAttemptHistory(history []StepAttempt) JobStep {
+	j.AttemptHistory = history
+	return j
+}
+
+func (j JobStepAnalysis) StepConditions() *StepConditions {
+	return j.Conditions
+}
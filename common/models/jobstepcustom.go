@@ -19,6 +19,9 @@ type JobStepCustom struct {
 	KubernetesTemplateFile string               `json:"templateFile" mapstructure:"templateFile"`
 	ItemType               helpers.BulkItemType `json:"itemType"`
 	CustomArguments        map[string]string    `json:"customArguments"`
+	Retry                  *RetryPolicy         `json:"retryPolicy" mapstructure:"retryPolicy"`
+	AttemptHistory         []StepAttempt        `json:"attempts" mapstructure:"attempts"`
+	Conditions             *StepConditions      `json:"conditions" mapstructure:"conditions"`
 }
 
 func JobStepCustomFromMap(mapData map[string]interface{}) (*JobStepCustom, error) {
@@ -73,3 +76,25 @@ func (j JobStepCustom) WithNewMediaFile(newMediaFile string) JobStep {
 	j.MediaFile = newMediaFile
 	return j
 }
+
+func (j JobStepCustom) RetryPolicy() *RetryPolicy {
+	return j.Retry
+}
+
+func (j JobStepCustom) Attempts() []StepAttempt {
+	return j.AttemptHistory
+}
+
+func (j JobStepCustom) WithAttemptRecorded(attempt StepAttempt) JobStep {
+	j.AttemptHistory = append(append([]StepAttempt{}, j.AttemptHistory...), attempt)
+	return j
+}
+
+func (j JobStepCustom) WithAttemptHistory(history []StepAttempt) JobStep {
+	j.AttemptHistory = history
+	return j
+}
+
+func (j JobStepCustom) StepConditions() *StepConditions {
+	return j.Conditions
+}
@@ -0,0 +1,260 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+/**
+returns true if the step has finished successfully, i.e. the steps that depend on it can go ahead
+*/
+func stepIsFinished(step JobStep) bool {
+	return step.Status() == JOB_COMPLETED || step.Status() == JOB_SKIPPED
+}
+
+/**
+returns true if the job has branches that can run at the same time, rather than a simple list of steps
+*/
+func (c *JobContainer) HasBranches() bool {
+	return len(c.Dependencies) > 0
+}
+
+/**
+returns the ids of the steps that must finish before the step at the given index can start.
+if the job has no dependency information then each step depends on the one before it
+*/
+func (c *JobContainer) dependenciesFor(idx int) []uuid.UUID {
+	if !c.HasBranches() {
+		if idx == 0 {
+			return nil
+		}
+		return []uuid.UUID{c.Steps[idx-1].StepId()}
+	}
+	return c.Dependencies[c.Steps[idx].StepId()]
+}
+
+/**
+returns every step that has the given status
+*/
+func (c *JobContainer) StepsWithStatus(status JobStatus) []JobStep {
+	rtn := make([]JobStep, 0)
+	for _, step := range c.Steps {
+		if step.Status() == status {
+			rtn = append(rtn, step)
+		}
+	}
+	return rtn
+}
+
+/**
+returns the steps that have not been started yet but whose dependencies have all finished, i.e. that can be run now.
+for a job without branches this is just the current step.
+*/
+func (c *JobContainer) ReadySteps() []JobStep {
+	rtn := make([]JobStep, 0)
+	for idx, step := range c.Steps {
+		if step.Status() != JOB_PENDING {
+			continue
+		}
+		ready := true
+		for _, depId := range c.dependenciesFor(idx) {
+			dep := c.FindStepById(depId)
+			if dep == nil || !stepIsFinished(*dep) {
+				ready = false
+				break
+			}
+		}
+		if ready {
+			rtn = append(rtn, step)
+		}
+	}
+	return rtn
+}
+
+/**
+returns the number of steps that have finished successfully
+*/
+func (c *JobContainer) countFinishedSteps() int {
+	count := 0
+	for _, step := range c.Steps {
+		if stepIsFinished(step) {
+			count += 1
+		}
+	}
+	return count
+}
+
+/**
+marks the step with the given id as completed. if every step has now finished then the job is marked as completed too.
+the step may already have been marked as completed when its results were received, so the count of completed steps is
+worked out again rather than just incremented.
+if the results failed the job, e.g. a QC step whose limits were exceeded, then the step and the job are left as failed.
+call SkipUnmetSteps afterwards to find out what should run next.
+*/
+func (c *JobContainer) CompleteStepById(stepId uuid.UUID) error {
+	for i, step := range c.Steps {
+		if step.StepId() != stepId {
+			continue
+		}
+		if c.Status == JOB_FAILED && step.Status() == JOB_FAILED {
+			log.Printf("step %s of %s failed when its results were received, not completing it", stepId, c.Id)
+			return nil
+		}
+		c.Steps[i] = step.WithNewStatus(JOB_COMPLETED, nil)
+		c.CompletedSteps = c.countFinishedSteps()
+		log.Printf("completed step %d / %d of %s", c.CompletedSteps, len(c.Steps), c.Id)
+		if c.CompletedSteps >= len(c.Steps) {
+			c.Status = JOB_COMPLETED
+			nowTime := time.Now()
+			c.EndTime = &nowTime
+		}
+		return nil
+	}
+	return errors.New(fmt.Sprintf("job %s does not have a step with id %s", c.Id, stepId))
+}
+
+/**
+marks the job as failed because of the step with the given id. steps on other branches are not touched, the caller
+is responsible for stopping them
+*/
+func (c *JobContainer) FailStepById(stepId uuid.UUID, msg string) {
+	c.Status = JOB_FAILED
+	nowTime := time.Now()
+	c.EndTime = &nowTime
+	c.ErrorMessage = msg
+	failedStep := c.FindStepById(stepId)
+	if failedStep == nil {
+		log.Printf("ERROR JobContainer.FailStepById job %s does not have a step with id %s", c.Id, stepId)
+		return
+	}
+	c.UpdateStepById(stepId, (*failedStep).WithNewStatus(JOB_FAILED, &msg))
+}
+
+/**
+checks the conditions on the steps that are ready to run against the analysis results of the earlier steps. Steps whose
+conditions are not met are marked as skipped and counted as done, which may in turn make other steps ready.
+returns the steps that should be run now. If every step has finished then the job is marked as completed and nil is returned;
+if the list is empty but the job has not completed then other branches are still running.
+if the conditions on a step can't be evaluated, e.g. because there was no analysis step, then the step is run.
+*/
+func (c *JobContainer) SkipUnmetSteps(redisClient redis.Cmdable) []JobStep {
+	var analysis *FileFormatInfo
+	haveLookedUp := false
+
+	var toRun []JobStep
+	for {
+		toRun = make([]JobStep, 0)
+		skippedAny := false
+		for _, step := range c.ReadySteps() {
+			conditions := step.StepConditions()
+			if conditions.IsEmpty() {
+				toRun = append(toRun, step)
+				continue
+			}
+
+			if !haveLookedUp { //skipped steps don't produce any results, so we only need to do this once
+				formatInfo, getErr := c.LatestAnalysisResult(redisClient)
+				if getErr != nil {
+					log.Printf("WARNING JobContainer.SkipUnmetSteps could not get analysis results for %s: %s", c.Id, getErr)
+				} else {
+					analysis = formatInfo
+				}
+				haveLookedUp = true
+			}
+
+			shouldRun, reason, evalErr := conditions.ShouldRun(analysis)
+			if evalErr != nil {
+				log.Printf("WARNING JobContainer.SkipUnmetSteps could not evaluate conditions for step %s of %s, running it anyway: %s", step.StepId(), c.Id, evalErr)
+				toRun = append(toRun, step)
+			} else if shouldRun {
+				toRun = append(toRun, step)
+			} else {
+				log.Printf("INFO JobContainer.SkipUnmetSteps job %s step %s: %s", c.Id, step.StepId(), reason)
+				c.UpdateStepById(step.StepId(), step.WithNewStatus(JOB_SKIPPED, &reason))
+				c.CompletedSteps = c.countFinishedSteps()
+				skippedAny = true
+			}
+		}
+		if !skippedAny { //nothing new can have become ready
+			break
+		}
+	}
+
+	if c.CompletedSteps >= len(c.Steps) {
+		c.Status = JOB_COMPLETED
+		nowTime := time.Now()
+		c.EndTime = &nowTime
+		return nil
+	}
+	return toRun
+}
+
+/**
+looks up the results of the most recent analysis step that has completed.
+returns nil, nil if there isn't one
+*/
+func (c *JobContainer) LatestAnalysisResult(redisClient redis.Cmdable) (*FileFormatInfo, error) {
+	blankId := uuid.UUID{}
+	for i := len(c.Steps) - 1; i >= 0; i-- {
+		if c.Steps[i].Status() != JOB_COMPLETED {
+			continue
+		}
+		var resultId uuid.UUID
+		switch analysisStep := c.Steps[i].(type) {
+		case *JobStepAnalysis:
+			resultId = analysisStep.ResultId
+		case JobStepAnalysis:
+			resultId = analysisStep.ResultId
+		default:
+			continue
+		}
+		if resultId != blankId {
+			return GetFileFormat(resultId, redisClient)
+		}
+	}
+	return nil, nil
+}
+
+/**
+decode the dependency map from a job container's raw json data. returns nil if there is none.
+*/
+func dependenciesFromMap(raw interface{}, forId string) map[uuid.UUID][]uuid.UUID {
+	rawMap, isMap := raw.(map[string]interface{})
+	if !isMap || len(rawMap) == 0 {
+		return nil
+	}
+
+	rtn := make(map[uuid.UUID][]uuid.UUID, len(rawMap))
+	for stepIdStr, rawDeps := range rawMap {
+		stepId, parseErr := uuid.Parse(stepIdStr)
+		if parseErr != nil {
+			log.Printf("ERROR: job %s has a dependency entry for invalid step id %s: %s", forId, stepIdStr, parseErr)
+			continue
+		}
+		depList, isList := rawDeps.([]interface{})
+		if !isList {
+			rtn[stepId] = nil
+			continue
+		}
+		deps := make([]uuid.UUID, 0, len(depList))
+		for _, rawDep := range depList {
+			depStr, isStr := rawDep.(string)
+			if !isStr {
+				log.Printf("ERROR: job %s has a dependency for step %s that is not a string", forId, stepIdStr)
+				continue
+			}
+			depId, depErr := uuid.Parse(depStr)
+			if depErr != nil {
+				log.Printf("ERROR: job %s has an invalid dependency %s for step %s: %s", forId, depStr, stepIdStr, depErr)
+				continue
+			}
+			deps = append(deps, depId)
+		}
+		rtn[stepId] = deps
+	}
+	return rtn
+}
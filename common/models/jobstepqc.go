@@ -0,0 +1,124 @@
+package models
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"time"
+)
+
+type JobStepQC struct {
+	JobStepType            string               `json:"stepType" mapstructure:"stepType"`
+	JobStepId              uuid.UUID            `json:"id" mapstructure:"id"`
+	JobContainerId         uuid.UUID            `json:"jobContainerId" mapstructure:"jobContainerId"`
+	ContainerData          *JobRunnerDesc       `json:"containerData" mapstructure:"containerData"`
+	StatusValue            JobStatus            `json:"jobStepStatus" mapstructure:"jobStepStatus"`
+	QCSettings             *QCSettings          `json:"qcSettings" mapstructure:"qcSettings"`
+	ReportId               *uuid.UUID           `json:"qcReport" mapstructure:"qcReport"`
+	Outcome                string               `json:"qcOutcome" mapstructure:"qcOutcome"` //one of the QC_OUTCOME values, once the report has been received
+	LastError              string               `json:"errorMessage" mapstructure:"errorMessage"`
+	MediaFile              string               `json:"mediaFile" mapstructure:"mediaFile"`
+	KubernetesTemplateFile string               `json:"templateFile" mapstructure:"templateFile"`
+	StartTime              *time.Time           `json:"startTime" mapstructure:"startTime"`
+	EndTime                *time.Time           `json:"endTime" mapstructure:"endTime"`
+	ItemType               helpers.BulkItemType `json:"itemType"`
+	Retry                  *RetryPolicy         `json:"retryPolicy" mapstructure:"retryPolicy"`
+	AttemptHistory         []StepAttempt        `json:"attempts" mapstructure:"attempts"`
+	Conditions             *StepConditions      `json:"conditions" mapstructure:"conditions"`
+}
+
+func JobStepQCFromMap(mapData map[string]interface{}) (*JobStepQC, error) {
+	var rtn JobStepQC
+	err := CustomisedMapStructureDecode(mapData, &rtn)
+	return &rtn, err
+}
+
+func (j JobStepQC) DeleteAssociatedItems(redisClient redis.Cmdable) []error {
+	if j.ReportId != nil {
+		removeErr := RemoveQCReport(*j.ReportId, redisClient)
+		if removeErr != nil {
+			return []error{removeErr}
+		}
+	}
+	return []error{}
+}
+
+func (j JobStepQC) StepId() uuid.UUID {
+	return j.JobStepId
+}
+
+func (j JobStepQC) ContainerId() uuid.UUID {
+	return j.JobContainerId
+}
+
+func (j JobStepQC) Status() JobStatus {
+	return j.StatusValue
+}
+
+func (j JobStepQC) OutputId() *uuid.UUID {
+	return j.ReportId
+}
+
+func (j JobStepQC) OutputData() interface{} {
+	return j.ReportId
+}
+
+func (j JobStepQC) RunnerDesc() *JobRunnerDesc {
+	return j.ContainerData
+}
+
+func (j JobStepQC) TimeTaken() float64 {
+	return -1
+}
+
+func (j JobStepQC) ErrorMessage() string {
+	return j.LastError
+}
+
+func (j JobStepQC) WithNewStatus(newStatus JobStatus, errMsg *string) JobStep {
+	j.StatusValue = newStatus
+	if errMsg != nil {
+		j.LastError = *errMsg
+	}
+	nowTime := time.Now()
+	switch j.StatusValue {
+	case JOB_STARTED:
+		j.StartTime = &nowTime
+		break
+	case JOB_FAILED:
+		fallthrough
+	case JOB_COMPLETED:
+		j.EndTime = &nowTime
+		break
+	default:
+		break
+	}
+	return j
+}
+
+func (j JobStepQC) WithNewMediaFile(newMediaFile string) JobStep {
+	j.MediaFile = newMediaFile
+	return j
+}
+
+func (j JobStepQC) RetryPolicy() *RetryPolicy {
+	return j.Retry
+}
+
+func (j JobStepQC) Attempts() []StepAttempt {
+	return j.AttemptHistory
+}
+
+func (j JobStepQC) WithAttemptRecorded(attempt StepAttempt) JobStep {
+	j.AttemptHistory = append(append([]StepAttempt{}, j.AttemptHistory...), attempt)
+	return j
+}
+
+func (j JobStepQC) WithAttemptHistory(history []StepAttempt) JobStep {
+	j.AttemptHistory = history
+	return j
+}
+
+func (j JobStepQC) StepConditions() *StepConditions {
+	return j.Conditions
+}
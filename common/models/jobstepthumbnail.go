@@ -8,10 +8,22 @@ import (
 	"time"
 )
 
+const (
+	THUMBNAIL_MODE_FIXED = "fixed" //take the frame at ThumbnailFrameSeconds
+	THUMBNAIL_MODE_SMART = "smart" //look for the most representative frame, using the fixed offset if none can be found
+)
+
 type ThumbnailResult struct {
-	OutPath      *string `json:"outPath" mapstructure:"outPath"`
-	ErrorMessage *string `json:"errorMessage" mapstructure:"errorMessage"`
-	TimeTaken    float64 `json:"timeTaken" mapstructure:"timeTaken"`
+	OutPath        *string       `json:"outPath" mapstructure:"outPath"`
+	ErrorMessage   *string       `json:"errorMessage" mapstructure:"errorMessage"`
+	TimeTaken      float64       `json:"timeTaken" mapstructure:"timeTaken"`
+	SpriteSheets   []string      `json:"spriteSheets" mapstructure:"spriteSheets"`     //only set for sprite sheet thumbnails, in which case OutPath is nil
+	SpriteIndex    *string       `json:"spriteIndex" mapstructure:"spriteIndex"`       //the WebVTT index for the sprite sheets
+	FrameSeconds   *float64      `json:"frameSeconds" mapstructure:"frameSeconds"`     //the time the thumbnail was taken from, for video thumbnails
+	FrameTimecode  string        `json:"frameTimecode" mapstructure:"frameTimecode"`   //the same time as HH:MM:SS.mmm
+	FrameSelection string        `json:"frameSelection" mapstructure:"frameSelection"` //how the frame was chosen, one of the THUMBNAIL_MODE values
+	Checksums      FileChecksums `json:"checksums" mapstructure:"checksums"`           //for each file that was written
+	Provenance     *Provenance   `json:"provenance" mapstructure:"provenance"`
 }
 
 type JobStepThumbnail struct {
@@ -23,6 +35,7 @@ type JobStepThumbnail struct {
 	LastError              string                `json:"errorMessage" mapstructure:"errorMessage"`
 	MediaFile              string                `json:"mediaFile" mapstructure:"mediaFile"`
 	ThumbnailFrameSeconds  float64               `json:"thumbnailFrameSeconds" mapstructure:"thumbnailFrameSeconds"`
+	ThumbnailMode          string                `json:"thumbnailMode" mapstructure:"thumbnailMode"` //one of the THUMBNAIL_MODE values, empty means fixed
 	ResultId               *uuid.UUID            `json:"thumbnailResult" mapstructure:"thumbnailResult"`
 	TimeTakenValue         float64               `json:"timeTaken" mapstructure:"timeTaken"`
 	KubernetesTemplateFile string                `json:"templateFile" mapstructure:"templateFile"`
@@ -30,6 +43,16 @@ type JobStepThumbnail struct {
 	StartTime              *time.Time            `json:"startTime" mapstructure:"startTime"`
 	EndTime                *time.Time            `json:"endTime" mapstructure:"endTime"`
 	ItemType               helpers.BulkItemType  `json:"itemType"`
+	Retry                  *RetryPolicy          `json:"retryPolicy" mapstructure:"retryPolicy"`
+	AttemptHistory         []StepAttempt         `json:"attempts" mapstructure:"attempts"`
+	Conditions             *StepConditions       `json:"conditions" mapstructure:"conditions"`
+	Sprites                *SpriteSheetSettings  `json:"sprites" mapstructure:"sprites"` //if set, sprite sheets are made instead of a single thumbnail
+	SpriteSheetIds         []uuid.UUID           `json:"spriteSheets" mapstructure:"spriteSheets"`
+	SpriteIndexId          *uuid.UUID            `json:"spriteIndex" mapstructure:"spriteIndex"`
+	ChosenFrameSeconds     *float64              `json:"chosenFrameSeconds" mapstructure:"chosenFrameSeconds"` //the time the thumbnail was actually taken from
+	ChosenFrameTimecode    string                `json:"chosenFrameTimecode" mapstructure:"chosenFrameTimecode"`
+	FrameSelection         string                `json:"frameSelection" mapstructure:"frameSelection"` //how the frame was chosen. "fixed" in smart mode means that no suitable frame was found
+	Provenance             *Provenance           `json:"provenance" mapstructure:"provenance"`         //how the output of the last attempt was made
 }
 
 func JobStepThumbnailFromMap(mapData map[string]interface{}) (*JobStepThumbnail, error) {
@@ -71,7 +94,27 @@ func (j JobStepThumbnail) DeleteAssociatedItems(redisClient redis.Cmdable) []err
 			}
 		}
 	}
-	return []error{}
+
+	errorList := make([]error, 0)
+	//the sheets are removed from disk along with the index's directory, so only their records need removing here
+	for _, sheetId := range j.SpriteSheetIds {
+		removeErr := RemoveFileEntry(sheetId, redisClient)
+		if removeErr != nil {
+			errorList = append(errorList, removeErr)
+		}
+	}
+	if j.SpriteIndexId != nil {
+		indexEntry, getErr := FileEntryForId(*j.SpriteIndexId, redisClient)
+		if getErr != nil {
+			log.Printf("ERROR: Could not retrieve sprite index associated with thumbnail step %s: %s", j.JobStepId, getErr)
+		} else {
+			removeErr := indexEntry.Delete(true, redisClient)
+			if removeErr != nil {
+				errorList = append(errorList, removeErr)
+			}
+		}
+	}
+	return errorList
 }
 
 func (j JobStepThumbnail) StepId() uuid.UUID {
@@ -131,3 +174,25 @@ func (j JobStepThumbnail) WithNewMediaFile(newMediaFile string) JobStep {
 func (j JobStepThumbnail) ContainerId() uuid.UUID {
 	return j.JobContainerId
 }
+
+func (j JobStepThumbnail) RetryPolicy() *RetryPolicy {
+	return j.Retry
+}
+
+func (j JobStepThumbnail) Attempts() []StepAttempt {
+	return j.AttemptHistory
+}
+
+func (j JobStepThumbnail) WithAttemptRecorded(attempt StepAttempt) JobStep {
+	j.AttemptHistory = append(append([]StepAttempt{}, j.AttemptHistory...), attempt)
+	return j
+}
+
+func (j JobStepThumbnail) WithAttemptHistory(history []StepAttempt) JobStep {
+	j.AttemptHistory = history
+	return j
+}
+
+func (j JobStepThumbnail) StepConditions() *StepConditions {
+	return j.Conditions
+}
@@ -9,20 +9,36 @@ import (
 )
 
 type JobStepTranscode struct {
-	JobStepType            string                `json:"stepType" mapstructure:"stepType"` //this field is vital so we can correctly unmarshal json data from the store
-	JobStepId              uuid.UUID             `json:"id" mapstructure:"id"`
-	JobContainerId         uuid.UUID             `json:"jobContainerId" mapstructure:"jobContainerId"`
-	ContainerData          *JobRunnerDesc        `json:"containerData" mapstructure:"containerData"`
-	StatusValue            JobStatus             `json:"jobStepStatus" mapstructure:"jobStepStatus"`
-	LastError              string                `json:"errorMessage" mapstructure:"errorMessage"`
-	MediaFile              string                `json:"mediaFile" mapstructure:"mediaFile"`
-	ResultId               *uuid.UUID            `json:"transcodeResult" mapstructure:"transcodeResult"`
-	TimeTakenValue         float64               `json:"timeTaken" mapstructure:"timeTaken"`
-	KubernetesTemplateFile string                `json:"templateFile" mapstructure:"templateFile"`
-	StartTime              *time.Time            `json:"startTime" mapstructure:"startTime"`
-	EndTime                *time.Time            `json:"endTime" mapstructure:"startTime"`
-	TranscodeSettings      TranscodeTypeSettings `json:"transcodeSettings" mapstructure:"transcodeSettings"`
-	ItemType               helpers.BulkItemType  `json:"itemType"`
+	JobStepType            string                 `json:"stepType" mapstructure:"stepType"` //this field is vital so we can correctly unmarshal json data from the store
+	JobStepId              uuid.UUID              `json:"id" mapstructure:"id"`
+	JobContainerId         uuid.UUID              `json:"jobContainerId" mapstructure:"jobContainerId"`
+	ContainerData          *JobRunnerDesc         `json:"containerData" mapstructure:"containerData"`
+	StatusValue            JobStatus              `json:"jobStepStatus" mapstructure:"jobStepStatus"`
+	LastError              string                 `json:"errorMessage" mapstructure:"errorMessage"`
+	MediaFile              string                 `json:"mediaFile" mapstructure:"mediaFile"`
+	ResultId               *uuid.UUID             `json:"transcodeResult" mapstructure:"transcodeResult"`
+	TimeTakenValue         float64                `json:"timeTaken" mapstructure:"timeTaken"`
+	KubernetesTemplateFile string                 `json:"templateFile" mapstructure:"templateFile"`
+	StartTime              *time.Time             `json:"startTime" mapstructure:"startTime"`
+	EndTime                *time.Time             `json:"endTime" mapstructure:"startTime"`
+	TranscodeSettings      TranscodeTypeSettings  `json:"transcodeSettings" mapstructure:"transcodeSettings"`
+	ItemType               helpers.BulkItemType   `json:"itemType"`
+	Retry                  *RetryPolicy           `json:"retryPolicy" mapstructure:"retryPolicy"`
+	AttemptHistory         []StepAttempt          `json:"attempts" mapstructure:"attempts"`
+	Conditions             *StepConditions        `json:"conditions" mapstructure:"conditions"`
+	RenditionResults       []RenditionOutput      `json:"renditionResults" mapstructure:"renditionResults"` //set instead of ResultId if the settings had renditions
+	PackageIds             []uuid.UUID            `json:"packageResults" mapstructure:"packageResults"`     //set instead of ResultId if the settings had packaging
+	QualitySettings        *QualityMetricSettings `json:"qualitySettings" mapstructure:"qualitySettings"`   //if set, the output is compared with the source once the transcode has finished
+	Quality                *QualityMetrics        `json:"quality" mapstructure:"quality"`
+	Provenance             *Provenance            `json:"provenance" mapstructure:"provenance"` //how the output of the last attempt was made
+}
+
+/**
+identifies the file that was made for one rendition of a multi-rendition transcode
+*/
+type RenditionOutput struct {
+	Name   string    `json:"name" mapstructure:"name"`
+	FileId uuid.UUID `json:"fileId" mapstructure:"fileId"`
 }
 
 func (j JobStepTranscode) DeleteAssociatedItems(redisClient redis.Cmdable) []error {
@@ -37,7 +53,31 @@ func (j JobStepTranscode) DeleteAssociatedItems(redisClient redis.Cmdable) []err
 			}
 		}
 	}
-	return []error{}
+
+	errorList := make([]error, 0)
+	for _, packageId := range j.PackageIds {
+		fileEntry, getErr := FileEntryForId(packageId, redisClient)
+		if getErr != nil {
+			log.Printf("ERROR: Could not retrieve file entry for package %s of transcode step %s: %s", packageId, j.JobStepId, getErr)
+			continue
+		}
+		removeErr := fileEntry.Delete(true, redisClient)
+		if removeErr != nil {
+			errorList = append(errorList, removeErr)
+		}
+	}
+	for _, rendition := range j.RenditionResults {
+		fileEntry, getErr := FileEntryForId(rendition.FileId, redisClient)
+		if getErr != nil {
+			log.Printf("ERROR: Could not retrieve file entry for rendition %s of transcode step %s: %s", rendition.Name, j.JobStepId, getErr)
+			continue
+		}
+		removeErr := fileEntry.Delete(true, redisClient)
+		if removeErr != nil {
+			errorList = append(errorList, removeErr)
+		}
+	}
+	return errorList
 }
 
 func (j JobStepTranscode) StepId() uuid.UUID {
@@ -113,6 +153,35 @@ func JobStepTranscodeFromMap(mapData map[string]interface{}) (*JobStepTranscode,
 		return &rtn, nil
 	}
 
+	var transcodeSettingsAudio TranscodeAudioSettings
+	audioErr := CustomisedMapStructureDecode(transcodeSettingsRaw, &transcodeSettingsAudio)
+	if audioErr == nil && transcodeSettingsAudio.IsValid() {
+		rtn.TranscodeSettings = transcodeSettingsAudio
+		return &rtn, nil
+	}
+
 	log.Printf("WARNING: transcode step %s from job %s has unrecognised settings", rtn.JobStepId, rtn.JobContainerId)
 	return &rtn, nil
 }
+
+func (j JobStepTranscode) RetryPolicy() *RetryPolicy {
+	return j.Retry
+}
+
+func (j JobStepTranscode) Attempts() []StepAttempt {
+	return j.AttemptHistory
+}
+
+func (j JobStepTranscode) WithAttemptRecorded(attempt StepAttempt) JobStep {
+	j.AttemptHistory = append(append([]StepAttempt{}, j.AttemptHistory...), attempt)
+	return j
+}
+
+func (j JobStepTranscode) WithAttemptHistory(history []StepAttempt) JobStep {
+	j.AttemptHistory = history
+	return j
+}
+
+func (j JobStepTranscode) StepConditions() *StepConditions {
+	return j.Conditions
+}
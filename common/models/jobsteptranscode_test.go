@@ -144,3 +144,93 @@ func TestJobStepTranscodeFromMapImage(t *testing.T) {
 	}
 
 }
+
+func TestJobStepTranscodeFromMapRenditions(t *testing.T) {
+	//JobStepTranscodeFromMap should decode the ladder settings and the files that were made for each rendition
+	fakeTranscodeSettings := map[string]interface{}{
+		"settingsId": "87230B0F-8E75-474D-B8D0-5C421C9D4E56",
+		"name":       "ladder",
+		"wrapper": map[string]interface{}{
+			"format": "mp4",
+		},
+		"renditions": []interface{}{
+			map[string]interface{}{"name": "720p", "video": map[string]interface{}{"codec": "h264", "bitrate": 3000000.0}},
+			map[string]interface{}{"name": "360p", "video": map[string]interface{}{"codec": "h264", "bitrate": 800000.0}},
+		},
+	}
+	mappedData := map[string]interface{}{
+		"stepType":          "transcode",
+		"id":                "088C9988-4FE3-4BC8-A0D3-55556AB0A922",
+		"jobContainerId":    "E40A69A6-324E-48D9-AC18-6D9BA44E16B5",
+		"jobStepStatus":     2.0,
+		"transcodeSettings": fakeTranscodeSettings,
+		"renditionResults": []interface{}{
+			map[string]interface{}{"name": "720p", "fileId": "2EF99A08-7AF6-45F1-B34E-A60C01FBF5B2"},
+			map[string]interface{}{"name": "360p", "fileId": "B5E3F1E0-4C3C-4E6B-8D63-0A0F7A5D9C11"},
+		},
+	}
+
+	result, err := JobStepTranscodeFromMap(mappedData)
+	if err != nil {
+		t.Error("JobStepTranscodeFromMap failed unexpectedly ", err)
+		t.FailNow()
+	}
+	settings, isAv := result.TranscodeSettings.(JobSettings)
+	if !isAv {
+		t.Errorf("Expected JobSettings, got %s", spew.Sdump(result.TranscodeSettings))
+	} else if len(settings.Renditions) != 2 || settings.Renditions[1].Video.Bitrate != 800000 {
+		t.Errorf("Got wrong renditions: %s", spew.Sdump(settings.Renditions))
+	}
+	if len(result.RenditionResults) != 2 {
+		t.Errorf("Expected 2 rendition results, got %d", len(result.RenditionResults))
+	} else if result.RenditionResults[1].Name != "360p" || result.RenditionResults[1].FileId != uuid.MustParse("B5E3F1E0-4C3C-4E6B-8D63-0A0F7A5D9C11") {
+		t.Errorf("Got wrong rendition result: %s", spew.Sdump(result.RenditionResults[1]))
+	}
+}
+
+func TestJobStepTranscodeFromMapProvenance(t *testing.T) {
+	//JobStepTranscodeFromMap should decode the record of how the output was made
+	mappedData := map[string]interface{}{
+		"stepType":       "transcode",
+		"id":             "088C9988-4FE3-4BC8-A0D3-55556AB0A922",
+		"jobContainerId": "E40A69A6-324E-48D9-AC18-6D9BA44E16B5",
+		"jobStepStatus":  2.0,
+		"provenance": map[string]interface{}{
+			"settingsId":   "87230B0F-8E75-474D-B8D0-5C421C9D4E56",
+			"settings":     map[string]interface{}{"name": "test", "wrapper": map[string]interface{}{"format": "mp4"}},
+			"commandLine":  []interface{}{"/usr/bin/ffmpeg", "-i", "in.mov", "-y", "out.mp4"},
+			"toolVersions": map[string]interface{}{"ffmpeg": "4.3.1", "ffprobe": "4.3.1"},
+			"hostname":     "transcode-abc123",
+			"startTime":    "2020-02-03T04:05:06Z",
+			"endTime":      "2020-02-03T05:06:07Z",
+		},
+	}
+
+	result, err := JobStepTranscodeFromMap(mappedData)
+	if err != nil {
+		t.Error("JobStepTranscodeFromMap failed unexpectedly ", err)
+		t.FailNow()
+	}
+	if result.Provenance == nil {
+		t.Error("Provenance was not decoded")
+		t.FailNow()
+	}
+	if result.Provenance.SettingsId == nil || *result.Provenance.SettingsId != uuid.MustParse("87230B0F-8E75-474D-B8D0-5C421C9D4E56") {
+		t.Errorf("Got wrong settings id: %s", spew.Sdump(result.Provenance.SettingsId))
+	}
+	if result.Provenance.Settings["name"] != "test" {
+		t.Errorf("Got wrong settings snapshot: %s", spew.Sdump(result.Provenance.Settings))
+	}
+	if len(result.Provenance.CommandLine) != 5 || result.Provenance.CommandLine[0] != "/usr/bin/ffmpeg" {
+		t.Errorf("Got wrong command line: %s", spew.Sdump(result.Provenance.CommandLine))
+	}
+	if result.Provenance.ToolVersions["ffmpeg"] != "4.3.1" {
+		t.Errorf("Got wrong tool versions: %s", spew.Sdump(result.Provenance.ToolVersions))
+	}
+	if result.Provenance.Hostname != "transcode-abc123" {
+		t.Errorf("Got wrong hostname %s", result.Provenance.Hostname)
+	}
+	if result.Provenance.EndTime == nil || result.Provenance.EndTime.Hour() != 5 {
+		t.Errorf("Got wrong end time: %s", spew.Sdump(result.Provenance.EndTime))
+	}
+}
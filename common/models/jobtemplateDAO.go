@@ -0,0 +1,148 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const storedTemplatesKey = "mediaflipper:jobtemplate:current"
+
+func keyForTemplateVersions(id uuid.UUID) string {
+	return fmt.Sprintf("mediaflipper:jobtemplate:versions:%s", id.String())
+}
+
+func keyForTemplateVersionCounter(id uuid.UUID) string {
+	return fmt.Sprintf("mediaflipper:jobtemplate:version:%s", id.String())
+}
+
+/**
+save the given template as a new version. The version number and update time are filled in here and the saved template
+is returned. Every version is kept, so that jobs made from an older version can still find it
+*/
+func PutStoredJobTemplate(tpl JobTemplateDefinition, redisClient redis.Cmdable) (*JobTemplateDefinition, error) {
+	//INCR is atomic, so two saves at once can't get the same version
+	version, versionErr := redisClient.Incr(keyForTemplateVersionCounter(tpl.Id)).Result()
+	if versionErr != nil {
+		log.Printf("Could not get a new version number for template %s: %s", tpl.Id, versionErr)
+		return nil, versionErr
+	}
+
+	updatedAt := time.Now()
+	tpl.Version = version
+	tpl.UpdatedAt = &updatedAt
+	encoded, encodeErr := json.Marshal(tpl)
+	if encodeErr != nil {
+		log.Printf("Could not format template %s: %s", tpl.Id, encodeErr)
+		return nil, encodeErr
+	}
+
+	pipe := redisClient.TxPipeline()
+	pipe.HSet(keyForTemplateVersions(tpl.Id), strconv.FormatInt(version, 10), string(encoded))
+	pipe.HSet(storedTemplatesKey, tpl.Id.String(), string(encoded))
+	_, execErr := pipe.Exec()
+	if execErr != nil {
+		log.Printf("Could not save template %s to the datastore: %s", tpl.Id, execErr)
+		return nil, execErr
+	}
+	return &tpl, nil
+}
+
+func decodeStoredTemplate(content string) (*JobTemplateDefinition, error) {
+	var tpl JobTemplateDefinition
+	unmarshalErr := json.Unmarshal([]byte(content), &tpl)
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	return &tpl, nil
+}
+
+/**
+retrieve the current version of the given template. Returns nil, nil if there is no template with that id
+*/
+func GetStoredJobTemplate(templateId uuid.UUID, redisClient redis.Cmdable) (*JobTemplateDefinition, error) {
+	content, getErr := redisClient.HGet(storedTemplatesKey, templateId.String()).Result()
+	if getErr == redis.Nil {
+		return nil, nil
+	} else if getErr != nil {
+		log.Printf("Could not retrieve template %s: %s", templateId, getErr)
+		return nil, getErr
+	}
+
+	tpl, decodeErr := decodeStoredTemplate(content)
+	if decodeErr != nil {
+		log.Printf("Corrupted template in the datastore for %s: %s", templateId, decodeErr)
+	}
+	return tpl, decodeErr
+}
+
+/**
+retrieve a specific version of the given template. Returns nil, nil if there is no such version
+*/
+func GetStoredJobTemplateVersion(templateId uuid.UUID, version int64, redisClient redis.Cmdable) (*JobTemplateDefinition, error) {
+	content, getErr := redisClient.HGet(keyForTemplateVersions(templateId), strconv.FormatInt(version, 10)).Result()
+	if getErr == redis.Nil {
+		return nil, nil
+	} else if getErr != nil {
+		log.Printf("Could not retrieve version %d of template %s: %s", version, templateId, getErr)
+		return nil, getErr
+	}
+
+	tpl, decodeErr := decodeStoredTemplate(content)
+	if decodeErr != nil {
+		log.Printf("Corrupted version %d of template %s in the datastore: %s", version, templateId, decodeErr)
+	}
+	return tpl, decodeErr
+}
+
+/**
+retrieve the current version of every stored template, including retired ones
+*/
+func ListStoredJobTemplates(redisClient redis.Cmdable) ([]JobTemplateDefinition, error) {
+	allContent, getErr := redisClient.HGetAll(storedTemplatesKey).Result()
+	if getErr != nil {
+		log.Printf("Could not list stored templates: %s", getErr)
+		return nil, getErr
+	}
+
+	rtn := make([]JobTemplateDefinition, 0, len(allContent))
+	for idString, content := range allContent {
+		tpl, decodeErr := decodeStoredTemplate(content)
+		if decodeErr != nil {
+			log.Printf("Corrupted template in the datastore for %s: %s", idString, decodeErr)
+			continue
+		}
+		rtn = append(rtn, *tpl)
+	}
+	return rtn, nil
+}
+
+/**
+retrieve every version of the given template, oldest first
+*/
+func StoredJobTemplateHistory(templateId uuid.UUID, redisClient redis.Cmdable) ([]JobTemplateDefinition, error) {
+	allContent, getErr := redisClient.HGetAll(keyForTemplateVersions(templateId)).Result()
+	if getErr != nil {
+		log.Printf("Could not retrieve the history of template %s: %s", templateId, getErr)
+		return nil, getErr
+	}
+
+	rtn := make([]JobTemplateDefinition, 0, len(allContent))
+	for version, content := range allContent {
+		tpl, decodeErr := decodeStoredTemplate(content)
+		if decodeErr != nil {
+			log.Printf("Corrupted version %s of template %s in the datastore: %s", version, templateId, decodeErr)
+			continue
+		}
+		rtn = append(rtn, *tpl)
+	}
+	sort.Slice(rtn, func(i, j int) bool {
+		return rtn[i].Version < rtn[j].Version
+	})
+	return rtn, nil
+}
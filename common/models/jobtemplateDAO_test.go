@@ -0,0 +1,222 @@
+package models
+
+import (
+	"errors"
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"strings"
+	"testing"
+)
+
+func makeTestTemplateManager(t *testing.T, testClient redis.Cmdable) *JobTemplateManager {
+	settingsMgr, settingsLoadErr := NewTranscodeSettingsManager("../../webapp/config/settings")
+	if settingsLoadErr != nil {
+		t.Fatalf("Could not load transcode settings: %s", settingsLoadErr)
+	}
+	mgr, loadErr := NewJobTemplateManager("../../webapp/config/standardjobtemplate.yaml", settingsMgr)
+	if loadErr != nil {
+		t.Fatalf("Could not load templates: %s", loadErr)
+	}
+	mgr.UseDatastore(testClient, func(fileName string) error {
+		if fileName != "config/AnalysisJobTemplate.yaml" {
+			return errors.New("no such file")
+		}
+		return nil
+	})
+	return mgr
+}
+
+func makeTestTemplate() JobTemplateDefinition {
+	analysisStepId := uuid.New()
+	return JobTemplateDefinition{
+		Id:          uuid.New(),
+		JobTypeName: "Test template",
+		Steps: []JobStepTemplateDefinition{
+			{
+				Id:                     analysisStepId,
+				PredeterminedType:      "analysis",
+				KubernetesTemplateFile: "config/AnalysisJobTemplate.yaml",
+			},
+			{
+				Id:                     uuid.New(),
+				PredeterminedType:      "transcode",
+				KubernetesTemplateFile: "config/AnalysisJobTemplate.yaml",
+				TranscodeSettingsId:    "7FEC2963-6A1D-46A2-8DE1-62DF939F6755",
+				Retry:                  &RetryPolicy{MaxAttempts: 2},
+				DependsOn:              []uuid.UUID{analysisStepId},
+			},
+		},
+	}
+}
+
+func TestJobTemplateManagerDatastore(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+	mgr := makeTestTemplateManager(t, testClient)
+
+	tpl := makeTestTemplate()
+	firstVersion, saveErr := mgr.SaveTemplate(tpl)
+	if saveErr != nil {
+		t.Fatalf("SaveTemplate failed: %s", saveErr)
+	}
+	if firstVersion.Version != 1 || firstVersion.UpdatedAt == nil {
+		t.Errorf("first version was not filled in: %d %v", firstVersion.Version, firstVersion.UpdatedAt)
+	}
+
+	firstJob, createErr := mgr.NewJobContainer(tpl.Id, helpers.ITEM_TYPE_VIDEO)
+	if createErr != nil {
+		t.Fatalf("could not make a job from the stored template: %s", createErr)
+	}
+	if firstJob.JobTemplateVersion != 1 || len(firstJob.Steps) != 2 {
+		t.Errorf("job was not made from version 1: version %d, %d steps", firstJob.JobTemplateVersion, len(firstJob.Steps))
+	}
+
+	tpl.JobTypeName = "Renamed template"
+	tpl.Steps = tpl.Steps[:1]
+	secondVersion, saveErr := mgr.SaveTemplate(tpl)
+	if saveErr != nil {
+		t.Fatalf("second SaveTemplate failed: %s", saveErr)
+	}
+	if secondVersion.Version != 2 {
+		t.Errorf("expected version 2, got %d", secondVersion.Version)
+	}
+
+	current, _ := mgr.GetJob(tpl.Id)
+	if current.JobTypeName != "Renamed template" || len(current.Steps) != 1 {
+		t.Errorf("GetJob did not return the latest version: %s with %d steps", current.JobTypeName, len(current.Steps))
+	}
+	original, foundOriginal := mgr.GetJobVersion(tpl.Id, firstJob.JobTemplateVersion)
+	if !foundOriginal || original.JobTypeName != "Test template" || len(original.Steps) != 2 {
+		t.Errorf("GetJobVersion did not return the version the job was made from: %v", original)
+	} else if original.Steps[1].Retry == nil || original.Steps[1].Retry.MaxAttempts != 2 {
+		t.Errorf("the retry policy was not kept in the stored template")
+	}
+
+	if len(mgr.ListTemplates()) != len(mgr.loadedTemplates)+1 {
+		t.Errorf("expected the stored template to be listed along with the ones from the file, got %d", len(mgr.ListTemplates()))
+	}
+
+	_, retireErr := mgr.RetireTemplate(tpl.Id)
+	if retireErr != nil {
+		t.Fatalf("RetireTemplate failed: %s", retireErr)
+	}
+	_, createErr = mgr.NewJobContainer(tpl.Id, helpers.ITEM_TYPE_VIDEO)
+	if createErr == nil {
+		t.Error("a job was made from a retired template")
+	}
+	if len(mgr.ListTemplates()) != len(mgr.loadedTemplates) {
+		t.Errorf("retired template should not be listed, got %d templates", len(mgr.ListTemplates()))
+	}
+	if len(mgr.ListAllTemplates()) != len(mgr.loadedTemplates)+1 {
+		t.Errorf("retired template should be in the full list, got %d templates", len(mgr.ListAllTemplates()))
+	}
+
+	history, historyErr := mgr.TemplateHistory(tpl.Id)
+	if historyErr != nil {
+		t.Fatalf("TemplateHistory failed: %s", historyErr)
+	}
+	if len(history) != 3 || history[0].Version != 1 || history[2].Version != 3 || !history[2].Retired {
+		t.Errorf("unexpected history: %v", history)
+	}
+}
+
+func TestJobTemplateManagerFileTemplatesReadOnly(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+	mgr := makeTestTemplateManager(t, testClient)
+
+	fileTemplateId := uuid.MustParse("846F823E-C0D3-4AF0-AD51-0F9573379057")
+	fileTemplate, _ := mgr.GetJob(fileTemplateId)
+	if _, saveErr := mgr.SaveTemplate(fileTemplate); saveErr == nil {
+		t.Error("saving over a template from the file should fail")
+	}
+	if _, retireErr := mgr.RetireTemplate(fileTemplateId); retireErr == nil {
+		t.Error("retiring a template from the file should fail")
+	}
+
+	clone, cloneErr := mgr.CloneTemplate(fileTemplateId, "")
+	if cloneErr != nil {
+		t.Fatalf("CloneTemplate failed: %s", cloneErr)
+	}
+	if clone.Id == fileTemplateId || clone.JobTypeName != "Copy of Standard thumbnail-and-transcode" || len(clone.Steps) != 3 {
+		t.Errorf("unexpected clone: %v", clone)
+	}
+	if mgr.IsReadOnly(clone.Id) {
+		t.Error("a clone should not be read-only")
+	}
+
+	job, createErr := mgr.NewJobContainer(fileTemplateId, helpers.ITEM_TYPE_VIDEO)
+	if createErr != nil || job.JobTemplateVersion != 0 {
+		t.Errorf("jobs from the template file should have version 0: %v %s", job, createErr)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	mgr := makeTestTemplateManager(t, nil)
+
+	if validationErr := mgr.ValidateTemplate(makeTestTemplate()); validationErr != nil {
+		t.Errorf("valid template was rejected: %s", validationErr)
+	}
+
+	bad := makeTestTemplate()
+	bad.JobTypeName = ""
+	bad.MediaType = helpers.ITEM_TYPE_AUDIO
+	bad.Steps[0].PredeterminedType = "frobnicate"
+	bad.Steps[1].KubernetesTemplateFile = "config/missing.yaml"
+	validationErr := mgr.ValidateTemplate(bad)
+	if validationErr == nil {
+		t.Fatal("invalid template was accepted")
+	}
+	for _, expected := range []string{"a name is required", "unknown type 'frobnicate'", "config/missing.yaml could not be loaded", "are for video but the template is for audio"} {
+		if !strings.Contains(validationErr.Error(), expected) {
+			t.Errorf("expected '%s' in the validation error, got %s", expected, validationErr)
+		}
+	}
+
+	missingSettings := makeTestTemplate()
+	missingSettings.Steps[1].TranscodeSettingsId = uuid.New().String()
+	if mgr.ValidateTemplate(missingSettings) == nil {
+		t.Error("a template referring to settings that don't exist was accepted")
+	}
+
+	for _, escaping := range []string{"/etc/passwd", "config/../../secrets.yaml", "../config/AnalysisJobTemplate.yaml", "other/AnalysisJobTemplate.yaml", "AnalysisJobTemplate.yaml", "config/../other/job.yaml"} {
+		outsideConfig := makeTestTemplate()
+		outsideConfig.Steps[1].KubernetesTemplateFile = escaping
+		if validationErr := mgr.ValidateTemplate(outsideConfig); validationErr == nil || !strings.Contains(validationErr.Error(), "must be a path within the config directory") {
+			t.Errorf("a kubernetes template at %s was accepted: %v", escaping, validationErr)
+		}
+	}
+
+	packagedQuality := makeTestTemplate()
+	packagedQuality.Steps[1].TranscodeSettingsId = "9A4E2B71-3C8F-4D25-B6E0-7F1C5A9D3E82"
+	packagedQuality.Steps[1].QualityMetrics = &QualityMetricSettings{Metrics: []string{QUALITY_METRIC_VMAF}}
+	if validationErr := mgr.ValidateTemplate(packagedQuality); validationErr != nil {
+		t.Errorf("measuring streaming packages without minimums should be accepted, got %s", validationErr)
+	}
+	packagedQuality.Steps[1].QualityMetrics.MinVMAF = 90
+	if validationErr := mgr.ValidateTemplate(packagedQuality); validationErr == nil || !strings.Contains(validationErr.Error(), "can't be measured") {
+		t.Errorf("a minimum quality for streaming packages was accepted: %v", validationErr)
+	}
+
+	wrongThumbnailSettings := makeTestTemplate()
+	wrongThumbnailSettings.Steps[1].PredeterminedType = "thumbnail"
+	if mgr.ValidateTemplate(wrongThumbnailSettings) == nil {
+		t.Error("a thumbnail step with video settings was accepted")
+	}
+}
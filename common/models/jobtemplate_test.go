@@ -15,8 +15,8 @@ func TestNewJobTemplateManager(t *testing.T) {
 	}
 
 	expectedUuid := uuid.MustParse("846F823E-C0D3-4AF0-AD51-0F9573379057")
-	if len(mgr.loadedTemplates) != 3 {
-		t.Errorf("Got %d templates, expected 3", len(mgr.loadedTemplates))
+	if len(mgr.loadedTemplates) != 6 {
+		t.Errorf("Got %d templates, expected 6", len(mgr.loadedTemplates))
 	}
 
 	if mgr.loadedTemplates[expectedUuid].JobTypeName != "Standard thumbnail-and-transcode" {
@@ -27,6 +27,11 @@ func TestNewJobTemplateManager(t *testing.T) {
 		t.Errorf("Got %d job steps, expected 3", len(mgr.loadedTemplates[expectedUuid].Steps))
 	}
 
+	spritesTemplate := mgr.loadedTemplates[uuid.MustParse("3D9A6C21-7F4E-4B58-9E0D-2C8B5A1F6E93")]
+	if len(spritesTemplate.Steps) != 3 || spritesTemplate.Steps[1].Sprites == nil || spritesTemplate.Steps[1].Sprites.TileWidth != 160 {
+		t.Errorf("sprite settings were not loaded for the thumbnail step of the scrubbing template")
+	}
+
 	//NewJobTemplateManager should return an error if it can't load the yaml
 	_, shouldLoadErr := NewJobTemplateManager("fdsfsdjhsdfk", nil)
 	if shouldLoadErr == nil {
@@ -76,6 +81,9 @@ func TestNewJobContainer(t *testing.T) {
 		if analysisStep.JobStepId == analysisStep.JobContainerId {
 			t.Error("Job step id was the same as container ID")
 		}
+		if analysisStep.Retry != nil {
+			t.Error("Analysis step should not have a retry policy")
+		}
 
 		thumbStep, isThumb := result.Steps[1].(JobStepThumbnail)
 		if !isThumb {
@@ -90,11 +98,23 @@ func TestNewJobContainer(t *testing.T) {
 		if thumbStep.JobStepId == thumbStep.JobContainerId {
 			t.Error("Job step id was the same as container ID")
 		}
+		if thumbStep.ThumbnailMode != THUMBNAIL_MODE_SMART || thumbStep.ThumbnailFrameSeconds != 2 {
+			t.Errorf("Thumbnail step had wrong frame settings, got mode '%s' at %f", thumbStep.ThumbnailMode, thumbStep.ThumbnailFrameSeconds)
+		}
+		if thumbStep.Conditions == nil || len(thumbStep.Conditions.RunIf) != 1 || thumbStep.Conditions.RunIf[0].Field != "duration" {
+			t.Errorf("Thumbnail step should have the conditions from the template, got %v", thumbStep.Conditions)
+		}
 
 		tcStep, isTc := result.Steps[2].(JobStepTranscode)
 		if !isTc {
 			t.Error("Expected job step 2 to be transcode")
 		} else {
+			if tcStep.Retry == nil || tcStep.Retry.MaxAttempts != 3 {
+				t.Errorf("Step 2 should have the retry policy from the template, got %v", tcStep.Retry)
+			}
+			if tcStep.Conditions == nil || len(tcStep.Conditions.SkipIf) != 2 {
+				t.Errorf("Step 2 should have the skip conditions from the template, got %v", tcStep.Conditions)
+			}
 			if tcStep.TranscodeSettings == nil {
 				t.Errorf("Step 2 had no transcode settings, expecting some")
 			} else {
@@ -104,6 +124,100 @@ func TestNewJobContainer(t *testing.T) {
 	}
 }
 
+/**
+NewJobContainer should translate the template step dependencies into the ids of the new job steps
+*/
+func TestNewJobContainer_Dependencies(t *testing.T) {
+	settingsMgr, settingsLoadErr := NewTranscodeSettingsManager("../../webapp/config/settings")
+	if settingsLoadErr != nil {
+		t.Fatalf("Could not load transcode settings: %s", settingsLoadErr)
+	}
+	mgr, loadErr := NewJobTemplateManager("../../webapp/config/standardjobtemplate.yaml", settingsMgr)
+	if loadErr != nil {
+		t.Fatalf("Load unexpectedly failed: %s", loadErr)
+	}
+
+	branched, err := mgr.NewJobContainer(uuid.MustParse("846F823E-C0D3-4AF0-AD51-0F9573379057"), helpers.ITEM_TYPE_VIDEO)
+	if err != nil {
+		t.Fatalf("NewJobContainer unexpectedly failed: %s", err)
+	}
+	if !branched.HasBranches() {
+		t.Fatal("expected the standard template to give a job with branches")
+	}
+	analysisId := branched.Steps[0].StepId()
+	if len(branched.Dependencies[analysisId]) != 0 {
+		t.Errorf("analysis step should not depend on anything, got %v", branched.Dependencies[analysisId])
+	}
+	for i := 1; i < 3; i++ {
+		deps := branched.Dependencies[branched.Steps[i].StepId()]
+		if len(deps) != 1 || deps[0] != analysisId {
+			t.Errorf("step %d should depend on the analysis step %s, got %v", i, analysisId, deps)
+		}
+	}
+
+	qcJob, err := mgr.NewJobContainer(uuid.MustParse("9C4E2B7A-1D3F-4A6E-8B5C-7E0F2D9A4C16"), helpers.ITEM_TYPE_VIDEO)
+	if err != nil {
+		t.Fatalf("NewJobContainer unexpectedly failed for the QC template: %s", err)
+	}
+	sourceQc, isQc := qcJob.Steps[1].(JobStepQC)
+	if !isQc || sourceQc.QCSettings == nil || sourceQc.QCSettings.ForDetector(QC_DETECTOR_BLACK).Action != QC_ACTION_FAIL {
+		t.Errorf("expected the second step of the QC template to be a QC step that fails on black, got %v", qcJob.Steps[1])
+	}
+	qcTranscode, isTranscode := qcJob.Steps[2].(JobStepTranscode)
+	if !isTranscode || qcTranscode.QualitySettings == nil || qcTranscode.QualitySettings.MinSSIM != 0.85 || !qcTranscode.QualitySettings.Wants(QUALITY_METRIC_VMAF) {
+		t.Errorf("expected the transcode step of the QC template to have quality metrics, got %v", qcJob.Steps[2])
+	}
+	transcodeQc, isQc := qcJob.Steps[3].(JobStepQC)
+	if !isQc || transcodeQc.QCSettings.EffectiveInput() != QC_INPUT_TRANSCODE || transcodeQc.QCSettings.Freeze != nil {
+		t.Errorf("expected the last step of the QC template to check the transcode, got %v", qcJob.Steps[3])
+	}
+
+	linear, err := mgr.NewJobContainer(uuid.MustParse("BAF0DCB9-7DE1-4D33-9DFF-B7AB565C47E8"), helpers.ITEM_TYPE_VIDEO)
+	if err != nil {
+		t.Fatalf("NewJobContainer unexpectedly failed: %s", err)
+	}
+	if linear.HasBranches() {
+		t.Errorf("template without dependencies should give a job without branches, got %v", linear.Dependencies)
+	}
+}
+
+func TestJobTemplateDefinition_CheckDependencies(t *testing.T) {
+	firstId := uuid.New()
+	secondId := uuid.New()
+
+	valid := JobTemplateDefinition{
+		Id: uuid.New(),
+		Steps: []JobStepTemplateDefinition{
+			{Id: firstId},
+			{Id: secondId, DependsOn: []uuid.UUID{firstId}},
+		},
+	}
+	if checkErr := valid.CheckDependencies(); checkErr != nil {
+		t.Errorf("valid dependencies gave an error: %s", checkErr)
+	}
+
+	missing := JobTemplateDefinition{
+		Id: uuid.New(),
+		Steps: []JobStepTemplateDefinition{
+			{Id: firstId, DependsOn: []uuid.UUID{uuid.New()}},
+		},
+	}
+	if missing.CheckDependencies() == nil {
+		t.Error("dependency on a step that is not in the template should give an error")
+	}
+
+	loop := JobTemplateDefinition{
+		Id: uuid.New(),
+		Steps: []JobStepTemplateDefinition{
+			{Id: firstId, DependsOn: []uuid.UUID{secondId}},
+			{Id: secondId, DependsOn: []uuid.UUID{firstId}},
+		},
+	}
+	if loop.CheckDependencies() == nil {
+		t.Error("dependencies that form a loop should give an error")
+	}
+}
+
 func TestJobContainer_SetMediaFile(t *testing.T) {
 
 }
@@ -0,0 +1,214 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/**
+the values of PredeterminedType that NewJobContainer knows how to make a step for
+*/
+var knownStepTypes = map[string]bool{
+	"analysis":  true,
+	"thumbnail": true,
+	"transcode": true,
+	"custom":    true,
+	"qc":        true,
+}
+
+/**
+returns the type of media that the given settings can be applied to
+*/
+func settingsMediaType(settings TranscodeTypeSettings) helpers.BulkItemType {
+	switch settings.(type) {
+	case JobSettings:
+		return helpers.ITEM_TYPE_VIDEO
+	case TranscodeAudioSettings:
+		return helpers.ITEM_TYPE_AUDIO
+	case TranscodeImageSettings:
+		return helpers.ITEM_TYPE_IMAGE
+	default:
+		return helpers.ITEM_TYPE_OTHER
+	}
+}
+
+/**
+the directory that the kubernetes templates are deployed into, relative to the webapp's working directory
+*/
+const kubernetesTemplateDir = "config"
+
+/**
+returns true if the given path refers to a file inside the config directory once it has been cleaned up, i.e. it is
+relative and starts with config/ without going back up out of it. Templates made through the API can only refer to the
+kubernetes templates that are deployed with the webapp, not to any file that the webapp can read. The template file
+itself is written by whoever deploys the webapp, so it is not restricted
+*/
+func isWithinConfigDir(fileName string) bool {
+	if path.IsAbs(fileName) || filepath.IsAbs(fileName) {
+		return false
+	}
+	cleaned := path.Clean(filepath.ToSlash(fileName))
+	return strings.HasPrefix(cleaned, kubernetesTemplateDir+"/")
+}
+
+/**
+returns true if the quality settings set minimum scores but the transcode settings output streaming packages, which can't
+be measured. The minimums could never be met, so every job would fail
+*/
+func qualityCantBeChecked(quality *QualityMetricSettings, settings TranscodeTypeSettings) bool {
+	if quality == nil || !quality.HasMinimums() {
+		return false
+	}
+	videoSettings, isVideo := settings.(JobSettings)
+	return isVideo && videoSettings.IsPackaged()
+}
+
+/**
+something that is wrong with a template
+*/
+type TemplateProblem struct {
+	StepIndex int    //index of the step that the problem is with, or -1 if it is with the template as a whole
+	Message   string //describes the problem, without saying which step it is in
+}
+
+func (p TemplateProblem) String() string {
+	if p.StepIndex < 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("step %d %s", p.StepIndex+1, p.Message)
+}
+
+/**
+checks that the given template is fit to be saved: every step is of a known type, its kubernetes template loads, any
+transcode settings it refers to exist and suit the media the template is for, and the step dependencies make sense.
+returns everything that is wrong with the template, or an empty list if it is fine
+*/
+func (mgr *JobTemplateManager) TemplateProblems(tpl JobTemplateDefinition) []TemplateProblem {
+	return mgr.templateProblems(tpl, false)
+}
+
+/**
+see TemplateProblems. If `configFilesOnly` is set then each KubernetesTemplateFile must also be a relative path within the
+config directory, and one that is not is never loaded
+*/
+func (mgr *JobTemplateManager) templateProblems(tpl JobTemplateDefinition, configFilesOnly bool) []TemplateProblem {
+	problems := make([]TemplateProblem, 0)
+	add := func(stepIndex int, format string, args ...interface{}) {
+		problems = append(problems, TemplateProblem{StepIndex: stepIndex, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if strings.TrimSpace(tpl.JobTypeName) == "" {
+		add(-1, "a name is required")
+	}
+	if len(tpl.Steps) == 0 {
+		add(-1, "a template needs at least one step")
+	}
+	switch tpl.MediaType {
+	case "", helpers.ITEM_TYPE_VIDEO, helpers.ITEM_TYPE_AUDIO, helpers.ITEM_TYPE_IMAGE:
+	default:
+		add(-1, "media type %s is not recognised", tpl.MediaType)
+	}
+
+	checkedFiles := make(map[string]bool)
+	expectedMedia := tpl.MediaType
+	for idx, stepTemplate := range tpl.Steps {
+		if stepTemplate.Id == uuid.Nil {
+			add(idx, "needs an id")
+		}
+		if !knownStepTypes[stepTemplate.PredeterminedType] {
+			add(idx, "has unknown type '%s'", stepTemplate.PredeterminedType)
+			continue
+		}
+
+		if stepTemplate.KubernetesTemplateFile == "" {
+			add(idx, "needs a KubernetesTemplateFile")
+		} else if configFilesOnly && !isWithinConfigDir(stepTemplate.KubernetesTemplateFile) {
+			add(idx, "kubernetes template %s must be a path within the config directory", stepTemplate.KubernetesTemplateFile)
+		} else if !checkedFiles[stepTemplate.KubernetesTemplateFile] {
+			checkedFiles[stepTemplate.KubernetesTemplateFile] = true
+			if mgr.templateFileCheck != nil {
+				if loadErr := mgr.templateFileCheck(stepTemplate.KubernetesTemplateFile); loadErr != nil {
+					add(idx, "kubernetes template %s could not be loaded: %s", stepTemplate.KubernetesTemplateFile, loadErr)
+				}
+			}
+		}
+
+		switch stepTemplate.PredeterminedType {
+		case "transcode":
+			settings, settingsErr := mgr.getTranscodeSettingsForValidation(stepTemplate.TranscodeSettingsId)
+			if settingsErr != nil {
+				add(idx, "has a problem with its transcode settings: %s", settingsErr)
+			} else {
+				mediaType := settingsMediaType(settings)
+				if expectedMedia == "" {
+					expectedMedia = mediaType
+				} else if mediaType != expectedMedia {
+					add(idx, "transcode settings %s are for %s but the template is for %s", settings.GetId(), mediaType, expectedMedia)
+				}
+				if qualityCantBeChecked(stepTemplate.QualityMetrics, settings) {
+					add(idx, "sets minimum quality scores but transcode settings %s output streaming packages, which can't be measured", settings.GetId())
+				}
+			}
+			if stepTemplate.QualityMetrics != nil && !stepTemplate.QualityMetrics.IsValid() {
+				add(idx, "has invalid quality metric settings")
+			}
+		case "thumbnail":
+			if stepTemplate.TranscodeSettingsId != "" {
+				settings, settingsErr := mgr.getTranscodeSettingsForValidation(stepTemplate.TranscodeSettingsId)
+				if settingsErr != nil {
+					add(idx, "has a problem with its transcode settings: %s", settingsErr)
+				} else if settingsMediaType(settings) != helpers.ITEM_TYPE_IMAGE {
+					add(idx, "thumbnails need image settings but %s are for %s", settings.GetId(), settingsMediaType(settings))
+				}
+			}
+			switch stepTemplate.ThumbnailMode {
+			case "", THUMBNAIL_MODE_FIXED, THUMBNAIL_MODE_SMART:
+			default:
+				add(idx, "has unknown thumbnail mode '%s'", stepTemplate.ThumbnailMode)
+			}
+			if stepTemplate.Sprites != nil && !stepTemplate.Sprites.WithDefaults().IsValid() {
+				add(idx, "has invalid sprite sheet settings")
+			}
+		case "qc":
+			if stepTemplate.QC == nil {
+				add(idx, "has no QC settings")
+			} else if validationErr := stepTemplate.QC.Validate(); validationErr != nil {
+				add(idx, "has invalid QC settings: %s", validationErr)
+			}
+		}
+	}
+
+	if depErr := tpl.CheckDependencies(); depErr != nil {
+		add(-1, "%s", depErr)
+	}
+	return problems
+}
+
+/**
+checks that the given template is fit to be saved, see TemplateProblems. This is applied to templates that are made
+through the API, so their kubernetes templates must also be within the config directory.
+returns nil if the template is fine or an error that lists everything that is wrong with it
+*/
+func (mgr *JobTemplateManager) ValidateTemplate(tpl JobTemplateDefinition) error {
+	problems := mgr.templateProblems(tpl, true)
+	if len(problems) == 0 {
+		return nil
+	}
+	descriptions := make([]string, len(problems))
+	for i, problem := range problems {
+		descriptions[i] = problem.String()
+	}
+	return errors.New(strings.Join(descriptions, "; "))
+}
+
+func (mgr *JobTemplateManager) getTranscodeSettingsForValidation(transcodeSettingsId string) (TranscodeTypeSettings, error) {
+	if mgr.transcodeSettingsMgr == nil {
+		return nil, errors.New("can't be checked because there are no transcode settings")
+	}
+	return mgr.getTranscodeSettings(transcodeSettingsId)
+}
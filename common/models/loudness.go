@@ -0,0 +1,135 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	DEFAULT_TRUE_PEAK      = -2.0 //dBTP, the ffmpeg loudnorm default
+	DEFAULT_LOUDNESS_RANGE = 7.0  //LU, the ffmpeg loudnorm default
+)
+
+/**
+EBU R128 loudness measurements of a file's audio, as given by the ffmpeg loudnorm filter.
+TargetOffset is the gain that loudnorm would add after normalising to the target it was measured against, so it is only
+meaningful for that target.
+*/
+type LoudnessAnalysis struct {
+	Integrated   float64 `json:"integrated" mapstructure:"integrated"`       //integrated loudness in LUFS
+	Range        float64 `json:"lra" mapstructure:"lra"`                     //loudness range in LU
+	TruePeak     float64 `json:"true_peak" mapstructure:"true_peak"`         //in dBTP
+	Threshold    float64 `json:"threshold" mapstructure:"threshold"`         //gating threshold in LUFS
+	TargetOffset float64 `json:"target_offset" mapstructure:"target_offset"` //in LU
+}
+
+/**
+loudness normalisation options for a transcode. TruePeak and Range are optional, 0 means use the ffmpeg default.
+Measured is not part of the saved settings; it is filled in by the wrapper once it has measured the input, so that the
+normalisation can be done in a single linear pass instead of loudnorm's dynamic mode
+*/
+type LoudnessSettings struct {
+	Target   float64           `json:"target" yaml:"target" mapstructure:"target"`          //integrated loudness to normalise to, in LUFS, e.g. -23
+	TruePeak float64           `json:"true_peak" yaml:"true_peak" mapstructure:"true_peak"` //maximum true peak in dBTP, e.g. -1
+	Range    float64           `json:"range" yaml:"range" mapstructure:"range"`             //loudness range target in LU
+	Measured *LoudnessAnalysis `json:"measured,omitempty" yaml:"-" mapstructure:"measured"`
+}
+
+/**
+settings types that can normalise loudness implement this, so that the wrapper can measure the input first
+*/
+type LoudnessNormalisedSettings interface {
+	LoudnessNormalisation() *LoudnessSettings //returns nil if the settings don't normalise loudness
+	WithMeasuredLoudness(measured *LoudnessAnalysis) TranscodeTypeSettings
+}
+
+func formatLoudness(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func (l LoudnessSettings) EffectiveTruePeak() float64 {
+	if l.TruePeak == 0 {
+		return DEFAULT_TRUE_PEAK
+	}
+	return l.TruePeak
+}
+
+func (l LoudnessSettings) EffectiveRange() float64 {
+	if l.Range == 0 {
+		return DEFAULT_LOUDNESS_RANGE
+	}
+	return l.Range
+}
+
+/**
+the limits here are the ones that the loudnorm filter accepts
+*/
+func (l LoudnessSettings) IsValid() bool {
+	return l.Target >= -70 && l.Target <= -5 &&
+		l.EffectiveTruePeak() >= -9 && l.EffectiveTruePeak() <= 0 &&
+		l.EffectiveRange() >= 1 && l.EffectiveRange() <= 50
+}
+
+/**
+returns the loudnorm filter for these settings. If the input has been measured then the measurements are passed on and
+linear normalisation is used, otherwise loudnorm has to normalise dynamically as it goes.
+*/
+func (l LoudnessSettings) FilterString() string {
+	parts := []string{
+		"I=" + formatLoudness(l.Target),
+		"TP=" + formatLoudness(l.EffectiveTruePeak()),
+		"LRA=" + formatLoudness(l.EffectiveRange()),
+	}
+	if l.Measured != nil {
+		parts = append(parts,
+			"measured_I="+formatLoudness(l.Measured.Integrated),
+			"measured_LRA="+formatLoudness(l.Measured.Range),
+			"measured_TP="+formatLoudness(l.Measured.TruePeak),
+			"measured_thresh="+formatLoudness(l.Measured.Threshold),
+			"offset="+formatLoudness(l.Measured.TargetOffset),
+			"linear=true",
+		)
+	}
+	return "loudnorm=" + strings.Join(parts, ":")
+}
+
+/**
+returns the ffmpeg options to apply this normalisation to the audio
+*/
+func (l LoudnessSettings) MarshalToArray() []string {
+	return []string{"-af", l.FilterString()}
+}
+
+/**
+returns the filter used to measure the input before normalising to these settings
+*/
+func (l LoudnessSettings) MeasureFilterString() string {
+	return fmt.Sprintf("loudnorm=I=%s:TP=%s:LRA=%s:print_format=json", formatLoudness(l.Target), formatLoudness(l.EffectiveTruePeak()), formatLoudness(l.EffectiveRange()))
+}
+
+/**
+returns a copy of the settings with the given measurements
+*/
+func (l LoudnessSettings) WithMeasured(measured *LoudnessAnalysis) *LoudnessSettings {
+	l.Measured = measured
+	return &l
+}
+
+/**
+returns a list of the ways that these measurements fall outside the given settings, or an empty list if they comply.
+tolerance is how far the integrated loudness can be from the target, in LU
+*/
+func (a LoudnessAnalysis) ComplianceProblems(spec LoudnessSettings, tolerance float64) []string {
+	problems := make([]string, 0)
+	if a.Integrated < spec.Target-tolerance || a.Integrated > spec.Target+tolerance {
+		problems = append(problems, fmt.Sprintf("integrated loudness %s LUFS is not within %s LU of %s", formatLoudness(a.Integrated), formatLoudness(tolerance), formatLoudness(spec.Target)))
+	}
+	if a.TruePeak > spec.EffectiveTruePeak() {
+		problems = append(problems, fmt.Sprintf("true peak %s dBTP is above %s", formatLoudness(a.TruePeak), formatLoudness(spec.EffectiveTruePeak())))
+	}
+	if spec.Range != 0 && a.Range > spec.Range {
+		problems = append(problems, fmt.Sprintf("loudness range %s LU is above %s", formatLoudness(a.Range), formatLoudness(spec.Range)))
+	}
+	return problems
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoudnessSettings_FilterString(t *testing.T) {
+	settings := LoudnessSettings{Target: -23, TruePeak: -1}
+	if settings.FilterString() != "loudnorm=I=-23:TP=-1:LRA=7" {
+		t.Errorf("got unexpected single pass filter '%s'", settings.FilterString())
+	}
+	if settings.MeasureFilterString() != "loudnorm=I=-23:TP=-1:LRA=7:print_format=json" {
+		t.Errorf("got unexpected measurement filter '%s'", settings.MeasureFilterString())
+	}
+
+	measured := settings.WithMeasured(&LoudnessAnalysis{Integrated: -27.61, Range: 18.06, TruePeak: -4.47, Threshold: -39.2, TargetOffset: 0.58})
+	expected := "loudnorm=I=-23:TP=-1:LRA=7:measured_I=-27.61:measured_LRA=18.06:measured_TP=-4.47:measured_thresh=-39.2:offset=0.58:linear=true"
+	if measured.FilterString() != expected {
+		t.Errorf("got unexpected two pass filter, expected '%s' got '%s'", expected, measured.FilterString())
+	}
+	if settings.Measured != nil {
+		t.Error("WithMeasured should not change the original settings")
+	}
+}
+
+func TestLoudnessSettings_IsValid(t *testing.T) {
+	tests := []struct {
+		settings LoudnessSettings
+		expected bool
+	}{
+		{LoudnessSettings{Target: -23}, true},
+		{LoudnessSettings{Target: -16, TruePeak: -1, Range: 11}, true},
+		{LoudnessSettings{Target: 0}, false},
+		{LoudnessSettings{Target: -80}, false},
+		{LoudnessSettings{Target: -23, TruePeak: 2}, false},
+		{LoudnessSettings{Target: -23, Range: 60}, false},
+	}
+	for _, test := range tests {
+		if test.settings.IsValid() != test.expected {
+			t.Errorf("%v gave %t, expected %t", test.settings, test.settings.IsValid(), test.expected)
+		}
+	}
+}
+
+func TestLoudnessAnalysis_ComplianceProblems(t *testing.T) {
+	spec := LoudnessSettings{Target: -23, TruePeak: -1}
+	compliant := LoudnessAnalysis{Integrated: -23.4, Range: 12, TruePeak: -2.1}
+	if problems := compliant.ComplianceProblems(spec, 1); len(problems) != 0 {
+		t.Errorf("expected no problems, got %v", problems)
+	}
+
+	tooLoud := LoudnessAnalysis{Integrated: -16.2, Range: 12, TruePeak: 0.3}
+	problems := tooLoud.ComplianceProblems(spec, 1)
+	if len(problems) != 2 {
+		t.Errorf("expected integrated and true peak problems, got %v", problems)
+	} else if !strings.Contains(problems[0], "-16.2 LUFS") || !strings.Contains(problems[1], "0.3 dBTP") {
+		t.Errorf("got unexpected problem descriptions %v", problems)
+	}
+
+	spec.Range = 10
+	if problems := compliant.ComplianceProblems(spec, 1); len(problems) != 1 {
+		t.Errorf("expected a loudness range problem, got %v", problems)
+	}
+}
+
+func TestJobSettings_Loudness(t *testing.T) {
+	settings := JobSettings{
+		Video:    VideoSettings{Codec: "h264", CRF: 23},
+		Audio:    AudioSettings{Codec: "aac", Bitrate: 128000, Channels: 2, Samplerate: 48000},
+		Wrapper:  WrapperSettings{Format: "mp4"},
+		Loudness: &LoudnessSettings{Target: -23},
+	}
+	expected := "-vcodec h264 -crf 23 -acodec aac -b:a 128000 -ac 2 -ar 48000 -af loudnorm=I=-23:TP=-2:LRA=7 -f mp4"
+	if strings.Join(settings.MarshalToArray(), " ") != expected {
+		t.Errorf("expected '%s' got '%s'", expected, strings.Join(settings.MarshalToArray(), " "))
+	}
+	if settings.MarshalToString() != expected {
+		t.Errorf("expected '%s' got '%s'", expected, settings.MarshalToString())
+	}
+
+	measured := settings.WithMeasuredLoudness(&LoudnessAnalysis{Integrated: -30, Range: 5, TruePeak: -10, Threshold: -40})
+	if !strings.Contains(measured.MarshalToString(), "measured_I=-30") {
+		t.Errorf("measured settings should do linear normalisation, got '%s'", measured.MarshalToString())
+	}
+	if settings.Loudness.Measured != nil {
+		t.Error("WithMeasuredLoudness should not change the original settings")
+	}
+
+	settings.Renditions = []RenditionSettings{{Name: "720p", Video: VideoSettings{Codec: "h264", CRF: 23}}}
+	if !strings.Contains(strings.Join(settings.RenditionToArray(settings.Renditions[0]), " "), "-af loudnorm=") {
+		t.Error("renditions should be normalised too")
+	}
+
+	settings.Loudness = &LoudnessSettings{Target: 3}
+	if settings.IsValid() {
+		t.Error("settings with invalid loudness should not be valid")
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"path"
+	"strconv"
+)
+
+const (
+	PACKAGE_HLS  = "hls"
+	PACKAGE_DASH = "dash"
+)
+
+const DEFAULT_SEGMENT_DURATION = 6
+
+/**
+settings for outputting a streaming package (a manifest plus a set of media segments) rather than a single file.
+Each format is written into its own directory, so that the segments can be found from the manifest location.
+*/
+type PackagingSettings struct {
+	Formats         []string `json:"formats" yaml:"formats" mapstructure:"formats"`                         //any of "hls" and "dash"
+	SegmentDuration int32    `json:"segmentduration" yaml:"segmentduration" mapstructure:"segmentduration"` //in seconds, defaults to 6
+}
+
+func (p PackagingSettings) IsValid() bool {
+	if len(p.Formats) == 0 {
+		return false
+	}
+	seenFormats := make(map[string]bool, len(p.Formats))
+	for _, format := range p.Formats {
+		if (format != PACKAGE_HLS && format != PACKAGE_DASH) || seenFormats[format] {
+			return false
+		}
+		seenFormats[format] = true
+	}
+	return true
+}
+
+func (p PackagingSettings) segmentDurationString() string {
+	if p.SegmentDuration <= 0 {
+		return strconv.FormatInt(DEFAULT_SEGMENT_DURATION, 10)
+	}
+	return strconv.FormatInt(int64(p.SegmentDuration), 10)
+}
+
+/**
+returns the ffmpeg muxer options to write the given package format with segments in outDir.
+The manifest path (see PackageManifestName) must follow them.
+*/
+func (p PackagingSettings) MuxerArgs(format string, outDir string) []string {
+	switch format {
+	case PACKAGE_HLS:
+		return []string{
+			"-f", "hls",
+			"-hls_time", p.segmentDurationString(),
+			"-hls_playlist_type", "vod",
+			"-hls_segment_filename", path.Join(outDir, "segment_%05d.ts"),
+		}
+	case PACKAGE_DASH:
+		//the dash muxer writes its segments alongside the manifest
+		return []string{
+			"-f", "dash",
+			"-seg_duration", p.segmentDurationString(),
+		}
+	default:
+		return nil
+	}
+}
+
+/**
+returns the filename of the manifest for the given package format
+*/
+func PackageManifestName(format string) string {
+	switch format {
+	case PACKAGE_HLS:
+		return "index.m3u8"
+	case PACKAGE_DASH:
+		return "manifest.mpd"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPackagingSettings_IsValid(t *testing.T) {
+	tests := []struct {
+		settings PackagingSettings
+		expected bool
+	}{
+		{PackagingSettings{Formats: []string{PACKAGE_HLS}}, true},
+		{PackagingSettings{Formats: []string{PACKAGE_HLS, PACKAGE_DASH}}, true},
+		{PackagingSettings{Formats: []string{}}, false},
+		{PackagingSettings{Formats: []string{"smooth"}}, false},
+		{PackagingSettings{Formats: []string{PACKAGE_DASH, PACKAGE_DASH}}, false},
+	}
+	for i, test := range tests {
+		if test.settings.IsValid() != test.expected {
+			t.Errorf("test %d: expected IsValid to be %t for %v", i, test.expected, test.settings.Formats)
+		}
+	}
+}
+
+func TestJobSettings_PackageToArray(t *testing.T) {
+	settings := JobSettings{
+		Video: VideoSettings{Codec: "h264", Bitrate: 1000000},
+		Audio: AudioSettings{Codec: "aac", Bitrate: 128000, Channels: 2, Samplerate: 48000},
+		Wrapper: WrapperSettings{
+			Packaging: &PackagingSettings{Formats: []string{PACKAGE_HLS, PACKAGE_DASH}, SegmentDuration: 4},
+		},
+	}
+	if !settings.IsValid() || !settings.IsPackaged() {
+		t.Error("packaged settings without a wrapper format should be valid")
+	}
+
+	hlsArgs := strings.Join(settings.PackageToArray(PACKAGE_HLS, "/output/hls"), " ")
+	expectedHls := "-vcodec h264 -b:v 1000000 -acodec aac -b:a 128000 -ac 2 -ar 48000 -f hls -hls_time 4 -hls_playlist_type vod -hls_segment_filename /output/hls/segment_%05d.ts"
+	if hlsArgs != expectedHls {
+		t.Errorf("got wrong hls options, expected '%s' got '%s'", expectedHls, hlsArgs)
+	}
+
+	settings.Wrapper.Packaging.SegmentDuration = 0
+	dashArgs := strings.Join(settings.PackageToArray(PACKAGE_DASH, "/output/dash"), " ")
+	if !strings.HasSuffix(dashArgs, "-f dash -seg_duration 6") {
+		t.Errorf("dash options should use the default segment duration, got '%s'", dashArgs)
+	}
+
+	settings.Renditions = []RenditionSettings{{Name: "720p"}}
+	if settings.IsValid() {
+		t.Error("packaging renditions is not supported, so the settings should not be valid")
+	}
+}
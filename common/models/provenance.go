@@ -0,0 +1,32 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+/**
+records how the output of a step was made, so that we can tell later on why one output differs from another.
+everything apart from SettingsId is filled in by the wrapper; SettingsId is the transcode settings that the step was
+created with, which can since have been changed or removed
+*/
+type Provenance struct {
+	SettingsId   *uuid.UUID             `json:"settingsId" mapstructure:"settingsId"`
+	Settings     map[string]interface{} `json:"settings" mapstructure:"settings"`         //snapshot of the settings that were actually used, including anything measured at run time
+	CommandLine  []string               `json:"commandLine" mapstructure:"commandLine"`   //the command that made the output, starting with the executable
+	ToolVersions map[string]string      `json:"toolVersions" mapstructure:"toolVersions"` //keyed by the name of the tool
+	Hostname     string                 `json:"hostname" mapstructure:"hostname"`         //the pod that ran the step
+	StartTime    *time.Time             `json:"startTime" mapstructure:"startTime"`
+	EndTime      *time.Time             `json:"endTime" mapstructure:"endTime"`
+}
+
+/**
+returns a copy of the provenance with the id of the transcode settings filled in, if there are any
+*/
+func (p Provenance) WithSettingsFrom(settings TranscodeTypeSettings) *Provenance {
+	if settings != nil {
+		settingsId := settings.GetId()
+		p.SettingsId = &settingsId
+	}
+	return &p
+}
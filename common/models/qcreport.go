@@ -0,0 +1,94 @@
+package models
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	QC_SEVERITY_INFO    = "info"    //detected but within the limits
+	QC_SEVERITY_WARNING = "warning" //the limits were exceeded and the job was flagged
+	QC_SEVERITY_ERROR   = "error"   //the limits were exceeded and the job was failed
+)
+
+const (
+	QC_OUTCOME_PASSED  = "passed"
+	QC_OUTCOME_FLAGGED = "flagged"
+	QC_OUTCOME_FAILED  = "failed"
+)
+
+/**
+one problem found by a QC detector. Times are in seconds from the start of the media, with the same times as HH:MM:SS.mmm
+*/
+type QCEvent struct {
+	Detector      string  `json:"detector" mapstructure:"detector"`
+	Start         float64 `json:"start" mapstructure:"start"`
+	End           float64 `json:"end" mapstructure:"end"`
+	StartTimecode string  `json:"startTimecode" mapstructure:"startTimecode"`
+	EndTimecode   string  `json:"endTimecode" mapstructure:"endTimecode"`
+	Severity      string  `json:"severity" mapstructure:"severity"`
+	Detail        string  `json:"detail" mapstructure:"detail"`
+}
+
+func (e QCEvent) Duration() float64 {
+	return e.End - e.Start
+}
+
+type QCReport struct {
+	Id             uuid.UUID `json:"id"`
+	JobContainerId uuid.UUID `json:"jobContainerId"`
+	JobStepId      uuid.UUID `json:"jobStepId"`
+	Input          string    `json:"input"`     //which media was checked, one of the QC_INPUT values
+	MediaFile      string    `json:"mediaFile"` //the file that was checked
+	Duration       float64   `json:"duration"`  //of the media, in seconds
+	Events         []QCEvent `json:"events"`
+	Outcome        string    `json:"outcome"`  //one of the QC_OUTCOME values
+	Problems       []string  `json:"problems"` //a description of each detector whose limits were exceeded
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+/**
+checks the events against the limits in the given settings, setting the severity of each event and the outcome of the
+report. if any detector whose action is fail was exceeded then the report fails, otherwise if any were exceeded it is flagged
+*/
+func (r *QCReport) Evaluate(settings QCSettings) {
+	r.Outcome = QC_OUTCOME_PASSED
+	r.Problems = make([]string, 0)
+
+	for _, detector := range QC_DETECTORS {
+		detectorSettings := settings.ForDetector(detector)
+		if detectorSettings == nil {
+			continue
+		}
+
+		eventCount := 0
+		totalSeconds := 0.0
+		for _, event := range r.Events {
+			if event.Detector == detector {
+				eventCount += 1
+				totalSeconds += event.Duration()
+			}
+		}
+
+		severity := QC_SEVERITY_INFO
+		if detectorSettings.IsExceeded(eventCount, totalSeconds) {
+			r.Problems = append(r.Problems, fmt.Sprintf("%s: %d events totalling %.2fs", detector, eventCount, totalSeconds))
+			if detectorSettings.Action == QC_ACTION_FAIL {
+				severity = QC_SEVERITY_ERROR
+				r.Outcome = QC_OUTCOME_FAILED
+			} else {
+				severity = QC_SEVERITY_WARNING
+				if r.Outcome != QC_OUTCOME_FAILED {
+					r.Outcome = QC_OUTCOME_FLAGGED
+				}
+			}
+		}
+
+		for i := range r.Events {
+			if r.Events[i].Detector == detector {
+				r.Events[i].Severity = severity
+			}
+		}
+	}
+}
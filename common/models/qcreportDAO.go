@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"log"
+)
+
+func keyForQCReport(id uuid.UUID) string {
+	return fmt.Sprintf("mediaflipper:qcreport:%s", id.String())
+}
+
+/**
+Retrieve the QC report for a given UUID from the datastore
+*/
+func GetQCReport(forId uuid.UUID, redisClient redis.Cmdable) (*QCReport, error) {
+	reportKey := keyForQCReport(forId)
+
+	content, getErr := redisClient.Get(reportKey).Result()
+	if getErr != nil {
+		log.Printf("Could not retrieve QC report for %s: %s", forId, getErr)
+		return nil, getErr
+	}
+
+	var decoded QCReport
+	decodErr := json.Unmarshal([]byte(content), &decoded)
+	if decodErr != nil {
+		log.Printf("Could not understand content from datastore for %s, removing it: %s", reportKey, decodErr)
+		redisClient.Del(reportKey)
+		return nil, decodErr
+	}
+	return &decoded, nil
+}
+
+/**
+Save the given report to the datastore. Returns nil if successful, or an error
+*/
+func PutQCReport(record *QCReport, redisClient redis.Cmdable) error {
+	reportKey := keyForQCReport(record.Id)
+
+	encoded, encodErr := json.Marshal(*record)
+	if encodErr != nil {
+		log.Print("Could not format data from ", *record, ": ", encodErr)
+		return encodErr
+	}
+
+	result := redisClient.Set(reportKey, string(encoded), -1)
+	if result.Err() != nil {
+		log.Printf("Could not save QC report to datastore: %s", result.Err())
+		return result.Err()
+	}
+	return nil
+}
+
+func RemoveQCReport(forId uuid.UUID, redisClient redis.Cmdable) error {
+	reportKey := keyForQCReport(forId)
+
+	deletedCount, err := redisClient.Del(reportKey).Result()
+	log.Printf("deleted %d records for QC report with id %s", deletedCount, forId)
+	return err
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestQCSettings_Validate(t *testing.T) {
+	if (QCSettings{}).Validate() == nil {
+		t.Error("settings without any detectors should not be valid")
+	}
+	if (QCSettings{Black: &QCDetectorSettings{}}).Validate() != nil {
+		t.Error("a detector with all defaults should be valid")
+	}
+	if (QCSettings{Input: "thumbnail", Black: &QCDetectorSettings{}}).Validate() == nil {
+		t.Error("an unknown input should not be valid")
+	}
+	if (QCSettings{Silence: &QCDetectorSettings{Action: "delete"}}).Validate() == nil {
+		t.Error("an unknown action should not be valid")
+	}
+
+	silence := QCSettings{Silence: &QCDetectorSettings{MaxEvents: 2}}.ForDetector(QC_DETECTOR_SILENCE)
+	if silence.Level != -60 || silence.MinDuration != 2 || silence.Action != QC_ACTION_FLAG || silence.MaxEvents != 2 {
+		t.Errorf("defaults were not filled in, got %v", silence)
+	}
+	if (QCSettings{}).ForDetector(QC_DETECTOR_FREEZE) != nil {
+		t.Error("a detector that is not set should not be enabled")
+	}
+}
+
+func TestQCReport_Evaluate(t *testing.T) {
+	settings := QCSettings{
+		Black:    &QCDetectorSettings{MaxTotalSeconds: 5, Action: QC_ACTION_FAIL},
+		Silence:  &QCDetectorSettings{MaxEvents: 1},
+		Clipping: &QCDetectorSettings{},
+	}
+
+	report := QCReport{Events: []QCEvent{
+		{Detector: QC_DETECTOR_BLACK, Start: 0, End: 2},
+		{Detector: QC_DETECTOR_SILENCE, Start: 10, End: 13},
+	}}
+	report.Evaluate(settings)
+	if report.Outcome != QC_OUTCOME_PASSED || len(report.Problems) != 0 {
+		t.Errorf("events within the limits should pass, got %s %v", report.Outcome, report.Problems)
+	}
+	if report.Events[0].Severity != QC_SEVERITY_INFO || report.Events[1].Severity != QC_SEVERITY_INFO {
+		t.Errorf("events within the limits should be info, got %v", report.Events)
+	}
+
+	report.Events = append(report.Events, QCEvent{Detector: QC_DETECTOR_CLIPPING, Start: 20, End: 20.05})
+	report.Evaluate(settings)
+	if report.Outcome != QC_OUTCOME_FLAGGED || len(report.Problems) != 1 || report.Events[2].Severity != QC_SEVERITY_WARNING {
+		t.Errorf("any clipping should flag the report, got %s %v", report.Outcome, report.Problems)
+	}
+
+	report.Events = append(report.Events, QCEvent{Detector: QC_DETECTOR_BLACK, Start: 30, End: 34})
+	report.Evaluate(settings)
+	if report.Outcome != QC_OUTCOME_FAILED || len(report.Problems) != 2 {
+		t.Errorf("too much black should fail the report, got %s %v", report.Outcome, report.Problems)
+	}
+	if report.Events[0].Severity != QC_SEVERITY_ERROR || report.Events[1].Severity != QC_SEVERITY_INFO {
+		t.Errorf("got unexpected severities %v", report.Events)
+	}
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	QC_DETECTOR_BLACK    = "black"
+	QC_DETECTOR_SILENCE  = "silence"
+	QC_DETECTOR_FREEZE   = "freeze"
+	QC_DETECTOR_CLIPPING = "clipping"
+)
+
+var QC_DETECTORS = []string{QC_DETECTOR_BLACK, QC_DETECTOR_SILENCE, QC_DETECTOR_FREEZE, QC_DETECTOR_CLIPPING}
+
+/**
+which media a QC step checks. the transcoded media is only available once a transcode step has run
+*/
+const (
+	QC_INPUT_SOURCE    = "source"
+	QC_INPUT_TRANSCODE = "transcode"
+)
+
+/**
+what happens to the job when a detector's limits are exceeded
+*/
+const (
+	QC_ACTION_FAIL = "fail" //the job is failed
+	QC_ACTION_FLAG = "flag" //the job completes but is flagged for somebody to look at
+)
+
+/**
+defaults for each detector. Level means:
+- black: the luma below which a pixel counts as black, as a fraction 0-1
+- silence and freeze: the noise tolerance in dB
+- clipping: the peak level in dBFS at or above which audio counts as clipped
+*/
+var qcDetectorDefaults = map[string]QCDetectorSettings{
+	QC_DETECTOR_BLACK:    {MinDuration: 2, Level: 0.1},
+	QC_DETECTOR_SILENCE:  {MinDuration: 2, Level: -60},
+	QC_DETECTOR_FREEZE:   {MinDuration: 2, Level: -60},
+	QC_DETECTOR_CLIPPING: {MinDuration: 0.01, Level: -0.1},
+}
+
+/**
+settings for one QC detector. Events shorter than MinDuration are not reported; zero values use the defaults above.
+the detector's limits are exceeded if there are more than MaxEvents events or they add up to more than MaxTotalSeconds.
+if neither limit is set then any event exceeds them.
+*/
+type QCDetectorSettings struct {
+	MinDuration     float64 `yaml:"MinDuration" json:"minDuration" mapstructure:"minDuration"` //seconds
+	Level           float64 `yaml:"Level" json:"level" mapstructure:"level"`
+	MaxEvents       int32   `yaml:"MaxEvents" json:"maxEvents" mapstructure:"maxEvents"`
+	MaxTotalSeconds float64 `yaml:"MaxTotalSeconds" json:"maxTotalSeconds" mapstructure:"maxTotalSeconds"`
+	Action          string  `yaml:"Action" json:"action" mapstructure:"action"` //one of the QC_ACTION values, defaults to flag
+}
+
+/**
+settings for a QC step. Only the detectors that are set are run
+*/
+type QCSettings struct {
+	Input    string              `yaml:"Input" json:"input" mapstructure:"input"` //one of the QC_INPUT values, defaults to source
+	Black    *QCDetectorSettings `yaml:"Black" json:"black" mapstructure:"black"`
+	Silence  *QCDetectorSettings `yaml:"Silence" json:"silence" mapstructure:"silence"`
+	Freeze   *QCDetectorSettings `yaml:"Freeze" json:"freeze" mapstructure:"freeze"`
+	Clipping *QCDetectorSettings `yaml:"Clipping" json:"clipping" mapstructure:"clipping"`
+}
+
+func (s QCDetectorSettings) withDefaults(detector string) QCDetectorSettings {
+	defaults := qcDetectorDefaults[detector]
+	if s.MinDuration == 0 {
+		s.MinDuration = defaults.MinDuration
+	}
+	if s.Level == 0 {
+		s.Level = defaults.Level
+	}
+	if s.Action == "" {
+		s.Action = QC_ACTION_FLAG
+	}
+	return s
+}
+
+/**
+returns true if the given events exceed the limits
+*/
+func (s QCDetectorSettings) IsExceeded(eventCount int, totalSeconds float64) bool {
+	if s.MaxEvents == 0 && s.MaxTotalSeconds == 0 {
+		return eventCount > 0
+	}
+	if s.MaxEvents > 0 && eventCount > int(s.MaxEvents) {
+		return true
+	}
+	return s.MaxTotalSeconds > 0 && totalSeconds > s.MaxTotalSeconds
+}
+
+/**
+returns the settings for the given detector with the defaults filled in, or nil if that detector is not enabled
+*/
+func (s QCSettings) ForDetector(detector string) *QCDetectorSettings {
+	var detectorSettings *QCDetectorSettings
+	switch detector {
+	case QC_DETECTOR_BLACK:
+		detectorSettings = s.Black
+	case QC_DETECTOR_SILENCE:
+		detectorSettings = s.Silence
+	case QC_DETECTOR_FREEZE:
+		detectorSettings = s.Freeze
+	case QC_DETECTOR_CLIPPING:
+		detectorSettings = s.Clipping
+	}
+	if detectorSettings == nil {
+		return nil
+	}
+	withDefaults := detectorSettings.withDefaults(detector)
+	return &withDefaults
+}
+
+func (s QCSettings) EffectiveInput() string {
+	if s.Input == "" {
+		return QC_INPUT_SOURCE
+	}
+	return s.Input
+}
+
+/**
+checks that the settings make sense, returning an error that describes the problem if not
+*/
+func (s QCSettings) Validate() error {
+	if s.EffectiveInput() != QC_INPUT_SOURCE && s.EffectiveInput() != QC_INPUT_TRANSCODE {
+		return errors.New(fmt.Sprintf("QC input must be %s or %s, not %s", QC_INPUT_SOURCE, QC_INPUT_TRANSCODE, s.Input))
+	}
+	enabledCount := 0
+	for _, detector := range QC_DETECTORS {
+		detectorSettings := s.ForDetector(detector)
+		if detectorSettings == nil {
+			continue
+		}
+		enabledCount += 1
+		if detectorSettings.Action != QC_ACTION_FAIL && detectorSettings.Action != QC_ACTION_FLAG {
+			return errors.New(fmt.Sprintf("QC action for %s must be %s or %s, not %s", detector, QC_ACTION_FAIL, QC_ACTION_FLAG, detectorSettings.Action))
+		}
+		if detectorSettings.MinDuration < 0 || detectorSettings.MaxEvents < 0 || detectorSettings.MaxTotalSeconds < 0 {
+			return errors.New(fmt.Sprintf("QC limits for %s can't be negative", detector))
+		}
+	}
+	if enabledCount == 0 {
+		return errors.New("QC settings don't enable any detectors")
+	}
+	return nil
+}
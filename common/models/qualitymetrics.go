@@ -0,0 +1,139 @@
+package models
+
+import (
+	"fmt"
+)
+
+const (
+	QUALITY_METRIC_PSNR = "psnr"
+	QUALITY_METRIC_SSIM = "ssim"
+	QUALITY_METRIC_VMAF = "vmaf" //only measured if the ffmpeg build has libvmaf
+)
+
+const (
+	DEFAULT_QUALITY_SEGMENT_SECONDS = 10.0
+	MAX_PSNR                        = 100.0 //identical frames have an infinite PSNR, they are counted as this instead
+)
+
+/**
+settings for measuring the quality of a transcode against its source once it has finished. The scores are worked out for
+the whole file and for each segment of SegmentSeconds. If any of the minimums are set and the aggregate score for that
+metric is below it then the transcode step fails; a minimum of zero is not checked.
+*/
+type QualityMetricSettings struct {
+	Metrics        []string `yaml:"Metrics" json:"metrics" mapstructure:"metrics"` //defaults to psnr and ssim
+	SegmentSeconds float64  `yaml:"SegmentSeconds" json:"segmentSeconds" mapstructure:"segmentSeconds"`
+	MinPSNR        float64  `yaml:"MinPSNR" json:"minPsnr" mapstructure:"minPsnr"` //dB
+	MinSSIM        float64  `yaml:"MinSSIM" json:"minSsim" mapstructure:"minSsim"` //0-1
+	MinVMAF        float64  `yaml:"MinVMAF" json:"minVmaf" mapstructure:"minVmaf"` //0-100
+}
+
+/**
+scores for one of the metrics. Metrics that were not measured are nil
+*/
+type QualityScores struct {
+	PSNR *float64 `json:"psnr" mapstructure:"psnr"`
+	SSIM *float64 `json:"ssim" mapstructure:"ssim"`
+	VMAF *float64 `json:"vmaf" mapstructure:"vmaf"`
+}
+
+type QualitySegment struct {
+	Start  float64       `json:"start" mapstructure:"start"`
+	End    float64       `json:"end" mapstructure:"end"`
+	Scores QualityScores `json:"scores" mapstructure:"scores"`
+}
+
+/**
+the results of comparing a transcode to its source. If the comparison could not be made then ErrorMessage is set
+*/
+type QualityMetrics struct {
+	ComparedFile  string           `json:"comparedFile" mapstructure:"comparedFile"` //the output that was compared with the source
+	Aggregate     QualityScores    `json:"aggregate" mapstructure:"aggregate"`
+	Segments      []QualitySegment `json:"segments" mapstructure:"segments"`
+	VMAFAvailable bool             `json:"vmafAvailable" mapstructure:"vmafAvailable"`
+	ErrorMessage  string           `json:"errorMessage" mapstructure:"errorMessage"`
+}
+
+func (s QualityMetricSettings) WithDefaults() QualityMetricSettings {
+	if len(s.Metrics) == 0 {
+		s.Metrics = []string{QUALITY_METRIC_PSNR, QUALITY_METRIC_SSIM}
+	}
+	if s.SegmentSeconds == 0 {
+		s.SegmentSeconds = DEFAULT_QUALITY_SEGMENT_SECONDS
+	}
+	return s
+}
+
+/**
+returns true if the given metric should be measured. A metric with a minimum set is always measured
+*/
+func (s QualityMetricSettings) Wants(metric string) bool {
+	switch metric {
+	case QUALITY_METRIC_PSNR:
+		if s.MinPSNR > 0 {
+			return true
+		}
+	case QUALITY_METRIC_SSIM:
+		if s.MinSSIM > 0 {
+			return true
+		}
+	case QUALITY_METRIC_VMAF:
+		if s.MinVMAF > 0 {
+			return true
+		}
+	}
+	for _, wanted := range s.WithDefaults().Metrics {
+		if wanted == metric {
+			return true
+		}
+	}
+	return false
+}
+
+func (s QualityMetricSettings) IsValid() bool {
+	for _, metric := range s.Metrics {
+		if metric != QUALITY_METRIC_PSNR && metric != QUALITY_METRIC_SSIM && metric != QUALITY_METRIC_VMAF {
+			return false
+		}
+	}
+	return s.SegmentSeconds >= 0 && s.MinPSNR >= 0 && s.MinSSIM >= 0 && s.MinSSIM <= 1 && s.MinVMAF >= 0 && s.MinVMAF <= 100
+}
+
+/**
+returns true if any of the minimums are set, i.e. the transcode can fail on its quality
+*/
+func (s QualityMetricSettings) HasMinimums() bool {
+	return s.MinPSNR > 0 || s.MinSSIM > 0 || s.MinVMAF > 0
+}
+
+/**
+checks the aggregate scores against the minimums, returning a description of each one that was not met.
+if a minimum is set but the metric could not be measured then that is a problem too, except for VMAF when the ffmpeg
+build does not support it
+*/
+func (s QualityMetricSettings) ThresholdProblems(metrics *QualityMetrics) []string {
+	problems := make([]string, 0)
+	if !s.HasMinimums() {
+		return problems
+	}
+	if metrics == nil || metrics.ErrorMessage != "" {
+		return append(problems, "quality could not be measured")
+	}
+
+	check := func(name string, score *float64, minimum float64) {
+		if minimum == 0 {
+			return
+		}
+		if score == nil {
+			problems = append(problems, fmt.Sprintf("%s was not measured", name))
+		} else if *score < minimum {
+			problems = append(problems, fmt.Sprintf("%s %.3f is below the minimum of %.3f", name, *score, minimum))
+		}
+	}
+	check("PSNR", metrics.Aggregate.PSNR, s.MinPSNR)
+	check("SSIM", metrics.Aggregate.SSIM, s.MinSSIM)
+	if metrics.VMAFAvailable {
+		check("VMAF", metrics.Aggregate.VMAF, s.MinVMAF)
+	}
+	return problems
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQualityMetricSettings_Wants(t *testing.T) {
+	defaults := QualityMetricSettings{}
+	if !defaults.Wants(QUALITY_METRIC_PSNR) || !defaults.Wants(QUALITY_METRIC_SSIM) || defaults.Wants(QUALITY_METRIC_VMAF) {
+		t.Error("PSNR and SSIM should be measured by default, but not VMAF")
+	}
+	vmafMinimum := QualityMetricSettings{Metrics: []string{QUALITY_METRIC_PSNR}, MinVMAF: 80}
+	if !vmafMinimum.Wants(QUALITY_METRIC_VMAF) || vmafMinimum.Wants(QUALITY_METRIC_SSIM) {
+		t.Error("a metric with a minimum should always be measured")
+	}
+	if (QualityMetricSettings{Metrics: []string{"mos"}}).IsValid() {
+		t.Error("an unknown metric should not be valid")
+	}
+	if (QualityMetricSettings{MinSSIM: 2}).IsValid() {
+		t.Error("an SSIM minimum above 1 should not be valid")
+	}
+}
+
+func TestQualityMetricSettings_ThresholdProblems(t *testing.T) {
+	psnr := 38.5
+	ssim := 0.91
+	metrics := &QualityMetrics{Aggregate: QualityScores{PSNR: &psnr, SSIM: &ssim}}
+
+	if problems := (QualityMetricSettings{}).ThresholdProblems(nil); len(problems) != 0 {
+		t.Errorf("settings without minimums should never have problems, got %v", problems)
+	}
+	if problems := (QualityMetricSettings{MinPSNR: 35, MinSSIM: 0.9}).ThresholdProblems(metrics); len(problems) != 0 {
+		t.Errorf("scores above the minimums should not have problems, got %v", problems)
+	}
+
+	problems := (QualityMetricSettings{MinPSNR: 40, MinSSIM: 0.9}).ThresholdProblems(metrics)
+	if len(problems) != 1 || !strings.Contains(problems[0], "PSNR 38.500") {
+		t.Errorf("expected a PSNR problem, got %v", problems)
+	}
+
+	if problems := (QualityMetricSettings{MinVMAF: 80}).ThresholdProblems(metrics); len(problems) != 0 {
+		t.Errorf("VMAF should not be checked if the ffmpeg build can't measure it, got %v", problems)
+	}
+	metrics.VMAFAvailable = true
+	if problems := (QualityMetricSettings{MinVMAF: 80}).ThresholdProblems(metrics); len(problems) != 1 {
+		t.Errorf("expected a problem for VMAF that should have been measured but was not, got %v", problems)
+	}
+
+	failed := &QualityMetrics{ErrorMessage: "could not compare"}
+	if problems := (QualityMetricSettings{MinSSIM: 0.9}).ThresholdProblems(failed); len(problems) != 1 {
+		t.Errorf("a failed measurement should be a problem when there are minimums, got %v", problems)
+	}
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+/**
+error classes used to decide whether a failed job step can be retried
+*/
+const (
+	ERROR_CLASS_PROCESS = "process_failed" //the external process exited with an error
+	ERROR_CLASS_IO      = "io_error"       //the external process failed with what looks like a storage problem
+	ERROR_CLASS_LOST    = "lost"           //the external process disappeared
+)
+
+/**
+fragments of error messages that indicate a (hopefully transient) problem with the storage rather than the media
+*/
+var ioErrorIndicators = []string{
+	"input/output error",
+	"stale file handle",
+	"stale nfs file handle",
+	"transport endpoint is not connected",
+	"resource temporarily unavailable",
+	"connection timed out",
+}
+
+/**
+determines whether and when a failed job step should be tried again.
+this is configured on the JobStepTemplateDefinition and copied onto each job step that is created from it.
+- MaxAttempts is the total number of times that the step can be run, including the first one
+- BackoffSeconds is the delay before the first retry. Each subsequent delay is multiplied by BackoffMultiplier (default 1, i.e. constant)
+- RetryableErrors lists the error classes that can be retried. If it is empty then every class can be retried.
+*/
+type RetryPolicy struct {
+	MaxAttempts       int      `yaml:"MaxAttempts" json:"maxAttempts" mapstructure:"maxAttempts"`
+	BackoffSeconds    float64  `yaml:"BackoffSeconds" json:"backoffSeconds" mapstructure:"backoffSeconds"`
+	BackoffMultiplier float64  `yaml:"BackoffMultiplier" json:"backoffMultiplier" mapstructure:"backoffMultiplier"`
+	RetryableErrors   []string `yaml:"RetryableErrors" json:"retryableErrors" mapstructure:"retryableErrors"`
+}
+
+/**
+a record of a failed attempt at running a job step.
+the log output from the attempt is kept separately, see GetContainerLogContentForAttempt
+*/
+type StepAttempt struct {
+	Attempt      int        `json:"attempt" mapstructure:"attempt"`
+	ErrorClass   string     `json:"errorClass" mapstructure:"errorClass"`
+	ErrorMessage string     `json:"errorMessage" mapstructure:"errorMessage"`
+	FailedAt     *time.Time `json:"failedAt" mapstructure:"failedAt"`
+	RetryAt      *time.Time `json:"retryAt" mapstructure:"retryAt"` //nil if the step was not retried after this attempt
+	Resumed      bool       `json:"resumed" mapstructure:"resumed"` //set if the job was resumed by hand after this attempt
+}
+
+/**
+returns the number of attempts that count towards the retry limit. Only the attempts since the job was last resumed count,
+so that a resumed step gets its full number of retries again; the earlier ones are kept for their history and logs
+*/
+func AttemptsSinceResume(attempts []StepAttempt) int {
+	for i := len(attempts) - 1; i >= 0; i-- {
+		if attempts[i].Resumed {
+			return len(attempts) - 1 - i
+		}
+	}
+	return len(attempts)
+}
+
+/**
+returns true if a step that has failed `attemptsSoFar` times, most recently with `errorClass`, should be tried again
+*/
+func (p *RetryPolicy) ShouldRetry(attemptsSoFar int, errorClass string) bool {
+	if p == nil || attemptsSoFar >= p.MaxAttempts {
+		return false
+	}
+	if len(p.RetryableErrors) == 0 {
+		return true
+	}
+	for _, retryable := range p.RetryableErrors {
+		if retryable == errorClass {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+returns how long to wait before the next attempt, after `attemptsSoFar` failures
+*/
+func (p *RetryPolicy) BackoffFor(attemptsSoFar int) time.Duration {
+	multiplier := p.BackoffMultiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	seconds := p.BackoffSeconds * math.Pow(multiplier, float64(attemptsSoFar-1))
+	return time.Duration(seconds * float64(time.Second))
+}
+
+/**
+work out the error class of a failure. `baseClass` is what the runner knows about the failure and `errorText`
+is any error message or log output from the external process, which is checked for signs of storage problems.
+*/
+func ClassifyStepError(baseClass string, errorText string) string {
+	if baseClass != ERROR_CLASS_PROCESS {
+		return baseClass
+	}
+	lowerText := strings.ToLower(errorText)
+	for _, indicator := range ioErrorIndicators {
+		if strings.Contains(lowerText, indicator) {
+			return ERROR_CLASS_IO
+		}
+	}
+	return baseClass
+}
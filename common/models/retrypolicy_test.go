@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_ShouldRetry(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts:     3,
+		RetryableErrors: []string{ERROR_CLASS_IO, ERROR_CLASS_LOST},
+	}
+
+	if !policy.ShouldRetry(1, ERROR_CLASS_IO) {
+		t.Error("first io error should be retried")
+	}
+	if !policy.ShouldRetry(2, ERROR_CLASS_LOST) {
+		t.Error("second lost runner should be retried")
+	}
+	if policy.ShouldRetry(3, ERROR_CLASS_IO) {
+		t.Error("should not retry once MaxAttempts has been reached")
+	}
+	if policy.ShouldRetry(1, ERROR_CLASS_PROCESS) {
+		t.Error("process failures are not in RetryableErrors so should not be retried")
+	}
+
+	anyError := &RetryPolicy{MaxAttempts: 2}
+	if !anyError.ShouldRetry(1, ERROR_CLASS_PROCESS) {
+		t.Error("empty RetryableErrors should retry any class")
+	}
+
+	var noPolicy *RetryPolicy
+	if noPolicy.ShouldRetry(1, ERROR_CLASS_IO) {
+		t.Error("nil policy should never retry")
+	}
+}
+
+func TestRetryPolicy_BackoffFor(t *testing.T) {
+	constant := &RetryPolicy{BackoffSeconds: 30}
+	if constant.BackoffFor(1) != 30*time.Second || constant.BackoffFor(3) != 30*time.Second {
+		t.Errorf("expected constant 30s backoff, got %s and %s", constant.BackoffFor(1), constant.BackoffFor(3))
+	}
+
+	exponential := &RetryPolicy{BackoffSeconds: 10, BackoffMultiplier: 2}
+	if exponential.BackoffFor(1) != 10*time.Second {
+		t.Errorf("expected first backoff of 10s, got %s", exponential.BackoffFor(1))
+	}
+	if exponential.BackoffFor(3) != 40*time.Second {
+		t.Errorf("expected third backoff of 40s, got %s", exponential.BackoffFor(3))
+	}
+}
+
+func TestClassifyStepError(t *testing.T) {
+	if ClassifyStepError(ERROR_CLASS_PROCESS, "ffmpeg: /mnt/media/in.mxf: Input/output error") != ERROR_CLASS_IO {
+		t.Error("expected an input/output error to be classed as io")
+	}
+	if ClassifyStepError(ERROR_CLASS_PROCESS, "Stale file handle") != ERROR_CLASS_IO {
+		t.Error("expected a stale file handle to be classed as io")
+	}
+	if ClassifyStepError(ERROR_CLASS_PROCESS, "Invalid data found when processing input") != ERROR_CLASS_PROCESS {
+		t.Error("expected a media error to stay as a process failure")
+	}
+	if ClassifyStepError(ERROR_CLASS_LOST, "input/output error") != ERROR_CLASS_LOST {
+		t.Error("a lost runner should stay lost whatever the logs say")
+	}
+}
+
+/**
+the retry policy and attempt history must survive being stored and loaded again
+*/
+func TestJobStepAttemptsRoundTrip(t *testing.T) {
+	failedAt := time.Date(2020, 2, 3, 4, 5, 6, 0, time.UTC)
+	retryAt := failedAt.Add(30 * time.Second)
+	step := JobStepCustom{
+		JobStepType:    "custom",
+		JobStepId:      uuid.New(),
+		JobContainerId: uuid.New(),
+		Retry:          &RetryPolicy{MaxAttempts: 3, BackoffSeconds: 30, RetryableErrors: []string{ERROR_CLASS_IO}},
+	}
+	updated := step.WithAttemptRecorded(StepAttempt{
+		Attempt:      1,
+		ErrorClass:   ERROR_CLASS_IO,
+		ErrorMessage: "External job failed",
+		FailedAt:     &failedAt,
+		RetryAt:      &retryAt,
+	})
+	if len(step.AttemptHistory) != 0 {
+		t.Error("WithAttemptRecorded should not change the original step")
+	}
+
+	content, _ := json.Marshal(updated)
+	var mapData map[string]interface{}
+	json.Unmarshal(content, &mapData)
+	result, err := JobStepCustomFromMap(mapData)
+	if err != nil {
+		t.Fatalf("JobStepCustomFromMap failed unexpectedly: %s", err)
+	}
+
+	if result.RetryPolicy() == nil || result.RetryPolicy().MaxAttempts != 3 || len(result.RetryPolicy().RetryableErrors) != 1 {
+		t.Errorf("retry policy did not survive, got %v", result.RetryPolicy())
+	}
+	attempts := result.Attempts()
+	if len(attempts) != 1 {
+		t.Fatalf("expected 1 attempt, got %d", len(attempts))
+	}
+	if attempts[0].ErrorClass != ERROR_CLASS_IO || attempts[0].Attempt != 1 {
+		t.Errorf("unexpected attempt data %v", attempts[0])
+	}
+	if attempts[0].FailedAt == nil || !attempts[0].FailedAt.Equal(failedAt) {
+		t.Errorf("expected FailedAt of %s, got %v", failedAt, attempts[0].FailedAt)
+	}
+	if attempts[0].RetryAt == nil || !attempts[0].RetryAt.Equal(retryAt) {
+		t.Errorf("expected RetryAt of %s, got %v", retryAt, attempts[0].RetryAt)
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+const (
+	DEFAULT_SPRITE_INTERVAL   = 10.0 //seconds between frames
+	DEFAULT_SPRITE_COLUMNS    = 10
+	DEFAULT_SPRITE_ROWS       = 10
+	DEFAULT_SPRITE_TILE_WIDTH = 160 //pixels. the height is worked out from the aspect ratio of the video
+	SPRITE_INDEX_NAME         = "sprites.vtt"
+)
+
+/**
+settings for a thumbnail step that makes scrubbing thumbnails instead of a single frame. Frames are taken at a regular
+interval and tiled into one or more sprite sheets, with a WebVTT index that maps each time range to its tile.
+any values that are not set use the defaults above
+*/
+type SpriteSheetSettings struct {
+	IntervalSeconds float64 `yaml:"IntervalSeconds" json:"intervalSeconds" mapstructure:"intervalSeconds"`
+	Columns         int32   `yaml:"Columns" json:"columns" mapstructure:"columns"`
+	Rows            int32   `yaml:"Rows" json:"rows" mapstructure:"rows"`
+	TileWidth       int32   `yaml:"TileWidth" json:"tileWidth" mapstructure:"tileWidth"`
+}
+
+/**
+returns a copy of the settings with the defaults filled in
+*/
+func (s SpriteSheetSettings) WithDefaults() SpriteSheetSettings {
+	if s.IntervalSeconds == 0 {
+		s.IntervalSeconds = DEFAULT_SPRITE_INTERVAL
+	}
+	if s.Columns == 0 {
+		s.Columns = DEFAULT_SPRITE_COLUMNS
+	}
+	if s.Rows == 0 {
+		s.Rows = DEFAULT_SPRITE_ROWS
+	}
+	if s.TileWidth == 0 {
+		s.TileWidth = DEFAULT_SPRITE_TILE_WIDTH
+	}
+	return s
+}
+
+func (s SpriteSheetSettings) IsValid() bool {
+	withDefaults := s.WithDefaults()
+	return withDefaults.IntervalSeconds > 0 && withDefaults.Columns > 0 && withDefaults.Rows > 0 && withDefaults.TileWidth > 0
+}
+
+/**
+returns the number of frames that fit on one sheet
+*/
+func (s SpriteSheetSettings) TilesPerSheet() int {
+	withDefaults := s.WithDefaults()
+	return int(withDefaults.Columns * withDefaults.Rows)
+}
@@ -0,0 +1,229 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/**
+operators that can be used in a StepCondition
+*/
+const (
+	CONDITION_EQ       = "eq"
+	CONDITION_NE       = "ne"
+	CONDITION_LT       = "lt"
+	CONDITION_LE       = "le"
+	CONDITION_GT       = "gt"
+	CONDITION_GE       = "ge"
+	CONDITION_CONTAINS = "contains" //the field value contains the given text, e.g. "mp4" is in "mov,mp4,m4a,3gp,3g2,mj2"
+	CONDITION_IN       = "in"       //the field value is one of a comma-separated list of values
+)
+
+/**
+a single test against the analysis results of an earlier step in the same job.
+Field is the name of a format field as returned by the analysis, e.g. format_name, duration, bit_rate, size, nb_streams.
+Fields of the first video or audio stream can be tested by prefixing them with "video." or "audio.", e.g. video.frame_rate,
+video.field_order or audio.channel_layout. The loudness measurements can be tested with a "loudness." prefix, e.g.
+loudness.integrated or loudness.true_peak.
+Value is always given as text; if the field is numeric then it is compared as a number.
+*/
+type StepCondition struct {
+	Field    string `yaml:"Field" json:"field" mapstructure:"field"`
+	Operator string `yaml:"Operator" json:"operator" mapstructure:"operator"`
+	Value    string `yaml:"Value" json:"value" mapstructure:"value"`
+}
+
+/**
+decides whether a job step should be run, based on the analysis results of an earlier step in the same job.
+this is configured on the JobStepTemplateDefinition and copied onto each job step that is created from it.
+- RunIf: the step only runs if every one of these conditions is met
+- SkipIf: the step is skipped if every one of these conditions is met
+a step that does not run is marked as JOB_SKIPPED and the job moves on to the next step.
+*/
+type StepConditions struct {
+	RunIf  []StepCondition `yaml:"RunIf" json:"runIf" mapstructure:"runIf"`
+	SkipIf []StepCondition `yaml:"SkipIf" json:"skipIf" mapstructure:"skipIf"`
+}
+
+func (c StepCondition) String() string {
+	return fmt.Sprintf("%s %s %s", c.Field, c.Operator, c.Value)
+}
+
+/**
+returns true if there is nothing to evaluate, i.e. the step always runs
+*/
+func (c *StepConditions) IsEmpty() bool {
+	return c == nil || (len(c.RunIf) == 0 && len(c.SkipIf) == 0)
+}
+
+/**
+works out whether a step with these conditions should be run, given the analysis results from an earlier step.
+returns true if the step should run; if not then the string describes why it is being skipped.
+returns an error if the conditions could not be evaluated, e.g. there are no analysis results or a field or operator is not
+recognised. In this case the boolean is true, so that the step is run rather than silently skipped.
+*/
+func (c *StepConditions) ShouldRun(analysis *FileFormatInfo) (bool, string, error) {
+	if c.IsEmpty() {
+		return true, "", nil
+	}
+	if analysis == nil {
+		return true, "", errors.New("no analysis results are available")
+	}
+
+	fields, fieldsErr := analysisFieldValues(analysis)
+	if fieldsErr != nil {
+		return true, "", fieldsErr
+	}
+
+	for _, cond := range c.RunIf {
+		met, condErr := cond.IsMet(fields)
+		if condErr != nil {
+			return true, "", condErr
+		}
+		if !met {
+			return false, fmt.Sprintf("Skipped as condition '%s' was not met", cond), nil
+		}
+	}
+
+	if len(c.SkipIf) > 0 {
+		descriptions := make([]string, len(c.SkipIf))
+		for i, cond := range c.SkipIf {
+			met, condErr := cond.IsMet(fields)
+			if condErr != nil {
+				return true, "", condErr
+			}
+			if !met {
+				return true, "", nil
+			}
+			descriptions[i] = cond.String()
+		}
+		return false, fmt.Sprintf("Skipped as '%s'", strings.Join(descriptions, "' and '")), nil
+	}
+	return true, "", nil
+}
+
+/**
+tests this condition against the given field values, as returned from analysisFieldValues
+*/
+func (c StepCondition) IsMet(fields map[string]interface{}) (bool, error) {
+	fieldValue, haveField := fields[c.Field]
+	if !haveField {
+		return false, errors.New(fmt.Sprintf("%s is not a field of the analysis results", c.Field))
+	}
+	fieldText := conditionValueText(fieldValue)
+
+	switch c.Operator {
+	case CONDITION_CONTAINS:
+		return strings.Contains(strings.ToLower(fieldText), strings.ToLower(c.Value)), nil
+	case CONDITION_IN:
+		for _, candidate := range strings.Split(c.Value, ",") {
+			if strings.EqualFold(strings.TrimSpace(candidate), fieldText) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case CONDITION_EQ, CONDITION_NE, CONDITION_LT, CONDITION_LE, CONDITION_GT, CONDITION_GE:
+		comparison, compareErr := compareConditionValue(fieldValue, c.Value)
+		if compareErr != nil {
+			return false, compareErr
+		}
+		switch c.Operator {
+		case CONDITION_EQ:
+			return comparison == 0, nil
+		case CONDITION_NE:
+			return comparison != 0, nil
+		case CONDITION_LT:
+			return comparison < 0, nil
+		case CONDITION_LE:
+			return comparison <= 0, nil
+		case CONDITION_GT:
+			return comparison > 0, nil
+		default:
+			return comparison >= 0, nil
+		}
+	default:
+		return false, errors.New(fmt.Sprintf("%s is not a recognised condition operator", c.Operator))
+	}
+}
+
+/**
+gets the analysis results as a map of field name to value, with the field names as they appear in the json.
+the fields of the first video and audio streams are included with "video." and "audio." prefixes, and the loudness
+measurements with a "loudness." prefix.
+numbers are always float64.
+*/
+func analysisFieldValues(analysis *FileFormatInfo) (map[string]interface{}, error) {
+	fields, formatErr := jsonFieldValues(analysis.FormatAnalysis)
+	if formatErr != nil {
+		return nil, formatErr
+	}
+
+	for _, codecType := range []string{"video", "audio"} {
+		stream := analysis.FirstStreamOfType(codecType)
+		if stream == nil {
+			continue
+		}
+		streamFields, streamErr := jsonFieldValues(stream)
+		if streamErr != nil {
+			return nil, streamErr
+		}
+		for k, v := range streamFields {
+			fields[codecType+"."+k] = v
+		}
+	}
+
+	if analysis.Loudness != nil {
+		loudnessFields, loudnessErr := jsonFieldValues(analysis.Loudness)
+		if loudnessErr != nil {
+			return nil, loudnessErr
+		}
+		for k, v := range loudnessFields {
+			fields["loudness."+k] = v
+		}
+	}
+	return fields, nil
+}
+
+func jsonFieldValues(from interface{}) (map[string]interface{}, error) {
+	content, marshalErr := json.Marshal(from)
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	var fields map[string]interface{}
+	unmarshalErr := json.Unmarshal(content, &fields)
+	return fields, unmarshalErr
+}
+
+func conditionValueText(value interface{}) string {
+	if numericValue, isNumeric := value.(float64); isNumeric {
+		return strconv.FormatFloat(numericValue, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+/**
+returns -1, 0 or 1 depending on whether fieldValue is less than, equal to or greater than conditionValue.
+numeric fields are compared numerically, anything else is compared as text
+*/
+func compareConditionValue(fieldValue interface{}, conditionValue string) (int, error) {
+	numericValue, isNumeric := fieldValue.(float64)
+	if !isNumeric {
+		return strings.Compare(conditionValueText(fieldValue), conditionValue), nil
+	}
+
+	parsedValue, parseErr := strconv.ParseFloat(strings.TrimSpace(conditionValue), 64)
+	if parseErr != nil {
+		return 0, errors.New(fmt.Sprintf("%s is not a number so can't be compared with a numeric field", conditionValue))
+	}
+	switch {
+	case numericValue < parsedValue:
+		return -1, nil
+	case numericValue > parsedValue:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
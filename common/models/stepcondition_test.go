@@ -0,0 +1,120 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestStepConditions_ShouldRun(t *testing.T) {
+	proxyFile := &FileFormatInfo{FormatAnalysis: FormatAnalysis{
+		FormatName: "mov,mp4,m4a,3gp,3g2,mj2",
+		Duration:   12.5,
+		BitRate:    2500000,
+	}}
+	masterFile := &FileFormatInfo{FormatAnalysis: FormatAnalysis{
+		FormatName: "mxf",
+		Duration:   12.5,
+		BitRate:    50000000,
+	}}
+
+	skipProxies := &StepConditions{
+		SkipIf: []StepCondition{
+			{Field: "format_name", Operator: CONDITION_CONTAINS, Value: "mp4"},
+			{Field: "bit_rate", Operator: CONDITION_LT, Value: "5000000"},
+		},
+	}
+	shouldRun, reason, err := skipProxies.ShouldRun(proxyFile)
+	if err != nil {
+		t.Errorf("ShouldRun failed unexpectedly: %s", err)
+	}
+	if shouldRun {
+		t.Error("a low-bitrate mp4 should have been skipped")
+	}
+	if reason != "Skipped as 'format_name contains mp4' and 'bit_rate lt 5000000'" {
+		t.Errorf("got unexpected reason '%s'", reason)
+	}
+
+	shouldRun, _, err = skipProxies.ShouldRun(masterFile)
+	if err != nil || !shouldRun {
+		t.Errorf("an mxf file should not have been skipped, got %t %v", shouldRun, err)
+	}
+
+	onlyWithDuration := &StepConditions{
+		RunIf: []StepCondition{{Field: "duration", Operator: CONDITION_GT, Value: "0"}},
+	}
+	shouldRun, _, _ = onlyWithDuration.ShouldRun(masterFile)
+	if !shouldRun {
+		t.Error("file with a duration should have run")
+	}
+	shouldRun, reason, _ = onlyWithDuration.ShouldRun(&FileFormatInfo{FormatAnalysis: FormatAnalysis{FormatName: "png_pipe"}})
+	if shouldRun {
+		t.Error("file with no duration should have been skipped")
+	}
+	if reason != "Skipped as condition 'duration gt 0' was not met" {
+		t.Errorf("got unexpected reason '%s'", reason)
+	}
+
+	//if the conditions can't be evaluated then the step should run
+	shouldRun, _, err = onlyWithDuration.ShouldRun(nil)
+	if err == nil || !shouldRun {
+		t.Errorf("missing analysis should give an error and run the step, got %t %v", shouldRun, err)
+	}
+	badField := &StepConditions{RunIf: []StepCondition{{Field: "codec", Operator: CONDITION_EQ, Value: "h264"}}}
+	shouldRun, _, err = badField.ShouldRun(masterFile)
+	if err == nil || !shouldRun {
+		t.Errorf("unknown field should give an error and run the step, got %t %v", shouldRun, err)
+	}
+
+	var noConditions *StepConditions
+	shouldRun, _, err = noConditions.ShouldRun(nil)
+	if err != nil || !shouldRun {
+		t.Errorf("nil conditions should always run, got %t %v", shouldRun, err)
+	}
+}
+
+func TestStepCondition_IsMet(t *testing.T) {
+	fields, _ := analysisFieldValues(&FileFormatInfo{
+		FormatAnalysis: FormatAnalysis{FormatName: "mxf", BitRate: 5000000, StreamCount: 3},
+		Streams: []StreamAnalysis{
+			{Index: 0, CodecType: "video", CodecName: "mpeg2video", FrameRate: 25, FieldOrder: "tt"},
+			{Index: 1, CodecType: "audio", CodecName: "pcm_s24le", Channels: 1},
+			{Index: 2, CodecType: "audio", CodecName: "pcm_s24le", Channels: 2},
+		},
+		Loudness: &LoudnessAnalysis{Integrated: -27.6, TruePeak: -4.5},
+	})
+
+	tests := []struct {
+		cond     StepCondition
+		expected bool
+	}{
+		{StepCondition{"format_name", CONDITION_EQ, "mxf"}, true},
+		{StepCondition{"format_name", CONDITION_NE, "mxf"}, false},
+		{StepCondition{"format_name", CONDITION_IN, "mov, MXF,avi"}, true},
+		{StepCondition{"bit_rate", CONDITION_IN, "2500000,5000000"}, true},
+		{StepCondition{"bit_rate", CONDITION_LE, "5000000"}, true},
+		{StepCondition{"bit_rate", CONDITION_GE, "5e6"}, true},
+		{StepCondition{"bit_rate", CONDITION_GT, "5000000"}, false},
+		{StepCondition{"nb_streams", CONDITION_EQ, "3"}, true},
+		{StepCondition{"video.frame_rate", CONDITION_EQ, "25"}, true},
+		{StepCondition{"video.field_order", CONDITION_IN, "tt,bb"}, true},
+		{StepCondition{"audio.channels", CONDITION_EQ, "1"}, true},
+		{StepCondition{"loudness.integrated", CONDITION_LT, "-24"}, true},
+		{StepCondition{"loudness.true_peak", CONDITION_GT, "-1"}, false},
+	}
+	for _, test := range tests {
+		result, err := test.cond.IsMet(fields)
+		if err != nil {
+			t.Errorf("'%s' failed unexpectedly: %s", test.cond, err)
+		} else if result != test.expected {
+			t.Errorf("'%s' gave %t, expected %t", test.cond, result, test.expected)
+		}
+	}
+
+	_, opErr := StepCondition{"bit_rate", "between", "1"}.IsMet(fields)
+	if opErr == nil {
+		t.Error("unknown operator should give an error")
+	}
+	_, numErr := StepCondition{"bit_rate", CONDITION_LT, "fast"}.IsMet(fields)
+	if numErr == nil {
+		t.Error("comparing a numeric field with text should give an error")
+	}
+}
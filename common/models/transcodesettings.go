@@ -42,16 +42,29 @@ type AudioSettings struct {
 }
 
 type WrapperSettings struct {
-	Format string `json:"format" yaml:"format"`
+	Format    string             `json:"format" yaml:"format"`
+	Packaging *PackagingSettings `json:"packaging" yaml:"packaging"` //if set, streaming packages are output instead of a single file of Format
+}
+
+/**
+one output of a multi-rendition (ABR ladder) transcode. The name is used to make the output filename and to identify the
+result. If Audio is not set then the audio settings of the parent JobSettings are used.
+*/
+type RenditionSettings struct {
+	Name  string         `json:"name" yaml:"name" mapstructure:"name"`
+	Video VideoSettings  `json:"video" yaml:"video" mapstructure:"video"`
+	Audio *AudioSettings `json:"audio" yaml:"audio" mapstructure:"audio"`
 }
 
 type JobSettings struct {
-	SettingsId  uuid.UUID       `json:"settingsid" yaml:"settingsid" mapstructure:"settingsid"`
-	Name        string          `json:"name" yaml:"name" mapstructure:"name"`
-	Description string          `json:"description" yaml:"description" mapstructure:"description"`
-	Video       VideoSettings   `json:"video" yaml:"video" mapstructure:"video"`
-	Audio       AudioSettings   `json:"audio" yaml:"audio" mapstructure:"audio"`
-	Wrapper     WrapperSettings `json:"wrapper" yaml:"wrapper" mapstructure:"wrapper"`
+	SettingsId  uuid.UUID           `json:"settingsid" yaml:"settingsid" mapstructure:"settingsid"`
+	Name        string              `json:"name" yaml:"name" mapstructure:"name"`
+	Description string              `json:"description" yaml:"description" mapstructure:"description"`
+	Video       VideoSettings       `json:"video" yaml:"video" mapstructure:"video"`
+	Audio       AudioSettings       `json:"audio" yaml:"audio" mapstructure:"audio"`
+	Wrapper     WrapperSettings     `json:"wrapper" yaml:"wrapper" mapstructure:"wrapper"`
+	Renditions  []RenditionSettings `json:"renditions" yaml:"renditions" mapstructure:"renditions"` //if set, one output is made for each of these instead of using Video
+	Loudness    *LoudnessSettings   `json:"loudness" yaml:"loudness" mapstructure:"loudness"`       //if set, the audio is normalised to this loudness
 }
 
 type JobSettingsSummary struct {
@@ -137,17 +150,42 @@ func (s JobSettings) MarshalToString() string {
 	settingsArray := []string{
 		s.Video.MarshalToString(),
 		s.Audio.MarshalToString(),
-		s.Wrapper.MarshalToString(),
 	}
+	if s.Loudness != nil {
+		settingsArray = append(settingsArray, strings.Join(s.Loudness.MarshalToArray(), " "))
+	}
+	settingsArray = append(settingsArray, s.Wrapper.MarshalToString())
 	return strings.Join(settingsArray, " ")
 }
 
 func (s JobSettings) MarshalToArray() []string {
-	result := append(s.Video.MarshalToArray(), s.Audio.MarshalToArray()...)
+	result := append(s.Video.MarshalToArray(), s.audioToArray(s.Audio)...)
 	result = append(result, s.Wrapper.MarshalToArray()...)
 	return result
 }
 
+/**
+returns the ffmpeg options for the given audio settings, followed by the loudness normalisation if there is any
+*/
+func (s JobSettings) audioToArray(audio AudioSettings) []string {
+	result := audio.MarshalToArray()
+	if s.Loudness != nil {
+		result = append(result, s.Loudness.MarshalToArray()...)
+	}
+	return result
+}
+
+func (s JobSettings) LoudnessNormalisation() *LoudnessSettings {
+	return s.Loudness
+}
+
+func (s JobSettings) WithMeasuredLoudness(measured *LoudnessAnalysis) TranscodeTypeSettings {
+	if s.Loudness != nil {
+		s.Loudness = s.Loudness.WithMeasured(measured)
+	}
+	return s
+}
+
 func (s JobSettings) Summarise() JobSettingsSummary {
 	return JobSettingsSummary{
 		SettingsId:  s.SettingsId,
@@ -165,5 +203,62 @@ func (s JobSettings) InternalMarshalJSON() ([]byte, error) {
 }
 
 func (s JobSettings) IsValid() bool {
-	return s.Wrapper.Format != "" //so long as we have a wrapper format the settings can be used
+	if s.Wrapper.Packaging != nil {
+		//packaging is not supported for renditions yet, as the outputs would need combining into a single manifest
+		return s.Wrapper.Packaging.IsValid() && !s.HasRenditions() && s.loudnessIsValid()
+	}
+	if s.Wrapper.Format == "" || !s.loudnessIsValid() {
+		return false
+	}
+	//each rendition is written to a file named after it, so the names must be distinct and safe to use in a file name
+	seenNames := make(map[string]bool, len(s.Renditions))
+	for _, r := range s.Renditions {
+		if !renditionNameMatcher.MatchString(r.Name) || seenNames[r.Name] {
+			return false
+		}
+		seenNames[r.Name] = true
+	}
+	return true
+}
+
+func (s JobSettings) loudnessIsValid() bool {
+	return s.Loudness == nil || s.Loudness.IsValid()
+}
+
+/**
+returns true if these settings produce streaming packages rather than a single output
+*/
+func (s JobSettings) IsPackaged() bool {
+	return s.Wrapper.Packaging != nil
+}
+
+/**
+returns the ffmpeg output options to write the given package format into outDir. The manifest path must follow them.
+*/
+func (s JobSettings) PackageToArray(format string, outDir string) []string {
+	result := append(s.Video.MarshalToArray(), s.audioToArray(s.Audio)...)
+	return append(result, s.Wrapper.Packaging.MuxerArgs(format, outDir)...)
+}
+
+/**
+returns true if these settings produce several renditions rather than a single output
+*/
+func (s JobSettings) HasRenditions() bool {
+	return len(s.Renditions) > 0
+}
+
+/**
+returns the ffmpeg output options for the given rendition. These select the first video stream and (if present) the first
+audio stream of the input, so several renditions can be output from the same command. The output filename must follow them.
+*/
+func (s JobSettings) RenditionToArray(r RenditionSettings) []string {
+	audio := s.Audio
+	if r.Audio != nil {
+		audio = *r.Audio
+	}
+	result := []string{"-map", "0:v:0", "-map", "0:a:0?"}
+	result = append(result, r.Video.MarshalToArray()...)
+	result = append(result, s.audioToArray(audio)...)
+	result = append(result, s.Wrapper.MarshalToArray()...)
+	return result
 }
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+const storedSettingsKey = "mediaflipper:transcodesettings:current"
+
+func keyForSettingsHistory(id uuid.UUID) string {
+	return fmt.Sprintf("mediaflipper:transcodesettings:history:%s", id.String())
+}
+
+func keyForSettingsVersion(id uuid.UUID) string {
+	return fmt.Sprintf("mediaflipper:transcodesettings:version:%s", id.String())
+}
+
+/**
+one version of a set of transcode settings that was made through the API. The settings are kept in their generic form
+so that any type of settings can be stored, use Decode to get them back.
+when settings are deleted a final version is recorded with Deleted set and no settings
+*/
+type StoredTranscodeSettings struct {
+	SettingsId uuid.UUID              `json:"settingsId"`
+	Version    int64                  `json:"version"`
+	Settings   map[string]interface{} `json:"settings"`
+	UpdatedAt  time.Time              `json:"updatedAt"`
+	Deleted    bool                   `json:"deleted"`
+}
+
+func (s StoredTranscodeSettings) Decode() (TranscodeTypeSettings, error) {
+	return TranscodeSettingsFromMap(s.Settings)
+}
+
+func settingsToMap(settings TranscodeTypeSettings) (map[string]interface{}, error) {
+	content, marshalErr := settings.InternalMarshalJSON()
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	var rtn map[string]interface{}
+	unmarshalErr := json.Unmarshal(content, &rtn)
+	return rtn, unmarshalErr
+}
+
+/**
+save a new version of the given settings. The version number is worked out here, and the saved record is returned
+*/
+func PutStoredTranscodeSettings(settings TranscodeTypeSettings, redisClient redis.Cmdable) (*StoredTranscodeSettings, error) {
+	settingsMap, mapErr := settingsToMap(settings)
+	if mapErr != nil {
+		log.Printf("Could not format settings %s: %s", settings.GetId(), mapErr)
+		return nil, mapErr
+	}
+	return putStoredSettingsVersion(settings.GetId(), settingsMap, redisClient)
+}
+
+func putStoredSettingsVersion(settingsId uuid.UUID, settingsMap map[string]interface{}, redisClient redis.Cmdable) (*StoredTranscodeSettings, error) {
+	//INCR is atomic, so two saves at once can't get the same version
+	version, versionErr := redisClient.Incr(keyForSettingsVersion(settingsId)).Result()
+	if versionErr != nil {
+		log.Printf("Could not get a new version number for settings %s: %s", settingsId, versionErr)
+		return nil, versionErr
+	}
+
+	record := StoredTranscodeSettings{
+		SettingsId: settingsId,
+		Version:    version,
+		Settings:   settingsMap,
+		UpdatedAt:  time.Now(),
+		Deleted:    settingsMap == nil,
+	}
+	encoded, encodeErr := json.Marshal(record)
+	if encodeErr != nil {
+		log.Printf("Could not format settings record %s: %s", settingsId, encodeErr)
+		return nil, encodeErr
+	}
+
+	pipe := redisClient.TxPipeline()
+	pipe.RPush(keyForSettingsHistory(settingsId), string(encoded))
+	if record.Deleted {
+		pipe.HDel(storedSettingsKey, settingsId.String())
+	} else {
+		pipe.HSet(storedSettingsKey, settingsId.String(), string(encoded))
+	}
+	_, execErr := pipe.Exec()
+	if execErr != nil {
+		log.Printf("Could not save settings %s to the datastore: %s", settingsId, execErr)
+		return nil, execErr
+	}
+	return &record, nil
+}
+
+/**
+remove the given settings. Their history is kept, with a final version that records the deletion
+*/
+func RemoveStoredTranscodeSettings(settingsId uuid.UUID, redisClient redis.Cmdable) error {
+	_, err := putStoredSettingsVersion(settingsId, nil, redisClient)
+	return err
+}
+
+/**
+retrieve the current version of the given settings. Returns nil, nil if there are no settings with that id
+*/
+func GetStoredTranscodeSettings(settingsId uuid.UUID, redisClient redis.Cmdable) (*StoredTranscodeSettings, error) {
+	content, getErr := redisClient.HGet(storedSettingsKey, settingsId.String()).Result()
+	if getErr == redis.Nil {
+		return nil, nil
+	} else if getErr != nil {
+		log.Printf("Could not retrieve settings %s: %s", settingsId, getErr)
+		return nil, getErr
+	}
+
+	var record StoredTranscodeSettings
+	unmarshalErr := json.Unmarshal([]byte(content), &record)
+	if unmarshalErr != nil {
+		log.Printf("Corrupted settings in the datastore for %s: %s", settingsId, unmarshalErr)
+		return nil, unmarshalErr
+	}
+	return &record, nil
+}
+
+/**
+retrieve the current version of every stored setting
+*/
+func ListStoredTranscodeSettings(redisClient redis.Cmdable) ([]StoredTranscodeSettings, error) {
+	allContent, getErr := redisClient.HGetAll(storedSettingsKey).Result()
+	if getErr != nil {
+		log.Printf("Could not list stored settings: %s", getErr)
+		return nil, getErr
+	}
+
+	rtn := make([]StoredTranscodeSettings, 0, len(allContent))
+	for idString, content := range allContent {
+		var record StoredTranscodeSettings
+		unmarshalErr := json.Unmarshal([]byte(content), &record)
+		if unmarshalErr != nil {
+			log.Printf("Corrupted settings in the datastore for %s: %s", idString, unmarshalErr)
+			continue
+		}
+		rtn = append(rtn, record)
+	}
+	return rtn, nil
+}
+
+/**
+retrieve every version of the given settings, oldest first
+*/
+func StoredTranscodeSettingsHistory(settingsId uuid.UUID, redisClient redis.Cmdable) ([]StoredTranscodeSettings, error) {
+	allContent, getErr := redisClient.LRange(keyForSettingsHistory(settingsId), 0, -1).Result()
+	if getErr != nil {
+		log.Printf("Could not retrieve the history of settings %s: %s", settingsId, getErr)
+		return nil, getErr
+	}
+
+	rtn := make([]StoredTranscodeSettings, 0, len(allContent))
+	for i, content := range allContent {
+		var record StoredTranscodeSettings
+		unmarshalErr := json.Unmarshal([]byte(content), &record)
+		if unmarshalErr != nil {
+			log.Printf("Corrupted version %d in the history of settings %s: %s", i, settingsId, unmarshalErr)
+			continue
+		}
+		rtn = append(rtn, record)
+	}
+	return rtn, nil
+}
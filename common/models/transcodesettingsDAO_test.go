@@ -0,0 +1,192 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+)
+
+const testStoredSettings = `{
+	"settingsid": "B1E0F5C2-3D4A-4E6B-8C7D-9A0B1C2D3E4F",
+	"name": "lowres",
+	"description": "small mp4 for testing",
+	"wrapper": {"format": "mp4"},
+	"video": {"codec": "h264", "bitrate": 500000, "preset": "fast", "scale": {"scalex": 640, "scaley": -2}},
+	"audio": {"codec": "aac", "bitrate": 96000, "channels": 2, "samplerate": 44100}
+}`
+
+func decodeTestSettings(t *testing.T, content string) TranscodeTypeSettings {
+	var raw map[string]interface{}
+	if unmarshalErr := json.Unmarshal([]byte(content), &raw); unmarshalErr != nil {
+		t.Fatalf("test data is not valid json: %s", unmarshalErr)
+	}
+	settings, decodeErr := TranscodeSettingsFromMap(raw)
+	if decodeErr != nil {
+		t.Fatalf("could not decode test settings: %s", decodeErr)
+	}
+	return settings
+}
+
+func TestTranscodeSettingsManagerDatastore(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	mgr, loadErr := NewTranscodeSettingsManager("../../webapp/config/settings")
+	if loadErr != nil {
+		t.Fatalf("Couldn't initialise: %s", loadErr)
+	}
+	mgr.UseDatastore(testClient)
+
+	settings := decodeTestSettings(t, testStoredSettings)
+	settingsId := settings.GetId()
+
+	firstVersion, saveErr := mgr.SaveSetting(settings)
+	if saveErr != nil {
+		t.Fatalf("SaveSetting failed: %s", saveErr)
+	}
+	if firstVersion.Version != 1 {
+		t.Errorf("expected first version to be 1, got %d", firstVersion.Version)
+	}
+
+	jobSettings := settings.(JobSettings)
+	jobSettings.Video.Bitrate = 750000
+	secondVersion, saveErr := mgr.SaveSetting(jobSettings)
+	if saveErr != nil {
+		t.Fatalf("second SaveSetting failed: %s", saveErr)
+	}
+	if secondVersion.Version != 2 {
+		t.Errorf("expected second version to be 2, got %d", secondVersion.Version)
+	}
+
+	current := mgr.GetSetting(settingsId)
+	if current == nil {
+		t.Fatal("GetSetting did not find the stored settings")
+	}
+	if current.(JobSettings).Video.Bitrate != 750000 {
+		t.Errorf("GetSetting returned an old version, bitrate is %d", current.(JobSettings).Video.Bitrate)
+	}
+	if mgr.IsReadOnly(settingsId) {
+		t.Error("stored settings should not be read-only")
+	}
+
+	allSettings := mgr.ListSettings()
+	if len(*allSettings) != len(mgr.knownSettings)+1 {
+		t.Errorf("expected %d settings from ListSettings, got %d", len(mgr.knownSettings)+1, len(*allSettings))
+	}
+
+	deleteErr := mgr.DeleteSetting(settingsId)
+	if deleteErr != nil {
+		t.Fatalf("DeleteSetting failed: %s", deleteErr)
+	}
+	if mgr.GetSetting(settingsId) != nil {
+		t.Error("settings were still returned after being deleted")
+	}
+
+	history, historyErr := mgr.SettingHistory(settingsId)
+	if historyErr != nil {
+		t.Fatalf("SettingHistory failed: %s", historyErr)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected 3 versions in the history, got %d", len(history))
+	}
+	for i, record := range history {
+		if record.Version != int64(i+1) {
+			t.Errorf("history entry %d has version %d", i, record.Version)
+		}
+	}
+	if history[0].Deleted || history[1].Deleted || !history[2].Deleted {
+		t.Error("only the last version in the history should be marked as deleted")
+	}
+	oldVersion, decodeErr := history[0].Decode()
+	if decodeErr != nil {
+		t.Errorf("could not decode the first version: %s", decodeErr)
+	} else if oldVersion.(JobSettings).Video.Bitrate != 500000 {
+		t.Errorf("first version has the wrong bitrate: %d", oldVersion.(JobSettings).Video.Bitrate)
+	}
+}
+
+func TestTranscodeSettingsManagerPresetsReadOnly(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	mgr, loadErr := NewTranscodeSettingsManager("../../webapp/config/settings")
+	if loadErr != nil {
+		t.Fatalf("Couldn't initialise: %s", loadErr)
+	}
+	mgr.UseDatastore(testClient)
+
+	presetId := uuid.MustParse("5C7B1E94-2D6A-4F83-A0B9-8E3D6C1F7A24")
+	if !mgr.IsReadOnly(presetId) {
+		t.Error("presets should be read-only")
+	}
+	_, saveErr := mgr.SaveSetting(mgr.GetSetting(presetId))
+	if saveErr == nil {
+		t.Error("saving over a preset should fail")
+	}
+	if deleteErr := mgr.DeleteSetting(presetId); deleteErr == nil {
+		t.Error("removing a preset should fail")
+	}
+	if len(s.Keys()) != 0 {
+		t.Errorf("nothing should have been written to the datastore, got %v", s.Keys())
+	}
+}
+
+func TestValidateTranscodeSettings(t *testing.T) {
+	good := decodeTestSettings(t, testStoredSettings)
+	if validationErr := ValidateTranscodeSettings(good); validationErr != nil {
+		t.Errorf("valid settings were rejected: %s", validationErr)
+	}
+
+	bad := good.(JobSettings)
+	bad.Wrapper.Format = "-f mp4"
+	bad.Video.CRF = 60
+	bad.Audio.Channels = 0
+	validationErr := ValidateTranscodeSettings(bad)
+	if validationErr == nil {
+		t.Fatal("invalid settings were accepted")
+	}
+	for _, expected := range []string{"format '-f mp4' is not a valid name", "crf must be between 0 and 51", "between 1 and 8 channels"} {
+		if !strings.Contains(validationErr.Error(), expected) {
+			t.Errorf("expected '%s' in the validation error, got %s", expected, validationErr)
+		}
+	}
+
+	unnamed := good.(JobSettings)
+	unnamed.Name = ""
+	if ValidateTranscodeSettings(unnamed) == nil {
+		t.Error("settings without a name were accepted")
+	}
+
+	//rendition names end up in the output file names, so they must not be able to escape the output directory
+	withRenditions := good.(JobSettings)
+	withRenditions.Renditions = []RenditionSettings{
+		{Name: "720p_hd", Video: withRenditions.Video},
+	}
+	if validationErr := ValidateTranscodeSettings(withRenditions); validationErr != nil {
+		t.Errorf("valid rendition name was rejected: %s", validationErr)
+	}
+	for _, badName := range []string{"../../../x", "sub/dir", "720p.mp4", ""} {
+		withRenditions.Renditions[0].Name = badName
+		validationErr := ValidateTranscodeSettings(withRenditions)
+		if validationErr == nil || !strings.Contains(validationErr.Error(), "rendition name") {
+			t.Errorf("rendition name '%s' should have been rejected, got %v", badName, validationErr)
+		}
+	}
+}
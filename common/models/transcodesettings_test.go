@@ -121,3 +121,73 @@ func TestTranscodeSettingsMarshal(t *testing.T) {
 		}
 	}
 }
+
+/**
+test that a rendition ladder loads from yaml and produces the output options for each rendition
+*/
+func TestJobSettings_Renditions(t *testing.T) {
+	yamlData :=
+		`- settingsid: "4E1DAF3C-5A33-4C4B-9E3B-1E0B5F0A8C21"
+  name: abrladder
+  description: test ladder
+  wrapper:
+    format: mp4
+  audio:
+    codec: aac
+    bitrate: 128000
+    channels: 2
+    samplerate: 48000
+  renditions:
+    - name: 720p
+      video:
+        codec: h264
+        bitrate: 3000000
+        scale:
+          scalex: -2
+          scaley: 720
+    - name: 360p
+      video:
+        codec: h264
+        bitrate: 800000
+      audio:
+        codec: aac
+        bitrate: 64000
+        channels: 2
+        samplerate: 48000
+`
+	var settings []JobSettings
+	err := yaml.Unmarshal([]byte(yamlData), &settings)
+	if err != nil {
+		t.Errorf("Could not unmarshal content from yaml: %s", err)
+		t.FailNow()
+	}
+
+	ladder := settings[0]
+	if !ladder.HasRenditions() || len(ladder.Renditions) != 2 {
+		t.Errorf("Expected 2 renditions, got %d", len(ladder.Renditions))
+		t.FailNow()
+	}
+	if !ladder.IsValid() {
+		t.Error("ladder settings should be valid")
+	}
+
+	hdArgs := strings.Join(ladder.RenditionToArray(ladder.Renditions[0]), " ")
+	expectedHd := "-map 0:v:0 -map 0:a:0? -vcodec h264 -b:v 3000000 -vf scale='min(-2,iw):min(720,ih)' -acodec aac -b:a 128000 -ac 2 -ar 48000 -f mp4"
+	if hdArgs != expectedHd {
+		t.Errorf("Got wrong options for 720p, expected '%s' got '%s'", expectedHd, hdArgs)
+	}
+	sdArgs := strings.Join(ladder.RenditionToArray(ladder.Renditions[1]), " ")
+	if !strings.Contains(sdArgs, "-b:a 64000") {
+		t.Errorf("360p rendition should use its own audio settings, got '%s'", sdArgs)
+	}
+
+	ladder.Renditions[1].Name = "720p"
+	if ladder.IsValid() {
+		t.Error("settings with duplicate rendition names should not be valid")
+	}
+
+	ladder.Renditions[1].Name = "../../../360p"
+	if ladder.IsValid() {
+		t.Error("settings with a rendition name that is a path should not be valid")
+	}
+}
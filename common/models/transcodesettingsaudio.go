@@ -0,0 +1,82 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"strings"
+)
+
+/**
+settings for an audio-only transcode, e.g. an mp3 proxy of a radio programme. Any video in the source is dropped.
+These are told apart from JobSettings by having the format at the top level rather than in a wrapper section.
+*/
+type TranscodeAudioSettings struct {
+	SettingsId  uuid.UUID         `json:"settingsid" yaml:"settingsid" mapstructure:"settingsid"`
+	Name        string            `json:"name" yaml:"name" mapstructure:"name"`
+	Description string            `json:"description" yaml:"description" mapstructure:"description"`
+	Audio       AudioSettings     `json:"audio" yaml:"audio" mapstructure:"audio"`
+	Format      string            `json:"format" yaml:"format" mapstructure:"format"`       //ffmpeg output format, e.g. mp3, adts (for aac) or ipod (for m4a)
+	Loudness    *LoudnessSettings `json:"loudness" yaml:"loudness" mapstructure:"loudness"` //if set, the audio is normalised to this loudness
+}
+
+/**
+output formats whose usual file extension is not the same as the ffmpeg format name
+*/
+var audioFormatExtensions = map[string]string{
+	"adts": "aac",
+	"ipod": "m4a",
+	"ogg":  "ogg",
+	"opus": "opus",
+	"wav":  "wav",
+}
+
+func (s TranscodeAudioSettings) MarshalToArray() []string {
+	result := []string{"-vn"}
+	result = append(result, s.Audio.MarshalToArray()...)
+	if s.Loudness != nil {
+		result = append(result, s.Loudness.MarshalToArray()...)
+	}
+	return append(result, "-f", s.Format)
+}
+
+func (s TranscodeAudioSettings) MarshalToString() string {
+	return strings.Join(s.MarshalToArray(), " ")
+}
+
+func (s TranscodeAudioSettings) GetId() uuid.UUID {
+	return s.SettingsId
+}
+
+func (s TranscodeAudioSettings) Summarise() JobSettingsSummary {
+	return JobSettingsSummary{
+		SettingsId:  s.SettingsId,
+		Name:        s.Name,
+		Description: s.Description,
+	}
+}
+
+func (s TranscodeAudioSettings) InternalMarshalJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+func (s TranscodeAudioSettings) IsValid() bool {
+	return s.Format != "" && s.Audio.Codec != "" && (s.Loudness == nil || s.Loudness.IsValid())
+}
+
+func (s TranscodeAudioSettings) LoudnessNormalisation() *LoudnessSettings {
+	return s.Loudness
+}
+
+func (s TranscodeAudioSettings) WithMeasuredLoudness(measured *LoudnessAnalysis) TranscodeTypeSettings {
+	if s.Loudness != nil {
+		s.Loudness = s.Loudness.WithMeasured(measured)
+	}
+	return s
+}
+
+func (s TranscodeAudioSettings) GetLikelyExtension() string {
+	if xtn, haveXtn := audioFormatExtensions[s.Format]; haveXtn {
+		return xtn
+	}
+	return s.Format
+}
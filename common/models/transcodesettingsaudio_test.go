@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTranscodeAudioSettings_MarshalToArray(t *testing.T) {
+	settings := TranscodeAudioSettings{
+		Name:     "aacproxy",
+		Audio:    AudioSettings{Codec: "aac", Bitrate: 128000, Channels: 2, Samplerate: 48000},
+		Format:   "adts",
+		Loudness: &LoudnessSettings{Target: -23},
+	}
+	result := settings.MarshalToString()
+	expected := "-vn -acodec aac -b:a 128000 -ac 2 -ar 48000 -af loudnorm=I=-23:TP=-2:LRA=7 -f adts"
+	if result != expected {
+		t.Errorf("got wrong options, expected '%s' got '%s'", expected, result)
+	}
+	if settings.GetLikelyExtension() != "aac" {
+		t.Errorf("expected aac extension for adts format, got %s", settings.GetLikelyExtension())
+	}
+
+	settings.Loudness = nil
+	settings.Format = "mp3"
+	if strings.Contains(settings.MarshalToString(), "loudnorm") {
+		t.Errorf("loudness should not be changed without a target, got '%s'", settings.MarshalToString())
+	}
+	if settings.GetLikelyExtension() != "mp3" {
+		t.Errorf("expected mp3 extension, got %s", settings.GetLikelyExtension())
+	}
+}
+
+/**
+audio settings and video settings must not be mistaken for each other when they are decoded
+*/
+func TestTranscodeAudioSettings_IsValid(t *testing.T) {
+	var videoAsAudio TranscodeAudioSettings
+	json.Unmarshal([]byte(`{"name":"mp4","wrapper":{"format":"mp4"},"audio":{"codec":"aac"},"video":{"codec":"h264"}}`), &videoAsAudio)
+	if videoAsAudio.IsValid() {
+		t.Error("video settings should not be valid as audio settings")
+	}
+
+	var audioAsVideo JobSettings
+	json.Unmarshal([]byte(`{"name":"mp3","format":"mp3","audio":{"codec":"libmp3lame"}}`), &audioAsVideo)
+	if audioAsVideo.IsValid() {
+		t.Error("audio settings should not be valid as video settings")
+	}
+}
@@ -2,16 +2,25 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"github.com/davecgh/go-spew/spew"
+	"github.com/go-redis/redis/v7"
 	"github.com/google/uuid"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"log"
 	"os"
+	"sync"
 )
 
+/**
+keeps track of the transcode settings. The presets from the settings directory are read-only; if a datastore is in use
+then settings made through the API are kept there alongside them
+*/
 type TranscodeSettingsManager struct {
-	knownSettings map[uuid.UUID]TranscodeTypeSettings
+	knownSettings map[uuid.UUID]TranscodeTypeSettings //the presets from the settings directory
+	redisClient   redis.Cmdable                       //nil if there is no datastore for stored settings
+	lock          sync.RWMutex                        //guards knownSettings, which is replaced when the settings directory is reloaded
 }
 
 func attemptUnmarshalJobSettings(from map[string]interface{}) (TranscodeTypeSettings, error) {
@@ -20,6 +29,12 @@ func attemptUnmarshalJobSettings(from map[string]interface{}) (TranscodeTypeSett
 	return result, marshalErr
 }
 
+func attemptUnmarshalAudioSettings(from map[string]interface{}) (TranscodeTypeSettings, error) {
+	var result TranscodeAudioSettings
+	marshalErr := CustomisedMapStructureDecode(from, &result)
+	return result, marshalErr
+}
+
 func attemptUnmarshalImageSettings(from map[string]interface{}) (TranscodeTypeSettings, error) {
 	var result TranscodeImageSettings
 	marshalErr := CustomisedMapStructureDecode(from, &result)
@@ -43,29 +58,49 @@ func loadSettingsFromFile(fileName string) ([]TranscodeTypeSettings, error) {
 		return nil, extractErr
 	}
 
-	rtn := make([]TranscodeTypeSettings, len(settingsList))
-
+	rtn := make([]TranscodeTypeSettings, 0, len(settingsList))
 	for i, rawSetting := range settingsList {
-		imageSetting, isErr := attemptUnmarshalImageSettings(rawSetting)
-		if isErr == nil && imageSetting.IsValid() {
-			log.Printf("got image settings: %s", spew.Sdump(imageSetting))
-			rtn[i] = imageSetting
+		setting, decodeErr := TranscodeSettingsFromMap(rawSetting)
+		if decodeErr != nil {
+			log.Printf("could not read in setting %d from %s: %s", i, fileName, decodeErr)
 			continue
-		} else {
-			log.Printf("could not read in setting %d from %s as image: %s", i, fileName, isErr)
 		}
+		rtn = append(rtn, setting)
+	}
+	return rtn, nil
+}
 
-		jobSetting, jsErr := attemptUnmarshalJobSettings(rawSetting)
-		if jsErr == nil && jobSetting.IsValid() {
-			log.Printf("got transcode settings: %s", spew.Sdump(jobSetting))
-			rtn[i] = jobSetting
-			continue
-		} else {
-			log.Printf("could not read in setting %d from %s as job: %s", i, fileName, jsErr)
-		}
+/**
+works out which type of settings the given data is for and decodes it. The types are tried in turn and the first one that
+gives valid settings is used; returns an error if none of them do
+*/
+func TranscodeSettingsFromMap(rawSetting map[string]interface{}) (TranscodeTypeSettings, error) {
+	imageSetting, isErr := attemptUnmarshalImageSettings(rawSetting)
+	if isErr == nil && imageSetting.IsValid() {
+		log.Printf("got image settings: %s", spew.Sdump(imageSetting))
+		return imageSetting, nil
+	}
 
+	jobSetting, jsErr := attemptUnmarshalJobSettings(rawSetting)
+	if jsErr == nil && jobSetting.IsValid() {
+		log.Printf("got transcode settings: %s", spew.Sdump(jobSetting))
+		return jobSetting, nil
 	}
-	return rtn, nil
+
+	audioSetting, asErr := attemptUnmarshalAudioSettings(rawSetting)
+	if asErr == nil && audioSetting.IsValid() {
+		log.Printf("got audio settings: %s", spew.Sdump(audioSetting))
+		return audioSetting, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("the data is not valid image (%s), job (%s) or audio (%s) settings", errOrInvalid(isErr), errOrInvalid(jsErr), errOrInvalid(asErr)))
+}
+
+func errOrInvalid(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "invalid"
 }
 
 /**
@@ -104,36 +139,144 @@ func NewTranscodeSettingsManager(forPath string) (*TranscodeSettingsManager, err
 	return &mgr, nil
 }
 
+/**
+keep settings that are made through the API in the given datastore, as well as the presets from the settings directory
+*/
+func (mgr *TranscodeSettingsManager) UseDatastore(redisClient redis.Cmdable) {
+	mgr.redisClient = redisClient
+}
+
+/**
+swap in the presets from another manager, i.e. one that has just been loaded from the changed settings directory. Stored
+settings are not affected
+*/
+func (mgr *TranscodeSettingsManager) ReplacePresets(from *TranscodeSettingsManager) {
+	from.lock.RLock()
+	newSettings := from.knownSettings
+	from.lock.RUnlock()
+
+	mgr.lock.Lock()
+	mgr.knownSettings = newSettings
+	mgr.lock.Unlock()
+}
+
+/**
+returns the given preset, and false if there is no preset with that id
+*/
+func (mgr *TranscodeSettingsManager) preset(forId uuid.UUID) (TranscodeTypeSettings, bool) {
+	mgr.lock.RLock()
+	defer mgr.lock.RUnlock()
+	result, gotIt := mgr.knownSettings[forId]
+	return result, gotIt
+}
+
+/**
+returns true if the given settings are one of the presets from the settings directory, which can't be changed through
+the API
+*/
+func (mgr *TranscodeSettingsManager) IsReadOnly(forId uuid.UUID) bool {
+	_, isPreset := mgr.preset(forId)
+	return isPreset
+}
+
 /**
 returns a setting for the given ID, or nil if it is not found
 */
 func (mgr *TranscodeSettingsManager) GetSetting(forId uuid.UUID) TranscodeTypeSettings {
-	result, gotIt := mgr.knownSettings[forId]
+	result, gotIt := mgr.preset(forId)
 	if gotIt {
 		return result
-	} else {
+	}
+	if mgr.redisClient == nil {
+		return nil
+	}
+
+	record, getErr := GetStoredTranscodeSettings(forId, mgr.redisClient)
+	if getErr != nil || record == nil {
 		return nil
 	}
+	stored, decodeErr := record.Decode()
+	if decodeErr != nil {
+		log.Printf("ERROR: stored settings %s version %d could not be decoded: %s", forId, record.Version, decodeErr)
+		return nil
+	}
+	return stored
 }
 
 /**
-returns a list of all the known settings
+returns a list of all the known settings, the presets followed by any stored settings
 */
 func (mgr *TranscodeSettingsManager) ListSettings() *[]TranscodeTypeSettings {
-	out := make([]TranscodeTypeSettings, len(mgr.knownSettings))
-	i := 0
+	mgr.lock.RLock()
+	out := make([]TranscodeTypeSettings, 0, len(mgr.knownSettings))
 	for _, s := range mgr.knownSettings {
-		out[i] = s
-		i += 1
+		out = append(out, s)
+	}
+	mgr.lock.RUnlock()
+	if mgr.redisClient == nil {
+		return &out
+	}
+
+	records, listErr := ListStoredTranscodeSettings(mgr.redisClient)
+	if listErr != nil {
+		log.Printf("ERROR: could not list stored settings, only presets will be returned: %s", listErr)
+		return &out
+	}
+	for _, record := range records {
+		stored, decodeErr := record.Decode()
+		if decodeErr != nil {
+			log.Printf("ERROR: stored settings %s version %d could not be decoded: %s", record.SettingsId, record.Version, decodeErr)
+			continue
+		}
+		out = append(out, stored)
 	}
 	return &out
 }
 
 func (mgr *TranscodeSettingsManager) ListSummary() *[]JobSettingsSummary {
-	out := make([]JobSettingsSummary, len(mgr.knownSettings))
-	i := 0
-	for _, s := range mgr.knownSettings {
+	allSettings := mgr.ListSettings()
+	out := make([]JobSettingsSummary, len(*allSettings))
+	for i, s := range *allSettings {
 		out[i] = s.Summarise()
 	}
 	return &out
 }
+
+/**
+validate and save the given settings as a new version. Presets can't be saved over. Returns the saved record
+*/
+func (mgr *TranscodeSettingsManager) SaveSetting(settings TranscodeTypeSettings) (*StoredTranscodeSettings, error) {
+	if mgr.redisClient == nil {
+		return nil, errors.New("there is no datastore to save settings to")
+	}
+	if validationErr := ValidateTranscodeSettings(settings); validationErr != nil {
+		return nil, validationErr
+	}
+	if mgr.IsReadOnly(settings.GetId()) {
+		return nil, errors.New(fmt.Sprintf("settings %s are a preset and can't be changed", settings.GetId()))
+	}
+	return PutStoredTranscodeSettings(settings, mgr.redisClient)
+}
+
+/**
+remove the given stored settings, keeping their history. Presets can't be removed
+*/
+func (mgr *TranscodeSettingsManager) DeleteSetting(forId uuid.UUID) error {
+	if mgr.redisClient == nil {
+		return errors.New("there is no datastore to delete settings from")
+	}
+	if mgr.IsReadOnly(forId) {
+		return errors.New(fmt.Sprintf("settings %s are a preset and can't be removed", forId))
+	}
+	return RemoveStoredTranscodeSettings(forId, mgr.redisClient)
+}
+
+/**
+returns every version of the given stored settings, oldest first. Presets have no history
+*/
+func (mgr *TranscodeSettingsManager) SettingHistory(forId uuid.UUID) ([]StoredTranscodeSettings, error) {
+	if mgr.redisClient == nil {
+		return []StoredTranscodeSettings{}, nil
+	}
+	return StoredTranscodeSettingsHistory(forId, mgr.redisClient)
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/google/uuid"
+	"reflect"
 	"testing"
 )
 
@@ -13,8 +14,8 @@ func TestNewTranscodeSettingsManager(t *testing.T) {
 		t.FailNow()
 	}
 
-	if len(mgr.knownSettings) != 3 {
-		t.Errorf("Wrong number of loaded settings, expected 3 got %d", len(mgr.knownSettings))
+	if len(mgr.knownSettings) != 7 {
+		t.Errorf("Wrong number of loaded settings, expected 7 got %d", len(mgr.knownSettings))
 	}
 
 	allSettings := mgr.ListSettings()
@@ -27,12 +28,19 @@ func TestNewTranscodeSettingsManager(t *testing.T) {
 		if !isPresent {
 			t.Errorf("setting with id %s was returned from ListSettings but is not present?!", s.GetId())
 		} else {
-			if verifyData != s {
+			if !reflect.DeepEqual(verifyData, s) {
 				t.Errorf("mismatched setting returned from ListSettings, expected %s got %s", spew.Sprint(verifyData), spew.Sprint(s))
 			}
 		}
 	}
 
+	mp3SettingGeneric := mgr.GetSetting(uuid.MustParse("5C7B1E94-2D6A-4F83-A0B9-8E3D6C1F7A24"))
+	if mp3Setting, isAudio := mp3SettingGeneric.(TranscodeAudioSettings); !isAudio {
+		t.Errorf("expected audio settings for the mp3 proxy, got %s", spew.Sprint(mp3SettingGeneric))
+	} else if mp3Setting.Format != "mp3" || mp3Setting.Audio.Codec != "libmp3lame" || mp3Setting.Loudness == nil || mp3Setting.Loudness.Target != -23 {
+		t.Errorf("audio settings were not loaded correctly: %s", spew.Sprint(mp3Setting))
+	}
+
 	mp4SettingGeneric := mgr.GetSetting(uuid.MustParse("7FEC2963-6A1D-46A2-8DE1-62DF939F6755"))
 	if mp4SettingGeneric == nil {
 		t.Errorf("GetSetting returned nil but expected record")
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/**
+codec, format, preset and pixel format names are passed straight to ffmpeg as arguments, so they must look like names
+and not like options
+*/
+var ffmpegNameMatcher = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.\-]*$`)
+
+/**
+rendition names become part of the output file names, so they are kept to characters that can't change the directory
+that the file is written to
+*/
+var renditionNameMatcher = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+type settingsProblems []string
+
+func (p *settingsProblems) add(format string, args ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+func (p *settingsProblems) checkName(what string, value string, required bool) {
+	if value == "" {
+		if required {
+			p.add("%s is required", what)
+		}
+		return
+	}
+	if !ffmpegNameMatcher.MatchString(value) {
+		p.add("%s '%s' is not a valid name", what, value)
+	}
+}
+
+func (p *settingsProblems) checkVideo(what string, v VideoSettings) {
+	p.checkName(what+" codec", v.Codec, true)
+	p.checkName(what+" preset", v.Preset, false)
+	p.checkName(what+" pixel format", v.PixFmt, false)
+	if v.CRF < 0 || v.CRF > 51 {
+		p.add("%s crf must be between 0 and 51", what)
+	}
+	if v.CRF == 0 && v.Bitrate <= 0 {
+		p.add("%s needs a bitrate or a crf", what)
+	}
+	if v.Scale != nil {
+		if v.Scale.ScaleX == 0 || v.Scale.ScaleX < -2 || v.Scale.ScaleY == 0 || v.Scale.ScaleY < -2 {
+			p.add("%s scale must be positive, or -1 or -2 to keep the aspect ratio", what)
+		}
+	}
+}
+
+func (p *settingsProblems) checkAudio(what string, a AudioSettings) {
+	p.checkName(what+" codec", a.Codec, true)
+	if a.Bitrate <= 0 {
+		p.add("%s bitrate must be positive", what)
+	}
+	if a.Channels < 1 || a.Channels > 8 {
+		p.add("%s must have between 1 and 8 channels", what)
+	}
+	if a.Samplerate < 8000 || a.Samplerate > 192000 {
+		p.add("%s sample rate must be between 8000 and 192000", what)
+	}
+}
+
+/**
+checks that the given settings are fit to be saved, over and above IsValid. This is applied to settings that are made
+through the API, and to the presets in the settings directory when the server starts.
+returns nil if the settings are fine or an error that lists everything that is wrong with them
+*/
+func ValidateTranscodeSettings(settings TranscodeTypeSettings) error {
+	if settings == nil {
+		return errors.New("no settings were given")
+	}
+	problems := make(settingsProblems, 0)
+	if !settings.IsValid() {
+		problems.add("the settings are not complete")
+	}
+	if strings.TrimSpace(settings.Summarise().Name) == "" {
+		problems.add("a name is required")
+	}
+
+	switch s := settings.(type) {
+	case JobSettings:
+		if !s.IsPackaged() { //the package formats decide the output format
+			problems.checkName("format", s.Wrapper.Format, true)
+		}
+		if s.HasRenditions() {
+			for _, rendition := range s.Renditions {
+				if !renditionNameMatcher.MatchString(rendition.Name) {
+					problems.add("rendition name '%s' must only contain letters, numbers, - and _", rendition.Name)
+				}
+				problems.checkVideo(fmt.Sprintf("rendition %s video", rendition.Name), rendition.Video)
+				if rendition.Audio != nil {
+					problems.checkAudio(fmt.Sprintf("rendition %s audio", rendition.Name), *rendition.Audio)
+				}
+			}
+		} else {
+			problems.checkVideo("video", s.Video)
+		}
+		problems.checkAudio("audio", s.Audio)
+	case TranscodeAudioSettings:
+		problems.checkName("format", s.Format, true)
+		problems.checkAudio("audio", s.Audio)
+	case TranscodeImageSettings:
+		if s.ScaleX > 10000 || s.ScaleY > 10000 {
+			problems.add("image size can't be more than 10000 pixels in either direction")
+		}
+	default:
+		problems.add("unrecognised type of settings")
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
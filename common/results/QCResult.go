@@ -0,0 +1,11 @@
+package results
+
+import "github.com/guardian/mediaflipper/common/models"
+
+type QCResult struct {
+	MediaFile    string           `json:"mediaFile"` //the file that was checked
+	Duration     float64          `json:"duration"`
+	Events       []models.QCEvent `json:"events"` //severities are filled in when the report is evaluated against the step's settings
+	TimeTaken    float64          `json:"timeTaken"`
+	ErrorMessage string           `json:"errorMessage"`
+}
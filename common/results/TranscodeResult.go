@@ -1,7 +1,31 @@
 package results
 
+import "github.com/guardian/mediaflipper/common/models"
+
+/**
+one output file from a transcode that produces several renditions
+*/
+type RenditionResult struct {
+	Name    string `json:"name"`
+	OutFile string `json:"outFile"`
+}
+
+/**
+one streaming package from a transcode that outputs packages. The segments are in the same directory as the manifest
+*/
+type PackageResult struct {
+	Format       string `json:"format"`
+	ManifestFile string `json:"manifestFile"`
+}
+
 type TranscodeResult struct {
-	OutFile      string  `json:"outFile"`
-	TimeTaken    float64 `json:"timeTaken"`
-	ErrorMessage string  `json:"errorMessage"`
+	OutFile        string                   `json:"outFile"`
+	TimeTaken      float64                  `json:"timeTaken"`
+	ErrorMessage   string                   `json:"errorMessage"`
+	Renditions     []RenditionResult        `json:"renditions"`     //only set if the settings had renditions, in which case OutFile is empty
+	Packages       []PackageResult          `json:"packages"`       //only set if the settings had packaging, in which case OutFile is empty
+	SourceLoudness *models.LoudnessAnalysis `json:"sourceLoudness"` //only set if the settings normalised loudness and the input could be measured
+	Quality        *models.QualityMetrics   `json:"quality"`        //only set if quality metrics were asked for
+	Checksums      models.FileChecksums     `json:"checksums"`      //for each output file, or the manifest of each package
+	Provenance     *models.Provenance       `json:"provenance"`     //nil if the transcode could not be started
 }
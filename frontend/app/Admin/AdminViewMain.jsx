@@ -39,6 +39,7 @@ class AdminViewMain extends React.Component {
                     <div className="button-container">
                         <label className="button button-container-entry clickable" onClick={()=>this.triggerPurge("jobrunningqueue")}>Purge running queue</label>
                         <label className="button button-container-entry clickable" onClick={()=>this.triggerPurge("jobrequestqueue")}>Purge request queue</label>
+                        <label className="button button-container-entry clickable" onClick={()=>this.triggerPurge("jobretryqueue")}>Purge retry queue</label>
                     </div>
                     <div className="results-area">
                         <ul className="admin-view-results-list">
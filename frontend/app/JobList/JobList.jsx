@@ -60,7 +60,7 @@ class JobList extends React.Component {
     }
 
     async loadJobTemplateLookup() {
-        const response = await fetch("/api/jobtemplate");
+        const response = await fetch("/api/jobtemplate?includeRetired=true");
         if(response.status===200){
             const data = await response.json();
             const newLookup = data.entries.reduce((acc,ent)=>{
@@ -130,6 +130,14 @@ class JobList extends React.Component {
         }
     }
 
+    //summarises how a step's output was made, with the full command line as a tooltip
+    static describeProvenance(provenance) {
+        if(!provenance) return null;
+        const versions = provenance.toolVersions ? Object.keys(provenance.toolVersions).map(tool=>tool + " " + provenance.toolVersions[tool]) : [];
+        const commandLine = provenance.commandLine ? provenance.commandLine.join(" ") : "";
+        return <span className="job-provenance" title={commandLine}>{versions.join(", ")}{provenance.hostname ? " on " + provenance.hostname : ""}</span>
+    }
+
     renderJobStepDetails(step, idx){
         switch(step.stepType){
             case "analysis":
@@ -162,8 +170,11 @@ class JobList extends React.Component {
                         <JobStatusComponent status={step.jobStepStatus}/><br/>
                         <a href="#" onClick={evt=>{ evt.preventDefault(); this.setState({showLogsFor: step.id})}}>Show logs...</a>
                     </div>
-                    <div className="job-list-entry-cell baseline">Transcode</div>
-                    <div className="job-list-entry-cell baseline"><MediaPreview className="thumbnail-preview" fileId={step.transcodeResult}/></div>
+                    <div className="job-list-entry-cell baseline">Transcode<br/>{JobList.describeProvenance(step.provenance)}</div>
+                    <div className="job-list-entry-cell baseline">
+                        <MediaPreview className="thumbnail-preview" fileId={step.transcodeResult}/>
+                        {step.packageResults ? step.packageResults.map(packageId=><MediaPreview key={packageId} className="thumbnail-preview" fileId={packageId}/>) : null}
+                    </div>
                     <div className="job-list-entry-cell wide">{step.errorMessage}</div>
                 </div>;
             case "custom":
@@ -177,6 +188,17 @@ class JobList extends React.Component {
                     <div className="job-list-entry-cell baseline"/>
                     <div className="job-list-entry-cell wide">{step.errorMessage}</div>
                 </div>;
+            case "qc":
+                return <div className="job-list-container">
+                    <div className="job-list-entry-cell baseline"><FontAwesomeIcon icon="wrench"/>  Step {idx+1}</div>
+                    <div className="job-list-entry-cell baseline">
+                        <JobStatusComponent status={step.jobStepStatus}/><br/>
+                        <a href="#" onClick={evt=>{ evt.preventDefault(); this.setState({showLogsFor: step.id})}}>Show logs...</a>
+                    </div>
+                    <div className="job-list-entry-cell baseline">Quality check ({step.qcSettings && step.qcSettings.input ? step.qcSettings.input : "source"})</div>
+                    <div className="job-list-entry-cell baseline">{step.qcReport ? <a href={"/api/qc/get?forId=" + step.qcReport} target="_blank">Report: {step.qcOutcome}</a> : <span/>}</div>
+                    <div className="job-list-entry-cell wide">{step.errorMessage}</div>
+                </div>;
             default:
                 return <div className="job-list-container"><div className="job-list-entry-cell wide">Unknown job step type {step.stepType}</div></div>
         }
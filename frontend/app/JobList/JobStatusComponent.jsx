@@ -22,6 +22,10 @@ class JobStatusComponent extends React.Component {
                 return <span className={this.props.className}><FontAwesomeIcon icon="eject" className="inline-icon" style={{color:"darkred"}}/>Purged</span>;
             case 5:
                 return <span className={this.props.className}><FontAwesomeIcon icon="hand-paper" className="inline-icon" style={{color:"darkblue"}}/>Not queued</span>;
+            case 6:
+                return <span className={this.props.className}><FontAwesomeIcon icon="exclamation" className="inline-icon" style={{color:"darkred"}}/>Lost</span>;
+            case 7:
+                return <span className={this.props.className}><FontAwesomeIcon icon="minus-circle" className="inline-icon" style={{color:"darkgrey"}}/>Skipped</span>;
             default:
                 return <span className={this.props.className}>Unknown value {this.props.status}</span>;
         }
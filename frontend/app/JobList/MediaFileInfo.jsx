@@ -29,6 +29,34 @@ class MediaFileInfo extends React.Component {
         return null;
     }
 
+    getStreams(){
+        if(this.state.fileInfo && this.state.fileInfo.streams) return this.state.fileInfo.streams;
+        if(this.props.fileInfo && this.props.fileInfo.streams) return this.props.fileInfo.streams;
+        return [];
+    }
+
+    getLoudness(){
+        if(this.state.fileInfo && this.state.fileInfo.loudness) return this.state.fileInfo.loudness;
+        if(this.props.fileInfo && this.props.fileInfo.loudness) return this.props.fileInfo.loudness;
+        return null;
+    }
+
+    static describeStream(stream) {
+        switch(stream.codec_type) {
+            case "video": {
+                const frameRate = stream.frame_rate ? Math.round(stream.frame_rate*100)/100 + "fps" : "unknown frame rate";
+                const scan = stream.field_order && stream.field_order!=="progressive" ? " interlaced (" + stream.field_order + ")" : "";
+                return stream.codec_name + " " + stream.width + "x" + stream.height + " " + frameRate + scan + (stream.pix_fmt ? ", " + stream.pix_fmt : "");
+            }
+            case "audio": {
+                const layout = stream.channel_layout ? stream.channel_layout : stream.channels + " channels";
+                return stream.codec_name + " " + layout + ", " + stream.sample_rate + "Hz" + (stream.language ? ", " + stream.language : "");
+            }
+            default:
+                return stream.codec_name ? stream.codec_name : "unknown codec";
+        }
+    }
+
     componentDidMount() {
         if(!this.props.fileInfo) this.loadData();
     }
@@ -59,6 +87,7 @@ class MediaFileInfo extends React.Component {
         }
 
         const showheader = filedata.format_long_name + ", " + Math.round(filedata.duration) + " seconds";
+        const loudness = this.getLoudness();
         return <HidableExpander headerText={showheader} initialExpanderState={this.props.initialExpanderState}>
             <table className="media-file-info">
                 <tbody>
@@ -86,6 +115,18 @@ class MediaFileInfo extends React.Component {
                     <td className="media-file-info right">Probe score</td>
                     <td className="media-file-info left">{filedata.probe_score}</td>
                 </tr>
+                {
+                    this.getStreams().map(stream=><tr key={stream.index}>
+                        <td className="media-file-info right">Stream {stream.index} ({stream.codec_type})</td>
+                        <td className="media-file-info left">{MediaFileInfo.describeStream(stream)}</td>
+                    </tr>)
+                }
+                {
+                    loudness ? <tr>
+                        <td className="media-file-info right">Loudness</td>
+                        <td className="media-file-info left">{loudness.integrated} LUFS integrated, {loudness.lra} LU range, {loudness.true_peak} dBTP true peak</td>
+                    </tr> : null
+                }
                 </tbody>
             </table>
         </HidableExpander>
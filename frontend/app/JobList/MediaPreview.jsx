@@ -1,6 +1,7 @@
 import React from 'react';
 import PropTypes from 'prop-types';
 import ThumbnailPreview from "./ThumbnailPreview.jsx";
+import StreamPlayer from "./StreamPlayer.jsx";
 
 class MediaPreview extends React.Component {
     static propTypes = {
@@ -54,7 +55,19 @@ class MediaPreview extends React.Component {
     render() {
         if(this.props.fileId && this.props.fileId!=="00000000-0000-0000-0000-000000000000"){
             const contentStreamUrl = "/api/file/content?forId=" + this.props.fileId;
-            if(this.state.fileMeta.hasOwnProperty("mimeType")){
+            //streaming packages are served from a path, so that the player can find the segments relative to the manifest
+            const packageStreamUrl = "/api/file/stream/" + this.props.fileId + "/";
+            if(this.state.fileMeta.type==="stream_hls") {
+                return <span>
+                    <StreamPlayer url={packageStreamUrl} kind="hls" className={this.props.className}/><br/>
+                    <a href={packageStreamUrl}>HLS manifest...</a>
+                </span>
+            } else if(this.state.fileMeta.type==="stream_dash") {
+                return <span>
+                    <StreamPlayer url={packageStreamUrl} kind="dash" className={this.props.className}/><br/>
+                    <a href={packageStreamUrl}>DASH manifest...</a>
+                </span>
+            } else if(this.state.fileMeta.hasOwnProperty("mimeType")){
                 if(this.state.fileMeta.mimeType.startsWith("video/")){
                     return <span>
                         <video src={contentStreamUrl} controls={true} className={this.props.className}/><br/>
@@ -0,0 +1,88 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+import Hls from 'hls.js';
+import dashjs from 'dashjs';
+
+/**
+plays an HLS or DASH streaming package. Browsers can't play these from a plain <video> tag (other than Safari with HLS),
+so the stream is fed to the video element through hls.js or dash.js
+*/
+class StreamPlayer extends React.Component {
+    static propTypes = {
+        url: PropTypes.string.isRequired,
+        kind: PropTypes.oneOf(["hls","dash"]).isRequired,
+        className: PropTypes.string
+    };
+
+    constructor(props) {
+        super(props);
+
+        this.videoRef = React.createRef();
+        this.player = null;
+        this.state = {
+            lastError: null
+        }
+    }
+
+    attachPlayer() {
+        const video = this.videoRef.current;
+        if(!video) return;
+
+        if(this.props.kind==="hls") {
+            if(Hls.isSupported()) {
+                this.player = new Hls();
+                this.player.on(Hls.Events.ERROR, (evt, data)=>{
+                    if(data.fatal) this.setState({lastError: "Could not play stream: " + data.details});
+                });
+                this.player.loadSource(this.props.url);
+                this.player.attachMedia(video);
+            } else if(video.canPlayType("application/vnd.apple.mpegurl")) {
+                video.src = this.props.url;   //Safari plays HLS natively
+            } else {
+                this.setState({lastError: "This browser can't play HLS streams"});
+            }
+        } else {
+            this.player = dashjs.MediaPlayer().create();
+            this.player.on(dashjs.MediaPlayer.events.ERROR, (evt)=>{
+                this.setState({lastError: "Could not play stream: " + (evt.error && evt.error.message ? evt.error.message : evt.error)});
+            });
+            this.player.initialize(video, this.props.url, false);
+        }
+    }
+
+    detachPlayer() {
+        if(this.player) {
+            if(this.props.kind==="hls") {
+                this.player.destroy();
+            } else {
+                this.player.reset();
+            }
+            this.player = null;
+        }
+    }
+
+    componentDidMount() {
+        this.attachPlayer();
+    }
+
+    componentDidUpdate(prevProps, prevState, snapshot) {
+        if(prevProps.url!==this.props.url || prevProps.kind!==this.props.kind) {
+            this.detachPlayer();
+            this.setState({lastError: null});
+            this.attachPlayer();
+        }
+    }
+
+    componentWillUnmount() {
+        this.detachPlayer();
+    }
+
+    render() {
+        return <span>
+            <video ref={this.videoRef} controls={true} className={this.props.className}/>
+            {this.state.lastError ? <p className="error-text">{this.state.lastError}</p> : null}
+        </span>
+    }
+}
+
+export default StreamPlayer;
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"github.com/guardian/mediaflipper/common/models"
 	"io"
 	"log"
 	"math"
@@ -19,19 +21,46 @@ func checkFileExists(filepath string) bool {
 	}
 }
 
-func copyFile(from string, to string) (int64, error) {
+/**
+copies the file, working out the checksums of the source content as it goes
+*/
+func copyFile(from string, to string) (int64, models.Checksums, error) {
 	sourceFile, sourceOpenErr := os.Open(from)
 	if sourceOpenErr != nil {
-		return 0, sourceOpenErr
+		return 0, models.Checksums{}, sourceOpenErr
 	}
 	defer sourceFile.Close()
 	destFile, destOpenErr := os.OpenFile(to, os.O_CREATE|os.O_WRONLY, 0640)
 	if destOpenErr != nil {
-		return 0, destOpenErr
+		return 0, models.Checksums{}, destOpenErr
 	}
 	defer destFile.Close()
 
-	return io.Copy(destFile, sourceFile)
+	checksummer := models.NewChecksumWriter()
+	byteSize, copyErr := io.Copy(io.MultiWriter(destFile, checksummer), sourceFile)
+	if copyErr != nil {
+		return byteSize, models.Checksums{}, copyErr
+	}
+	return byteSize, checksummer.Checksums(), destFile.Sync()
+}
+
+/**
+checks that the content that was read from the source matches the checksums recorded when the file was made, and that
+the content now at the destination matches too. Re-reading the destination catches anything that went wrong on the
+way to disk
+*/
+func verifyCopy(to string, expected models.Checksums, sourceChecksums models.Checksums) error {
+	if !expected.Matches(sourceChecksums) {
+		return errors.New(fmt.Sprintf("the source does not match its recorded checksums (expected sha256 %s, got %s)", expected.SHA256, sourceChecksums.SHA256))
+	}
+	destChecksums, checksumErr := models.ChecksumFile(to)
+	if checksumErr != nil {
+		return checksumErr
+	}
+	if !expected.Matches(destChecksums) {
+		return errors.New(fmt.Sprintf("the copy does not match the recorded checksums (expected sha256 %s, got %s)", expected.SHA256, destChecksums.SHA256))
+	}
+	return nil
 }
 
 func fileSizeFormatter(size int64) string {
@@ -72,13 +101,28 @@ func copierThread(inputCh chan *CopyRequest, waitGroup *sync.WaitGroup) {
 			continue
 		}
 
-		byteSize, copyErr := copyFile(rec.From, rec.To)
+		byteSize, sourceChecksums, copyErr := copyFile(rec.From, rec.To)
 		if copyErr != nil {
 			log.Printf("ERROR copierThread can't copy '%s' to '%s': %s", rec.From, rec.To, copyErr)
 			continue
 		} else {
 			log.Printf("INFO copierThread coped %s: %s", rec.To, fileSizeFormatter(byteSize))
 		}
+
+		if rec.Checksums == nil {
+			log.Printf("WARNING copierThread %s has no recorded checksums, the copy can't be verified", rec.From)
+			continue
+		}
+		verifyErr := verifyCopy(rec.To, *rec.Checksums, sourceChecksums)
+		if verifyErr != nil {
+			log.Printf("ERROR copierThread copy of '%s' to '%s' failed verification: %s", rec.From, rec.To, verifyErr)
+			removeErr := os.Remove(rec.To)
+			if removeErr != nil {
+				log.Printf("ERROR copierThread could not remove bad copy '%s': %s", rec.To, removeErr)
+			}
+		} else {
+			log.Printf("INFO copierThread verified %s", rec.To)
+		}
 	}
 }
 
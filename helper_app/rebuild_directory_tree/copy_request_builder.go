@@ -16,6 +16,7 @@ import (
 type CopyRequest struct {
 	From string
 	To string
+	Checksums *models.Checksums //if set, the copy is verified against these
 }
 
 type GenericResponseContainer struct {
@@ -94,9 +95,14 @@ func AsyncCopyRequestBuilder(inputCh chan *models.JobContainer, baseUrl string,
 			}
 
 			for _, file := range *filesListPtr {
+				if file.FileType == models.TYPE_ORIGINAL {
+					//the original is only registered to record its checksums, it is already in the tree we are rebuilding
+					continue
+				}
 				copyReq := &CopyRequest{
-					From: file.ServerPath,
-					To:   path.Join(desiredDestPath, path.Base(file.ServerPath)),
+					From:      file.ServerPath,
+					To:        path.Join(desiredDestPath, path.Base(file.ServerPath)),
+					Checksums: file.Checksums,
 				}
 				outputCh <- copyReq
 			}
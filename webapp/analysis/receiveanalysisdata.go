@@ -105,6 +105,8 @@ func (h ReceiveData) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			newRecord := models.FileFormatInfo{
 				Id:             analysisStep.ResultId,
 				FormatAnalysis: incoming.Format,
+				Streams:        incoming.Streams,
+				Loudness:       incoming.Loudness,
 			}
 
 			putErr := models.PutFileFormat(&newRecord, h.redisClient)
@@ -118,6 +120,31 @@ func (h ReceiveData) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			if incoming.Loudness != nil {
+				jobContainerInfo.SourceLoudness = incoming.Loudness
+			}
+
+			//record the fixity of the original so that it can be checked when the media is delivered
+			if incoming.Checksums != nil {
+				originalEntry, entryErr := models.NewFileEntry(analysisStep.MediaFile, *jobContainerId, models.TYPE_ORIGINAL)
+				if entryErr != nil {
+					log.Printf("WARNING: Could not get information for original media %s: %s", analysisStep.MediaFile, entryErr)
+				} else {
+					originalEntry.Checksums = incoming.Checksums
+					storErr := originalEntry.Store(h.redisClient)
+					if storErr != nil {
+						log.Printf("Could not store file entry for original media: %s", storErr)
+						helpers.WriteJsonContent(helpers.GenericErrorResponse{
+							Status: "db_error",
+							Detail: "Could not save record",
+						}, w, 500)
+						errorChan <- nil
+						return
+					}
+					analysisStep.OriginalFileId = &originalEntry.Id
+				}
+			}
+
 			updateErr := jobContainerInfo.UpdateStepById(*jobStepId, analysisStep)
 			if updateErr != nil {
 				log.Printf("Could not set jobstep info for %s in job %s: %s", jobStepId, jobContainerId, updateErr)
@@ -17,7 +17,7 @@ import (
 ServeHttp should store the provided data in a new record, return the id and store it against the jobstep
 */
 func TestReceiveData_ServeHTTP(t *testing.T) {
-	mockRequestBody := []byte(`{"successful":true,"format":{"nb_streams":1, "nb_programs":1, "format_name": "test", "format_long_name": "test format name", "duration":12.345}}`)
+	mockRequestBody := []byte(`{"successful":true,"format":{"nb_streams":1, "nb_programs":1, "format_name": "test", "format_long_name": "test format name", "duration":12.345},"streams":[{"index":0,"codec_type":"video","codec_name":"prores","frame_rate":25,"field_order":"progressive"}],"loudness":{"integrated":-27.61,"lra":18.06,"true_peak":-4.47,"threshold":-39.2,"target_offset":0.58}}`)
 	mockBody := helpers.NewMockReadCloser()
 	mockBody.DataToRead = mockRequestBody
 
@@ -114,12 +114,23 @@ func TestReceiveData_ServeHTTP(t *testing.T) {
 				if fileFormatData.FormatAnalysis.Duration != 12.345 {
 					t.Error("saved format information has incorrect duration")
 				}
+				if len(fileFormatData.Streams) != 1 {
+					t.Errorf("expected 1 stream to be saved, got %d", len(fileFormatData.Streams))
+				} else if fileFormatData.Streams[0].CodecName != "prores" || fileFormatData.Streams[0].FrameRate != 25 {
+					t.Errorf("saved stream information was incorrect: %s", spew.Sdump(fileFormatData.Streams[0]))
+				}
+				if fileFormatData.Loudness == nil || fileFormatData.Loudness.Integrated != -27.61 {
+					t.Errorf("saved loudness information was incorrect: %s", spew.Sdump(fileFormatData.Loudness))
+				}
 			}
 
 			updatedJobContainer, getErr := models2.JobContainerForId(jobMasterId, testClient)
 			if getErr != nil {
 				t.Error("Could not retrieve saved job")
 			} else {
+				if updatedJobContainer.SourceLoudness == nil || updatedJobContainer.SourceLoudness.TruePeak != -4.47 {
+					t.Errorf("source loudness was not saved on the job: %s", spew.Sdump(updatedJobContainer.SourceLoudness))
+				}
 				updatedStep := updatedJobContainer.FindStepById(jobStepId)
 				if updatedStep != nil {
 					analysisStep := (*updatedStep).(*models2.JobStepAnalysis)
@@ -221,3 +232,88 @@ func TestReceiveData_ServeHTTP_NoCorrectSteps(t *testing.T) {
 		spew.Dump(jsonContent)
 	}
 }
+
+/*
+ServeHttp should register the original media with the checksums that were worked out by the wrapper
+*/
+func TestReceiveData_ServeHTTP_Checksums(t *testing.T) {
+	mockRequestBody := []byte(`{"successful":true,"format":{"nb_streams":1, "nb_programs":1, "format_name": "test", "format_long_name": "test format name", "duration":12.345},"streams":[],"checksums":{"md5":"5eb63bbbe01eeed093cb22bb8f5acdc3","sha256":"b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"}}`)
+	mockBody := helpers.NewMockReadCloser()
+	mockBody.DataToRead = mockRequestBody
+
+	mockRequest := http.Request{
+		Method:     "POST",
+		RequestURI: "https://myserver.com/api/analysis/result?forJob=E6D1337A-6850-4C15-8938-18907B2FF311&stepId=815206e7-3c09-4e0f-ad87-3a4d67767315",
+		Proto:      "https",
+		ProtoMajor: 1,
+		ProtoMinor: 0,
+		Body:       mockBody,
+	}
+
+	jobMasterId := uuid.MustParse("E6D1337A-6850-4C15-8938-18907B2FF311")
+	jobStepId := uuid.MustParse("815206e7-3c09-4e0f-ad87-3a4d67767315")
+
+	startTime := time.Now()
+
+	fakeJobContainer := models2.JobContainer{
+		Id: jobMasterId,
+		Steps: []models2.JobStep{
+			models2.JobStepAnalysis{
+				JobStepType: "analysis",
+				JobStepId:   jobStepId,
+				MediaFile:   "receiveanalysisdata.go",
+			},
+		},
+		Status:            1,
+		JobTemplateId:     uuid.New(),
+		IncomingMediaFile: "receiveanalysisdata.go",
+		StartTime:         &startTime,
+	}
+
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	defer func() {
+		testClient.Close()
+		s.Close()
+	}()
+
+	fakeJobContainer.Store(testClient)
+
+	toTest := ReceiveData{redisClient: testClient}
+	mockWriter := helpers.NewMockResponseWriter()
+	toTest.ServeHTTP(mockWriter, &mockRequest)
+
+	if mockWriter.State.WrittenStatusCode == nil || *mockWriter.State.WrittenStatusCode != 200 {
+		t.Fatalf("Expected a 200 response, got %s", spew.Sdump(mockWriter.State.WrittenStatusCode))
+	}
+
+	updatedJobContainer, getErr := models2.JobContainerForId(jobMasterId, testClient)
+	if getErr != nil {
+		t.Fatal("Could not retrieve saved job: ", getErr)
+	}
+	analysisStep := (*updatedJobContainer.FindStepById(jobStepId)).(*models2.JobStepAnalysis)
+	if analysisStep.OriginalFileId == nil {
+		t.Fatal("the original media was not registered against the step")
+	}
+
+	originalEntry, entryErr := models2.FileEntryForId(*analysisStep.OriginalFileId, testClient)
+	if entryErr != nil {
+		t.Fatal("Could not retrieve the entry for the original media: ", entryErr)
+	}
+	if originalEntry.FileType != models2.TYPE_ORIGINAL {
+		t.Errorf("expected the entry to be %s, got %s", models2.TYPE_ORIGINAL, originalEntry.FileType)
+	}
+	if originalEntry.ServerPath != "receiveanalysisdata.go" {
+		t.Errorf("expected the entry to point to the original media, got %s", originalEntry.ServerPath)
+	}
+	if originalEntry.Checksums == nil || originalEntry.Checksums.SHA256 != "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9" {
+		t.Errorf("the checksums were not saved on the entry: %s", spew.Sdump(originalEntry.Checksums))
+	}
+}
@@ -0,0 +1,29 @@
+package configreload
+
+import (
+	"github.com/guardian/mediaflipper/common/helpers"
+	"net/http"
+)
+
+type ConfigVersionHandler struct {
+	reloader *ConfigReloader
+}
+
+/**
+report the version of the configuration that is in use, and the last reload that was refused if there was one
+*/
+func (h ConfigVersionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "GET") {
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{
+		"status":       "ok",
+		"active":       h.reloader.Active(),
+		"lastRejected": h.reloader.LastRejected(),
+	}, w, 200)
+}
@@ -0,0 +1,20 @@
+package configreload
+
+import "net/http"
+
+type ConfigReloadEndpoints struct {
+	versionEndpoint ConfigVersionHandler
+	reloadEndpoint  ReloadHandler
+}
+
+func NewConfigReloadEndpoints(reloader *ConfigReloader) ConfigReloadEndpoints {
+	return ConfigReloadEndpoints{
+		versionEndpoint: ConfigVersionHandler{reloader: reloader},
+		reloadEndpoint:  ReloadHandler{reloader: reloader},
+	}
+}
+
+func (e ConfigReloadEndpoints) WireUp(baseUrl string) {
+	http.Handle(baseUrl+"/reload", e.reloadEndpoint)
+	http.Handle(baseUrl, e.versionEndpoint)
+}
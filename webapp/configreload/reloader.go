@@ -0,0 +1,185 @@
+package configreload
+
+import (
+	"errors"
+	"fmt"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/webapp/jobrunner"
+	"github.com/guardian/mediaflipper/webapp/validation"
+	"log"
+	"sync"
+	"time"
+)
+
+/**
+describes the configuration that is in use. Version goes up by one each time that a reload is applied
+*/
+type ConfigVersion struct {
+	Version  int64     `json:"version"`
+	Checksum string    `json:"checksum"` //sha256 of the server config, the template file and every settings file
+	LoadedAt time.Time `json:"loadedAt"`
+	Reason   string    `json:"reason"` //what caused the load, e.g. "startup", "SIGHUP" or "file change"
+}
+
+/**
+describes a reload that was refused. The configuration that was already in use is kept
+*/
+type RejectedReload struct {
+	Checksum   string    `json:"checksum"`
+	RejectedAt time.Time `json:"rejectedAt"`
+	Reason     string    `json:"reason"`
+	Problems   []string  `json:"problems"`
+}
+
+/**
+re-reads the server config, the transcode settings and the job templates and swaps them into the managers and the job
+runner that are in use, so that they can be changed without a restart. A reload is only applied if everything in it
+passes validation, otherwise it is refused and the current configuration stays in use
+*/
+type ConfigReloader struct {
+	configPath   string
+	templatePath string
+	settingsMgr  *models.TranscodeSettingsManager
+	templateMgr  *models.JobTemplateManager
+	runner       *jobrunner.JobRunner
+
+	lock         sync.Mutex //only one reload can happen at a time, and guards the fields below
+	activeConfig *helpers.Config
+	active       ConfigVersion
+	lastRejected *RejectedReload
+}
+
+/**
+set up a ConfigReloader for the configuration that the server has just started with, which becomes version 1
+*/
+func NewConfigReloader(configPath string, templatePath string, config *helpers.Config, settingsMgr *models.TranscodeSettingsManager,
+	templateMgr *models.JobTemplateManager, runner *jobrunner.JobRunner) *ConfigReloader {
+	checksum, checksumErr := configChecksum(configPath, templatePath, config.SettingsPath)
+	if checksumErr != nil {
+		log.Printf("WARNING NewConfigReloader could not checksum the configuration, the first check for changes will reload it: %s", checksumErr)
+	}
+
+	return &ConfigReloader{
+		configPath:   configPath,
+		templatePath: templatePath,
+		settingsMgr:  settingsMgr,
+		templateMgr:  templateMgr,
+		runner:       runner,
+		activeConfig: config,
+		active: ConfigVersion{
+			Version:  1,
+			Checksum: checksum,
+			LoadedAt: time.Now(),
+			Reason:   "startup",
+		},
+	}
+}
+
+/**
+returns a description of the configuration that is in use
+*/
+func (r *ConfigReloader) Active() ConfigVersion {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.active
+}
+
+/**
+returns the most recent reload that was refused, or nil if none have been
+*/
+func (r *ConfigReloader) LastRejected() *RejectedReload {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.lastRejected == nil {
+		return nil
+	}
+	rejected := *r.lastRejected
+	return &rejected
+}
+
+func (r *ConfigReloader) reject(checksum string, reason string, problems []string) error {
+	r.lastRejected = &RejectedReload{
+		Checksum:   checksum,
+		RejectedAt: time.Now(),
+		Reason:     reason,
+		Problems:   problems,
+	}
+	for _, problem := range problems {
+		log.Printf("ERROR ConfigReloader: %s", problem)
+	}
+	return errors.New(fmt.Sprintf("the configuration was not reloaded because of %d problems, version %d is still in use", len(problems), r.active.Version))
+}
+
+/**
+re-read the configuration and, if it is all valid, start using it. `reason` is recorded against the new version.
+returns an error if the reload was refused, in which case the configuration that was in use is kept
+*/
+func (r *ConfigReloader) Reload(reason string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	log.Printf("INFO ConfigReloader reloading configuration because of %s", reason)
+	newConfig, configErr := helpers.ReadConfig(r.configPath)
+	if configErr != nil {
+		return r.reject("", reason, []string{fmt.Sprintf("%s: could not be read: %s", r.configPath, configErr)})
+	}
+	if newConfig.MaxJobs == 0 { //the same default as at startup
+		newConfig.MaxJobs = 10
+	}
+
+	checksum, checksumErr := configChecksum(r.configPath, r.templatePath, newConfig.SettingsPath)
+	if checksumErr != nil {
+		return r.reject("", reason, []string{fmt.Sprintf("could not read the configuration: %s", checksumErr)})
+	}
+
+	report := validation.ValidateConfig(newConfig.SettingsPath, r.templatePath)
+	if !report.IsValid() {
+		problems := make([]string, len(report.Problems))
+		for i, problem := range report.Problems {
+			problems[i] = problem.String()
+		}
+		return r.reject(checksum, reason, problems)
+	}
+
+	newSettingsMgr, settingsErr := models.NewTranscodeSettingsManager(newConfig.SettingsPath)
+	if settingsErr != nil {
+		return r.reject(checksum, reason, []string{fmt.Sprintf("%s: could not load transcode settings: %s", newConfig.SettingsPath, settingsErr)})
+	}
+	newTemplateMgr, templateErr := models.NewJobTemplateManager(r.templatePath, newSettingsMgr)
+	if templateErr != nil {
+		return r.reject(checksum, reason, []string{fmt.Sprintf("%s: could not load job templates: %s", r.templatePath, templateErr)})
+	}
+
+	r.warnAboutRestartOnlyChanges(newConfig)
+
+	//the settings go first, so that the new templates never refer to settings that aren't there yet
+	r.settingsMgr.ReplacePresets(newSettingsMgr)
+	r.templateMgr.ReplaceFileTemplates(newTemplateMgr)
+	r.runner.SetLimits(int32(newConfig.MaxJobs), jobrunner.NewConcurrencyLimits(&newConfig.Concurrency))
+
+	r.activeConfig = newConfig
+	r.active = ConfigVersion{
+		Version:  r.active.Version + 1,
+		Checksum: checksum,
+		LoadedAt: time.Now(),
+		Reason:   reason,
+	}
+	log.Printf("INFO ConfigReloader now using configuration version %d, MaxJobs is %d", r.active.Version, newConfig.MaxJobs)
+	return nil
+}
+
+/**
+the redis connection, the executor and the scratch storage are set up once at startup, so changing them needs a restart
+*/
+func (r *ConfigReloader) warnAboutRestartOnlyChanges(newConfig *helpers.Config) {
+	if newConfig.Redis != r.activeConfig.Redis {
+		log.Printf("WARNING ConfigReloader the redis settings have changed, this needs a restart to take effect")
+	}
+	if newConfig.Executor != r.activeConfig.Executor {
+		log.Printf("WARNING ConfigReloader the executor settings have changed, this needs a restart to take effect")
+	}
+	if newConfig.Scratch != r.activeConfig.Scratch {
+		log.Printf("WARNING ConfigReloader the scratch storage settings have changed, this needs a restart to take effect")
+	}
+}
@@ -0,0 +1,175 @@
+package configreload
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/webapp/jobrunner"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+)
+
+const testServerConfig = `redis:
+  address: localhost:6379
+settingspath: %s
+maxjobs: %d
+`
+
+const extraTemplate = `
+- Id: 5A0C7E21-9B3D-4F6E-8A1C-2D4B6F8E0A13
+  Name: Analysis only
+  Steps:
+    - Id: 702DBDC5-CE51-4760-82E4-01BC1FB4771E
+      PredeterminedType: analysis
+      KubernetesTemplateFile: config/AnalysisJobTemplate.yaml
+`
+
+const brokenTemplate = `
+- Id: 1C3E5A79-0B2D-4F68-9A1C-3E5F7B9D0A24
+  Name: Broken
+  Steps:
+    - Id: 702DBDC5-CE51-4760-82E4-01BC1FB4771E
+      PredeterminedType: transcode
+      KubernetesTemplateFile: config/AnalysisJobTemplate.yaml
+      TranscodeSettingsId: 00000000-1111-2222-3333-444444444444
+`
+
+func copyFile(t *testing.T, from string, to string) {
+	content, readErr := ioutil.ReadFile(from)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	writeFile(t, to, string(content))
+}
+
+func writeFile(t *testing.T, fileName string, content string) {
+	writeErr := ioutil.WriteFile(fileName, []byte(content), 0644)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+}
+
+/**
+valid changes should be swapped in and counted as a new version, invalid ones should be refused and leave the current
+configuration in place
+*/
+func TestConfigReloader(t *testing.T) {
+	//kubernetes template paths in the job templates are relative to the webapp directory
+	wd, _ := os.Getwd()
+	chdirErr := os.Chdir("..")
+	if chdirErr != nil {
+		t.Fatal(chdirErr)
+	}
+	defer os.Chdir(wd)
+
+	dir, dirErr := ioutil.TempDir("", "configreloadtest")
+	if dirErr != nil {
+		t.Fatal(dirErr)
+	}
+	defer os.RemoveAll(dir)
+
+	settingsDir := path.Join(dir, "settings")
+	os.Mkdir(settingsDir, 0755)
+	settingsFiles, _ := ioutil.ReadDir("config/settings")
+	for _, fileInfo := range settingsFiles {
+		copyFile(t, path.Join("config/settings", fileInfo.Name()), path.Join(settingsDir, fileInfo.Name()))
+	}
+	templateFile := path.Join(dir, "templates.yaml")
+	copyFile(t, "config/standardjobtemplate.yaml", templateFile)
+	originalTemplates, _ := ioutil.ReadFile(templateFile)
+	configFile := path.Join(dir, "serverconfig.yaml")
+	writeFile(t, configFile, fmt.Sprintf(testServerConfig, settingsDir, 10))
+
+	config, configErr := helpers.ReadConfig(configFile)
+	if configErr != nil {
+		t.Fatal(configErr)
+	}
+	settingsMgr, settingsErr := models.NewTranscodeSettingsManager(settingsDir)
+	if settingsErr != nil {
+		t.Fatal(settingsErr)
+	}
+	templateMgr, templateErr := models.NewJobTemplateManager(templateFile, settingsMgr)
+	if templateErr != nil {
+		t.Fatal(templateErr)
+	}
+	runner := &jobrunner.JobRunner{}
+	runner.SetLimits(10, jobrunner.ConcurrencyLimits{})
+
+	reloader := NewConfigReloader(configFile, templateFile, config, settingsMgr, templateMgr, runner)
+	reloader.checkForChanges()
+	if reloader.Active().Version != 1 {
+		t.Errorf("nothing changed so expected version 1, got %d", reloader.Active().Version)
+	}
+
+	//a change to MaxJobs is picked up by the file check
+	writeFile(t, configFile, fmt.Sprintf(testServerConfig, settingsDir, 3))
+	reloader.checkForChanges()
+	if reloader.Active().Version != 2 || reloader.Active().Reason != "file change" {
+		t.Errorf("expected version 2 from a file change, got %d from %s", reloader.Active().Version, reloader.Active().Reason)
+	}
+	if maxJobs, _ := runner.Limits(); maxJobs != 3 {
+		t.Errorf("expected MaxJobs to be reloaded as 3, got %d", maxJobs)
+	}
+
+	//a new template is swapped into the manager that is already in use
+	extraId := uuid.MustParse("5A0C7E21-9B3D-4F6E-8A1C-2D4B6F8E0A13")
+	writeFile(t, templateFile, string(originalTemplates)+extraTemplate)
+	reloadErr := reloader.Reload("test")
+	if reloadErr != nil {
+		t.Fatalf("expected the new template to be accepted, got %s", reloadErr)
+	}
+	if _, exists := templateMgr.GetJob(extraId); !exists {
+		t.Error("expected the new template to be available after the reload")
+	}
+	if !templateMgr.IsReadOnly(extraId) {
+		t.Error("expected the new template to be read-only as it is from the template file")
+	}
+
+	//a template that refers to settings that don't exist is refused and the last good version stays in use
+	writeFile(t, templateFile, string(originalTemplates)+brokenTemplate)
+	reloadErr = reloader.Reload("test")
+	if reloadErr == nil {
+		t.Fatal("expected the broken template to be refused")
+	}
+	if reloader.Active().Version != 3 {
+		t.Errorf("expected version 3 to stay in use, got %d", reloader.Active().Version)
+	}
+	if _, exists := templateMgr.GetJob(extraId); !exists {
+		t.Error("expected the templates from version 3 to stay in use after a refused reload")
+	}
+	rejected := reloader.LastRejected()
+	if rejected == nil || len(rejected.Problems) == 0 {
+		t.Fatal("expected the refused reload to be recorded with its problems")
+	}
+
+	//a refused change is not tried again until the files change
+	reloader.checkForChanges()
+	if reloader.LastRejected().RejectedAt != rejected.RejectedAt {
+		t.Error("expected the refused change not to be tried again")
+	}
+
+	mockRequest := http.Request{
+		Method:     "GET",
+		RequestURI: "https://myserver.com/api/admin/config",
+		Proto:      "https",
+		ProtoMajor: 1,
+		ProtoMinor: 0,
+	}
+	mockWriter := helpers.NewMockResponseWriter()
+	ConfigVersionHandler{reloader: reloader}.ServeHTTP(mockWriter, &mockRequest)
+	if mockWriter.State.WrittenStatusCode != nil && *mockWriter.State.WrittenStatusCode != 200 {
+		t.Errorf("expected a 200 response, got %d", *mockWriter.State.WrittenStatusCode)
+	}
+	content, _ := mockWriter.LastWrittenJson()
+	active, _ := content["active"].(map[string]interface{})
+	if active == nil || active["version"] != float64(3) {
+		t.Errorf("expected the handler to report version 3, got %v", content["active"])
+	}
+	if content["lastRejected"] == nil {
+		t.Error("expected the handler to report the refused reload")
+	}
+}
@@ -0,0 +1,39 @@
+package configreload
+
+import (
+	"github.com/guardian/mediaflipper/common/helpers"
+	"net/http"
+)
+
+type ReloadHandler struct {
+	reloader *ConfigReloader
+}
+
+/**
+reload the configuration straight away, in the same way as a SIGHUP. Returns the new version, or a 409 with the
+problems that stopped it from being applied
+*/
+func (h ReloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "POST") {
+		return
+	}
+
+	reloadErr := h.reloader.Reload("api request")
+	if reloadErr != nil {
+		helpers.WriteJsonContent(map[string]interface{}{
+			"status":   "rejected",
+			"detail":   reloadErr.Error(),
+			"rejected": h.reloader.LastRejected(),
+		}, w, 409)
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{
+		"status": "ok",
+		"active": h.reloader.Active(),
+	}, w, 200)
+}
@@ -0,0 +1,104 @@
+package configreload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+	"time"
+)
+
+/**
+how often Watch checks the configuration files for changes
+*/
+const DefaultWatchInterval = 10 * time.Second
+
+/**
+returns a sha256 checksum over the contents of the server config, the template file and every file in the settings
+directory, so that a change to any of them can be spotted
+*/
+func configChecksum(configPath string, templatePath string, settingsPath string) (string, error) {
+	fileNames := []string{configPath, templatePath}
+	settingsFiles, listErr := ioutil.ReadDir(settingsPath)
+	if listErr != nil {
+		return "", listErr
+	}
+	for _, fileInfo := range settingsFiles { //ReadDir sorts by name, so the order is always the same
+		if !fileInfo.IsDir() {
+			fileNames = append(fileNames, path.Join(settingsPath, fileInfo.Name()))
+		}
+	}
+
+	hasher := sha256.New()
+	for _, fileName := range fileNames {
+		content, readErr := ioutil.ReadFile(fileName)
+		if readErr != nil {
+			return "", readErr
+		}
+		hasher.Write([]byte(fileName))
+		hasher.Write([]byte{0})
+		hasher.Write(content)
+		hasher.Write([]byte{0})
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+/**
+reloads the configuration if any of the files have changed since it was loaded. A change that has already been refused
+is not tried again until the files change once more
+*/
+func (r *ConfigReloader) checkForChanges() {
+	r.lock.Lock()
+	settingsPath := r.activeConfig.SettingsPath
+	activeChecksum := r.active.Checksum
+	rejectedChecksum := ""
+	if r.lastRejected != nil {
+		rejectedChecksum = r.lastRejected.Checksum
+	}
+	r.lock.Unlock()
+
+	checksum, checksumErr := configChecksum(r.configPath, r.templatePath, settingsPath)
+	if checksumErr != nil {
+		log.Printf("WARNING ConfigReloader could not check the configuration for changes: %s", checksumErr)
+		return
+	}
+	if checksum == activeChecksum || checksum == rejectedChecksum {
+		return
+	}
+
+	reloadErr := r.Reload("file change")
+	if reloadErr != nil {
+		log.Printf("ERROR ConfigReloader %s", reloadErr)
+	}
+}
+
+/**
+check the configuration files for changes every `interval` and reload them when they do. This does not return, so
+should be run as a goroutine
+*/
+func (r *ConfigReloader) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		r.checkForChanges()
+	}
+}
+
+/**
+reload the configuration whenever the process gets a SIGHUP
+*/
+func (r *ConfigReloader) HandleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			reloadErr := r.Reload("SIGHUP")
+			if reloadErr != nil {
+				log.Printf("ERROR ConfigReloader %s", reloadErr)
+			}
+		}
+	}()
+}
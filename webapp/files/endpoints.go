@@ -9,6 +9,7 @@ type FilesEndpoints struct {
 	GetFileHandler     GetFileInfo
 	StreamFileHandler  StreamFile
 	FilesForJobHandler ListByJob
+	StreamHandler      StreamPackage
 }
 
 func NewFilesEndpoints(redisClient *redis.Client) FilesEndpoints {
@@ -16,6 +17,7 @@ func NewFilesEndpoints(redisClient *redis.Client) FilesEndpoints {
 		GetFileHandler:     GetFileInfo{redisClient: redisClient},
 		StreamFileHandler:  StreamFile{redisClient: redisClient},
 		FilesForJobHandler: ListByJob{redisClient: redisClient},
+		StreamHandler:      StreamPackage{redisClient: redisClient},
 	}
 }
 
@@ -23,4 +25,5 @@ func (e FilesEndpoints) WireUp(baseUrl string) {
 	http.Handle(baseUrl+"/get", e.GetFileHandler)
 	http.Handle(baseUrl+"/content", e.StreamFileHandler)
 	http.Handle(baseUrl+"/byJob", e.FilesForJobHandler)
+	http.Handle(baseUrl+"/stream/", http.StripPrefix(baseUrl+"/stream/", e.StreamHandler))
 }
@@ -0,0 +1,92 @@
+package files
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	models2 "github.com/guardian/mediaflipper/common/models"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+type StreamPackage struct {
+	redisClient *redis.Client
+}
+
+/**
+serve the manifest and segments of a streaming package. The url path is {file-id}/{path-relative-to-manifest}, so that
+players can resolve the segment urls in the manifest, e.g. /api/file/stream/{file-id}/index.m3u8 gives the manifest and
+/api/file/stream/{file-id}/segment_00001.ts one of its segments.
+sprite sheet indexes are served in the same way, so that the sheet urls in the WebVTT file resolve.
+this must be wired with the prefix stripped from the path
+*/
+func (h StreamPackage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+	if !helpers.AssertHttpMethod(r, w, "GET") {
+		return
+	}
+
+	pathParts := strings.SplitN(r.URL.Path, "/", 2)
+	fileId, uuidErr := uuid.Parse(pathParts[0])
+	if uuidErr != nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "error",
+			Detail: "Invalid file ID",
+		}, w, 400)
+		return
+	}
+
+	entry, err := models2.FileEntryForId(fileId, h.redisClient)
+	if err != nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "db_error",
+			Detail: "could not retrieve record from database",
+		}, w, 500)
+		return
+	}
+
+	if !entry.IsPackage() {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "bad_request",
+			Detail: "file is not a streaming package or sprite index, use /content to get it",
+		}, w, 400)
+		return
+	}
+
+	var relativePath string
+	if len(pathParts) > 1 {
+		relativePath = pathParts[1]
+	}
+	if relativePath == "" {
+		//redirect to the manifest by name, so that the player resolves the segments relative to it
+		http.Redirect(w, r, strings.TrimSuffix(r.RequestURI, "/")+"/"+path.Base(entry.ServerPath), http.StatusFound)
+		return
+	}
+
+	serverPath, pathErr := entry.PackageFilePath(relativePath)
+	if pathErr != nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "bad_request",
+			Detail: pathErr.Error(),
+		}, w, 400)
+		return
+	}
+
+	statInfo, statErr := os.Stat(serverPath)
+	if statErr != nil || statInfo.IsDir() {
+		log.Printf("Could not find %s in package %s: %v", relativePath, entry.Id, statErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "not_found",
+			Detail: "no such file in the package",
+		}, w, 404)
+		return
+	}
+
+	w.Header().Set("Content-Type", models2.StreamContentType(serverPath))
+	http.ServeFile(w, r, serverPath)
+}
@@ -3,15 +3,13 @@ package jobrunner
 import (
 	"errors"
 	"github.com/guardian/mediaflipper/common/models"
-	v1batch "k8s.io/client-go/kubernetes/typed/batch/v1"
-	v13 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"log"
 )
 
 /**
 create an analysis job based on the provided template
 */
-func CreateAnalysisJob(jobDesc models.JobStepAnalysis, maybeOutPath string, jobClient v1batch.JobInterface, svcClient v13.ServiceInterface) error {
+func CreateAnalysisJob(jobDesc models.JobStepAnalysis, maybeOutPath string, executor JobExecutor) error {
 	if jobDesc.MediaFile == "" {
 		log.Printf("Can't perform analysis with no media file")
 		return errors.New("Can't perform analysis with no media file")
@@ -27,5 +25,5 @@ func CreateAnalysisJob(jobDesc models.JobStepAnalysis, maybeOutPath string, jobC
 		"OUTPUT_PATH":      maybeOutPath,
 	}
 
-	return CreateGenericJob(jobDesc.JobStepId, "flip-analysis", vars, true, jobDesc.KubernetesTemplateFile, jobClient, svcClient)
+	return executor.LaunchJob(jobDesc.JobStepId, "flip-analysis", vars, true, jobDesc.KubernetesTemplateFile)
 }
@@ -139,6 +139,7 @@ func (runner *JobRunner) EnqueueContentsAsync(redisClient redis.Cmdable, templat
 
 				if buildErr == nil {
 					job.SetMediaFile(rec.GetSourcePath())
+					job.Priority = models.ClampJobPriority(rec.GetPriority())
 					job.AssociatedBulk = &models.BulkAssociation{
 						Item: rec.GetId(),
 						List: l.GetId(),
@@ -125,9 +125,7 @@ func TestJobRunner_EnqueueContentsAsync(t *testing.T) {
 	//realRunner := NewJobRunner(testClient, nil, nil, 1, false)
 	realRunner := JobRunner{
 		redisClient:     testClient,
-		jobClient:       nil,
-		serviceClient:   nil,
-		podClient:       nil,
+		executor:        nil,
 		shutdownChan:    nil,
 		queuePollTicker: nil,
 		templateMgr:     nil,
@@ -202,3 +200,105 @@ func TestJobRunner_EnqueueContentsAsync(t *testing.T) {
 		}
 	}
 }
+
+/**
+a template manager that builds a new job each time, so that each bulk item gets its own
+*/
+type freshJobTemplateManager struct{}
+
+func (m freshJobTemplateManager) NewJobContainer(templateId uuid.UUID, itemType helpers.BulkItemType) (*models.JobContainer, error) {
+	nowTime := time.Now()
+	return &models.JobContainer{
+		Id:            uuid.New(),
+		Steps:         []models.JobStep{},
+		JobTemplateId: templateId,
+		StartTime:     &nowTime,
+		ItemType:      itemType,
+	}, nil
+}
+
+func (m freshJobTemplateManager) ListTemplates() []models.JobTemplateDefinition {
+	return nil
+}
+
+func (m freshJobTemplateManager) GetJob(jobId uuid.UUID) (models.JobTemplateDefinition, bool) {
+	return models.JobTemplateDefinition{}, false
+}
+
+/**
+EnqueueContentsAsync should give each job the priority of its bulk item, so that a high priority item goes ahead of
+one that was added before it
+*/
+func TestJobRunner_EnqueueContentsAsync_priority(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	runner := JobRunner{
+		redisClient: testClient,
+		maxJobs:     1,
+		bulkListDAO: bulkprocessor.BulkListDAOImpl{},
+	}
+
+	bulkId := uuid.New()
+	bulk := bulkprocessor.BulkListImpl{
+		BulkListId:  bulkId,
+		BulkListDAO: bulkprocessor.BulkListDAOImpl{},
+	}
+
+	normalItem := bulkprocessor.BulkItemImpl{
+		Id:         uuid.New(),
+		BulkListId: bulkId,
+		SourcePath: "path/to/archive",
+		Priority:   models.JOB_PRIORITY_NORMAL,
+		State:      bulkprocessor.ITEM_STATE_NOT_QUEUED,
+		Type:       helpers.ITEM_TYPE_VIDEO,
+	}
+	urgentItem := bulkprocessor.BulkItemImpl{
+		Id:         uuid.New(),
+		BulkListId: bulkId,
+		SourcePath: "path/to/newsclip",
+		Priority:   50,
+		State:      bulkprocessor.ITEM_STATE_NOT_QUEUED,
+		Type:       helpers.ITEM_TYPE_VIDEO,
+	}
+	for _, item := range []*bulkprocessor.BulkItemImpl{&normalItem, &urgentItem} {
+		if addErr := bulk.AddRecord(item, testClient); addErr != nil {
+			t.Fatal("AddRecord failed unexpectedly: ", addErr)
+		}
+	}
+
+	receivedErr := <-runner.EnqueueContentsAsync(testClient, freshJobTemplateManager{}, &bulk, nil, bulkprocessor.ITEM_STATE_NOT_QUEUED, nil)
+	if receivedErr != nil {
+		t.Fatalf("EnqueueContentsAsync failed unexpectedly: %s", receivedErr)
+	}
+
+	queued, rangeErr := testClient.ZRange("mediaflipper:jobrequestqueue", 0, -1).Result()
+	if rangeErr != nil {
+		t.Fatal("could not read the request queue: ", rangeErr)
+	}
+	if len(queued) != 2 {
+		t.Fatalf("expected 2 jobs on the request queue, got %d", len(queued))
+	}
+
+	var sourcePaths []string
+	for _, idString := range queued {
+		job, getErr := models.JobContainerForId(uuid.MustParse(idString), testClient)
+		if getErr != nil {
+			t.Fatalf("could not get queued job %s: %s", idString, getErr)
+		}
+		sourcePaths = append(sourcePaths, job.IncomingMediaFile)
+		if job.IncomingMediaFile == urgentItem.SourcePath && job.Priority != 50 {
+			t.Errorf("expected the urgent job to have priority 50, got %d", job.Priority)
+		}
+	}
+	if sourcePaths[0] != urgentItem.SourcePath || sourcePaths[1] != normalItem.SourcePath {
+		t.Errorf("expected the urgent item to be first on the queue, got %v", sourcePaths)
+	}
+}
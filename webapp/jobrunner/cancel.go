@@ -0,0 +1,183 @@
+package jobrunner
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/webapp/bulkprocessor"
+	"github.com/guardian/mediaflipper/webapp/livestream"
+	"log"
+)
+
+/**
+cancel the given job. It is taken off all of the queues and marked as aborted while holding the running queue lock, so
+that the runner can't move it on in the meantime. Then the external jobs for the steps in progress are killed and any
+outputs that those steps had already produced are removed. The outputs of steps that completed are kept.
+returns the updated job container
+*/
+func (j *JobRunner) CancelJob(container *models.JobContainer) (*models.JobContainer, error) {
+	resultChan := make(chan error)
+	var cancelled *models.JobContainer
+
+	whenQueueReady := func(lockErr error) {
+		if lockErr != nil {
+			resultChan <- lockErr
+			return
+		}
+
+		//re-read the job now that we have the lock, it may have moved on since the caller got it
+		current, getErr := models.JobContainerForId(container.Id, j.redisClient)
+		if getErr != nil {
+			resultChan <- getErr
+			return
+		}
+		if !current.CanCancel() {
+			resultChan <- errors.New(fmt.Sprintf("job %s has already finished", current.Id))
+			return
+		}
+
+		removeErr := j.RemoveJob(current)
+		if removeErr != nil {
+			log.Printf("ERROR JobRunner.CancelJob could not remove %s from the queues: %s", current.Id, removeErr)
+			resultChan <- removeErr
+			return
+		}
+		current.AbortActiveSteps("Job was cancelled")
+		storErr := current.Store(j.redisClient)
+		if storErr != nil {
+			log.Printf("ERROR JobRunner.CancelJob could not store job %s: %s", current.Id, storErr)
+			resultChan <- storErr
+			return
+		}
+		cancelled = current
+		resultChan <- nil
+	}
+
+	models.WhenQueueAvailable(j.redisClient, models.RUNNING_QUEUE, whenQueueReady, true)
+	cancelErr := <-resultChan
+	if cancelErr != nil {
+		return nil, cancelErr
+	}
+
+	abortedSteps := cancelled.StepsWithStatus(models.JOB_ABORTED)
+	//kill the external jobs for the steps that were in progress, if there are any. this also keeps their logs.
+	if j.executor != nil {
+		for _, abortedStep := range abortedSteps {
+			cleanupErr := j.executor.CleanUpJobStep(&abortedStep, j.redisClient)
+			if cleanupErr != nil {
+				log.Printf("INFO JobRunner.CancelJob could not clean up step %s of %s, it may not have been started: %s", abortedStep.StepId(), cancelled.Id, cleanupErr)
+			}
+		}
+	}
+
+	//anything that the interrupted steps produced is incomplete, so get rid of it. the outputs of steps that had already
+	//completed are kept.
+	for _, abortedStep := range abortedSteps {
+		for _, deleteErr := range abortedStep.DeleteAssociatedItems(j.redisClient) {
+			log.Printf("WARNING JobRunner.CancelJob could not remove output of step %s from %s: %s", abortedStep.StepId(), cancelled.Id, deleteErr)
+		}
+	}
+	dropRemovedOutputs(cancelled, j.redisClient)
+	storErr := cancelled.Store(j.redisClient)
+	if storErr != nil {
+		log.Printf("ERROR JobRunner.CancelJob could not store job %s after removing outputs: %s", cancelled.Id, storErr)
+	}
+	livestream.JobChanged(cancelled, j.redisClient)
+	for _, abortedStep := range abortedSteps {
+		livestream.StepChanged(cancelled, abortedStep.StepId(), j.redisClient)
+	}
+	j.notifier.JobFinished(cancelled)
+
+	association := cancelled.AssociatedBulk
+	if association != nil {
+		updateErr := j.bulkListDAO.UpdateById(association.List, association.Item, bulkprocessor.ITEM_STATE_ABORTED, j.redisClient)
+		if updateErr != nil {
+			log.Printf("ERROR JobRunner.CancelJob could not update bulk state for %s: %s", association.List, updateErr)
+		} else {
+			j.notifier.BulkItemFinished(association.List)
+		}
+	}
+
+	log.Printf("INFO JobRunner.CancelJob cancelled job %s", cancelled.Id)
+	return cancelled, nil
+}
+
+/**
+clear the references on `container` to any outputs that no longer exist, so that it does not point to files that have been
+removed. it is not stored.
+*/
+func dropRemovedOutputs(container *models.JobContainer, redisClient redis.Cmdable) {
+	isRemoved := func(fileId *uuid.UUID) bool {
+		if fileId == nil {
+			return false
+		}
+		_, getErr := models.FileEntryForId(*fileId, redisClient)
+		return getErr == redis.Nil
+	}
+
+	if isRemoved(container.ThumbnailId) {
+		container.ThumbnailId = nil
+	}
+	if isRemoved(container.SpriteIndexId) {
+		container.SpriteIndexId = nil
+	}
+	if isRemoved(container.TranscodedMediaId) {
+		container.TranscodedMediaId = nil
+	}
+	if container.Renditions != nil {
+		remaining := make([]models.RenditionOutput, 0)
+		for _, rendition := range container.Renditions {
+			if !isRemoved(&rendition.FileId) {
+				remaining = append(remaining, rendition)
+			}
+		}
+		container.Renditions = remaining
+	}
+}
+
+/**
+cancel every job for the items in the given bulk list that are waiting or running. items in those states that don't
+have a job are marked as aborted too.
+returns the number of jobs that were cancelled
+*/
+func (j *JobRunner) CancelBulkList(l bulkprocessor.BulkList) (int, error) {
+	cancelledCount := 0
+	for _, state := range []bulkprocessor.BulkItemState{bulkprocessor.ITEM_STATE_PENDING, bulkprocessor.ITEM_STATE_ACTIVE} {
+		//read all of the items first, as cancelling them changes the state index that we are reading from
+		items, filterErr := l.FilterRecordsByState(state, j.redisClient)
+		if filterErr != nil {
+			log.Printf("ERROR JobRunner.CancelBulkList could not get items for %s: %s", l.GetId(), filterErr)
+			return cancelledCount, filterErr
+		}
+
+		for _, item := range items {
+			jobs, getErr := models.JobContainerForBulkItem(item.GetId(), j.redisClient)
+			if getErr != nil {
+				log.Printf("WARNING JobRunner.CancelBulkList could not get jobs for bulk item %s: %s", item.GetId(), getErr)
+			}
+			itemCancelled := false
+			for _, job := range jobs {
+				if !job.CanCancel() {
+					continue
+				}
+				_, cancelErr := j.CancelJob(&job)
+				if cancelErr != nil {
+					log.Printf("ERROR JobRunner.CancelBulkList could not cancel job %s for bulk item %s: %s", job.Id, item.GetId(), cancelErr)
+				} else {
+					itemCancelled = true
+					cancelledCount += 1
+				}
+			}
+			if !itemCancelled {
+				updateErr := j.bulkListDAO.UpdateById(l.GetId(), item.GetId(), bulkprocessor.ITEM_STATE_ABORTED, j.redisClient)
+				if updateErr != nil {
+					log.Printf("ERROR JobRunner.CancelBulkList could not update bulk state for %s: %s", item.GetId(), updateErr)
+				}
+			}
+		}
+	}
+	j.notifier.BulkItemFinished(l.GetId()) //in case the last items that were aborted had no jobs
+	return cancelledCount, nil
+}
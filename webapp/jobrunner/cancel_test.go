@@ -0,0 +1,124 @@
+package jobrunner
+
+import (
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/webapp/webhooks"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+/**
+CancelJob should take the job off the running queue, kill the runner for the current step, mark the job aborted
+and remove the partial output of the current step, keeping the output of the step that had completed
+*/
+func TestJobRunner_CancelJob(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	completedOutput, tempErr := ioutil.TempFile("", "mediaflipper-cancel-test")
+	if tempErr != nil {
+		t.Fatalf("could not create temporary file: %s", tempErr)
+	}
+	completedOutput.Close()
+	defer os.Remove(completedOutput.Name())
+	partialOutput, tempErr := ioutil.TempFile("", "mediaflipper-cancel-test")
+	if tempErr != nil {
+		t.Fatalf("could not create temporary file: %s", tempErr)
+	}
+	partialOutput.Close()
+	defer os.Remove(partialOutput.Name())
+
+	nowTime := time.Now()
+	jobId := uuid.New()
+	outputEntry := models.FileEntry{Id: uuid.New(), ServerPath: completedOutput.Name(), JobContainerId: jobId}
+	outputEntry.Store(testClient)
+	partialEntry := models.FileEntry{Id: uuid.New(), ServerPath: partialOutput.Name(), JobContainerId: jobId}
+	partialEntry.Store(testClient)
+
+	job := &models.JobContainer{
+		Id: jobId,
+		Steps: []models.JobStep{
+			&models.JobStepTranscode{JobStepType: "transcode", JobStepId: uuid.New(), JobContainerId: jobId, StatusValue: models.JOB_COMPLETED, ResultId: &outputEntry.Id, StartTime: &nowTime},
+			&models.JobStepThumbnail{JobStepType: "thumbnail", JobStepId: uuid.New(), JobContainerId: jobId, StatusValue: models.JOB_STARTED, ResultId: &partialEntry.Id, StartTime: &nowTime},
+		},
+		CompletedSteps:    1,
+		Status:            models.JOB_STARTED,
+		StartTime:         &nowTime,
+		TranscodedMediaId: &outputEntry.Id,
+		ThumbnailId:       &partialEntry.Id,
+	}
+	job.Store(testClient)
+	runningEntry := models.JobQueueEntry{JobId: jobId, StepId: job.Steps[1].StepId(), Status: models.JOB_STARTED}
+	models.AddToQueue(testClient, models.RUNNING_QUEUE, runningEntry)
+
+	mockExecutor := &JobExecutorMock{
+		RunnerStatus: map[uuid.UUID]models.ContainerStatus{job.Steps[1].StepId(): models.CONTAINER_ACTIVE},
+	}
+	webhooks.PutSubscription(webhooks.Subscription{Id: uuid.New(), Url: "https://cms.example.com/hook", Secret: "abc"}, testClient)
+	runner := JobRunner{redisClient: testClient, executor: mockExecutor, maxJobs: 10, notifier: webhooks.NewNotifier(testClient)}
+
+	cancelled, cancelErr := runner.CancelJob(job)
+	if cancelErr != nil {
+		t.Fatalf("CancelJob failed unexpectedly: %s", cancelErr)
+	}
+	if cancelled.Status != models.JOB_ABORTED {
+		t.Errorf("expected returned job to be aborted, got %d", cancelled.Status)
+	}
+
+	stored, _ := models.JobContainerForId(jobId, testClient)
+	if stored.Status != models.JOB_ABORTED || stored.EndTime == nil {
+		t.Errorf("expected stored job to be aborted with an end time, got status %d", stored.Status)
+	}
+	if stored.Steps[1].Status() != models.JOB_ABORTED {
+		t.Errorf("expected the running step to be aborted, got %d", stored.Steps[1].Status())
+	}
+	if stored.ThumbnailId != nil {
+		t.Error("expected the thumbnail reference to be cleared")
+	}
+	if stored.TranscodedMediaId == nil || *stored.TranscodedMediaId != outputEntry.Id {
+		t.Error("expected the transcoded media reference to be kept")
+	}
+
+	queueLen, _ := models.GetQueueLength(testClient, models.RUNNING_QUEUE)
+	if queueLen != 0 {
+		t.Errorf("expected the running queue to be empty, got %d entries", queueLen)
+	}
+	if len(mockExecutor.CleanedSteps) != 1 || mockExecutor.CleanedSteps[0] != job.Steps[1].StepId() {
+		t.Errorf("expected the runner for the current step to be cleaned up, cleaned %v", mockExecutor.CleanedSteps)
+	}
+
+	if _, statErr := os.Stat(partialOutput.Name()); !os.IsNotExist(statErr) {
+		t.Error("expected the partial output file to be deleted")
+	}
+	if _, getErr := models.FileEntryForId(partialEntry.Id, testClient); getErr != redis.Nil {
+		t.Errorf("expected the partial file entry to be removed, got %v", getErr)
+	}
+	if _, statErr := os.Stat(completedOutput.Name()); statErr != nil {
+		t.Errorf("expected the completed step's output file to be kept: %s", statErr)
+	}
+	if _, getErr := models.FileEntryForId(outputEntry.Id, testClient); getErr != nil {
+		t.Errorf("expected the completed step's file entry to be kept, got %v", getErr)
+	}
+
+	if queued, _ := testClient.ZCard("mediaflipper:webhook:retryqueue").Result(); queued != 1 {
+		t.Errorf("expected a webhook to be queued for the cancellation, got %d", queued)
+	}
+
+	//an aborted job can't be cancelled again
+	_, cancelErr = runner.CancelJob(job)
+	if cancelErr == nil {
+		t.Error("expected CancelJob to refuse a job that has already been aborted")
+	}
+}
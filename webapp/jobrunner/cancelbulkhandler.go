@@ -0,0 +1,57 @@
+package jobrunner
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/webapp/bulkprocessor"
+	"log"
+	"net/http"
+)
+
+type CancelBulkHandler struct {
+	redisClient *redis.Client
+	runner      *JobRunner
+}
+
+/**
+cancel every waiting or running job for the given bulk list. Expects ?forId={bulk-list-id}.
+this runs in the background unless ?sync=true is given
+*/
+func (h CancelBulkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !helpers.AssertHttpMethod(r, w, "POST") {
+		return
+	}
+
+	parsedUrl, forId, errResponse := helpers.GetForId(r.RequestURI)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	bulkList, lookupErr := bulkprocessor.BulkListForId(*forId, h.redisClient)
+	if lookupErr != nil {
+		log.Printf("ERROR CancelBulkHandler could not look up bulk list for %s: %s", *forId, lookupErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", "could not look up bulk list"}, w, 500)
+		return
+	}
+
+	if parsedUrl.Query().Get("sync") != "" {
+		count, cancelErr := h.runner.CancelBulkList(bulkList)
+		if cancelErr != nil {
+			log.Printf("ERROR CancelBulkHandler could not cancel jobs for %s: %s", *forId, cancelErr)
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", cancelErr.Error()}, w, 500)
+			return
+		}
+		helpers.WriteJsonContent(map[string]interface{}{"status": "ok", "cancelled": count}, w, 200)
+	} else {
+		go func() {
+			count, cancelErr := h.runner.CancelBulkList(bulkList)
+			if cancelErr != nil {
+				log.Printf("ERROR CancelBulkHandler could not cancel jobs for %s, can't inform client: %s", bulkList.GetId(), cancelErr)
+			} else {
+				log.Printf("INFO CancelBulkHandler cancelled %d jobs for %s", count, bulkList.GetId())
+			}
+		}()
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"ok", "cancel operation running in background"}, w, 200)
+	}
+}
@@ -161,7 +161,7 @@ func CleanUpJobStep(step *models.JobStep, jobclient batchv1.JobInterface, podCli
 	if step == nil {
 		return errors.New("passed jobstep was nil")
 	}
-	logsKey := fmt.Sprintf("mediaflipper:containerlog:%s", (*step).StepId().String())
+	logsKey := containerLogKey((*step).StepId())
 
 	k8Job, jobErr := FindK8Job((*step).StepId(), jobclient)
 	if jobErr != nil {
@@ -0,0 +1,186 @@
+package jobrunner
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+)
+
+/**
+maximum number of job steps that can be running at once, per step type (analysis, thumbnail, transcode, custom)
+and per job template. a missing or zero entry means that there is no limit other than the global MaxJobs.
+*/
+type ConcurrencyLimits struct {
+	StepTypes map[string]int64
+	Templates map[uuid.UUID]int64
+}
+
+/**
+number of job steps currently on the running queue, per step type and per job template
+*/
+type ConcurrencyUsage struct {
+	StepTypes map[string]int64
+	Templates map[uuid.UUID]int64
+}
+
+/**
+the usage and limit for a single step type or template, as reported by the queue stats endpoint
+*/
+type ConcurrencyStat struct {
+	Running int64 `json:"running"`
+	Limit   int64 `json:"limit"`
+}
+
+/**
+build a ConcurrencyLimits from the server config. Template limits with an invalid id are logged and ignored.
+*/
+func NewConcurrencyLimits(config *helpers.ConcurrencyConfig) ConcurrencyLimits {
+	limits := ConcurrencyLimits{
+		StepTypes: make(map[string]int64, len(config.StepTypes)),
+		Templates: make(map[uuid.UUID]int64, len(config.Templates)),
+	}
+
+	for stepType, limit := range config.StepTypes {
+		limits.StepTypes[stepType] = int64(limit)
+	}
+	for templateIdString, limit := range config.Templates {
+		templateId, parseErr := uuid.Parse(templateIdString)
+		if parseErr != nil {
+			log.Printf("WARNING NewConcurrencyLimits ignoring limit for template '%s', it's not a valid uuid: %s", templateIdString, parseErr)
+			continue
+		}
+		limits.Templates[templateId] = int64(limit)
+	}
+	return limits
+}
+
+/**
+return the type name of the given job step, as used in the job templates
+*/
+func stepTypeFor(step models.JobStep) string {
+	switch step.(type) {
+	case *models.JobStepAnalysis, models.JobStepAnalysis:
+		return "analysis"
+	case *models.JobStepThumbnail, models.JobStepThumbnail:
+		return "thumbnail"
+	case *models.JobStepTranscode, models.JobStepTranscode:
+		return "transcode"
+	case *models.JobStepCustom, models.JobStepCustom:
+		return "custom"
+	case *models.JobStepQC, models.JobStepQC:
+		return "qc"
+	default:
+		return "unknown"
+	}
+}
+
+func newConcurrencyUsage() *ConcurrencyUsage {
+	return &ConcurrencyUsage{
+		StepTypes: make(map[string]int64),
+		Templates: make(map[uuid.UUID]int64),
+	}
+}
+
+/**
+record that the given step of the given job has started (delta=1) or finished (delta=-1)
+*/
+func (u *ConcurrencyUsage) Add(container *models.JobContainer, step models.JobStep, delta int64) {
+	u.StepTypes[stepTypeFor(step)] += delta
+	u.Templates[container.JobTemplateId] += delta
+}
+
+/**
+find out what is currently running by looking up the job for every entry on the running queue.
+entries whose job can't be found are not counted.
+*/
+func GetConcurrencyUsage(redisClient redis.Cmdable) (*ConcurrencyUsage, error) {
+	queueSnapshot, snapErr := models.SnapshotQueue(redisClient, models.RUNNING_QUEUE)
+	if snapErr != nil {
+		return nil, snapErr
+	}
+	return usageFromSnapshot(queueSnapshot, redisClient), nil
+}
+
+func usageFromSnapshot(queueSnapshot []models.JobQueueEntry, redisClient redis.Cmdable) *ConcurrencyUsage {
+	usage := newConcurrencyUsage()
+	containerCache := make(map[uuid.UUID]*models.JobContainer)
+	for _, entry := range queueSnapshot {
+		container, haveContainer := containerCache[entry.JobId]
+		if !haveContainer {
+			var getErr error
+			container, getErr = models.JobContainerForId(entry.JobId, redisClient)
+			if getErr != nil {
+				log.Printf("WARNING GetConcurrencyUsage could not look up job %s for running step %s: %s", entry.JobId, entry.StepId, getErr)
+				continue
+			}
+			containerCache[entry.JobId] = container
+		}
+		step := container.FindStepById(entry.StepId)
+		if step == nil {
+			log.Printf("WARNING GetConcurrencyUsage job %s has no step %s", entry.JobId, entry.StepId)
+			continue
+		}
+		usage.Add(container, *step, 1)
+	}
+	return usage
+}
+
+/**
+returns true if any step type or template limits have been configured
+*/
+func (l ConcurrencyLimits) HasLimits() bool {
+	return len(l.StepTypes) > 0 || len(l.Templates) > 0
+}
+
+/**
+return the step that should be run next for the given job, or nil if there are none ready
+*/
+func nextStepFor(container *models.JobContainer) models.JobStep {
+	readySteps := container.ReadySteps()
+	if len(readySteps) == 0 {
+		return nil
+	}
+	return readySteps[0]
+}
+
+/**
+returns true if starting the given step of the given job would not take us over any limit
+*/
+func (l ConcurrencyLimits) Allows(usage *ConcurrencyUsage, container *models.JobContainer, step models.JobStep) bool {
+	stepType := stepTypeFor(step)
+	if limit, haveLimit := l.StepTypes[stepType]; haveLimit && limit > 0 && usage.StepTypes[stepType] >= limit {
+		return false
+	}
+	if limit, haveLimit := l.Templates[container.JobTemplateId]; haveLimit && limit > 0 && usage.Templates[container.JobTemplateId] >= limit {
+		return false
+	}
+	return true
+}
+
+/**
+build a report of the usage against each configured limit, for the queue stats endpoint.
+anything that is running but has no limit is reported with a limit of 0
+*/
+func (l ConcurrencyLimits) Report(usage *ConcurrencyUsage) map[string]interface{} {
+	stepTypes := make(map[string]ConcurrencyStat, len(l.StepTypes))
+	for stepType, running := range usage.StepTypes {
+		stepTypes[stepType] = ConcurrencyStat{Running: running}
+	}
+	for stepType, limit := range l.StepTypes {
+		stepTypes[stepType] = ConcurrencyStat{Running: usage.StepTypes[stepType], Limit: limit}
+	}
+
+	templates := make(map[string]ConcurrencyStat, len(l.Templates))
+	for templateId, running := range usage.Templates {
+		templates[templateId.String()] = ConcurrencyStat{Running: running}
+	}
+	for templateId, limit := range l.Templates {
+		templates[templateId.String()] = ConcurrencyStat{Running: usage.Templates[templateId], Limit: limit}
+	}
+	return map[string]interface{}{
+		"stepTypes": stepTypes,
+		"templates": templates,
+	}
+}
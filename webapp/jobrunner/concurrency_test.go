@@ -0,0 +1,246 @@
+package jobrunner
+
+import (
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"testing"
+	"time"
+)
+
+func makeSingleStepJob(t *testing.T, client redis.Cmdable, templateId uuid.UUID, stepType string) *models.JobContainer {
+	nowTime := time.Now()
+	jobId := uuid.New()
+	var step models.JobStep
+	switch stepType {
+	case "analysis":
+		step = &models.JobStepAnalysis{
+			JobStepType:    "analysis",
+			JobStepId:      uuid.New(),
+			JobContainerId: jobId,
+			MediaFile:      "/path/to/media",
+			StartTime:      &nowTime,
+		}
+	case "custom":
+		step = &models.JobStepCustom{
+			JobStepType:    "custom",
+			JobStepId:      uuid.New(),
+			JobContainerId: jobId,
+			MediaFile:      "/path/to/media",
+			StartTime:      &nowTime,
+		}
+	default:
+		t.Fatalf("makeSingleStepJob can't make a %s step", stepType)
+	}
+
+	job := &models.JobContainer{
+		Id:            jobId,
+		Steps:         []models.JobStep{step},
+		Status:        models.JOB_PENDING,
+		JobTemplateId: templateId,
+		StartTime:     &nowTime,
+	}
+	storErr := job.Store(client)
+	if storErr != nil {
+		t.Fatalf("could not store test job: %s", storErr)
+	}
+	return job
+}
+
+func TestNewConcurrencyLimits(t *testing.T) {
+	limits := NewConcurrencyLimits(&helpers.ConcurrencyConfig{
+		StepTypes: map[string]int{"transcode": 2},
+		Templates: map[string]int{"846F823E-C0D3-4AF0-AD51-0F9573379057": 3, "not-a-uuid": 1},
+	})
+
+	if limits.StepTypes["transcode"] != 2 {
+		t.Errorf("expected transcode limit of 2, got %d", limits.StepTypes["transcode"])
+	}
+	if len(limits.Templates) != 1 {
+		t.Errorf("expected invalid template id to be dropped, got %d template limits", len(limits.Templates))
+	}
+	if limits.Templates[uuid.MustParse("846F823E-C0D3-4AF0-AD51-0F9573379057")] != 3 {
+		t.Error("template limit was not set")
+	}
+	if !limits.HasLimits() {
+		t.Error("HasLimits should be true")
+	}
+	if (ConcurrencyLimits{}).HasLimits() {
+		t.Error("empty limits should not have limits")
+	}
+}
+
+func TestConcurrencyLimits_Allows(t *testing.T) {
+	templateId := uuid.New()
+	otherTemplateId := uuid.New()
+	limits := ConcurrencyLimits{
+		StepTypes: map[string]int64{"transcode": 1},
+		Templates: map[uuid.UUID]int64{templateId: 2},
+	}
+	usage := newConcurrencyUsage()
+
+	tcStep := &models.JobStepTranscode{}
+	analysisStep := &models.JobStepAnalysis{}
+	job := &models.JobContainer{JobTemplateId: templateId}
+	otherJob := &models.JobContainer{JobTemplateId: otherTemplateId}
+
+	if !limits.Allows(usage, job, tcStep) {
+		t.Error("nothing running, first transcode should be allowed")
+	}
+	usage.Add(otherJob, tcStep, 1)
+	if limits.Allows(usage, job, tcStep) {
+		t.Error("transcode limit reached, second transcode should not be allowed")
+	}
+	if !limits.Allows(usage, job, analysisStep) {
+		t.Error("analysis has no limit and should be allowed")
+	}
+	usage.Add(job, analysisStep, 1)
+	usage.Add(job, analysisStep, 1)
+	if limits.Allows(usage, job, analysisStep) {
+		t.Error("template limit reached, should not be allowed")
+	}
+	if !limits.Allows(usage, otherJob, analysisStep) {
+		t.Error("other template has no limit and should be allowed")
+	}
+
+	report := limits.Report(usage)
+	stepReport := report["stepTypes"].(map[string]ConcurrencyStat)
+	if stepReport["transcode"].Running != 1 || stepReport["transcode"].Limit != 1 {
+		t.Errorf("unexpected transcode report %v", stepReport["transcode"])
+	}
+	if stepReport["analysis"].Running != 2 || stepReport["analysis"].Limit != 0 {
+		t.Errorf("unexpected analysis report %v", stepReport["analysis"])
+	}
+}
+
+/**
+waitingQueueTick should skip over jobs whose first step is at its limit, leave them on the queue
+and carry on dispatching jobs that can run
+*/
+func TestJobRunner_waitingQueueTick_limits(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	templateId := uuid.New()
+	customOne := makeSingleStepJob(t, testClient, templateId, "custom")
+	customTwo := makeSingleStepJob(t, testClient, templateId, "custom")
+	analysis := makeSingleStepJob(t, testClient, templateId, "analysis")
+	for _, job := range []*models.JobContainer{customOne, customTwo, analysis} {
+		pushToRequestQueue(testClient, job)
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	mockExecutor := &JobExecutorMock{}
+	runner := JobRunner{
+		redisClient: testClient,
+		executor:    mockExecutor,
+		maxJobs:     10,
+		limits:      ConcurrencyLimits{StepTypes: map[string]int64{"custom": 1}},
+	}
+
+	runner.waitingQueueTick()
+
+	if len(mockExecutor.LaunchedSteps) != 2 {
+		t.Fatalf("expected 2 steps to be launched, got %d", len(mockExecutor.LaunchedSteps))
+	}
+	if mockExecutor.LaunchedSteps[0] != customOne.Steps[0].StepId() {
+		t.Errorf("expected first custom job to be launched first")
+	}
+	if mockExecutor.LaunchedSteps[1] != analysis.Steps[0].StepId() {
+		t.Errorf("expected analysis job to be launched second")
+	}
+
+	waiting, _ := testClient.ZRange("mediaflipper:"+string(models.REQUEST_QUEUE), 0, -1).Result()
+	if len(waiting) != 1 || waiting[0] != customTwo.Id.String() {
+		t.Errorf("expected only the second custom job to be left waiting, got %v", waiting)
+	}
+
+	//once the first one has completed the second should be dispatched
+	mockExecutor.RunnerStatus = map[uuid.UUID]models.ContainerStatus{
+		customOne.Steps[0].StepId(): models.CONTAINER_COMPLETED,
+		analysis.Steps[0].StepId():  models.CONTAINER_ACTIVE,
+	}
+	runner.clearCompletedTick()
+	runner.waitingQueueTick()
+
+	if len(mockExecutor.LaunchedSteps) != 3 || mockExecutor.LaunchedSteps[2] != customTwo.Steps[0].StepId() {
+		t.Errorf("expected second custom job to be launched after the first completed, launched steps were %v", mockExecutor.LaunchedSteps)
+	}
+}
+
+/**
+if the next step of a job is at its limit when the previous step completes, the job should go back onto the request queue
+and be resumed from that step later
+*/
+func TestJobRunner_clearCompletedTick_deferNextStep(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	templateId := uuid.New()
+	blocker := makeSingleStepJob(t, testClient, templateId, "custom")
+
+	nowTime := time.Now()
+	jobId := uuid.New()
+	twoStepJob := &models.JobContainer{
+		Id: jobId,
+		Steps: []models.JobStep{
+			&models.JobStepAnalysis{JobStepType: "analysis", JobStepId: uuid.New(), JobContainerId: jobId, MediaFile: "/path/to/media", StartTime: &nowTime},
+			&models.JobStepCustom{JobStepType: "custom", JobStepId: uuid.New(), JobContainerId: jobId, MediaFile: "/path/to/media", StartTime: &nowTime},
+		},
+		Status:        models.JOB_STARTED,
+		JobTemplateId: templateId,
+		StartTime:     &nowTime,
+	}
+	twoStepJob.Store(testClient)
+
+	models.AddToQueue(testClient, models.RUNNING_QUEUE, models.JobQueueEntry{JobId: blocker.Id, StepId: blocker.Steps[0].StepId(), Status: models.JOB_STARTED})
+	models.AddToQueue(testClient, models.RUNNING_QUEUE, models.JobQueueEntry{JobId: jobId, StepId: twoStepJob.Steps[0].StepId(), Status: models.JOB_STARTED})
+
+	mockExecutor := &JobExecutorMock{
+		RunnerStatus: map[uuid.UUID]models.ContainerStatus{
+			blocker.Steps[0].StepId():    models.CONTAINER_ACTIVE,
+			twoStepJob.Steps[0].StepId(): models.CONTAINER_COMPLETED,
+		},
+	}
+	runner := JobRunner{
+		redisClient: testClient,
+		executor:    mockExecutor,
+		maxJobs:     10,
+		limits:      ConcurrencyLimits{StepTypes: map[string]int64{"custom": 1}},
+	}
+
+	runner.clearCompletedTick()
+
+	if len(mockExecutor.LaunchedSteps) != 0 {
+		t.Errorf("next step should not have been launched, got %v", mockExecutor.LaunchedSteps)
+	}
+	waiting, _ := testClient.ZRange("mediaflipper:"+string(models.REQUEST_QUEUE), 0, -1).Result()
+	if len(waiting) != 1 || waiting[0] != jobId.String() {
+		t.Errorf("expected the job to be back on the request queue, got %v", waiting)
+	}
+
+	//now free up the limit, the job should continue from its second step
+	mockExecutor.RunnerStatus[blocker.Steps[0].StepId()] = models.CONTAINER_COMPLETED
+	runner.clearCompletedTick()
+	runner.waitingQueueTick()
+
+	if len(mockExecutor.LaunchedSteps) != 1 || mockExecutor.LaunchedSteps[0] != twoStepJob.Steps[1].StepId() {
+		t.Errorf("expected the second step to be launched, got %v", mockExecutor.LaunchedSteps)
+	}
+}
@@ -3,7 +3,6 @@ package jobrunner
 import (
 	"github.com/go-redis/redis/v7"
 	"github.com/guardian/mediaflipper/common/models"
-	"k8s.io/client-go/kubernetes"
 	"net/http"
 )
 
@@ -13,15 +12,19 @@ type JobRunnerEndpoints struct {
 	EnqueueBulk   BulkEnqueueHandler
 	ManualCleanup ManualCleanupHandler
 	FailPending   FailPendingHandler
+	Priority      PriorityHandler
+	CancelBulk    CancelBulkHandler
 }
 
-func NewJobRunnerEndpoints(redisClient *redis.Client, templateMgr *models.JobTemplateManager, runner *JobRunner, clientset *kubernetes.Clientset) JobRunnerEndpoints {
+func NewJobRunnerEndpoints(redisClient *redis.Client, templateMgr *models.JobTemplateManager, runner *JobRunner, executor JobExecutor) JobRunnerEndpoints {
 	return JobRunnerEndpoints{
-		QueueStats:    QueueStatsHandler{redisClient: redisClient},
+		QueueStats:    QueueStatsHandler{redisClient: redisClient, runner: runner},
 		PurgeHandler:  PurgeHandler{redisClient: redisClient},
 		EnqueueBulk:   BulkEnqueueHandler{redisClient: redisClient, templateManager: templateMgr, runner: runner},
-		ManualCleanup: ManualCleanupHandler{redisClient: redisClient, k8clientset: clientset},
+		ManualCleanup: ManualCleanupHandler{redisClient: redisClient, executor: executor},
 		FailPending:   FailPendingHandler{redisClient: redisClient, runner: runner},
+		Priority:      PriorityHandler{redisClient: redisClient, runner: runner},
+		CancelBulk:    CancelBulkHandler{redisClient: redisClient, runner: runner},
 	}
 }
 
@@ -31,4 +34,6 @@ func (e JobRunnerEndpoints) WireUp(baseUrl string) {
 	http.Handle(baseUrl+"/enqueue", e.EnqueueBulk)
 	http.Handle(baseUrl+"/cleanup", e.ManualCleanup)
 	http.Handle(baseUrl+"/failpending", e.FailPending)
+	http.Handle(baseUrl+"/priority", e.Priority)
+	http.Handle(baseUrl+"/cancelbulk", e.CancelBulk)
 }
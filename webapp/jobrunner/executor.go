@@ -0,0 +1,60 @@
+package jobrunner
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"k8s.io/client-go/kubernetes"
+)
+
+/**
+a JobExecutor is the backend that actually runs the external process for a job step.
+the JobRunner only ever talks to the executor, so the same runner can drive steps in Kubernetes or as local processes
+*/
+type JobExecutor interface {
+	//start an external job for the given job step. kubernetesTemplateFile may be ignored by executors that don't use it
+	LaunchJob(jobStepId uuid.UUID, jobNameBase string, envVars map[string]string, overwriteExistingVars bool, kubernetesTemplateFile string) error
+	//return a description of the running process(es) for the given job step. an empty list means nothing was found.
+	FindRunnerFor(jobStepId uuid.UUID) (*[]models.JobRunnerDesc, error)
+	//extract the logs for the given step into the datastore and remove any trace of the external process
+	CleanUpJobStep(step *models.JobStep, redisClient redis.Cmdable) error
+}
+
+const (
+	EXECUTOR_KUBERNETES = "kubernetes"
+	EXECUTOR_LOCAL      = "local"
+)
+
+/**
+build the JobExecutor requested by the server config.
+the kubernetes executor (the default) requires a valid clientset, the local executor does not.
+*/
+func NewJobExecutor(config *helpers.ExecutorConfig, k8client *kubernetes.Clientset) (JobExecutor, error) {
+	switch config.Type {
+	case "":
+		fallthrough
+	case EXECUTOR_KUBERNETES:
+		if k8client == nil {
+			return nil, errors.New("kubernetes executor requested but there is no connection to kubernetes")
+		}
+		k8Executor, err := NewKubernetesExecutor(k8client)
+		if err != nil {
+			return nil, err
+		}
+		return k8Executor, nil
+	case EXECUTOR_LOCAL:
+		return NewLocalExecutor(config.WrapperPath, config.WebappBase), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("executor type '%s' is not recognised", config.Type))
+	}
+}
+
+/**
+the key under which the logs for a given job step are stored
+*/
+func containerLogKey(stepId uuid.UUID) string {
+	return fmt.Sprintf("mediaflipper:containerlog:%s", stepId.String())
+}
@@ -0,0 +1,49 @@
+package jobrunner
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"k8s.io/client-go/kubernetes"
+	v1batch "k8s.io/client-go/kubernetes/typed/batch/v1"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"log"
+)
+
+/**
+JobExecutor implementation that runs each step as a Kubernetes Job in the namespace that we are deployed in
+*/
+type KubernetesExecutor struct {
+	jobClient     v1batch.JobInterface
+	podClient     v1.PodInterface
+	serviceClient v1.ServiceInterface
+}
+
+/**
+create a new KubernetesExecutor. returns an error if the current namespace can't be determined.
+*/
+func NewKubernetesExecutor(k8client *kubernetes.Clientset) (*KubernetesExecutor, error) {
+	ns, getNsErr := GetMyNamespace()
+	if getNsErr != nil {
+		log.Printf("ERROR NewKubernetesExecutor could not determine current k8s namespace: %s", getNsErr)
+		return nil, getNsErr
+	}
+
+	return &KubernetesExecutor{
+		jobClient:     k8client.BatchV1().Jobs(ns),
+		podClient:     k8client.CoreV1().Pods(ns),
+		serviceClient: k8client.CoreV1().Services(ns),
+	}, nil
+}
+
+func (e *KubernetesExecutor) LaunchJob(jobStepId uuid.UUID, jobNameBase string, envVars map[string]string, overwriteExistingVars bool, kubernetesTemplateFile string) error {
+	return CreateGenericJob(jobStepId, jobNameBase, envVars, overwriteExistingVars, kubernetesTemplateFile, e.jobClient, e.serviceClient)
+}
+
+func (e *KubernetesExecutor) FindRunnerFor(jobStepId uuid.UUID) (*[]models.JobRunnerDesc, error) {
+	return FindRunnerFor(jobStepId, e.jobClient)
+}
+
+func (e *KubernetesExecutor) CleanUpJobStep(step *models.JobStep, redisClient redis.Cmdable) error {
+	return CleanUpJobStep(step, e.jobClient, e.podClient, redisClient)
+}
@@ -0,0 +1,237 @@
+package jobrunner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+/**
+a bytes.Buffer that can safely be written by the subprocess pipes while being read elsewhere
+*/
+type lockedBuffer struct {
+	buf   bytes.Buffer
+	mutex sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+/**
+book-keeping information about a wrapper process that we have started
+*/
+type localProcess struct {
+	name           string
+	cmd            *exec.Cmd
+	output         *lockedBuffer
+	startTime      time.Time
+	completionTime *time.Time
+	exitErr        error
+	done           bool
+}
+
+/**
+JobExecutor implementation that runs each step by launching the wrapper binary as a child process of the webapp.
+this is intended for development and small installations that don't have a Kubernetes cluster.
+process state is only held in memory, so anything running when the webapp exits will be reported as lost.
+*/
+type LocalExecutor struct {
+	wrapperPath string
+	webappBase  string
+	processes   map[uuid.UUID]*localProcess
+	mutex       sync.Mutex
+}
+
+/**
+create a new LocalExecutor.
+arguments:
+- wrapperPath - path to the wrapper binary. Defaults to "wrapper" (i.e. look it up from $PATH) if blank.
+- webappBase  - the url that the wrapper should use to send results back. Defaults to http://localhost:9000 if blank.
+*/
+func NewLocalExecutor(wrapperPath string, webappBase string) *LocalExecutor {
+	if wrapperPath == "" {
+		wrapperPath = "wrapper"
+	}
+	if webappBase == "" {
+		webappBase = "http://localhost:9000"
+	}
+	return &LocalExecutor{
+		wrapperPath: wrapperPath,
+		webappBase:  webappBase,
+		processes:   make(map[uuid.UUID]*localProcess),
+	}
+}
+
+/**
+the variables that are passed on from the webapp's own environment to the wrapper. Nothing else is passed on, so that
+the webapp's settings and credentials don't leak into the jobs
+*/
+var inheritedEnvVars = []string{"PATH", "HOME"}
+
+/**
+builds the environment for a wrapper process: the inherited variables from the webapp's environment, with `envVars`
+applied on top. If overwriteExistingVars is false then an inherited variable is kept in preference to one in `envVars`,
+in the same way that the kubernetes executor keeps the variables that are set in the template.
+*/
+func localEnvironment(envVars map[string]string, overwriteExistingVars bool, webappBase string) []string {
+	vars := map[string]string{"WEBAPP_BASE": webappBase}
+	for k, v := range envVars {
+		vars[k] = v
+	}
+	for _, name := range inheritedEnvVars {
+		value, isSet := os.LookupEnv(name)
+		if !isSet {
+			continue
+		}
+		if _, haveOverwrite := vars[name]; !haveOverwrite || !overwriteExistingVars {
+			vars[name] = value
+		}
+	}
+
+	env := make([]string, 0, len(vars))
+	for k, v := range vars {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	return env
+}
+
+/**
+start the wrapper with the given environment, see localEnvironment. The kubernetes template file is not relevant here
+*/
+func (e *LocalExecutor) LaunchJob(jobStepId uuid.UUID, jobNameBase string, envVars map[string]string, overwriteExistingVars bool, kubernetesTemplateFile string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if existing, haveExisting := e.processes[jobStepId]; haveExisting && !existing.done {
+		return errors.New(fmt.Sprintf("there is already a process running for step %s", jobStepId))
+	}
+
+	cmd := exec.Command(e.wrapperPath)
+	cmd.Env = localEnvironment(envVars, overwriteExistingVars, e.webappBase)
+
+	output := &lockedBuffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	startErr := cmd.Start()
+	if startErr != nil {
+		log.Printf("ERROR LocalExecutor could not start %s for %s: %s", e.wrapperPath, jobStepId, startErr)
+		return startErr
+	}
+
+	proc := &localProcess{
+		name:      fmt.Sprintf("%s-%d", jobNameBase, cmd.Process.Pid),
+		cmd:       cmd,
+		output:    output,
+		startTime: time.Now(),
+	}
+	e.processes[jobStepId] = proc
+	log.Printf("INFO LocalExecutor started %s for job step %s", proc.name, jobStepId)
+
+	go func() {
+		waitErr := cmd.Wait()
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		nowTime := time.Now()
+		proc.completionTime = &nowTime
+		proc.exitErr = waitErr
+		proc.done = true
+		if waitErr != nil {
+			log.Printf("INFO LocalExecutor %s exited with an error: %s", proc.name, waitErr)
+		} else {
+			log.Printf("INFO LocalExecutor %s completed", proc.name)
+		}
+	}()
+	return nil
+}
+
+func (e *LocalExecutor) FindRunnerFor(jobStepId uuid.UUID) (*[]models.JobRunnerDesc, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	proc, haveProc := e.processes[jobStepId]
+	if !haveProc {
+		rtn := []models.JobRunnerDesc{}
+		return &rtn, nil
+	}
+
+	var statusVal models.ContainerStatus
+	completionTimeString := ""
+	if !proc.done {
+		statusVal = models.CONTAINER_ACTIVE
+	} else {
+		completionTimeString = proc.completionTime.Format(time.RFC3339)
+		if proc.exitErr == nil {
+			statusVal = models.CONTAINER_COMPLETED
+		} else {
+			statusVal = models.CONTAINER_FAILED
+		}
+	}
+
+	rtn := []models.JobRunnerDesc{
+		{
+			JobUID:         fmt.Sprintf("local-%d", proc.cmd.Process.Pid),
+			Status:         statusVal,
+			StartTime:      proc.startTime.Format(time.RFC3339),
+			CompletionTime: completionTimeString,
+			Name:           proc.name,
+		},
+	}
+	return &rtn, nil
+}
+
+/**
+store the output of the process for the given step and forget about it. If the process is still running it is killed.
+*/
+func (e *LocalExecutor) CleanUpJobStep(step *models.JobStep, redisClient redis.Cmdable) error {
+	if step == nil {
+		return errors.New("passed jobstep was nil")
+	}
+	stepId := (*step).StepId()
+
+	e.mutex.Lock()
+	proc, haveProc := e.processes[stepId]
+	stillRunning := false
+	if haveProc {
+		stillRunning = !proc.done
+		delete(e.processes, stepId)
+	}
+	e.mutex.Unlock()
+
+	if !haveProc {
+		log.Printf("ERROR LocalExecutor.CleanUpJobStep could not find a process for job container id %s", (*step).ContainerId())
+		return errors.New("could not find local process")
+	}
+
+	if stillRunning {
+		log.Printf("WARNING LocalExecutor.CleanUpJobStep %s is still running, killing it", proc.name)
+		killErr := proc.cmd.Process.Kill()
+		if killErr != nil {
+			log.Printf("ERROR LocalExecutor.CleanUpJobStep could not kill %s: %s", proc.name, killErr)
+		}
+	}
+
+	_, setErr := redisClient.Set(containerLogKey(stepId), proc.output.String(), -1).Result()
+	if setErr != nil {
+		log.Printf("ERROR LocalExecutor.CleanUpJobStep could not store log content: %s", setErr)
+		return setErr
+	}
+	return nil
+}
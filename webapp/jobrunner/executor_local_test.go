@@ -0,0 +1,154 @@
+package jobrunner
+
+import (
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+/**
+write out a shell script that stands in for the wrapper binary
+*/
+func writeFakeWrapper(t *testing.T, dir string, exitCode string) string {
+	scriptPath := path.Join(dir, "fakewrapper-"+exitCode+".sh")
+	content := "#!/bin/sh\necho \"mode is $WRAPPER_MODE, webapp is $WEBAPP_BASE\"\nexit " + exitCode + "\n"
+	writeErr := ioutil.WriteFile(scriptPath, []byte(content), 0755)
+	if writeErr != nil {
+		t.Fatalf("could not write fake wrapper: %s", writeErr)
+	}
+	return scriptPath
+}
+
+/**
+poll the executor until the given step is no longer active, or fail after a few seconds
+*/
+func waitForLocalRunner(t *testing.T, e *LocalExecutor, stepId uuid.UUID) models.JobRunnerDesc {
+	for i := 0; i < 50; i++ {
+		runners, err := e.FindRunnerFor(stepId)
+		if err != nil {
+			t.Fatalf("FindRunnerFor returned an unexpected error: %s", err)
+		}
+		if len(*runners) != 1 {
+			t.Fatalf("expected 1 runner, got %d", len(*runners))
+		}
+		if (*runners)[0].Status != models.CONTAINER_ACTIVE {
+			return (*runners)[0]
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("local process did not finish in time")
+	return models.JobRunnerDesc{}
+}
+
+func TestLocalExecutorSuccess(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "executortest")
+	defer os.RemoveAll(dir)
+
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	e := NewLocalExecutor(writeFakeWrapper(t, dir, "0"), "http://mywebapp:9000")
+	stepId := uuid.New()
+	launchErr := e.LaunchJob(stepId, "flip-analysis", map[string]string{"WRAPPER_MODE": "analyse"}, true, "")
+	if launchErr != nil {
+		t.Fatalf("LaunchJob returned an unexpected error: %s", launchErr)
+	}
+
+	runner := waitForLocalRunner(t, e, stepId)
+	if runner.Status != models.CONTAINER_COMPLETED {
+		t.Errorf("expected status %d, got %d", models.CONTAINER_COMPLETED, runner.Status)
+	}
+	if !strings.HasPrefix(runner.Name, "flip-analysis-") {
+		t.Errorf("unexpected runner name %s", runner.Name)
+	}
+	if runner.CompletionTime == "" {
+		t.Error("completed runner had no completion time")
+	}
+
+	var step models.JobStep = &models.JobStepAnalysis{JobStepId: stepId, JobContainerId: uuid.New()}
+	cleanupErr := e.CleanUpJobStep(&step, testClient)
+	if cleanupErr != nil {
+		t.Errorf("CleanUpJobStep returned an unexpected error: %s", cleanupErr)
+	}
+
+	logContent, getErr := testClient.Get(containerLogKey(stepId)).Result()
+	if getErr != nil {
+		t.Errorf("could not get stored log: %s", getErr)
+	}
+	if logContent != "mode is analyse, webapp is http://mywebapp:9000\n" {
+		t.Errorf("unexpected log content '%s'", logContent)
+	}
+
+	runners, _ := e.FindRunnerFor(stepId)
+	if len(*runners) != 0 {
+		t.Errorf("expected no runners after cleanup, got %d", len(*runners))
+	}
+}
+
+func TestLocalExecutorFailure(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "executortest")
+	defer os.RemoveAll(dir)
+
+	e := NewLocalExecutor(writeFakeWrapper(t, dir, "1"), "")
+	stepId := uuid.New()
+	launchErr := e.LaunchJob(stepId, "flip-transc", map[string]string{"WRAPPER_MODE": "transcode"}, true, "")
+	if launchErr != nil {
+		t.Fatalf("LaunchJob returned an unexpected error: %s", launchErr)
+	}
+
+	runner := waitForLocalRunner(t, e, stepId)
+	if runner.Status != models.CONTAINER_FAILED {
+		t.Errorf("expected status %d, got %d", models.CONTAINER_FAILED, runner.Status)
+	}
+}
+
+func TestLocalExecutorNotFound(t *testing.T) {
+	e := NewLocalExecutor("", "")
+
+	runners, err := e.FindRunnerFor(uuid.New())
+	if err != nil {
+		t.Errorf("FindRunnerFor returned an unexpected error: %s", err)
+	}
+	if len(*runners) != 0 {
+		t.Errorf("expected no runners for an unknown step, got %d", len(*runners))
+	}
+}
+
+/**
+the wrapper should only get PATH and HOME from the webapp's environment, along with the job's own variables
+*/
+func TestLocalEnvironment(t *testing.T) {
+	os.Setenv("MEDIAFLIPPER_TEST_SECRET", "do-not-pass-on")
+	defer os.Unsetenv("MEDIAFLIPPER_TEST_SECRET")
+	oldHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", oldHome)
+	os.Setenv("HOME", "/home/webapp")
+
+	env := localEnvironment(map[string]string{"WRAPPER_MODE": "analyse", "HOME": "/tmp/job"}, true, "http://mywebapp:9000")
+	sort.Strings(env)
+	expected := []string{"HOME=/tmp/job", "PATH=" + os.Getenv("PATH"), "WEBAPP_BASE=http://mywebapp:9000", "WRAPPER_MODE=analyse"}
+	if strings.Join(env, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected environment %v", env)
+	}
+
+	env = localEnvironment(map[string]string{"HOME": "/tmp/job"}, false, "http://mywebapp:9000")
+	sort.Strings(env)
+	if env[0] != "HOME=/home/webapp" {
+		t.Errorf("expected the existing HOME to be kept when not overwriting, got %v", env)
+	}
+}
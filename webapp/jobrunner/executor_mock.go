@@ -0,0 +1,55 @@
+package jobrunner
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"sync"
+)
+
+/**
+JobExecutor implementation for testing, records what was asked of it and returns canned responses
+*/
+type JobExecutorMock struct {
+	ErrorResponse error
+	RunnerStatus  map[uuid.UUID]models.ContainerStatus
+	LaunchedSteps []uuid.UUID
+	CleanedSteps  []uuid.UUID
+	RunnerLogs    map[uuid.UUID]string //if an entry is present for a step, CleanUpJobStep stores it as the step's logs
+	mutex         sync.Mutex           //CleanUpJobStep is called from a goroutine
+}
+
+func (e *JobExecutorMock) LaunchJob(jobStepId uuid.UUID, jobNameBase string, envVars map[string]string, overwriteExistingVars bool, kubernetesTemplateFile string) error {
+	if e.ErrorResponse != nil {
+		return e.ErrorResponse
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.LaunchedSteps = append(e.LaunchedSteps, jobStepId)
+	return nil
+}
+
+func (e *JobExecutorMock) FindRunnerFor(jobStepId uuid.UUID) (*[]models.JobRunnerDesc, error) {
+	if e.ErrorResponse != nil {
+		return nil, e.ErrorResponse
+	}
+	rtn := []models.JobRunnerDesc{}
+	if status, haveStatus := e.RunnerStatus[jobStepId]; haveStatus {
+		rtn = append(rtn, models.JobRunnerDesc{JobUID: "mock-" + jobStepId.String(), Status: status})
+	}
+	return &rtn, nil
+}
+
+func (e *JobExecutorMock) CleanUpJobStep(step *models.JobStep, redisClient redis.Cmdable) error {
+	if e.ErrorResponse != nil {
+		return e.ErrorResponse
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.CleanedSteps = append(e.CleanedSteps, (*step).StepId())
+	if logContent, haveLogs := e.RunnerLogs[(*step).StepId()]; haveLogs {
+		_, setErr := redisClient.Set(containerLogKey((*step).StepId()), logContent, -1).Result()
+		return setErr
+	}
+	return nil
+}
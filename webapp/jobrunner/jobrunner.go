@@ -4,14 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
 	"github.com/guardian/mediaflipper/common/models"
 	"github.com/guardian/mediaflipper/webapp/bulkprocessor"
-	"k8s.io/client-go/kubernetes"
-	v1batch "k8s.io/client-go/kubernetes/typed/batch/v1"
-	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
-	v13 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"github.com/guardian/mediaflipper/webapp/livestream"
+	"github.com/guardian/mediaflipper/webapp/webhooks"
 	"log"
 	"reflect"
+	"sync"
 	"time"
 )
 
@@ -22,64 +22,71 @@ type JobRunnerIF interface {
 }
 
 type JobRunner struct {
-	redisClient *redis.Client
-	//k8client        *kubernetes.Clientset
-	jobClient       v1batch.JobInterface
-	podClient       v1.PodInterface
-	serviceClient   v13.ServiceInterface
+	redisClient     *redis.Client
+	executor        JobExecutor
 	shutdownChan    chan bool
 	queuePollTicker *time.Ticker
 	templateMgr     *models.JobTemplateManager
 	maxJobs         int32
+	limits          ConcurrencyLimits
+	limitsLock      sync.RWMutex //guards maxJobs and limits, which can be changed by a config reload
 	bulkListDAO     bulkprocessor.BulkListDAO
+	notifier        *webhooks.Notifier //may be nil, in which case no webhooks are sent
 }
 
 /**
-create a new JobRunner object
+create a new JobRunner object.
+`executor` is the backend used to run the job steps, it may be nil if runProcessor is false.
+`limits` gives the per-step-type and per-template concurrency limits, which apply on top of `maxJobs`.
+`notifier` sends the webhooks for job and bulk list events, it may be nil if they are not wanted
 */
-func NewJobRunner(redisClient *redis.Client, k8client *kubernetes.Clientset, templateManager *models.JobTemplateManager, maxJobs int32, runProcessor bool) JobRunner {
+func NewJobRunner(redisClient *redis.Client, executor JobExecutor, templateManager *models.JobTemplateManager, maxJobs int32, limits ConcurrencyLimits, notifier *webhooks.Notifier, runProcessor bool) *JobRunner {
 	shutdownChan := make(chan bool)
 	queuePollTicker := time.NewTicker(1 * time.Second)
 
-	if runProcessor {
-		ns, getNsErr := GetMyNamespace()
-		jobClient := k8client.BatchV1().Jobs(ns)
-		serviceClient := k8client.CoreV1().Services(ns)
-		podClient := k8client.CoreV1().Pods(ns)
+	runner := &JobRunner{
+		redisClient:     redisClient,
+		executor:        executor,
+		shutdownChan:    shutdownChan,
+		queuePollTicker: queuePollTicker,
+		templateMgr:     templateManager,
+		maxJobs:         maxJobs,
+		limits:          limits,
+		bulkListDAO:     bulkprocessor.BulkListDAOImpl{},
+		notifier:        notifier,
+	}
 
-		if getNsErr != nil {
-			log.Printf("ERROR NewJobRunner could not determine current k8s namespace: %s", getNsErr)
-			panic("could not determine namespace")
+	if runProcessor {
+		if executor == nil {
+			panic("cannot run the request processor without a job executor")
 		}
-
-		runner := JobRunner{
-			redisClient:     redisClient,
-			jobClient:       jobClient,
-			serviceClient:   serviceClient,
-			podClient:       podClient,
-			shutdownChan:    shutdownChan,
-			queuePollTicker: queuePollTicker,
-			templateMgr:     templateManager,
-			maxJobs:         maxJobs,
-			bulkListDAO:     bulkprocessor.BulkListDAOImpl{},
+		migrateErr := migrateLegacyRequestQueue(redisClient)
+		if migrateErr != nil {
+			log.Printf("ERROR NewJobRunner could not migrate the legacy request queue: %s", migrateErr)
 		}
-
 		go runner.requestProcessor()
-		return runner
-	} else {
-		runner := JobRunner{
-			redisClient:     redisClient,
-			jobClient:       nil,
-			serviceClient:   nil,
-			podClient:       nil,
-			shutdownChan:    shutdownChan,
-			queuePollTicker: queuePollTicker,
-			templateMgr:     templateManager,
-			maxJobs:         maxJobs,
-			bulkListDAO:     bulkprocessor.BulkListDAOImpl{},
-		}
-		return runner
 	}
+	return runner
+}
+
+/**
+change the limits on the number of job steps that can run at once. Steps that are already running are not affected, the
+new limits apply from the next time that the queues are checked
+*/
+func (j *JobRunner) SetLimits(maxJobs int32, limits ConcurrencyLimits) {
+	j.limitsLock.Lock()
+	defer j.limitsLock.Unlock()
+	j.maxJobs = maxJobs
+	j.limits = limits
+}
+
+/**
+returns the limits on the number of job steps that can run at once, see SetLimits
+*/
+func (j *JobRunner) Limits() (int32, ConcurrencyLimits) {
+	j.limitsLock.RLock()
+	defer j.limitsLock.RUnlock()
+	return j.maxJobs, j.limits
 }
 
 /**
@@ -88,9 +95,110 @@ add the provided JobEntry to the queue for processing
 func (j *JobRunner) AddJob(container *models.JobContainer) error {
 	result := pushToRequestQueue(j.redisClient, container)
 	log.Printf("Enqueued job for processing: %s", container.Id)
+	if result == nil {
+		j.notifier.JobCreated(container)
+	}
 	return result
 }
 
+/**
+change the priority of the given job. If it is still waiting on the request queue then it is moved to the new position,
+otherwise the new value is just recorded on the job.
+this is done while holding the running queue lock, so that an update from the runner can't overwrite the new priority
+or be overwritten by it.
+returns the updated job container
+*/
+func (j *JobRunner) SetJobPriority(jobId uuid.UUID, priority int32) (*models.JobContainer, error) {
+	resultChan := make(chan error)
+	var updated *models.JobContainer
+
+	whenQueueReady := func(lockErr error) {
+		if lockErr != nil {
+			resultChan <- lockErr
+			return
+		}
+
+		container, getErr := models.JobContainerForId(jobId, j.redisClient)
+		if getErr != nil {
+			resultChan <- getErr
+			return
+		}
+
+		container.Priority = models.ClampJobPriority(priority)
+		storErr := container.Store(j.redisClient)
+		if storErr != nil {
+			log.Printf("ERROR JobRunner.SetJobPriority could not store updated job %s: %s", jobId, storErr)
+			resultChan <- storErr
+			return
+		}
+
+		wasQueued, setErr := setRequestQueuePriority(j.redisClient, jobId, container.Priority)
+		if setErr != nil {
+			resultChan <- setErr
+			return
+		}
+		if wasQueued {
+			log.Printf("INFO JobRunner.SetJobPriority job %s is now waiting with priority %d", jobId, container.Priority)
+		} else {
+			log.Printf("INFO JobRunner.SetJobPriority job %s is not waiting, only updated the job record", jobId)
+		}
+		updated = container
+		resultChan <- nil
+	}
+
+	models.WhenQueueAvailable(j.redisClient, models.RUNNING_QUEUE, whenQueueReady, true)
+	setErr := <-resultChan
+	if setErr != nil {
+		return nil, setErr
+	}
+	return updated, nil
+}
+
+/**
+restart a failed or lost job from the step that did not complete, keeping the results of the steps that did.
+the runner for the failed step is cleaned up first, otherwise it would be picked up again and the step would fail straight away.
+the caller should check container.CanResume() first.
+*/
+func (j *JobRunner) ResumeJob(container *models.JobContainer) error {
+	if !container.CanResume() {
+		return errors.New(fmt.Sprintf("job %s is not in a state that can be resumed", container.Id))
+	}
+
+	if j.executor != nil && container.Status == models.JOB_FAILED {
+		for _, failedStep := range container.StepsWithStatus(models.JOB_FAILED) {
+			cleanupErr := j.executor.CleanUpJobStep(&failedStep, j.redisClient)
+			if cleanupErr != nil {
+				log.Printf("WARNING JobRunner.ResumeJob could not clean up failed step %s: %s", failedStep.StepId(), cleanupErr)
+			}
+		}
+	}
+
+	removeErr := j.RemoveJob(container) //make sure there is nothing left over on the queues
+	if removeErr != nil {
+		log.Printf("ERROR JobRunner.ResumeJob could not clear queue entries for %s: %s", container.Id, removeErr)
+		return removeErr
+	}
+
+	container.ResetIncompleteSteps()
+	storErr := container.Store(j.redisClient)
+	if storErr != nil {
+		log.Printf("ERROR JobRunner.ResumeJob could not store job %s: %s", container.Id, storErr)
+		return storErr
+	}
+
+	association := container.AssociatedBulk
+	if association != nil {
+		updateErr := j.bulkListDAO.UpdateById(association.List, association.Item, bulkprocessor.ITEM_STATE_PENDING, j.redisClient)
+		if updateErr != nil {
+			log.Printf("ERROR JobRunner.ResumeJob could not update bulk state for %s: %s", association.List, updateErr)
+		}
+	}
+
+	log.Printf("INFO JobRunner.ResumeJob resuming job %s with %d of %d steps already done", container.Id, container.CompletedSteps, len(container.Steps))
+	j.notifier.ForgetJob(container.Id) //so that the resumed job's events are sent again
+	return pushToRequestQueue(j.redisClient, container)
+}
+
 /**
 remove any possible step from the given job from the queue
 */
@@ -112,9 +220,13 @@ func (j *JobRunner) RemoveJob(container *models.JobContainer) error {
 	pipe := j.redisClient.Pipeline()
 	for _, entry := range entries {
 		models.RemoveFromQueue(pipe, models.RUNNING_QUEUE, entry)
-		removeFromRequestQueue(pipe, container)
 	}
+	removeFromRequestQueue(pipe, container)
+	removeFromRetryQueue(pipe, container)
 	_, pipeErr := pipe.Exec()
+	if pipeErr == nil {
+		livestream.QueueChanged(container, "", nil, livestream.QUEUE_REMOVED, j.redisClient)
+	}
 	return pipeErr
 }
 
@@ -134,9 +246,9 @@ func (j *JobRunner) requestProcessor() {
 }
 
 /**
-trigger the action for a given item and put it onto the running queue if successful
+trigger the actions for the steps of the given job that are ready to run and put them onto the running queue if successful
 */
-func (j *JobRunner) actionRequest(container *models.JobContainer) error {
+func (j *JobRunner) actionRequest(container *models.JobContainer, usage *ConcurrencyUsage) error {
 	association := container.AssociatedBulk
 	if association != nil {
 		updateErr := j.bulkListDAO.UpdateById(association.List, association.Item, bulkprocessor.ITEM_STATE_ACTIVE, j.redisClient)
@@ -144,12 +256,86 @@ func (j *JobRunner) actionRequest(container *models.JobContainer) error {
 			log.Printf("ERROR: actionRequest could not update bulk state for %s: %s", association.List, updateErr)
 		}
 	}
-	initialStep := container.InitialStep()
-	if initialStep == nil {
+	if container.InitialStep() == nil {
 		log.Printf("WARNING: Job %s from template %s had no steps!", container.Id.String(), container.JobTemplateId.String())
 		return errors.New("job had no steps!")
-	} else {
-		return j.actionStep(initialStep, container)
+	}
+
+	//if this job was put back onto the request queue part-way through, because a concurrency limit had been reached or
+	//a step is being retried, then this picks up from where it left off
+	stepsToRun := container.ReadySteps()
+	if len(stepsToRun) == 0 {
+		//this can happen if the step that was waiting has since been started by another branch of the job finishing
+		log.Printf("INFO: actionRequest job %s has no steps that are ready to run, other branches must still be running", container.Id)
+		return nil
+	}
+	return j.actionSteps(stepsToRun, container, usage)
+}
+
+/**
+start each of the given steps. If there are concurrency limits then any step that would go over them is not started, and
+the job is put back onto the request queue to start it later.
+the steps that are started are marked as such on `container`, but it is not stored.
+*/
+func (j *JobRunner) actionSteps(steps []models.JobStep, container *models.JobContainer, usage *ConcurrencyUsage) error {
+	_, limits := j.Limits()
+	deferred := false
+	for _, step := range steps {
+		if usage != nil && !limits.Allows(usage, container, step) {
+			deferred = true
+			continue
+		}
+		runErr := j.actionStep(step, container)
+		if runErr != nil {
+			return runErr
+		}
+		//mark the step so that it is not started again if the job comes back off the request queue
+		container.UpdateStepById(step.StepId(), step.WithNewStatus(models.JOB_STARTED, nil))
+		stepId := step.StepId()
+		livestream.QueueChanged(container, models.RUNNING_QUEUE, &stepId, livestream.QUEUE_ADDED, j.redisClient)
+		livestream.StepChanged(container, stepId, j.redisClient)
+		if usage != nil {
+			usage.Add(container, step, 1)
+		}
+	}
+
+	if deferred {
+		log.Printf("INFO actionSteps Job %s: concurrency limit reached for a step, returning to the request queue", container.Id)
+		pushErr := pushToRequestQueue(j.redisClient, container)
+		if pushErr != nil {
+			log.Printf("ERROR actionSteps could not return job %s to the request queue: %s", container.Id, pushErr)
+			return pushErr
+		}
+	}
+	return nil
+}
+
+/**
+stop any other steps of the job that are still running, after one branch has failed or been lost.
+they are taken off the running queue, their external jobs are removed and they are marked as aborted on `container`, which
+is not stored.
+*/
+func (j *JobRunner) stopRunningSteps(container *models.JobContainer, msg string) {
+	for _, step := range container.StepsWithStatus(models.JOB_STARTED) {
+		log.Printf("INFO stopRunningSteps stopping step %s of %s: %s", step.StepId(), container.Id, msg)
+		pipe := j.redisClient.Pipeline()
+		for _, statusValue := range models.ALL_JOB_STATUS {
+			models.RemoveFromQueue(pipe, models.RUNNING_QUEUE, models.JobQueueEntry{JobId: container.Id, StepId: step.StepId(), Status: statusValue})
+		}
+		_, pipeErr := pipe.Exec()
+		if pipeErr != nil {
+			log.Printf("ERROR stopRunningSteps could not remove step %s from the running queue: %s", step.StepId(), pipeErr)
+		}
+
+		go func(jobStep models.JobStep, containerId uuid.UUID) {
+			cleanupErr := j.executor.CleanUpJobStep(&jobStep, j.redisClient)
+			if cleanupErr != nil {
+				log.Printf("ERROR stopRunningSteps could not clean up jobstep %s for %s: %s", jobStep.StepId(), containerId, cleanupErr)
+			}
+		}(step, container.Id)
+
+		container.UpdateStepById(step.StepId(), step.WithNewStatus(models.JOB_ABORTED, &msg))
+		livestream.StepChanged(container, step.StepId(), j.redisClient)
 	}
 }
 
@@ -158,7 +344,7 @@ func (j *JobRunner) actionStep(step models.JobStep, container *models.JobContain
 	var newQueueEntry *models.JobQueueEntry
 
 	if isAnalysis {
-		err := CreateAnalysisJob(*analysisJob, container.OutputPath, j.jobClient, j.serviceClient)
+		err := CreateAnalysisJob(*analysisJob, container.OutputPath, j.executor)
 		if err != nil {
 			log.Print("Could not create analysis job! ", err)
 			return err
@@ -173,7 +359,7 @@ func (j *JobRunner) actionStep(step models.JobStep, container *models.JobContain
 
 	thumbJob, isThumb := step.(*models.JobStepThumbnail)
 	if isThumb {
-		err := CreateThumbnailJob(*thumbJob, container.OutputPath, j.jobClient, j.serviceClient)
+		err := CreateThumbnailJob(*thumbJob, container.OutputPath, j.executor)
 		if err != nil {
 			log.Print("Could not create thumbnail job! ", err)
 			return err
@@ -188,7 +374,7 @@ func (j *JobRunner) actionStep(step models.JobStep, container *models.JobContain
 
 	tcJob, isTc := step.(*models.JobStepTranscode)
 	if isTc {
-		err := CreateTranscodeJob(*tcJob, container.OutputPath, j.jobClient, j.serviceClient)
+		err := CreateTranscodeJob(*tcJob, container.OutputPath, j.executor)
 		if err != nil {
 			log.Print("Could not create transcode job! ", err)
 			return err
@@ -203,7 +389,7 @@ func (j *JobRunner) actionStep(step models.JobStep, container *models.JobContain
 
 	custJob, isCust := step.(*models.JobStepCustom)
 	if isCust {
-		err := CreateCustomJob(*custJob, container, j.jobClient, j.serviceClient, j.redisClient)
+		err := CreateCustomJob(*custJob, container, j.executor, j.redisClient)
 		if err != nil {
 			log.Print("Could not create custom job! ", err)
 			return err
@@ -216,6 +402,21 @@ func (j *JobRunner) actionStep(step models.JobStep, container *models.JobContain
 		}
 	}
 
+	qcJob, isQc := step.(*models.JobStepQC)
+	if isQc {
+		err := CreateQCJob(*qcJob, container, j.executor, j.redisClient)
+		if err != nil {
+			log.Print("Could not create QC job! ", err)
+			return err
+		}
+		log.Printf("External job created for %s with type qc", qcJob.JobStepId)
+		newQueueEntry = &models.JobQueueEntry{
+			JobId:  step.ContainerId(),
+			StepId: step.StepId(),
+			Status: models.JOB_PENDING,
+		}
+	}
+
 	if newQueueEntry != nil {
 		pushErr := models.AddToQueue(j.redisClient, models.RUNNING_QUEUE, *newQueueEntry)
 		if pushErr != nil {
@@ -250,9 +451,14 @@ func (j *JobRunner) clearCompletedTick() {
 
 	defer models.ReleaseQueueLock(j.redisClient, models.RUNNING_QUEUE) //ensure that the lock is always release!
 
+	var usage *ConcurrencyUsage
+	if _, limits := j.Limits(); limits.HasLimits() {
+		usage = usageFromSnapshot(queueSnapshot, j.redisClient)
+	}
+
 	for _, queueEntry := range queueSnapshot {
-		runners, runErr := FindRunnerFor(queueEntry.StepId, j.jobClient)
-		if runErr != nil { //could not retrieve a runner from k8. Assume that this is a transient error, don't dump it from the queue
+		runners, runErr := j.executor.FindRunnerFor(queueEntry.StepId)
+		if runErr != nil { //could not retrieve a runner from the executor. Assume that this is a transient error, don't dump it from the queue
 			log.Print("Could not get runner for ", queueEntry.StepId, ": ", runErr)
 			removeErr := models.RemoveFromQueue(j.redisClient, models.RUNNING_QUEUE, queueEntry)
 			if removeErr != nil {
@@ -272,20 +478,27 @@ func (j *JobRunner) clearCompletedTick() {
 				continue //pick it up on the next iteration
 			}
 			jobStep := container.FindStepById(queueEntry.StepId)
+			errMsg := fmt.Sprintf("could not get any runners for step id %s", queueEntry.StepId)
 			if jobStep == nil {
 				log.Printf("ERROR clearCompletedTick job entry %s does not have a step with id %s so can't mark as lost", queueEntry.JobId, queueEntry.StepId)
+			} else if j.retryFailedStep(container, queueEntry.StepId, models.ERROR_CLASS_LOST, errMsg) {
+				log.Printf("INFO clearCompletedTick lost step %s of job %s will be retried", queueEntry.StepId, queueEntry.JobId)
 			} else {
-				errMsg := fmt.Sprintf("could not get any runners for step id %s", queueEntry.StepId)
+				jobStep = container.FindStepById(queueEntry.StepId) //pick up any attempt that was recorded
 				updatedStep := (*jobStep).WithNewStatus(models.JOB_LOST, &errMsg)
 				updateErr := container.UpdateStepById(updatedStep.StepId(), updatedStep)
 				if updateErr != nil {
 					log.Printf("ERROR clearCompletedTick could not save updated job step: %s", updateErr)
 				}
 				container.Status = models.JOB_LOST
+				j.stopRunningSteps(container, "Another branch of this job was lost")
 				storErr := container.Store(j.redisClient)
 				if storErr != nil {
 					log.Printf("ERROR clearcompletedTick could not store updated job: %s", storErr)
 				}
+				livestream.StepChanged(container, queueEntry.StepId, j.redisClient)
+				livestream.JobChanged(container, j.redisClient)
+				j.notifier.JobFinished(container)
 			}
 			removeErr := models.RemoveFromQueue(j.redisClient, models.RUNNING_QUEUE, queueEntry)
 			if removeErr != nil {
@@ -312,43 +525,64 @@ func (j *JobRunner) clearCompletedTick() {
 				continue //pick it up on the next iteration
 			}
 			log.Printf("DEBUG clearCompletedTick External job step %s completed", queueEntry.StepId)
-			nextStep := container.CompleteStepAndMoveOn() //this updates the internal state of `container`
+			if usage != nil {
+				completedStep := container.FindStepById(queueEntry.StepId)
+				if completedStep != nil {
+					usage.Add(container, *completedStep, -1)
+				}
+			}
+			completeErr := container.CompleteStepById(queueEntry.StepId) //this updates the internal state of `container`
+			if completeErr != nil {
+				log.Printf("ERROR clearCompletedTick could not complete step: %s", completeErr)
+			}
 
-			storErr := container.Store(j.redisClient)
-			if storErr != nil {
-				log.Printf("ERROR clearCompletedTick Could not store job container: %s", storErr)
+			var nextSteps []models.JobStep
+			if container.HasStopped() {
+				log.Printf("INFO clearCompletedTick job %s has already stopped, not starting any more steps", container.Id)
+				j.stopRunningSteps(container, "Another branch of this job failed") //in case the step failed the job when its results were received
 			} else {
-				log.Printf("DEBUG clearCompletedTick Job completed and saved")
+				nextSteps = container.SkipUnmetSteps(j.redisClient) //steps whose conditions are not met are marked as skipped and passed over
 			}
 
-			//clean up the job and pod and extract the log, asynchronously
-			go func() {
-				jobStep := container.FindStepById(queueEntry.StepId)
-				if jobStep == nil {
-					log.Printf("WARNING clearCompletedTick could not find jobstep with ID %s within job container with id %s", queueEntry.StepId, container.Id)
-				} else {
-					cleanupErr := CleanUpJobStep(jobStep, j.jobClient, j.podClient, j.redisClient)
-					if cleanupErr != nil {
-						log.Printf("ERROR clearCompletedTick could not clean up jobstep %s for %s: %s", (*jobStep).StepId(), container.Id, cleanupErr)
-					}
-				}
-			}()
-
-			if nextStep != nil { //nil => this was the last jobstep, not nil => another step to queue
+			if len(nextSteps) > 0 {
 				log.Printf("Job %s: Moving to next job step ", container.Id)
-				runErr := j.actionStep(nextStep, container)
+				runErr := j.actionSteps(nextSteps, container, usage)
 				if runErr != nil {
 					log.Print("Could not action next step: ", runErr)
 					container.Status = models.JOB_FAILED
 					container.ErrorMessage = runErr.Error()
 					t := time.Now()
 					container.EndTime = &t
-					storErr = container.Store(j.redisClient)
-					if storErr != nil {
-						log.Printf("Could not store updated job container: %s", storErr)
-					}
+					j.stopRunningSteps(container, "Another step of this job could not be started")
 				}
+			}
+
+			storErr := container.Store(j.redisClient)
+			if storErr != nil {
+				log.Printf("ERROR clearCompletedTick Could not store job container: %s", storErr)
 			} else {
+				log.Printf("DEBUG clearCompletedTick Job completed and saved")
+			}
+			livestream.StepChanged(container, queueEntry.StepId, j.redisClient)
+			livestream.JobChanged(container, j.redisClient)
+			j.notifier.StepCompleted(container, queueEntry.StepId)
+			j.notifier.JobFinished(container)
+
+			//clean up the job and pod and extract the log, asynchronously.
+			//look up the step here, `queueEntry` is re-used by the next iteration of the loop
+			completedJobStep := container.FindStepById(queueEntry.StepId)
+			if completedJobStep == nil {
+				log.Printf("WARNING clearCompletedTick could not find jobstep with ID %s within job container with id %s", queueEntry.StepId, container.Id)
+			} else {
+				go func(jobStep *models.JobStep, containerId uuid.UUID) {
+					cleanupErr := j.executor.CleanUpJobStep(jobStep, j.redisClient)
+					if cleanupErr != nil {
+						log.Printf("ERROR clearCompletedTick could not clean up jobstep %s for %s: %s", (*jobStep).StepId(), containerId, cleanupErr)
+					}
+				}(completedJobStep, container.Id)
+			}
+
+			if container.Status == models.JOB_COMPLETED {
 				association := container.AssociatedBulk
 				if association != nil {
 					log.Printf("DEBUG clearCompletedTick: updating bulk item %s in list %s to completed", association.Item, association.List)
@@ -356,6 +590,17 @@ func (j *JobRunner) clearCompletedTick() {
 					if updateErr != nil {
 						log.Printf("ERROR: actionRequest could not update bulk state for %s: %s", association.List, updateErr)
 					}
+					j.notifier.BulkItemFinished(association.List)
+				}
+			} else if container.Status == models.JOB_FAILED {
+				association := container.AssociatedBulk
+				if association != nil {
+					log.Printf("DEBUG clearCompletedTick: updating bulk item %s in list %s to failed", association.Item, association.List)
+					updateErr := j.bulkListDAO.UpdateById(association.List, association.Item, bulkprocessor.ITEM_STATE_FAILED, j.redisClient)
+					if updateErr != nil {
+						log.Printf("ERROR: actionRequest could not update bulk state for %s: %s", association.List, updateErr)
+					}
+					j.notifier.BulkItemFinished(association.List)
 				}
 			}
 		case models.CONTAINER_FAILED:
@@ -371,13 +616,24 @@ func (j *JobRunner) clearCompletedTick() {
 				log.Printf("Could not get job master data for %s: %s", queueEntry.JobId, getErr)
 				continue //pick it up on the next iteration
 			}
-			container.FailCurrentStep("Kubernetes container failed")
+			if j.retryFailedStep(container, queueEntry.StepId, models.ERROR_CLASS_PROCESS, "External job failed") {
+				continue //the step will be run again, don't fail the job
+			}
+			failMsg := "External job failed"
+			if failedStep := container.FindStepById(queueEntry.StepId); failedStep != nil && len((*failedStep).Attempts()) > 1 {
+				failMsg = fmt.Sprintf("External job failed after %d attempts", len((*failedStep).Attempts()))
+			}
+			container.FailStepById(queueEntry.StepId, failMsg)
+			j.stopRunningSteps(container, "Another branch of this job failed")
 			storErr := container.Store(j.redisClient)
 			if storErr != nil {
 				log.Printf("Could not store job container: %s", storErr)
 			} else {
 				log.Printf("Job failed and saved")
 			}
+			livestream.StepChanged(container, queueEntry.StepId, j.redisClient)
+			livestream.JobChanged(container, j.redisClient)
+			j.notifier.JobFinished(container)
 
 			association := container.AssociatedBulk
 			if association != nil {
@@ -386,6 +642,7 @@ func (j *JobRunner) clearCompletedTick() {
 				if updateErr != nil {
 					log.Printf("ERROR: actionRequest could not update bulk state for %s: %s", association.List, updateErr)
 				}
+				j.notifier.BulkItemFinished(association.List)
 			} else {
 				log.Printf("DEBUG clearCompletedTick: job %s has no associated bulk item", container.Id)
 			}
@@ -402,6 +659,11 @@ func (j *JobRunner) clearCompletedTick() {
 					log.Printf("Could not get job master data for %s: %s", queueEntry.JobId, getErr)
 					continue //pick it up on the next iteration
 				}
+				if container.HasStopped() {
+					//another branch of the job has failed since the snapshot was taken, so this step has been stopped already
+					log.Printf("INFO clearCompletedTick job %s has already stopped, not updating step %s", container.Id, queueEntry.StepId)
+					continue
+				}
 				jobStep := container.FindStepById(queueEntry.StepId)
 
 				updatedJobStep := (*jobStep).WithNewStatus(models.JOB_STARTED, nil)
@@ -416,7 +678,10 @@ func (j *JobRunner) clearCompletedTick() {
 					return
 				}
 
-				container.Steps[container.CompletedSteps] = updatedJobStep
+				updateErr := container.UpdateStepById(queueEntry.StepId, updatedJobStep)
+				if updateErr != nil {
+					log.Printf("ERROR clearCompletedTick could not update step %s of %s: %s", queueEntry.StepId, container.Id, updateErr)
+				}
 
 				if container.Status != models.JOB_STARTED {
 					container.Status = models.JOB_STARTED
@@ -430,6 +695,9 @@ func (j *JobRunner) clearCompletedTick() {
 				} else {
 					log.Printf("Job started, container saved")
 				}
+				livestream.StepChanged(container, queueEntry.StepId, j.redisClient)
+				livestream.JobChanged(container, j.redisClient)
+				j.notifier.JobStarted(container)
 			}
 		}
 	}
@@ -454,6 +722,22 @@ func (j *JobRunner) waitingQueueTick() {
 	models.SetQueueLock(j.redisClient, models.RUNNING_QUEUE)
 	defer models.ReleaseQueueLock(j.redisClient, models.RUNNING_QUEUE) //ensure that the lock is always released!
 
+	_, promoteErr := promoteDueRetries(j.redisClient)
+	if promoteErr != nil {
+		log.Printf("ERROR: Could not move due retries onto the request queue: %s", promoteErr)
+	}
+
+	maxJobs, limits := j.Limits()
+	var usage *ConcurrencyUsage
+	if limits.HasLimits() {
+		var usageErr error
+		usage, usageErr = GetConcurrencyUsage(j.redisClient)
+		if usageErr != nil {
+			log.Printf("ERROR: Could not determine current concurrency usage: %s", usageErr)
+			return
+		}
+	}
+
 	for {
 		//need to update and check this every iteration as we are putting stuff onto the queue
 		queuelen, getErr := models.GetQueueLength(j.redisClient, models.RUNNING_QUEUE)
@@ -461,30 +745,36 @@ func (j *JobRunner) waitingQueueTick() {
 			log.Printf("ERROR: Could not get queue length: %s", getErr)
 			continue
 		}
-		if queuelen >= int64(j.maxJobs) {
+		if queuelen >= int64(maxJobs) {
 			log.Printf("Max running jobs reached")
 			break
 		}
 
-		newJob, getErr := getNextRequestQueueEntry(j.redisClient)
+		newJob, getErr := j.nextRunnableRequest(usage)
 		if getErr == nil {
 			if newJob == nil {
 				break
 			} else {
-				actioningErr := j.actionRequest(newJob)
+				actioningErr := j.actionRequest(newJob, usage)
 				if actioningErr != nil {
 					log.Printf("Could not action job: %s", actioningErr)
 					newJob.Status = models.JOB_FAILED
 					newJob.ErrorMessage = actioningErr.Error()
+					j.stopRunningSteps(newJob, "Another step of this job could not be started")
 					storeErr := newJob.Store(j.redisClient)
 					if storeErr != nil {
 						log.Printf("Could not save job description: %s", storeErr)
 						return
 					}
+					livestream.JobChanged(newJob, j.redisClient)
+					j.notifier.JobFinished(newJob)
 				} else {
-					t := time.Now()
-					newJob.StartTime = &t
+					if newJob.CompletedSteps == 0 && newJob.Status != models.JOB_STARTED { //don't reset the start time of a job that was waiting part-way through or for a retry
+						t := time.Now()
+						newJob.StartTime = &t
+					}
 					newJob.Store(j.redisClient)
+					livestream.JobChanged(newJob, j.redisClient)
 				}
 			}
 		} else {
@@ -494,3 +784,21 @@ func (j *JobRunner) waitingQueueTick() {
 	}
 
 }
+
+/**
+get the next job that can be started from the request queue.
+if there are concurrency limits then any job whose next step would go over a limit is skipped and left on the queue
+*/
+func (j *JobRunner) nextRunnableRequest(usage *ConcurrencyUsage) (*models.JobContainer, error) {
+	if usage == nil {
+		return getNextRequestQueueEntry(j.redisClient)
+	}
+	_, limits := j.Limits()
+	return getNextAllowedRequest(j.redisClient, func(container *models.JobContainer) bool {
+		step := nextStepFor(container)
+		if step == nil { //let actionRequest deal with it
+			return true
+		}
+		return limits.Allows(usage, container, step)
+	})
+}
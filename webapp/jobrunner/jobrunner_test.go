@@ -90,7 +90,7 @@ func TestJobRunner_clearCompletedTick_notfound(t *testing.T) {
 	}
 
 	runner := JobRunner{redisClient: testClient,
-		jobClient:       &mockJobClient,
+		executor:        &KubernetesExecutor{jobClient: &mockJobClient},
 		shutdownChan:    nil,
 		queuePollTicker: nil,
 		templateMgr:     nil,
@@ -230,3 +230,167 @@ func TestJobRunner_RemoveJob(t *testing.T) {
 		}
 	}
 }
+
+/**
+ResumeJob should reset the failed step and the ones after it, keep the completed ones, and put the job back on the request queue
+so that it continues from the failed step
+*/
+func TestJobRunner_ResumeJob(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	nowTime := time.Now()
+	jobId := uuid.New()
+	analysisResultId := uuid.New()
+	failedMsg := "External job failed"
+	job := &models.JobContainer{
+		Id: jobId,
+		Steps: []models.JobStep{
+			&models.JobStepAnalysis{JobStepType: "analysis", JobStepId: uuid.New(), JobContainerId: jobId, StatusValue: models.JOB_COMPLETED, ResultId: analysisResultId, StartTime: &nowTime},
+			&models.JobStepCustom{JobStepType: "custom", JobStepId: uuid.New(), JobContainerId: jobId, StatusValue: models.JOB_FAILED, LastError: failedMsg, StartTime: &nowTime},
+			&models.JobStepCustom{JobStepType: "custom", JobStepId: uuid.New(), JobContainerId: jobId, StatusValue: models.JOB_PENDING, StartTime: &nowTime},
+		},
+		CompletedSteps: 1,
+		Status:         models.JOB_FAILED,
+		ErrorMessage:   failedMsg,
+		StartTime:      &nowTime,
+		EndTime:        &nowTime,
+	}
+	job.Store(testClient)
+
+	mockExecutor := &JobExecutorMock{}
+	runner := JobRunner{redisClient: testClient, executor: mockExecutor, maxJobs: 10}
+
+	resumeErr := runner.ResumeJob(job)
+	if resumeErr != nil {
+		t.Fatalf("ResumeJob failed unexpectedly: %s", resumeErr)
+	}
+
+	if len(mockExecutor.CleanedSteps) != 1 || mockExecutor.CleanedSteps[0] != job.Steps[1].StepId() {
+		t.Errorf("expected the failed step's runner to be cleaned up, cleaned %v", mockExecutor.CleanedSteps)
+	}
+
+	resumed, _ := models.JobContainerForId(jobId, testClient)
+	if resumed.Status != models.JOB_PENDING || resumed.ErrorMessage != "" || resumed.EndTime != nil {
+		t.Errorf("expected job to be pending with no error or end time, got status %d error '%s'", resumed.Status, resumed.ErrorMessage)
+	}
+	if resumed.CompletedSteps != 1 {
+		t.Errorf("expected completed steps to be kept at 1, got %d", resumed.CompletedSteps)
+	}
+	analysisStep := resumed.Steps[0].(*models.JobStepAnalysis)
+	if analysisStep.StatusValue != models.JOB_COMPLETED || analysisStep.ResultId != analysisResultId {
+		t.Errorf("expected completed analysis step and its result to be kept, got %v", analysisStep)
+	}
+	for i := 1; i < 3; i++ {
+		if resumed.Steps[i].Status() != models.JOB_PENDING || resumed.Steps[i].ErrorMessage() != "" {
+			t.Errorf("expected step %d to be reset to pending, got status %d error '%s'", i, resumed.Steps[i].Status(), resumed.Steps[i].ErrorMessage())
+		}
+	}
+
+	runner.waitingQueueTick()
+	if len(mockExecutor.LaunchedSteps) != 1 || mockExecutor.LaunchedSteps[0] != job.Steps[1].StepId() {
+		t.Errorf("expected the job to continue from the failed step, launched %v", mockExecutor.LaunchedSteps)
+	}
+
+	//a job that is already running can't be resumed
+	if runner.ResumeJob(resumed) == nil {
+		t.Error("expected ResumeJob to refuse a job that is not failed")
+	}
+}
+
+/**
+when a step completes, every step that depends only on finished steps should be started at once. If one branch then fails
+the others should be stopped and the job failed.
+*/
+func TestJobRunner_clearCompletedTick_branches(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	nowTime := time.Now()
+	jobId := uuid.New()
+	analysisStep := &models.JobStepAnalysis{JobStepType: "analysis", JobStepId: uuid.New(), JobContainerId: jobId, StatusValue: models.JOB_STARTED, StartTime: &nowTime}
+	firstBranch := &models.JobStepCustom{JobStepType: "custom", JobStepId: uuid.New(), JobContainerId: jobId, MediaFile: "/path/to/media"}
+	secondBranch := &models.JobStepCustom{JobStepType: "custom", JobStepId: uuid.New(), JobContainerId: jobId, MediaFile: "/path/to/media"}
+	finalStep := &models.JobStepCustom{JobStepType: "custom", JobStepId: uuid.New(), JobContainerId: jobId, MediaFile: "/path/to/media"}
+	job := &models.JobContainer{
+		Id:    jobId,
+		Steps: []models.JobStep{analysisStep, firstBranch, secondBranch, finalStep},
+		Dependencies: map[uuid.UUID][]uuid.UUID{
+			analysisStep.JobStepId: {},
+			firstBranch.JobStepId:  {analysisStep.JobStepId},
+			secondBranch.JobStepId: {analysisStep.JobStepId},
+			finalStep.JobStepId:    {firstBranch.JobStepId, secondBranch.JobStepId},
+		},
+		Status:    models.JOB_STARTED,
+		StartTime: &nowTime,
+	}
+	job.Store(testClient)
+	models.AddToQueue(testClient, models.RUNNING_QUEUE, models.JobQueueEntry{JobId: jobId, StepId: analysisStep.JobStepId, Status: models.JOB_STARTED})
+
+	mockExecutor := &JobExecutorMock{
+		RunnerStatus: map[uuid.UUID]models.ContainerStatus{
+			analysisStep.JobStepId: models.CONTAINER_COMPLETED,
+		},
+	}
+	runner := JobRunner{redisClient: testClient, executor: mockExecutor, maxJobs: 10}
+
+	runner.clearCompletedTick()
+
+	if len(mockExecutor.LaunchedSteps) != 2 {
+		t.Fatalf("expected both branches to be launched, got %v", mockExecutor.LaunchedSteps)
+	}
+	running, _ := models.SnapshotQueue(testClient, models.RUNNING_QUEUE)
+	if len(running) != 2 {
+		t.Errorf("expected both branches on the running queue, got %s", spew.Sdump(running))
+	}
+	updated, _ := models.JobContainerForId(jobId, testClient)
+	if updated.CompletedSteps != 1 || updated.Steps[1].Status() != models.JOB_STARTED || updated.Steps[2].Status() != models.JOB_STARTED {
+		t.Errorf("expected the branches to be started, got %d completed steps and statuses %d %d", updated.CompletedSteps, updated.Steps[1].Status(), updated.Steps[2].Status())
+	}
+	if updated.Steps[3].Status() != models.JOB_PENDING {
+		t.Errorf("final step should wait for both branches, got status %d", updated.Steps[3].Status())
+	}
+
+	//now the first branch fails, the second one should be stopped
+	mockExecutor.RunnerStatus[firstBranch.JobStepId] = models.CONTAINER_FAILED
+	mockExecutor.RunnerStatus[secondBranch.JobStepId] = models.CONTAINER_ACTIVE
+	runner.clearCompletedTick()
+	time.Sleep(100 * time.Millisecond) //cleanup is done in the background
+
+	failed, _ := models.JobContainerForId(jobId, testClient)
+	if failed.Status != models.JOB_FAILED {
+		t.Errorf("expected the job to fail, got status %d", failed.Status)
+	}
+	if failed.Steps[1].Status() != models.JOB_FAILED || failed.Steps[2].Status() != models.JOB_ABORTED || failed.Steps[3].Status() != models.JOB_PENDING {
+		t.Errorf("got unexpected step statuses %d %d %d", failed.Steps[1].Status(), failed.Steps[2].Status(), failed.Steps[3].Status())
+	}
+	remaining, _ := models.SnapshotQueue(testClient, models.RUNNING_QUEUE)
+	if len(remaining) != 0 {
+		t.Errorf("expected nothing left on the running queue, got %s", spew.Sdump(remaining))
+	}
+	mockExecutor.mutex.Lock()
+	defer mockExecutor.mutex.Unlock()
+	cleanedSecond := false
+	for _, stepId := range mockExecutor.CleanedSteps {
+		if stepId == secondBranch.JobStepId {
+			cleanedSecond = true
+		}
+	}
+	if !cleanedSecond {
+		t.Errorf("expected the second branch to be cleaned up, cleaned %v", mockExecutor.CleanedSteps)
+	}
+}
@@ -2,76 +2,311 @@ package jobrunner
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
 	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/webapp/livestream"
 	"log"
+	"math"
+	"time"
 )
 
+/**
+the request queue is a sorted set of job container ids. The score is made up of the inverted priority multiplied by this value
+plus the enqueue time in milliseconds, so that ZPOPMIN gives us the highest priority job and then the oldest one within that priority.
+millisecond timestamps stay below this value until the year 2286 and the largest possible score is still exactly representable
+as a float64
+*/
+const requestQueuePriorityMultiplier = 1e13
+
+func requestQueueScore(priority int32, enqueuedMillis float64) float64 {
+	return float64(models.JOB_PRIORITY_MAX-models.ClampJobPriority(priority))*requestQueuePriorityMultiplier + enqueuedMillis
+}
+
 func getNextRequestQueueEntry(client *redis.Client) (*models.JobContainer, error) {
 	return getNextJobRunnerRequest(client, models.REQUEST_QUEUE)
 }
 
+/**
+pops the highest priority job id off the given queue and returns the stored job container for it.
+returns nil with no error if there was nothing waiting.
+*/
 func getNextJobRunnerRequest(client *redis.Client, queueName models.QueueName) (*models.JobContainer, error) {
 	jobKey := fmt.Sprintf("mediaflipper:%s", queueName)
 
-	result := client.LPop(jobKey)
-
-	content, getErr := result.Result()
+	results, getErr := client.ZPopMin(jobKey).Result()
 	if getErr != nil {
-		if getErr.Error() == "redis: nil" { //FIXME: there must be a better way of making this check!
-			return nil, nil
-		}
 		log.Print("ERROR jobrunnerrequestDAO/getNextJobRunnerRequest Could not get next item from job queue: ", getErr)
 		return nil, getErr
 	}
 
-	if content == "" {
-		log.Print("DEBUG jobrunnerrequestDAO/getNextJobRunnerRequest no items in queue right now")
+	if len(results) == 0 {
 		return nil, nil
 	}
-	var rq models.JobContainer
-	log.Printf("DEBUG: Got %s for %s", content, jobKey)
 
-	marshalErr := json.Unmarshal([]byte(content), &rq)
-	if marshalErr != nil {
-		log.Print("ERROR jobrunnerrequestDAO/getNextJobRunnerRequest Could not decode item from job queue: ", marshalErr)
-		//it's already been removed by the LPOP operation
-		return nil, marshalErr
+	idString, isString := results[0].Member.(string)
+	if !isString {
+		log.Printf("ERROR jobrunnerrequestDAO/getNextJobRunnerRequest got unexpected member %v from %s", results[0].Member, jobKey)
+		return nil, errors.New(fmt.Sprintf("unexpected data in %s", jobKey))
+	}
+	log.Printf("DEBUG: Got %s for %s", idString, jobKey)
+
+	jobId, parseErr := uuid.Parse(idString)
+	if parseErr != nil {
+		log.Printf("ERROR jobrunnerrequestDAO/getNextJobRunnerRequest Could not parse job id '%s' from job queue: %s", idString, parseErr)
+		//it's already been removed by the ZPOPMIN operation
+		return nil, parseErr
+	}
+
+	container, getErr := models.JobContainerForId(jobId, client)
+	if getErr != nil {
+		return nil, getErr
 	}
-	return &rq, nil
+	container.QueuedAtMillis = math.Mod(results[0].Score, requestQueuePriorityMultiplier)
+	return container, nil
 }
 
-func pushToRequestQueue(client redis.Cmdable, item *models.JobContainer) error {
-	encodedContent, marshalErr := json.Marshal(*item)
-	if marshalErr != nil {
-		log.Print("ERROR jobrunnerrequestDAO/pushToRequestQueue Could not encode content for ", item, ": ", marshalErr)
-		return marshalErr
+/**
+the most request queue entries to look at in one go when searching for a job that can be run. Jobs further back than
+this wait until some of the ones ahead of them have been started, rather than every tick reading the whole queue.
+*/
+const requestQueueScanLimit = 100
+
+/**
+scans the front of the request queue in priority order and removes and returns the first job that `canRun` accepts.
+jobs that are not accepted are left where they are. returns nil with no error if nothing could be run.
+*/
+func getNextAllowedRequest(client *redis.Client, canRun func(container *models.JobContainer) bool) (*models.JobContainer, error) {
+	jobKey := fmt.Sprintf("mediaflipper:%s", models.REQUEST_QUEUE)
+
+	entries, rangeErr := client.ZRangeWithScores(jobKey, 0, requestQueueScanLimit-1).Result()
+	if rangeErr != nil {
+		log.Printf("ERROR jobrunnerrequestDAO/getNextAllowedRequest could not range %s: %s", jobKey, rangeErr)
+		return nil, rangeErr
+	}
+
+	for _, entry := range entries {
+		idString, _ := entry.Member.(string)
+		jobId, parseErr := uuid.Parse(idString)
+		if parseErr != nil {
+			log.Printf("ERROR jobrunnerrequestDAO/getNextAllowedRequest dropping invalid job id '%s' from job queue: %s", idString, parseErr)
+			client.ZRem(jobKey, idString)
+			continue
+		}
+		container, getErr := models.JobContainerForId(jobId, client)
+		if getErr == redis.Nil {
+			log.Printf("ERROR jobrunnerrequestDAO/getNextAllowedRequest dropping job %s from job queue, it does not exist", jobId)
+			client.ZRem(jobKey, idString)
+			continue
+		} else if getErr != nil {
+			return nil, getErr
+		}
+
+		if !canRun(container) {
+			continue
+		}
+
+		removed, remErr := client.ZRem(jobKey, idString).Result()
+		if remErr != nil {
+			log.Printf("ERROR jobrunnerrequestDAO/getNextAllowedRequest could not remove %s from job queue: %s", jobId, remErr)
+			return nil, remErr
+		}
+		if removed == 0 { //something else took it off the queue in the meantime
+			continue
+		}
+		container.QueuedAtMillis = math.Mod(entry.Score, requestQueuePriorityMultiplier)
+		return container, nil
 	}
+	return nil, nil
+}
 
+/**
+put the given container onto the request queue at its current priority. The container itself must have been stored
+already, only the id is held on the queue.
+a job that was taken off the queue and is being put back (e.g. because a concurrency limit stopped it from starting)
+goes back in at its original enqueue time, so that it does not lose its place to jobs that arrived after it.
+*/
+func pushToRequestQueue(client redis.Cmdable, item *models.JobContainer) error {
 	jobKey := fmt.Sprintf("mediaflipper:%s", models.REQUEST_QUEUE)
 
-	result := client.RPush(jobKey, string(encodedContent))
+	if item.QueuedAtMillis == 0 {
+		item.QueuedAtMillis = float64(time.Now().UnixNano() / 1e6)
+	}
+	result := client.ZAdd(jobKey, &redis.Z{
+		Score:  requestQueueScore(item.Priority, item.QueuedAtMillis),
+		Member: item.Id.String(),
+	})
 	if result.Err() != nil {
-		log.Printf("ERROR jobrunnerrequestDAO/pushToRequestQueue Could not push to list %s: %s", jobKey, result.Err())
+		log.Printf("ERROR jobrunnerrequestDAO/pushToRequestQueue Could not push to queue %s: %s", jobKey, result.Err())
 		return result.Err()
 	}
+	livestream.QueueChanged(item, models.REQUEST_QUEUE, nil, livestream.QUEUE_ADDED, client)
 	return nil
 }
 
 func removeFromRequestQueue(client redis.Cmdable, item *models.JobContainer) error {
-	encodedContent, marshalErr := json.Marshal(*item)
-	if marshalErr != nil {
-		log.Print("ERROR jobrunnerrequestDAO/removeFromRequestQueue Could not encode content for ", item, ": ", marshalErr)
-		return marshalErr
+	jobKey := fmt.Sprintf("mediaflipper:%s", models.REQUEST_QUEUE)
+
+	result := client.ZRem(jobKey, item.Id.String())
+	if result.Err() != nil {
+		log.Printf("ERROR jobrunnerrequestDAO/removeFromRequestQueue Could not remove from queue %s: %s", jobKey, result.Err())
+		return result.Err()
+	}
+	return nil
+}
+
+/**
+change the priority of a job that is waiting on the request queue, keeping its original enqueue time.
+returns false if the job is not waiting on the queue.
+*/
+func setRequestQueuePriority(client redis.Cmdable, jobId uuid.UUID, newPriority int32) (bool, error) {
+	jobKey := fmt.Sprintf("mediaflipper:%s", models.REQUEST_QUEUE)
+
+	currentScore, getErr := client.ZScore(jobKey, jobId.String()).Result()
+	if getErr == redis.Nil {
+		return false, nil
+	} else if getErr != nil {
+		log.Printf("ERROR jobrunnerrequestDAO/setRequestQueuePriority could not get current score for %s: %s", jobId, getErr)
+		return false, getErr
 	}
 
+	enqueuedMillis := math.Mod(currentScore, requestQueuePriorityMultiplier)
+	//XX => only update if it's still there, don't re-add a job that has been picked up in the meantime
+	_, setErr := client.ZAddXX(jobKey, &redis.Z{
+		Score:  requestQueueScore(newPriority, enqueuedMillis),
+		Member: jobId.String(),
+	}).Result()
+	if setErr != nil {
+		log.Printf("ERROR jobrunnerrequestDAO/setRequestQueuePriority could not update score for %s: %s", jobId, setErr)
+		return false, setErr
+	}
+	return true, nil
+}
+
+/**
+earlier versions kept the request queue as a list of serialised job containers. If we find one of those, move its
+contents onto the sorted set so that nothing that was waiting gets lost.
+*/
+func migrateLegacyRequestQueue(client *redis.Client) error {
 	jobKey := fmt.Sprintf("mediaflipper:%s", models.REQUEST_QUEUE)
 
-	result := client.LRem(jobKey, 0, string(encodedContent))
+	keyType, typeErr := client.Type(jobKey).Result()
+	if typeErr != nil {
+		log.Printf("ERROR jobrunnerrequestDAO/migrateLegacyRequestQueue could not check type of %s: %s", jobKey, typeErr)
+		return typeErr
+	}
+	if keyType != "list" {
+		return nil
+	}
+
+	rawData, rangeErr := client.LRange(jobKey, 0, -1).Result()
+	if rangeErr != nil {
+		log.Printf("ERROR jobrunnerrequestDAO/migrateLegacyRequestQueue could not read legacy queue: %s", rangeErr)
+		return rangeErr
+	}
+	log.Printf("INFO jobrunnerrequestDAO/migrateLegacyRequestQueue migrating %d waiting jobs to the priority queue", len(rawData))
+
+	nowMillis := float64(time.Now().UnixNano() / 1e6)
+	members := make([]*redis.Z, 0)
+	for i, content := range rawData {
+		var rq models.JobContainer
+		marshalErr := json.Unmarshal([]byte(content), &rq)
+		if marshalErr != nil {
+			log.Printf("ERROR jobrunnerrequestDAO/migrateLegacyRequestQueue dropping undecodable entry: %s. Offending data was %s", marshalErr, content)
+			continue
+		}
+		members = append(members, &redis.Z{
+			Score:  requestQueueScore(rq.Priority, nowMillis+float64(i)), //preserve the existing order
+			Member: rq.Id.String(),
+		})
+	}
+
+	pipe := client.TxPipeline()
+	pipe.Del(jobKey)
+	if len(members) > 0 {
+		pipe.ZAdd(jobKey, members...)
+	}
+	_, execErr := pipe.Exec()
+	if execErr != nil {
+		log.Printf("ERROR jobrunnerrequestDAO/migrateLegacyRequestQueue could not write priority queue: %s", execErr)
+	}
+	return execErr
+}
+
+/**
+put the given job onto the retry queue, to be moved back onto the request queue by promoteDueRetries once `retryAt` has passed.
+the container itself must have been stored already.
+*/
+func pushToRetryQueue(client redis.Cmdable, jobId uuid.UUID, retryAt time.Time) error {
+	jobKey := fmt.Sprintf("mediaflipper:%s", models.RETRY_QUEUE)
+
+	result := client.ZAdd(jobKey, &redis.Z{
+		Score:  float64(retryAt.UnixNano() / 1e6),
+		Member: jobId.String(),
+	})
+	if result.Err() != nil {
+		log.Printf("ERROR jobrunnerrequestDAO/pushToRetryQueue Could not push to queue %s: %s", jobKey, result.Err())
+		return result.Err()
+	}
+	return nil
+}
+
+func removeFromRetryQueue(client redis.Cmdable, item *models.JobContainer) error {
+	jobKey := fmt.Sprintf("mediaflipper:%s", models.RETRY_QUEUE)
+
+	result := client.ZRem(jobKey, item.Id.String())
 	if result.Err() != nil {
-		log.Printf("ERROR jobrunnerrequestDAO/removeFromRequestQueue Could not push to list %s: %s", jobKey, result.Err())
+		log.Printf("ERROR jobrunnerrequestDAO/removeFromRetryQueue Could not remove from queue %s: %s", jobKey, result.Err())
 		return result.Err()
 	}
 	return nil
 }
+
+/**
+move every job on the retry queue whose retry time has passed onto the request queue, at its normal priority.
+returns the number of jobs that were moved.
+*/
+func promoteDueRetries(client *redis.Client) (int, error) {
+	jobKey := fmt.Sprintf("mediaflipper:%s", models.RETRY_QUEUE)
+
+	nowMillis := time.Now().UnixNano() / 1e6
+	idList, rangeErr := client.ZRangeByScore(jobKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: fmt.Sprintf("%d", nowMillis),
+	}).Result()
+	if rangeErr != nil {
+		log.Printf("ERROR jobrunnerrequestDAO/promoteDueRetries could not range %s: %s", jobKey, rangeErr)
+		return 0, rangeErr
+	}
+
+	promoted := 0
+	for _, idString := range idList {
+		removed, remErr := client.ZRem(jobKey, idString).Result()
+		if remErr != nil {
+			log.Printf("ERROR jobrunnerrequestDAO/promoteDueRetries could not remove %s from %s: %s", idString, jobKey, remErr)
+			return promoted, remErr
+		}
+		if removed == 0 { //something else took it off the queue in the meantime
+			continue
+		}
+
+		jobId, parseErr := uuid.Parse(idString)
+		if parseErr != nil {
+			log.Printf("ERROR jobrunnerrequestDAO/promoteDueRetries dropping invalid job id '%s' from retry queue: %s", idString, parseErr)
+			continue
+		}
+		container, getErr := models.JobContainerForId(jobId, client)
+		if getErr != nil {
+			log.Printf("ERROR jobrunnerrequestDAO/promoteDueRetries dropping job %s from retry queue, could not get it: %s", jobId, getErr)
+			continue
+		}
+		pushErr := pushToRequestQueue(client, container)
+		if pushErr != nil {
+			return promoted, pushErr
+		}
+		promoted += 1
+	}
+	return promoted, nil
+}
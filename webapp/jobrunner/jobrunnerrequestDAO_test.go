@@ -0,0 +1,261 @@
+package jobrunner
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"testing"
+	"time"
+)
+
+func makeFakeQueuedJob(t *testing.T, client redis.Cmdable, priority int32) *models.JobContainer {
+	nowTime := time.Now()
+	job := &models.JobContainer{
+		Id:        uuid.New(),
+		Steps:     []models.JobStep{},
+		Status:    models.JOB_PENDING,
+		StartTime: &nowTime, //if it's not set then you get a segfault when indexing
+		Priority:  priority,
+	}
+	storErr := job.Store(client)
+	if storErr != nil {
+		t.Fatalf("could not store fake job: %s", storErr)
+	}
+	return job
+}
+
+/**
+getNextRequestQueueEntry should return the highest priority job first and then the earliest within a priority
+*/
+func TestRequestQueuePriorityOrder(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	archiveOne := makeFakeQueuedJob(t, testClient, models.JOB_PRIORITY_NORMAL)
+	archiveTwo := makeFakeQueuedJob(t, testClient, models.JOB_PRIORITY_NORMAL)
+	urgent := makeFakeQueuedJob(t, testClient, 50)
+
+	for _, job := range []*models.JobContainer{archiveOne, archiveTwo, urgent} {
+		pushErr := pushToRequestQueue(testClient, job)
+		if pushErr != nil {
+			t.Fatalf("pushToRequestQueue failed: %s", pushErr)
+		}
+		time.Sleep(2 * time.Millisecond) //ensure that the enqueue times differ
+	}
+
+	queueLen, _ := models.GetQueueLength(testClient, models.REQUEST_QUEUE)
+	if queueLen != 3 {
+		t.Errorf("expected 3 items on the request queue, got %d", queueLen)
+	}
+
+	for i, expected := range []uuid.UUID{urgent.Id, archiveOne.Id, archiveTwo.Id} {
+		next, getErr := getNextRequestQueueEntry(testClient)
+		if getErr != nil {
+			t.Fatalf("getNextRequestQueueEntry failed: %s", getErr)
+		}
+		if next == nil {
+			t.Fatalf("getNextRequestQueueEntry returned nothing for item %d", i)
+		}
+		if next.Id != expected {
+			t.Errorf("item %d: expected %s got %s", i, expected, next.Id)
+		}
+	}
+
+	next, getErr := getNextRequestQueueEntry(testClient)
+	if getErr != nil || next != nil {
+		t.Errorf("expected empty queue to return nil, nil. got %v, %s", next, getErr)
+	}
+}
+
+/**
+a job that is taken off the request queue and put back, e.g. because a concurrency limit stopped it from starting,
+should keep its place ahead of jobs that were queued after it
+*/
+func TestRequestQueueDeferKeepsPlace(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	first := makeFakeQueuedJob(t, testClient, models.JOB_PRIORITY_NORMAL)
+	second := makeFakeQueuedJob(t, testClient, models.JOB_PRIORITY_NORMAL)
+	pushToRequestQueue(testClient, first)
+	time.Sleep(2 * time.Millisecond)
+	pushToRequestQueue(testClient, second)
+
+	popped, _ := getNextRequestQueueEntry(testClient)
+	if popped == nil || popped.Id != first.Id {
+		t.Fatalf("expected %s to come out first, got %v", first.Id, popped)
+	}
+	time.Sleep(2 * time.Millisecond)
+	pushToRequestQueue(testClient, popped)
+
+	allowed, _ := getNextAllowedRequest(testClient, func(container *models.JobContainer) bool { return true })
+	if allowed == nil || allowed.Id != first.Id {
+		t.Fatalf("expected the deferred job %s to still be first, got %v", first.Id, allowed)
+	}
+	time.Sleep(2 * time.Millisecond)
+	pushToRequestQueue(testClient, allowed)
+
+	waiting, _ := testClient.ZRange("mediaflipper:"+string(models.REQUEST_QUEUE), 0, -1).Result()
+	if len(waiting) != 2 || waiting[0] != first.Id.String() {
+		t.Errorf("expected the deferred job to be back at the front, got %v", waiting)
+	}
+}
+
+/**
+getNextAllowedRequest should only look at the front of the queue, not read through every waiting job
+*/
+func TestGetNextAllowedRequestScanLimit(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	var last *models.JobContainer
+	for i := 0; i <= requestQueueScanLimit; i++ {
+		last = makeFakeQueuedJob(t, testClient, models.JOB_PRIORITY_NORMAL)
+		last.QueuedAtMillis = float64(i + 1)
+		pushToRequestQueue(testClient, last)
+	}
+
+	checked := 0
+	next, getErr := getNextAllowedRequest(testClient, func(container *models.JobContainer) bool {
+		checked += 1
+		return container.Id == last.Id
+	})
+	if getErr != nil || next != nil {
+		t.Errorf("expected nothing to be found within the scan limit, got %v, %s", next, getErr)
+	}
+	if checked != requestQueueScanLimit {
+		t.Errorf("expected %d jobs to be checked, got %d", requestQueueScanLimit, checked)
+	}
+	queueLen, _ := models.GetQueueLength(testClient, models.REQUEST_QUEUE)
+	if queueLen != requestQueueScanLimit+1 {
+		t.Errorf("expected every job to be left on the queue, got %d", queueLen)
+	}
+}
+
+/**
+SetJobPriority should move a waiting job ahead of others and record the priority on the job
+*/
+func TestJobRunner_SetJobPriority(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	runner := JobRunner{redisClient: testClient}
+
+	first := makeFakeQueuedJob(t, testClient, models.JOB_PRIORITY_NORMAL)
+	second := makeFakeQueuedJob(t, testClient, models.JOB_PRIORITY_NORMAL)
+	pushToRequestQueue(testClient, first)
+	time.Sleep(2 * time.Millisecond)
+	pushToRequestQueue(testClient, second)
+
+	updated, setErr := runner.SetJobPriority(second.Id, 200)
+	if setErr != nil {
+		t.Fatalf("SetJobPriority failed: %s", setErr)
+	}
+	if updated.Priority != models.JOB_PRIORITY_MAX {
+		t.Errorf("expected priority to be clamped to %d, got %d", models.JOB_PRIORITY_MAX, updated.Priority)
+	}
+
+	stored, _ := models.JobContainerForId(second.Id, testClient)
+	if stored.Priority != models.JOB_PRIORITY_MAX {
+		t.Errorf("stored job had priority %d, expected %d", stored.Priority, models.JOB_PRIORITY_MAX)
+	}
+
+	next, _ := getNextRequestQueueEntry(testClient)
+	if next == nil || next.Id != second.Id {
+		t.Errorf("expected bumped job %s to come out first, got %v", second.Id, next)
+	}
+
+	//the job is no longer waiting, so changing it should not put it back on the queue
+	_, setErr = runner.SetJobPriority(second.Id, 10)
+	if setErr != nil {
+		t.Errorf("SetJobPriority on a running job failed: %s", setErr)
+	}
+	queueLen, _ := models.GetQueueLength(testClient, models.REQUEST_QUEUE)
+	if queueLen != 1 {
+		t.Errorf("expected 1 item left on the request queue, got %d", queueLen)
+	}
+
+	_, notFoundErr := runner.SetJobPriority(uuid.New(), 10)
+	if notFoundErr != redis.Nil {
+		t.Errorf("expected redis.Nil for a missing job, got %v", notFoundErr)
+	}
+}
+
+/**
+migrateLegacyRequestQueue should convert an old list-based queue into the sorted set, keeping the order
+*/
+func TestMigrateLegacyRequestQueue(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	jobKey := fmt.Sprintf("mediaflipper:%s", models.REQUEST_QUEUE)
+	jobs := []*models.JobContainer{
+		makeFakeQueuedJob(t, testClient, models.JOB_PRIORITY_NORMAL),
+		makeFakeQueuedJob(t, testClient, models.JOB_PRIORITY_NORMAL),
+		makeFakeQueuedJob(t, testClient, models.JOB_PRIORITY_NORMAL),
+	}
+	for _, job := range jobs {
+		content, _ := json.Marshal(job)
+		testClient.RPush(jobKey, string(content))
+	}
+	testClient.RPush(jobKey, "this is not json")
+
+	migrateErr := migrateLegacyRequestQueue(testClient)
+	if migrateErr != nil {
+		t.Fatalf("migrateLegacyRequestQueue failed: %s", migrateErr)
+	}
+
+	for i, job := range jobs {
+		next, getErr := getNextRequestQueueEntry(testClient)
+		if getErr != nil {
+			t.Fatalf("getNextRequestQueueEntry failed after migration: %s", getErr)
+		}
+		if next == nil || next.Id != job.Id {
+			t.Errorf("item %d: expected %s, got %v", i, job.Id, next)
+		}
+	}
+
+	//running it again on a sorted set should be a no-op
+	migrateErr = migrateLegacyRequestQueue(testClient)
+	if migrateErr != nil {
+		t.Errorf("second migrateLegacyRequestQueue failed: %s", migrateErr)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/guardian/mediaflipper/common/helpers"
 	"github.com/guardian/mediaflipper/common/models"
-	"k8s.io/client-go/kubernetes"
 	"log"
 	"net/http"
 	"net/url"
@@ -14,7 +13,7 @@ import (
 
 type ManualCleanupHandler struct {
 	redisClient *redis.Client
-	k8clientset *kubernetes.Clientset
+	executor    JobExecutor
 }
 
 //manually initiate a cleanup operation
@@ -25,10 +24,9 @@ func (h ManualCleanupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	ns, nsErr := GetMyNamespace()
-	if nsErr != nil {
-		log.Printf("ERROR ManualCleanupHandler could not determine namespace: %s", nsErr)
-		helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", "could not determine namespace"}, w, 500)
+	if h.executor == nil {
+		log.Printf("ERROR ManualCleanupHandler no job executor is configured")
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", "no job executor configured"}, w, 500)
 		return
 	}
 
@@ -67,10 +65,6 @@ func (h ManualCleanupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	log.Printf("DEBUG ManualCleanupHandler namespace is %s", ns)
-	jobclient := h.k8clientset.BatchV1().Jobs(ns)
-	podclient := h.k8clientset.CoreV1().Pods(ns)
-
 	jobInfo, getErr := models.JobContainerForId(jobId, h.redisClient)
 	if getErr != nil {
 		log.Printf("ERROR ManualCleanupHandler could not look up job container with id '%s': %s", jobId, getErr)
@@ -88,7 +82,7 @@ func (h ManualCleanupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err := CleanUpJobStep(step, jobclient, podclient, h.redisClient)
+	err := h.executor.CleanUpJobStep(step, h.redisClient)
 	if err != nil {
 		log.Printf("ERROR ManualCleanupHandler could not perform cleanup: %s", err)
 		helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", err.Error()}, w, 500)
@@ -0,0 +1,55 @@
+package jobrunner
+
+import (
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type PriorityHandler struct {
+	redisClient *redis.Client
+	runner      *JobRunner
+}
+
+/**
+change the priority of a job. Expects ?forId={job-id}&priority={0-100}. Higher priority jobs are dispatched first.
+*/
+func (h PriorityHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !helpers.AssertHttpMethod(r, w, "PUT") {
+		return
+	}
+
+	requestUrl, forId, errResponse := helpers.GetForId(r.RequestURI)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	priorityString := requestUrl.Query().Get("priority")
+	priority, parseErr := strconv.ParseInt(priorityString, 10, 32)
+	if parseErr != nil {
+		log.Printf("ERROR PriorityHandler priority value '%s' is not valid: %s", priorityString, parseErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", "priority parameter must be a number"}, w, 400)
+		return
+	}
+	if int32(priority) < models.JOB_PRIORITY_NORMAL || int32(priority) > models.JOB_PRIORITY_MAX {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", fmt.Sprintf("priority must be between %d and %d", models.JOB_PRIORITY_NORMAL, models.JOB_PRIORITY_MAX)}, w, 400)
+		return
+	}
+
+	container, setErr := h.runner.SetJobPriority(*forId, int32(priority))
+	if setErr == redis.Nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"not_found", "no job with that id"}, w, 404)
+		return
+	} else if setErr != nil {
+		log.Printf("ERROR PriorityHandler could not update priority for %s: %s", *forId, setErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", "could not update priority"}, w, 500)
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{"status": "ok", "jobId": container.Id, "priority": container.Priority}, w, 200)
+}
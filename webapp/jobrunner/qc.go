@@ -0,0 +1,56 @@
+package jobrunner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+)
+
+/**
+create a QC job based on the provided template. Depending on the settings this checks either the incoming media or the
+output of an earlier transcode step
+*/
+func CreateQCJob(jobDesc models.JobStepQC, container *models.JobContainer, executor JobExecutor, redisClient redis.Cmdable) error {
+	if jobDesc.QCSettings == nil {
+		return errors.New("Can't perform QC with no QC settings")
+	}
+
+	mediaFile := jobDesc.MediaFile
+	if jobDesc.QCSettings.EffectiveInput() == models.QC_INPUT_TRANSCODE {
+		if container.TranscodedMediaId == nil {
+			return errors.New(fmt.Sprintf("Can't perform QC on the transcoded media of %s, there is none. Does the QC step depend on the transcode?", container.Id))
+		}
+		fileEntry, getErr := models.FileEntryForId(*container.TranscodedMediaId, redisClient)
+		if getErr != nil {
+			log.Printf("ERROR: Could not get a file entry for id %s", *container.TranscodedMediaId)
+			return getErr
+		}
+		mediaFile = fileEntry.ServerPath
+	}
+	if mediaFile == "" {
+		log.Printf("Can't perform QC with no media file")
+		return errors.New("Can't perform QC with no media file")
+	}
+
+	jsonSettings, marshalErr := json.Marshal(jobDesc.QCSettings)
+	if marshalErr != nil {
+		log.Printf("Could not convert QC settings into json: %s", marshalErr)
+		return marshalErr
+	}
+
+	vars := map[string]string{
+		"WRAPPER_MODE":     "qc",
+		"JOB_CONTAINER_ID": jobDesc.JobContainerId.String(),
+		"JOB_STEP_ID":      jobDesc.JobStepId.String(),
+		"FILE_NAME":        mediaFile,
+		"MAX_RETRIES":      "10",
+		"MEDIA_TYPE":       string(jobDesc.ItemType),
+		"OUTPUT_PATH":      container.OutputPath,
+		"QC_SETTINGS":      string(jsonSettings),
+	}
+
+	return executor.LaunchJob(jobDesc.JobStepId, "flip-qc", vars, true, jobDesc.KubernetesTemplateFile)
+}
@@ -10,6 +10,7 @@ import (
 
 type QueueStatsHandler struct {
 	redisClient *redis.Client
+	runner      *JobRunner
 }
 
 func (h QueueStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -24,6 +25,14 @@ func (h QueueStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	helpers.WriteJsonContent(map[string]interface{}{"status": "ok", "queues": result}, w, 200)
+	usage, usageErr := GetConcurrencyUsage(h.redisClient)
+	if usageErr != nil {
+		log.Printf("ERROR: QueueStatsHandler could not get concurrency usage: %s", usageErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", usageErr.Error()}, w, 500)
+		return
+	}
+
+	_, limits := h.runner.Limits()
+	helpers.WriteJsonContent(map[string]interface{}{"status": "ok", "queues": result, "concurrency": limits.Report(usage)}, w, 200)
 	return
 }
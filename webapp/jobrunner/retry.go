@@ -0,0 +1,88 @@
+package jobrunner
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/webapp/livestream"
+	"log"
+	"time"
+)
+
+/**
+called when the external runner for the given step has failed (ERROR_CLASS_PROCESS) or been lost (ERROR_CLASS_LOST).
+if the step has a retry policy then the attempt is recorded on the step, along with a copy of its logs.
+if the policy allows another attempt, the step is reset to pending, the container is stored and the job goes onto the
+retry queue to be run again once the backoff has elapsed; in this case the return value is true.
+otherwise the return value is false and the caller should fail the job as normal. `container` is updated with the recorded
+attempt but is not stored.
+*/
+func (j *JobRunner) retryFailedStep(container *models.JobContainer, stepId uuid.UUID, baseClass string, errMsg string) bool {
+	jobStep := container.FindStepById(stepId)
+	if jobStep == nil {
+		log.Printf("ERROR retryFailedStep job %s does not have a step with id %s", container.Id, stepId)
+		return false
+	}
+	policy := (*jobStep).RetryPolicy()
+	if policy == nil {
+		return false
+	}
+
+	attemptNumber := len((*jobStep).Attempts()) + 1 //numbers every attempt, so that the logs of each one are kept
+	countedAttempts := models.AttemptsSinceResume((*jobStep).Attempts()) + 1
+
+	if baseClass != models.ERROR_CLASS_LOST {
+		//the old runner must be gone before we launch a new one for the same step, and cleaning up gives us the logs
+		cleanupErr := j.executor.CleanUpJobStep(jobStep, j.redisClient)
+		if cleanupErr != nil {
+			log.Printf("WARNING retryFailedStep could not clean up attempt %d of step %s: %s", attemptNumber, stepId, cleanupErr)
+		}
+	}
+	archiveErr := models.ArchiveContainerLogForAttempt(stepId, attemptNumber, j.redisClient)
+	if archiveErr != nil {
+		log.Printf("WARNING retryFailedStep could not keep logs for attempt %d of step %s: %s", attemptNumber, stepId, archiveErr)
+	}
+	logContent, _ := models.GetContainerLogContent(stepId, j.redisClient) //no logs is not a problem here
+
+	nowTime := time.Now()
+	attempt := models.StepAttempt{
+		Attempt:      attemptNumber,
+		ErrorClass:   models.ClassifyStepError(baseClass, errMsg+"\n"+logContent),
+		ErrorMessage: errMsg,
+		FailedAt:     &nowTime,
+	}
+
+	if !policy.ShouldRetry(countedAttempts, attempt.ErrorClass) {
+		log.Printf("INFO retryFailedStep attempt %d of step %s failed with %s, not retrying", attemptNumber, stepId, attempt.ErrorClass)
+		updateErr := container.UpdateStepById(stepId, (*jobStep).WithAttemptRecorded(attempt))
+		if updateErr != nil {
+			log.Printf("ERROR retryFailedStep could not record attempt on step %s: %s", stepId, updateErr)
+		}
+		return false
+	}
+
+	retryAt := nowTime.Add(policy.BackoffFor(countedAttempts))
+	attempt.RetryAt = &retryAt
+	retryMsg := fmt.Sprintf("attempt %d failed with %s, retrying at %s", attemptNumber, attempt.ErrorClass, retryAt.Format(time.RFC3339))
+	updatedStep := (*jobStep).WithAttemptRecorded(attempt).WithNewStatus(models.JOB_PENDING, &retryMsg)
+	updateErr := container.UpdateStepById(stepId, updatedStep)
+	if updateErr != nil {
+		log.Printf("ERROR retryFailedStep could not update step %s: %s", stepId, updateErr)
+		return false
+	}
+
+	storErr := container.Store(j.redisClient)
+	if storErr != nil {
+		log.Printf("ERROR retryFailedStep could not store job %s: %s", container.Id, storErr)
+		return false
+	}
+	pushErr := pushToRetryQueue(j.redisClient, container.Id, retryAt)
+	if pushErr != nil {
+		log.Printf("ERROR retryFailedStep could not put job %s onto the retry queue: %s", container.Id, pushErr)
+		return false
+	}
+	log.Printf("INFO retryFailedStep job %s step %s: %s", container.Id, stepId, retryMsg)
+	livestream.StepChanged(container, stepId, j.redisClient)
+	livestream.QueueChanged(container, models.RETRY_QUEUE, nil, livestream.QUEUE_ADDED, j.redisClient)
+	return true
+}
@@ -0,0 +1,225 @@
+package jobrunner
+
+import (
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"strings"
+	"testing"
+)
+
+func makeRetryableJob(t *testing.T, client redis.Cmdable, policy *models.RetryPolicy) (*models.JobContainer, uuid.UUID) {
+	job := makeSingleStepJob(t, client, uuid.New(), "custom")
+	job.Steps[0].(*models.JobStepCustom).Retry = policy
+	job.Status = models.JOB_STARTED
+	storErr := job.Store(client)
+	if storErr != nil {
+		t.Fatalf("could not store test job: %s", storErr)
+	}
+	stepId := job.Steps[0].StepId()
+	models.AddToQueue(client, models.RUNNING_QUEUE, models.JobQueueEntry{JobId: job.Id, StepId: stepId, Status: models.JOB_STARTED})
+	return job, stepId
+}
+
+/**
+a step that fails with a retryable error should be recorded and run again, and the job should only fail
+once the attempts have run out
+*/
+func TestJobRunner_retryFailedStep(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	job, stepId := makeRetryableJob(t, testClient, &models.RetryPolicy{
+		MaxAttempts:     2,
+		RetryableErrors: []string{models.ERROR_CLASS_IO},
+	})
+
+	mockExecutor := &JobExecutorMock{
+		RunnerStatus: map[uuid.UUID]models.ContainerStatus{stepId: models.CONTAINER_FAILED},
+		RunnerLogs:   map[uuid.UUID]string{stepId: "could not read /mnt/media/in.mxf: Input/output error"},
+	}
+	runner := JobRunner{
+		redisClient: testClient,
+		executor:    mockExecutor,
+		maxJobs:     10,
+	}
+
+	runner.clearCompletedTick()
+
+	updated, _ := models.JobContainerForId(job.Id, testClient)
+	if updated.Status == models.JOB_FAILED {
+		t.Fatalf("job should not have failed, it has retries left")
+	}
+	step := updated.Steps[0]
+	if step.Status() != models.JOB_PENDING {
+		t.Errorf("expected step to be reset to pending, got %d", step.Status())
+	}
+	if len(step.Attempts()) != 1 {
+		t.Fatalf("expected 1 attempt to be recorded, got %d", len(step.Attempts()))
+	}
+	if step.Attempts()[0].ErrorClass != models.ERROR_CLASS_IO || step.Attempts()[0].RetryAt == nil {
+		t.Errorf("unexpected attempt record %v", step.Attempts()[0])
+	}
+	if len(mockExecutor.CleanedSteps) != 1 || mockExecutor.CleanedSteps[0] != stepId {
+		t.Errorf("expected the failed runner to be cleaned up before retrying, cleaned %v", mockExecutor.CleanedSteps)
+	}
+	attemptLog, logErr := models.GetContainerLogContentForAttempt(stepId, 1, testClient)
+	if logErr != nil || !strings.Contains(attemptLog, "Input/output error") {
+		t.Errorf("expected logs to be kept for attempt 1, got '%s' (%v)", attemptLog, logErr)
+	}
+	retryLen, _ := models.GetQueueLength(testClient, models.RETRY_QUEUE)
+	if retryLen != 1 {
+		t.Errorf("expected the job to be on the retry queue, queue length was %d", retryLen)
+	}
+
+	//no backoff, so the next waiting queue tick should launch the step again
+	runner.waitingQueueTick()
+	if len(mockExecutor.LaunchedSteps) != 1 || mockExecutor.LaunchedSteps[0] != stepId {
+		t.Fatalf("expected the failed step to be launched again, got %v", mockExecutor.LaunchedSteps)
+	}
+	retryLen, _ = models.GetQueueLength(testClient, models.RETRY_QUEUE)
+	if retryLen != 0 {
+		t.Errorf("expected the retry queue to be empty, queue length was %d", retryLen)
+	}
+
+	//second failure uses up the attempts
+	runner.clearCompletedTick()
+	failed, _ := models.JobContainerForId(job.Id, testClient)
+	if failed.Status != models.JOB_FAILED {
+		t.Errorf("expected job to have failed once retries were exhausted, got status %d", failed.Status)
+	}
+	if len(failed.Steps[0].Attempts()) != 2 {
+		t.Errorf("expected 2 attempts to be recorded, got %d", len(failed.Steps[0].Attempts()))
+	}
+	if failed.Steps[0].Attempts()[1].RetryAt != nil {
+		t.Error("final attempt should not have a retry time")
+	}
+	expectedMsg := "External job failed after 2 attempts"
+	if failed.ErrorMessage != expectedMsg {
+		t.Errorf("expected error message '%s', got '%s'", expectedMsg, failed.ErrorMessage)
+	}
+}
+
+/**
+resuming a job whose step ran out of retries should give the step its full number of retries again, while keeping the
+earlier attempts and numbering the new ones after them
+*/
+func TestJobRunner_retryFailedStep_resumed(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	job, stepId := makeRetryableJob(t, testClient, &models.RetryPolicy{MaxAttempts: 1})
+
+	mockExecutor := &JobExecutorMock{
+		RunnerStatus: map[uuid.UUID]models.ContainerStatus{stepId: models.CONTAINER_FAILED},
+	}
+	runner := JobRunner{
+		redisClient: testClient,
+		executor:    mockExecutor,
+		maxJobs:     10,
+	}
+
+	runner.clearCompletedTick()
+	failed, _ := models.JobContainerForId(job.Id, testClient)
+	if failed.Status != models.JOB_FAILED || len(failed.Steps[0].Attempts()) != 1 {
+		t.Fatalf("expected the job to fail after its only attempt, got status %d with %d attempts", failed.Status, len(failed.Steps[0].Attempts()))
+	}
+
+	resumeErr := runner.ResumeJob(failed)
+	if resumeErr != nil {
+		t.Fatalf("ResumeJob failed: %s", resumeErr)
+	}
+	resumed, _ := models.JobContainerForId(job.Id, testClient)
+	if len(resumed.Steps[0].Attempts()) != 1 || !resumed.Steps[0].Attempts()[0].Resumed {
+		t.Errorf("expected the earlier attempt to be kept and marked as resumed, got %v", resumed.Steps[0].Attempts())
+	}
+
+	runner.waitingQueueTick()
+	if len(mockExecutor.LaunchedSteps) != 1 || mockExecutor.LaunchedSteps[0] != stepId {
+		t.Fatalf("expected the resumed step to be launched, got %v", mockExecutor.LaunchedSteps)
+	}
+
+	//the resumed step has used its one attempt again, so it should fail rather than retry
+	runner.clearCompletedTick()
+	failedAgain, _ := models.JobContainerForId(job.Id, testClient)
+	if failedAgain.Status != models.JOB_FAILED {
+		t.Errorf("expected the job to fail again, got status %d", failedAgain.Status)
+	}
+	attempts := failedAgain.Steps[0].Attempts()
+	if len(attempts) != 2 || attempts[1].Attempt != 2 || attempts[1].Resumed {
+		t.Errorf("expected the new attempt to be recorded as attempt 2, got %v", attempts)
+	}
+
+	//with more attempts allowed, a resumed step that has had its retries should be retried again
+	failedAgain.Steps[0].(*models.JobStepCustom).Retry = &models.RetryPolicy{MaxAttempts: 2}
+	failedAgain.Store(testClient)
+	runner.ResumeJob(failedAgain)
+	runner.waitingQueueTick()
+	runner.clearCompletedTick()
+	retried, _ := models.JobContainerForId(job.Id, testClient)
+	if retried.Status == models.JOB_FAILED {
+		t.Error("expected the resumed step to be retried, it has an attempt left since the resume")
+	}
+	if attempts := retried.Steps[0].Attempts(); len(attempts) != 3 || attempts[2].RetryAt == nil {
+		t.Errorf("expected attempt 3 to be scheduled for a retry, got %v", attempts)
+	}
+}
+
+/**
+a failure that is not in the policy's retryable errors should fail the job straight away
+*/
+func TestJobRunner_retryFailedStep_notRetryable(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	job, stepId := makeRetryableJob(t, testClient, &models.RetryPolicy{
+		MaxAttempts:     3,
+		RetryableErrors: []string{models.ERROR_CLASS_IO},
+	})
+
+	mockExecutor := &JobExecutorMock{
+		RunnerStatus: map[uuid.UUID]models.ContainerStatus{stepId: models.CONTAINER_FAILED},
+		RunnerLogs:   map[uuid.UUID]string{stepId: "Invalid data found when processing input"},
+	}
+	runner := JobRunner{
+		redisClient: testClient,
+		executor:    mockExecutor,
+		maxJobs:     10,
+	}
+
+	runner.clearCompletedTick()
+
+	updated, _ := models.JobContainerForId(job.Id, testClient)
+	if updated.Status != models.JOB_FAILED {
+		t.Errorf("expected job to fail, got status %d", updated.Status)
+	}
+	if len(updated.Steps[0].Attempts()) != 1 || updated.Steps[0].Attempts()[0].ErrorClass != models.ERROR_CLASS_PROCESS {
+		t.Errorf("expected a single process_failed attempt, got %v", updated.Steps[0].Attempts())
+	}
+	retryLen, _ := models.GetQueueLength(testClient, models.RETRY_QUEUE)
+	if retryLen != 0 {
+		t.Errorf("job should not be on the retry queue, queue length was %d", retryLen)
+	}
+}
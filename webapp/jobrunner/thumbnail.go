@@ -1,23 +1,20 @@
 package jobrunner
 
 import (
+	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/davecgh/go-spew/spew"
 	models2 "github.com/guardian/mediaflipper/common/models"
-	v1 "k8s.io/client-go/kubernetes/typed/batch/v1"
-	v13 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"log"
+	"strconv"
 )
 
-func CreateThumbnailJob(jobDesc models2.JobStepThumbnail, maybeOutPath string, jobClient v1.JobInterface, svcClient v13.ServiceInterface) error {
+func CreateThumbnailJob(jobDesc models2.JobStepThumbnail, maybeOutPath string, executor JobExecutor) error {
 	if jobDesc.MediaFile == "" {
 		log.Printf("Can't perform thumbnail with no media file")
 		return errors.New("can't perform thumbnail with no media file")
 	}
 
-	var thumbFrameSeconds float64
-
 	var jsonTranscodeSettings []byte
 	if jobDesc.TranscodeSettings != nil {
 		var marshalErr error
@@ -28,18 +25,32 @@ func CreateThumbnailJob(jobDesc models2.JobStepThumbnail, maybeOutPath string, j
 			return marshalErr
 		}
 	}
+	var jsonSpriteSettings []byte
+	if jobDesc.Sprites != nil {
+		var marshalErr error
+		jsonSpriteSettings, marshalErr = json.Marshal(jobDesc.Sprites.WithDefaults())
+		if marshalErr != nil {
+			log.Printf("Could not convert sprite settings into json: %s", marshalErr)
+			return marshalErr
+		}
+	}
 	vars := map[string]string{
 		"WRAPPER_MODE":       "thumbnail",
 		"JOB_CONTAINER_ID":   jobDesc.JobContainerId.String(),
 		"JOB_STEP_ID":        jobDesc.JobStepId.String(),
 		"FILE_NAME":          jobDesc.MediaFile,
 		"TRANSCODE_SETTINGS": string(jsonTranscodeSettings),
-		"THUMBNAIL_FRAME":    fmt.Sprintf("%f", thumbFrameSeconds),
+		"THUMBNAIL_MODE":     jobDesc.ThumbnailMode,
 		"MAX_RETRIES":        "10",
 		"MEDIA_TYPE":         string(jobDesc.ItemType),
 		"OUTPUT_PATH":        maybeOutPath,
+		"SPRITE_SETTINGS":    string(jsonSpriteSettings),
+	}
+	//if no offset is given then leave it to the wrapper's default
+	if jobDesc.ThumbnailFrameSeconds > 0 {
+		vars["THUMBNAIL_FRAME"] = strconv.FormatFloat(jobDesc.ThumbnailFrameSeconds, 'f', -1, 64)
 	}
 
 	//jobName := fmt.Sprintf("mediaflipper-thumbnail-%s", path.Base(jobDesc.MediaFile))
-	return CreateGenericJob(jobDesc.JobStepId, "flip-thumb", vars, true, jobDesc.KubernetesTemplateFile, jobClient, svcClient)
+	return executor.LaunchJob(jobDesc.JobStepId, "flip-thumb", vars, true, jobDesc.KubernetesTemplateFile)
 }
@@ -1,15 +1,14 @@
 package jobrunner
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/davecgh/go-spew/spew"
 	models2 "github.com/guardian/mediaflipper/common/models"
-	v1batch "k8s.io/client-go/kubernetes/typed/batch/v1"
-	v13 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"log"
 )
 
-func CreateTranscodeJob(jobDesc models2.JobStepTranscode, maybeOutPath string, jobClient v1batch.JobInterface, svcClient v13.ServiceInterface) error {
+func CreateTranscodeJob(jobDesc models2.JobStepTranscode, maybeOutPath string, executor JobExecutor) error {
 	if jobDesc.MediaFile == "" {
 		log.Printf("ERROR: CreateTranscodeJob Can't perform transcode with no media file")
 		return errors.New("Can't perform thumbnail with no media file")
@@ -21,6 +20,14 @@ func CreateTranscodeJob(jobDesc models2.JobStepTranscode, maybeOutPath string, j
 		log.Printf("ERROR: CreateTranscodeJob Offending data was %s", spew.Sdump(jobDesc.TranscodeSettings))
 		return marshalErr
 	}
+	var jsonQualitySettings []byte
+	if jobDesc.QualitySettings != nil {
+		jsonQualitySettings, marshalErr = json.Marshal(jobDesc.QualitySettings.WithDefaults())
+		if marshalErr != nil {
+			log.Printf("ERROR: CreateTranscodeJob Could not convert quality metric settings into json: %s", marshalErr)
+			return marshalErr
+		}
+	}
 	vars := map[string]string{
 		"WRAPPER_MODE":       "transcode",
 		"JOB_CONTAINER_ID":   jobDesc.JobContainerId.String(),
@@ -30,7 +37,8 @@ func CreateTranscodeJob(jobDesc models2.JobStepTranscode, maybeOutPath string, j
 		"MAX_RETRIES":        "10",
 		"MEDIA_TYPE":         string(jobDesc.ItemType),
 		"OUTPUT_PATH":        maybeOutPath,
+		"QUALITY_SETTINGS":   string(jsonQualitySettings),
 	}
 
-	return CreateGenericJob(jobDesc.JobStepId, "flip-transc", vars, true, jobDesc.KubernetesTemplateFile, jobClient, svcClient)
+	return executor.LaunchJob(jobDesc.JobStepId, "flip-transc", vars, true, jobDesc.KubernetesTemplateFile)
 }
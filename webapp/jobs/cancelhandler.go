@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/webapp/jobrunner"
+	"log"
+	"net/http"
+)
+
+type CancelJobHandler struct {
+	redisClient *redis.Client
+	runner      *jobrunner.JobRunner
+}
+
+/**
+cancel a waiting or running job. Expects ?forId={job-id}.
+the external job for the current step is killed, the job is marked as aborted and anything it had already output is removed
+*/
+func (h CancelJobHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !helpers.AssertHttpMethod(r, w, "POST") {
+		return
+	}
+
+	_, forId, errResponse := helpers.GetForId(r.RequestURI)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	container, getErr := models.JobContainerForId(*forId, h.redisClient)
+	if getErr == redis.Nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"not_found", "no job with that id"}, w, 404)
+		return
+	} else if getErr != nil {
+		log.Printf("ERROR CancelJobHandler could not get job %s: %s", *forId, getErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", "could not retrieve job"}, w, 500)
+		return
+	}
+
+	if !container.CanCancel() {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"conflict", "job has already finished"}, w, 409)
+		return
+	}
+
+	cancelled, cancelErr := h.runner.CancelJob(container)
+	if cancelErr != nil {
+		log.Printf("ERROR CancelJobHandler could not cancel job %s: %s", *forId, cancelErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", "could not cancel job"}, w, 500)
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{"status": "ok", "jobId": cancelled.Id, "jobStatus": cancelled.Status}, w, 200)
+}
@@ -43,6 +43,7 @@ func (h CreateJobHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		helpers.WriteJsonContent(helpers.GenericErrorResponse{"server_error", createErr.Error()}, w, 500)
 		return
 	}
+	newEntry.Priority = models.ClampJobPriority(rq.Priority)
 
 	jobErr := newEntry.Store(h.RedisClient)
 	if jobErr != nil {
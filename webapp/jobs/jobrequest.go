@@ -5,4 +5,5 @@ import "github.com/google/uuid"
 type JobRequest struct {
 	JobTemplateId    uuid.UUID `json:"jobTemplateId"`
 	OriginalFilename string    `json:"originalFilename"`
+	Priority         int32     `json:"priority"` //optional, defaults to models.JOB_PRIORITY_NORMAL
 }
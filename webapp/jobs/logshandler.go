@@ -8,6 +8,8 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type GetLogsHandler struct {
@@ -34,7 +36,22 @@ func (h GetLogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stream, err := models.GetContainerLogContentStream(stepId, h.redisClient)
+	var stream io.Reader
+	var err error
+	if qps.Get("attempt") != "" {
+		//logs from a previous, failed, attempt at the step
+		attempt, attemptParseErr := strconv.ParseInt(qps.Get("attempt"), 10, 32)
+		if attemptParseErr != nil {
+			log.Printf("ERROR GetLogsHandler could not understand the attempt '%s': %s", qps.Get("attempt"), attemptParseErr)
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{"bad_data", "attempt not valid"}, w, 400)
+			return
+		}
+		var content string
+		content, err = models.GetContainerLogContentForAttempt(stepId, int(attempt), h.redisClient)
+		stream = strings.NewReader(content)
+	} else {
+		stream, err = models.GetContainerLogContentStream(stepId, h.redisClient)
+	}
 	if err != nil {
 		log.Printf("ERROR GetLogsHandler could not retrieve logs: %s", err)
 		helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", err.Error()}, w, 500)
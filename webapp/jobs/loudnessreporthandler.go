@@ -0,0 +1,118 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	models2 "github.com/guardian/mediaflipper/common/models"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type LoudnessReportHandler struct {
+	redisClient *redis.Client
+}
+
+type LoudnessReportEntry struct {
+	JobId             uuid.UUID                 `json:"jobId"`
+	IncomingMediaFile string                    `json:"incomingMediaFile"`
+	Loudness          *models2.LoudnessAnalysis `json:"loudness"`
+	Problems          []string                  `json:"problems"`
+}
+
+type LoudnessReportResponse struct {
+	Status     string                `json:"status"`
+	NextCursor uint64                `json:"nextCursor"`
+	Entries    []LoudnessReportEntry `json:"entries"`
+}
+
+func floatQueryParam(query url.Values, name string, defaultValue float64) (float64, error) {
+	stringValue := query.Get(name)
+	if stringValue == "" {
+		return defaultValue, nil
+	}
+	value, parseErr := strconv.ParseFloat(stringValue, 64)
+	if parseErr != nil {
+		return 0, errors.New(fmt.Sprintf("%s parameter must be a number", name))
+	}
+	return value, nil
+}
+
+/**
+report the jobs whose source media does not meet a loudness specification. Jobs whose source has not been measured are
+not included.
+
+query parameters:
+- startindex, limit - the window of jobs to check, as for the job list. Defaults to the latest 100
+- target - the integrated loudness to check against in LUFS. Defaults to -23 (EBU R128)
+- tolerance - how far the integrated loudness can be from the target in LU. Defaults to 1
+- truepeak - the maximum true peak in dBTP. Defaults to -1
+- range - the maximum loudness range in LU. Not checked by default
+*/
+func (h LoudnessReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !helpers.AssertHttpMethod(r, w, "GET") {
+		return
+	}
+
+	requestUrl, _ := url.ParseRequestURI(r.RequestURI)
+	query := requestUrl.Query()
+
+	paramDefaults := map[string]float64{
+		"startindex": 0,
+		"limit":      100,
+		"target":     -23,
+		"tolerance":  1,
+		"truepeak":   -1,
+		"range":      0,
+	}
+	params := make(map[string]float64, len(paramDefaults))
+	for name, defaultValue := range paramDefaults {
+		value, parseErr := floatQueryParam(query, name, defaultValue)
+		if parseErr != nil {
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{"bad_data", parseErr.Error()}, w, 400)
+			return
+		}
+		params[name] = value
+	}
+	spec := models2.LoudnessSettings{
+		Target:   params["target"],
+		TruePeak: params["truepeak"],
+		Range:    params["range"],
+	}
+
+	jobs, nextCursor, getErr := models2.ListJobContainers(uint64(params["startindex"]), int64(params["limit"]), h.redisClient, models2.SORT_CTIME, nil)
+	if getErr != nil {
+		log.Printf("ERROR LoudnessReportHandler could not look up data: %s", getErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "db_error",
+			Detail: "could not get data, see logs for details",
+		}, w, 500)
+		return
+	}
+
+	entries := make([]LoudnessReportEntry, 0)
+	for _, job := range *jobs {
+		if job.SourceLoudness == nil {
+			continue
+		}
+		problems := job.SourceLoudness.ComplianceProblems(spec, params["tolerance"])
+		if len(problems) > 0 {
+			entries = append(entries, LoudnessReportEntry{
+				JobId:             job.Id,
+				IncomingMediaFile: job.IncomingMediaFile,
+				Loudness:          job.SourceLoudness,
+				Problems:          problems,
+			})
+		}
+	}
+
+	helpers.WriteJsonContent(LoudnessReportResponse{
+		Status:     "ok",
+		NextCursor: nextCursor,
+		Entries:    entries,
+	}, w, 200)
+}
@@ -3,6 +3,7 @@ package jobs
 import (
 	"github.com/go-redis/redis/v7"
 	models2 "github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/webapp/jobrunner"
 	"k8s.io/client-go/kubernetes"
 	"net/http"
 )
@@ -14,9 +15,12 @@ type JobsEndpoints struct {
 	ReindexHandler ReindexHandler
 	GetLogsHandler GetLogsHandler
 	StatusSummary  StatusSummaryHandler
+	ResumeHandler  ResumeJobHandler
+	CancelHandler  CancelJobHandler
+	LoudnessReport LoudnessReportHandler
 }
 
-func NewJobsEndpoints(redisClient *redis.Client, k8client *kubernetes.Clientset, jobTemplateMgr *models2.JobTemplateManager) JobsEndpoints {
+func NewJobsEndpoints(redisClient *redis.Client, k8client *kubernetes.Clientset, jobTemplateMgr *models2.JobTemplateManager, runner *jobrunner.JobRunner) JobsEndpoints {
 	return JobsEndpoints{
 		GetHandler:     GetJobHandler{redisClient},
 		CreateHandler:  CreateJobHandler{redisClient, k8client, jobTemplateMgr},
@@ -24,6 +28,9 @@ func NewJobsEndpoints(redisClient *redis.Client, k8client *kubernetes.Clientset,
 		ReindexHandler: ReindexHandler{redisClient},
 		GetLogsHandler: GetLogsHandler{redisClient: redisClient},
 		StatusSummary:  StatusSummaryHandler{redisClient: redisClient},
+		ResumeHandler:  ResumeJobHandler{redisClient: redisClient, runner: runner},
+		CancelHandler:  CancelJobHandler{redisClient: redisClient, runner: runner},
+		LoudnessReport: LoudnessReportHandler{redisClient: redisClient},
 	}
 }
 
@@ -34,4 +41,7 @@ func (e JobsEndpoints) WireUp(baseUrlPath string) {
 	http.Handle(baseUrlPath+"/reindex", e.ReindexHandler)
 	http.Handle(baseUrlPath+"/logs", e.GetLogsHandler)
 	http.Handle(baseUrlPath+"/summary/status", e.StatusSummary)
+	http.Handle(baseUrlPath+"/resume", e.ResumeHandler)
+	http.Handle(baseUrlPath+"/cancel", e.CancelHandler)
+	http.Handle(baseUrlPath+"/loudness", e.LoudnessReport)
 }
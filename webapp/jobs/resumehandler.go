@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/webapp/jobrunner"
+	"log"
+	"net/http"
+)
+
+type ResumeJobHandler struct {
+	redisClient *redis.Client
+	runner      *jobrunner.JobRunner
+}
+
+/**
+restart a failed or lost job from the step that failed, rather than from the beginning. Expects ?forId={job-id}.
+steps that already completed are not run again and their results are kept.
+*/
+func (h ResumeJobHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !helpers.AssertHttpMethod(r, w, "POST") {
+		return
+	}
+
+	_, forId, errResponse := helpers.GetForId(r.RequestURI)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	container, getErr := models.JobContainerForId(*forId, h.redisClient)
+	if getErr == redis.Nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"not_found", "no job with that id"}, w, 404)
+		return
+	} else if getErr != nil {
+		log.Printf("ERROR ResumeJobHandler could not get job %s: %s", *forId, getErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", "could not retrieve job"}, w, 500)
+		return
+	}
+
+	if !container.CanResume() {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"conflict", "only failed or lost jobs can be resumed"}, w, 409)
+		return
+	}
+
+	resumeErr := h.runner.ResumeJob(container)
+	if resumeErr != nil {
+		log.Printf("ERROR ResumeJobHandler could not resume job %s: %s", *forId, resumeErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", "could not resume job"}, w, 500)
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{"status": "ok", "jobId": container.Id, "fromStep": container.CompletedSteps}, w, 200)
+}
@@ -0,0 +1,68 @@
+package jobtemplate
+
+import (
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"net/http"
+)
+
+type CloneTemplate struct {
+	templateMgr *models.JobTemplateManager
+}
+
+type CloneTemplateRequest struct {
+	Name string `json:"name"`
+}
+
+/**
+make a copy of a job template under a new id. Expects ?forId={template-id} and optionally {"name": "new name"} in the
+request body. Templates from the template file can be cloned, which is the way to make a changed version of them
+*/
+func (h CloneTemplate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "POST") {
+		return
+	}
+
+	_, forId, errResponse := helpers.GetForId(r.RequestURI)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	var rq CloneTemplateRequest
+	if r.Body != nil {
+		//the body is optional, so an empty one is fine
+		if readErr := helpers.ReadJsonBody(r.Body, &rq); readErr != nil {
+			log.Printf("WARNING CloneTemplate could not read request body, using the default name: %s", readErr)
+		}
+	}
+
+	source, exists := h.templateMgr.GetJob(*forId)
+	if !exists {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"not_found", "no template with that id"}, w, 404)
+		return
+	}
+
+	//templates from the template file are not validated when they are loaded, so make sure that the copy will save
+	if validationErr := h.templateMgr.ValidateTemplate(source); validationErr != nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"invalid", validationErr.Error()}, w, 400)
+		return
+	}
+
+	saved, saveErr := h.templateMgr.CloneTemplate(*forId, rq.Name)
+	if saveErr != nil {
+		log.Printf("ERROR CloneTemplate could not clone template %s: %s", *forId, saveErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", "could not save template"}, w, 500)
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{
+		"status": "ok",
+		"entry":  saved,
+	}, w, 200)
+}
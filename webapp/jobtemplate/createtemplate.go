@@ -0,0 +1,49 @@
+package jobtemplate
+
+import (
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"net/http"
+)
+
+type CreateTemplate struct {
+	templateMgr *models.JobTemplateManager
+}
+
+/**
+save a new job template, given as json in the request body. If there is no Id in the body then one is made up.
+returns the saved template with its version number
+*/
+func (h CreateTemplate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "POST") {
+		return
+	}
+
+	tpl, errResponse := readTemplateBody(r.Body, nil, h.templateMgr)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	if _, exists := h.templateMgr.GetJob(tpl.Id); exists {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"conflict", "there is already a template with that id, use update to change it"}, w, 409)
+		return
+	}
+
+	saved, saveErr := h.templateMgr.SaveTemplate(*tpl)
+	if saveErr != nil {
+		log.Printf("ERROR CreateTemplate could not save template %s: %s", tpl.Id, saveErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", "could not save template"}, w, 500)
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{
+		"status": "ok",
+		"entry":  saved,
+	}, w, 200)
+}
@@ -6,18 +6,33 @@ import (
 )
 
 type TemplateEndpoints struct {
-	listHandler ListTemplateHandler
-	getHandler  GetTemplate
+	listHandler    ListTemplateHandler
+	getHandler     GetTemplate
+	createHandler  CreateTemplate
+	updateHandler  UpdateTemplate
+	cloneHandler   CloneTemplate
+	retireHandler  RetireTemplate
+	historyHandler TemplateHistory
 }
 
 func NewTemplateEndpoints(jobTemplateMgr *models2.JobTemplateManager) TemplateEndpoints {
 	return TemplateEndpoints{
-		listHandler: ListTemplateHandler{templateMgr: jobTemplateMgr},
-		getHandler:  GetTemplate{templateMgr: jobTemplateMgr},
+		listHandler:    ListTemplateHandler{templateMgr: jobTemplateMgr},
+		getHandler:     GetTemplate{templateMgr: jobTemplateMgr},
+		createHandler:  CreateTemplate{templateMgr: jobTemplateMgr},
+		updateHandler:  UpdateTemplate{templateMgr: jobTemplateMgr},
+		cloneHandler:   CloneTemplate{templateMgr: jobTemplateMgr},
+		retireHandler:  RetireTemplate{templateMgr: jobTemplateMgr},
+		historyHandler: TemplateHistory{templateMgr: jobTemplateMgr},
 	}
 }
 
 func (e TemplateEndpoints) WireUp(baseUrl string) {
 	http.Handle(baseUrl+"", e.listHandler)
 	http.Handle(baseUrl+"/get", e.getHandler)
+	http.Handle(baseUrl+"/create", e.createHandler)
+	http.Handle(baseUrl+"/update", e.updateHandler)
+	http.Handle(baseUrl+"/clone", e.cloneHandler)
+	http.Handle(baseUrl+"/retire", e.retireHandler)
+	http.Handle(baseUrl+"/history", e.historyHandler)
 }
@@ -4,28 +4,46 @@ import (
 	"github.com/guardian/mediaflipper/common/helpers"
 	"github.com/guardian/mediaflipper/common/models"
 	"net/http"
+	"strconv"
 )
 
 type GetTemplate struct {
 	templateMgr *models.JobTemplateManager
 }
 
+/**
+get a job template. Expects ?forId={template-id} and optionally &version={n} to get the version that a job was made from
+*/
 func (h GetTemplate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !helpers.AssertHttpMethod(r, w, "GET") {
 		return
 	}
 
-	_, templateId, err := helpers.GetForId(r.RequestURI)
+	requestUrl, templateId, err := helpers.GetForId(r.RequestURI)
 	if err != nil {
 		helpers.WriteJsonContent(err, w, 400)
 		return
 	}
 
-	tpl, foundTpl := h.templateMgr.GetJob(*templateId)
+	var tpl models.JobTemplateDefinition
+	var foundTpl bool
+	versionString := requestUrl.Query().Get("version")
+	if versionString != "" {
+		version, parseErr := strconv.ParseInt(versionString, 10, 64)
+		if parseErr != nil || version < 0 {
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{"bad_request", "version must be a number"}, w, 400)
+			return
+		}
+		tpl, foundTpl = h.templateMgr.GetJobVersion(*templateId, version)
+	} else {
+		tpl, foundTpl = h.templateMgr.GetJob(*templateId)
+	}
+
 	if foundTpl {
 		helpers.WriteJsonContent(map[string]interface{}{
-			"status": "ok",
-			"entry":  tpl,
+			"status":   "ok",
+			"entry":    tpl,
+			"readOnly": h.templateMgr.IsReadOnly(*templateId),
 		}, w, 200)
 	} else {
 		helpers.WriteJsonContent(helpers.GenericErrorResponse{"not_found", "no template with that id"}, w, 404)
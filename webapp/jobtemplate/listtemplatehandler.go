@@ -4,6 +4,7 @@ import (
 	"github.com/guardian/mediaflipper/common/helpers"
 	models2 "github.com/guardian/mediaflipper/common/models"
 	"net/http"
+	"net/url"
 )
 
 type ListTemplateHandler struct {
@@ -15,8 +16,16 @@ func (h ListTemplateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	requestUrl, _ := url.ParseRequestURI(r.RequestURI)
+	var templates []models2.JobTemplateDefinition
+	if requestUrl != nil && requestUrl.Query().Get("includeRetired") == "true" {
+		templates = h.templateMgr.ListAllTemplates()
+	} else {
+		templates = h.templateMgr.ListTemplates()
+	}
+
 	helpers.WriteJsonContent(map[string]interface{}{
 		"status":  "ok",
-		"entries": h.templateMgr.ListTemplates(),
+		"entries": templates,
 	}, w, 200)
 }
@@ -0,0 +1,58 @@
+package jobtemplate
+
+import (
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"net/http"
+)
+
+type RetireTemplate struct {
+	templateMgr *models.JobTemplateManager
+}
+
+/**
+retire a job template so that no new jobs can be made from it. Expects ?forId={template-id}.
+the template and its history are kept for the jobs that were made from it. Templates from the template file can't be retired
+*/
+func (h RetireTemplate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "POST") {
+		return
+	}
+
+	_, forId, errResponse := helpers.GetForId(r.RequestURI)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	if h.templateMgr.IsReadOnly(*forId) {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"forbidden", "this template is from the template file and can't be retired"}, w, 403)
+		return
+	}
+	current, exists := h.templateMgr.GetJob(*forId)
+	if !exists {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"not_found", "no template with that id"}, w, 404)
+		return
+	}
+	if current.Retired {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"conflict", "this template has already been retired"}, w, 409)
+		return
+	}
+
+	retired, retireErr := h.templateMgr.RetireTemplate(*forId)
+	if retireErr != nil {
+		log.Printf("ERROR RetireTemplate could not retire template %s: %s", *forId, retireErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", "could not retire template"}, w, 500)
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{
+		"status": "ok",
+		"entry":  retired,
+	}, w, 200)
+}
@@ -0,0 +1,123 @@
+package jobtemplate
+
+import (
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v7"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"net/http"
+	"testing"
+)
+
+const testTemplateBody = `{
+	"JobTypeName": "Audio only",
+	"Steps": [
+		{"Id": "702DBDC5-CE51-4760-82E4-01BC1FB4771E", "PredeterminedType": "analysis", "KubernetesTemplateFile": "config/AnalysisJobTemplate.yaml"},
+		{"Id": "6FF216B6-A395-4237-A9F2-2FEB3F24823E", "PredeterminedType": "transcode", "KubernetesTemplateFile": "config/AnalysisJobTemplate.yaml",
+			"TranscodeSettingsId": "5C7B1E94-2D6A-4F83-A0B9-8E3D6C1F7A24"}
+	]
+}`
+
+func callHandler(handler http.Handler, method string, uri string, body string) (int, map[string]interface{}) {
+	mockBody := helpers.NewMockReadCloser()
+	mockBody.DataToRead = []byte(body)
+
+	mockRequest := http.Request{
+		Method:     method,
+		RequestURI: "https://myserver.com/api/jobtemplate" + uri,
+		Proto:      "https",
+		ProtoMajor: 1,
+		ProtoMinor: 0,
+		Body:       mockBody,
+	}
+	mockWriter := helpers.NewMockResponseWriter()
+	handler.ServeHTTP(mockWriter, &mockRequest)
+
+	jsonContent, _ := mockWriter.LastWrittenJson()
+	if mockWriter.State.WrittenStatusCode == nil {
+		return 200, jsonContent
+	}
+	return *mockWriter.State.WrittenStatusCode, jsonContent
+}
+
+func TestTemplateHandlers(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	settingsMgr, settingsLoadErr := models.NewTranscodeSettingsManager("../config/settings")
+	if settingsLoadErr != nil {
+		t.Fatalf("Could not load transcode settings: %s", settingsLoadErr)
+	}
+	templateMgr, loadErr := models.NewJobTemplateManager("../config/standardjobtemplate.yaml", settingsMgr)
+	if loadErr != nil {
+		t.Fatalf("Could not load templates: %s", loadErr)
+	}
+	templateMgr.UseDatastore(testClient, nil)
+	endpoints := NewTemplateEndpoints(templateMgr)
+
+	status, content := callHandler(endpoints.createHandler, "POST", "/create", testTemplateBody)
+	if status != 200 {
+		t.Fatalf("create returned %d: %v", status, content)
+	}
+	entry := content["entry"].(map[string]interface{})
+	newId := entry["Id"].(string)
+	if entry["Version"] != float64(1) {
+		t.Errorf("expected version 1 from create, got %v", entry["Version"])
+	}
+
+	status, content = callHandler(endpoints.createHandler, "POST", "/create", `{"JobTypeName": "Broken", "Steps": [{"PredeterminedType": "frobnicate"}]}`)
+	if status != 400 {
+		t.Errorf("expected 400 for an invalid template, got %d: %v", status, content)
+	}
+
+	status, content = callHandler(endpoints.updateHandler, "PUT", "/update?forId="+newId, testTemplateBody)
+	if status != 200 {
+		t.Fatalf("update returned %d: %v", status, content)
+	}
+	if content["entry"].(map[string]interface{})["Version"] != float64(2) {
+		t.Errorf("expected version 2 from update, got %v", content["entry"])
+	}
+
+	fileTemplateId := "846F823E-C0D3-4AF0-AD51-0F9573379057"
+	status, _ = callHandler(endpoints.updateHandler, "PUT", "/update?forId="+fileTemplateId, testTemplateBody)
+	if status != 403 {
+		t.Errorf("expected 403 updating a template from the file, got %d", status)
+	}
+
+	status, content = callHandler(endpoints.cloneHandler, "POST", "/clone?forId="+fileTemplateId, `{"name": "My copy"}`)
+	if status != 200 {
+		t.Fatalf("clone returned %d: %v", status, content)
+	}
+	if content["entry"].(map[string]interface{})["JobTypeName"] != "My copy" {
+		t.Errorf("clone was not renamed: %v", content["entry"])
+	}
+
+	status, content = callHandler(endpoints.retireHandler, "POST", "/retire?forId="+newId, "")
+	if status != 200 {
+		t.Fatalf("retire returned %d: %v", status, content)
+	}
+	status, _ = callHandler(endpoints.retireHandler, "POST", "/retire?forId="+newId, "")
+	if status != 409 {
+		t.Errorf("expected 409 retiring a template twice, got %d", status)
+	}
+
+	status, content = callHandler(endpoints.getHandler, "GET", "/get?forId="+newId+"&version=1", "")
+	if status != 200 {
+		t.Fatalf("get version returned %d: %v", status, content)
+	}
+	if content["entry"].(map[string]interface{})["Retired"] != false {
+		t.Errorf("version 1 should not be retired: %v", content["entry"])
+	}
+
+	status, content = callHandler(endpoints.historyHandler, "GET", "/history?forId="+newId, "")
+	if status != 200 || content["count"] != float64(3) {
+		t.Errorf("expected 3 versions in the history, got %d: %v", status, content)
+	}
+}
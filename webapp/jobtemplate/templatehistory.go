@@ -0,0 +1,42 @@
+package jobtemplate
+
+import (
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"net/http"
+)
+
+type TemplateHistory struct {
+	templateMgr *models.JobTemplateManager
+}
+
+/**
+list every version of a stored job template, oldest first. Expects ?forId={template-id}.
+templates from the template file have no history so get an empty list
+*/
+func (h TemplateHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !helpers.AssertHttpMethod(r, w, "GET") {
+		return
+	}
+
+	_, forId, errResponse := helpers.GetForId(r.RequestURI)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	versions, historyErr := h.templateMgr.TemplateHistory(*forId)
+	if historyErr != nil {
+		log.Printf("ERROR TemplateHistory could not get history for %s: %s", *forId, historyErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", "could not retrieve history"}, w, 500)
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{
+		"status":   "ok",
+		"readOnly": h.templateMgr.IsReadOnly(*forId),
+		"count":    len(versions),
+		"entries":  versions,
+	}, w, 200)
+}
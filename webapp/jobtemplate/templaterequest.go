@@ -0,0 +1,38 @@
+package jobtemplate
+
+import (
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"io"
+	"log"
+)
+
+/**
+reads in a template from the request body and makes sure that it is fit to save.
+if forId is given then the template is given that id, otherwise a new one is made up if the body does not have one.
+the version information is worked out when the template is saved so anything in the body is ignored
+*/
+func readTemplateBody(body io.Reader, forId *uuid.UUID, templateMgr *models.JobTemplateManager) (*models.JobTemplateDefinition, *helpers.GenericErrorResponse) {
+	var tpl models.JobTemplateDefinition
+	readErr := helpers.ReadJsonBody(body, &tpl)
+	if readErr != nil {
+		log.Printf("ERROR jobtemplate could not read request body: %s", readErr)
+		return nil, &helpers.GenericErrorResponse{Status: "bad_request", Detail: "expected a json template"}
+	}
+
+	if forId != nil {
+		tpl.Id = *forId
+	} else if tpl.Id == uuid.Nil {
+		tpl.Id = uuid.New()
+	}
+	tpl.Version = 0
+	tpl.Retired = false
+	tpl.UpdatedAt = nil
+
+	validationErr := templateMgr.ValidateTemplate(tpl)
+	if validationErr != nil {
+		return nil, &helpers.GenericErrorResponse{Status: "invalid", Detail: validationErr.Error()}
+	}
+	return &tpl, nil
+}
@@ -0,0 +1,65 @@
+package jobtemplate
+
+import (
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"net/http"
+)
+
+type UpdateTemplate struct {
+	templateMgr *models.JobTemplateManager
+}
+
+/**
+save a new version of an existing job template. Expects ?forId={template-id} and the complete template as json in the
+request body; any Id in the body is ignored. Jobs that were made from earlier versions keep using those versions.
+templates from the template file and retired templates can't be changed
+*/
+func (h UpdateTemplate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "PUT") {
+		return
+	}
+
+	_, forId, errResponse := helpers.GetForId(r.RequestURI)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	if h.templateMgr.IsReadOnly(*forId) {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"forbidden", "this template is from the template file and can't be changed, clone it instead"}, w, 403)
+		return
+	}
+	current, exists := h.templateMgr.GetJob(*forId)
+	if !exists {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"not_found", "no template with that id"}, w, 404)
+		return
+	}
+	if current.Retired {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"conflict", "this template has been retired"}, w, 409)
+		return
+	}
+
+	tpl, errResponse := readTemplateBody(r.Body, forId, h.templateMgr)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	saved, saveErr := h.templateMgr.SaveTemplate(*tpl)
+	if saveErr != nil {
+		log.Printf("ERROR UpdateTemplate could not save template %s: %s", *forId, saveErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", "could not save template"}, w, 500)
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{
+		"status": "ok",
+		"entry":  saved,
+	}, w, 200)
+}
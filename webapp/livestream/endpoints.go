@@ -0,0 +1,20 @@
+package livestream
+
+import (
+	"github.com/go-redis/redis/v7"
+	"net/http"
+)
+
+type LiveStreamEndpoints struct {
+	streamEndpoint StreamHandler
+}
+
+func NewLiveStreamEndpoints(redisClient *redis.Client, hub *Hub) LiveStreamEndpoints {
+	return LiveStreamEndpoints{
+		streamEndpoint: StreamHandler{redisClient: redisClient, hub: hub},
+	}
+}
+
+func (e LiveStreamEndpoints) WireUp(baseUrl string) {
+	http.Handle(baseUrl+"/stream", e.streamEndpoint)
+}
@@ -0,0 +1,112 @@
+package livestream
+
+import (
+	"encoding/json"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"log"
+	"sync"
+)
+
+/**
+how many messages can be waiting for a client before any more are dropped
+*/
+const subscriberBufferSize = 256
+
+/**
+what a client wants to hear about. If neither id is set then it gets everything
+*/
+type Filter struct {
+	JobId      *uuid.UUID
+	BulkListId *uuid.UUID
+}
+
+func (f Filter) Matches(msg Message) bool {
+	if f.JobId != nil && (msg.JobId == nil || *msg.JobId != *f.JobId) {
+		return false
+	}
+	if f.BulkListId != nil && (msg.BulkListId == nil || *msg.BulkListId != *f.BulkListId) {
+		return false
+	}
+	return true
+}
+
+type subscriber struct {
+	filter   Filter
+	messages chan Message
+}
+
+/**
+listens on the live channel and passes each message on to the clients of this instance that want it.
+there is one of these per webapp instance, so only one redis connection is used however many clients there are
+*/
+type Hub struct {
+	redisClient *redis.Client
+	lock        sync.RWMutex
+	subscribers map[*subscriber]bool
+}
+
+func NewHub(redisClient *redis.Client) *Hub {
+	return &Hub{
+		redisClient: redisClient,
+		subscribers: make(map[*subscriber]bool),
+	}
+}
+
+func (h *Hub) subscribe(filter Filter) *subscriber {
+	s := &subscriber{
+		filter:   filter,
+		messages: make(chan Message, subscriberBufferSize),
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.subscribers[s] = true
+	return s
+}
+
+func (h *Hub) unsubscribe(s *subscriber) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.subscribers, s)
+}
+
+/**
+pass a message from the live channel on to every client that wants it. A client that is not keeping up misses the
+message rather than holding up the others
+*/
+func (h *Hub) dispatch(payload string) {
+	var msg Message
+	unmarshalErr := json.Unmarshal([]byte(payload), &msg)
+	if unmarshalErr != nil {
+		log.Printf("WARNING livestream ignoring invalid message: %s. Offending data was %s", unmarshalErr, payload)
+		return
+	}
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	for s := range h.subscribers {
+		if !s.filter.Matches(msg) {
+			continue
+		}
+		select {
+		case s.messages <- msg:
+		default:
+			log.Printf("WARNING livestream client is not keeping up, dropped %s message", msg.Type)
+		}
+	}
+}
+
+/**
+subscribe to the live channel and dispatch messages until the subscription is closed. The redis client reconnects by
+itself if the connection drops. This should be run as a goroutine
+*/
+func (h *Hub) Run() {
+	pubsub := h.redisClient.Subscribe(liveChannel)
+	defer pubsub.Close()
+
+	log.Printf("INFO livestream listening for updates on %s", liveChannel)
+	for msg := range pubsub.Channel() {
+		h.dispatch(msg.Payload)
+	}
+	log.Printf("WARNING livestream subscription to %s has closed", liveChannel)
+}
@@ -0,0 +1,172 @@
+package livestream
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func encodeMessage(t *testing.T, msg Message) string {
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(encoded)
+}
+
+func TestFilterMatches(t *testing.T) {
+	jobId := uuid.New()
+	otherJobId := uuid.New()
+	listId := uuid.New()
+
+	inList := Message{Type: MESSAGE_STEP, JobId: &jobId, BulkListId: &listId}
+	notInList := Message{Type: MESSAGE_STEP, JobId: &otherJobId}
+
+	if !(Filter{}).Matches(inList) || !(Filter{}).Matches(notInList) {
+		t.Error("an empty filter should match everything")
+	}
+	if !(Filter{JobId: &jobId}).Matches(inList) || (Filter{JobId: &jobId}).Matches(notInList) {
+		t.Error("a job filter should only match messages for that job")
+	}
+	if !(Filter{BulkListId: &listId}).Matches(inList) || (Filter{BulkListId: &listId}).Matches(notInList) {
+		t.Error("a bulk list filter should only match messages for jobs in that list")
+	}
+}
+
+/**
+dispatch should pass each message to the subscribers that want it, and drop messages for a subscriber that is not
+reading them rather than blocking
+*/
+func TestHubDispatch(t *testing.T) {
+	jobId := uuid.New()
+	otherJobId := uuid.New()
+	h := NewHub(nil)
+	everything := h.subscribe(Filter{})
+	oneJob := h.subscribe(Filter{JobId: &jobId})
+	otherJob := h.subscribe(Filter{JobId: &otherJobId})
+
+	h.dispatch(encodeMessage(t, Message{Type: MESSAGE_JOB, JobId: &jobId, Status: "active"}))
+	h.dispatch("this is not json")
+
+	if len(everything.messages) != 1 || len(oneJob.messages) != 1 || len(otherJob.messages) != 0 {
+		t.Errorf("unexpected message counts %d, %d, %d", len(everything.messages), len(oneJob.messages), len(otherJob.messages))
+	}
+	received := <-oneJob.messages
+	if received.Type != MESSAGE_JOB || received.Status != "active" {
+		t.Errorf("unexpected message %v", received)
+	}
+
+	for i := 0; i < subscriberBufferSize+10; i++ {
+		h.dispatch(encodeMessage(t, Message{Type: MESSAGE_QUEUE, JobId: &jobId}))
+	}
+	if len(everything.messages) != subscriberBufferSize {
+		t.Errorf("expected a full buffer of %d messages, got %d", subscriberBufferSize, len(everything.messages))
+	}
+
+	h.unsubscribe(oneJob)
+	if len(h.subscribers) != 2 {
+		t.Errorf("expected 2 subscribers after unsubscribing, got %d", len(h.subscribers))
+	}
+}
+
+/**
+reads the next server-sent event from the stream, skipping comments
+*/
+func readEvent(t *testing.T, reader *bufio.Reader) (string, Message) {
+	var eventName string
+	var msg Message
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil {
+			t.Fatalf("could not read from stream: %s", readErr)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && eventName != "":
+			return eventName, msg
+		case strings.HasPrefix(line, "event: "):
+			eventName = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &msg); err != nil {
+				t.Fatalf("could not parse event data '%s': %s", line, err)
+			}
+		}
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+	testClient := redis.NewClient(&redis.Options{Addr: s.Addr()})
+
+	startTime := time.Now()
+	container := models.JobContainer{Id: uuid.New(), Status: models.JOB_STARTED, Steps: []models.JobStep{}, StartTime: &startTime}
+	if storErr := container.Store(testClient); storErr != nil {
+		t.Fatal(storErr)
+	}
+
+	h := NewHub(testClient)
+	server := httptest.NewServer(StreamHandler{redisClient: testClient, hub: h})
+	defer server.Close()
+
+	response, getErr := http.Get(server.URL + "/api/live/stream?jobId=" + uuid.New().String())
+	if getErr != nil {
+		t.Fatal(getErr)
+	}
+	response.Body.Close()
+	if response.StatusCode != 404 {
+		t.Errorf("expected 404 for an unknown job, got %d", response.StatusCode)
+	}
+
+	response, getErr = http.Get(server.URL + "/api/live/stream?jobId=" + container.Id.String() + "&bulkListId=" + uuid.New().String())
+	if getErr != nil {
+		t.Fatal(getErr)
+	}
+	response.Body.Close()
+	if response.StatusCode != 400 {
+		t.Errorf("expected 400 when giving both a job and a bulk list, got %d", response.StatusCode)
+	}
+
+	response, getErr = http.Get(server.URL + "/api/live/stream?jobId=" + container.Id.String())
+	if getErr != nil {
+		t.Fatal(getErr)
+	}
+	defer response.Body.Close()
+	if response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("unexpected content type %s", response.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(response.Body)
+
+	eventName, initial := readEvent(t, reader)
+	if eventName != "job" || initial.Status != "active" || *initial.JobId != container.Id {
+		t.Errorf("expected the current job status first, got %s %v", eventName, initial)
+	}
+
+	otherJobId := uuid.New()
+	stepId := uuid.New()
+	h.dispatch(encodeMessage(t, Message{Type: MESSAGE_PROGRESS, JobId: &otherJobId}))
+	h.dispatch(encodeMessage(t, Message{
+		Type:     MESSAGE_PROGRESS,
+		JobId:    &container.Id,
+		StepId:   &stepId,
+		Progress: &models.TranscodeProgress{FramesProcessed: 1234},
+	}))
+
+	timeout := time.AfterFunc(5*time.Second, func() { response.Body.Close() }) //so that the read fails rather than hanging
+	defer timeout.Stop()
+	eventName, progress := readEvent(t, reader)
+	if eventName != "progress" || *progress.StepId != stepId || progress.Progress.FramesProcessed != 1234 {
+		t.Errorf("expected the progress for this job only, got %s %v", eventName, progress)
+	}
+}
@@ -0,0 +1,151 @@
+package livestream
+
+import (
+	"encoding/json"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"time"
+)
+
+/**
+every message goes out on this one channel, each webapp instance subscribes to it once and picks out what its own
+clients are interested in
+*/
+const liveChannel = "mediaflipper:live"
+
+type MessageType string
+
+const (
+	MESSAGE_PROGRESS MessageType = "progress" //transcode progress from the wrapper
+	MESSAGE_STEP     MessageType = "step"     //a step of the job changed status
+	MESSAGE_JOB      MessageType = "job"      //the job changed status
+	MESSAGE_QUEUE    MessageType = "queue"    //the job went onto or came off the queues
+)
+
+type QueueChange string
+
+const (
+	QUEUE_ADDED   QueueChange = "added"
+	QUEUE_REMOVED QueueChange = "removed"
+)
+
+/**
+an update that is streamed to the clients. Which of the optional fields are set depends on the type of message
+*/
+type Message struct {
+	Type           MessageType               `json:"type"`
+	Timestamp      time.Time                 `json:"timestamp"`
+	JobId          *uuid.UUID                `json:"jobId,omitempty"`
+	BulkListId     *uuid.UUID                `json:"bulkListId,omitempty"`
+	StepId         *uuid.UUID                `json:"stepId,omitempty"`
+	Status         string                    `json:"status,omitempty"`         //the status of the step for step messages, or of the job for the others
+	ErrorMessage   string                    `json:"errorMessage,omitempty"`   //of the step for step messages, or of the job for job messages
+	CompletedSteps int                       `json:"completedSteps,omitempty"` //job messages only
+	TotalSteps     int                       `json:"totalSteps,omitempty"`     //job messages only
+	Queue          models.QueueName          `json:"queue,omitempty"`          //queue messages only. Not set when the job was taken off all of them
+	QueueChange    QueueChange               `json:"queueChange,omitempty"`    //queue messages only
+	Progress       *models.TranscodeProgress `json:"progress,omitempty"`       //progress messages only
+}
+
+/**
+send the message to every webapp instance. Failures are only logged, as nothing else depends on the message getting through
+*/
+func Publish(msg Message, redisClient redis.Cmdable) {
+	encoded, marshalErr := json.Marshal(msg)
+	if marshalErr != nil {
+		log.Printf("ERROR livestream could not encode %s message: %s", msg.Type, marshalErr)
+		return
+	}
+	publishErr := redisClient.Publish(liveChannel, string(encoded)).Err()
+	if publishErr != nil {
+		log.Printf("WARNING livestream could not publish %s message: %s", msg.Type, publishErr)
+	}
+}
+
+func newMessage(msgType MessageType, container *models.JobContainer) Message {
+	jobId := container.Id
+	msg := Message{
+		Type:      msgType,
+		Timestamp: time.Now(),
+		JobId:     &jobId,
+	}
+	if container.AssociatedBulk != nil {
+		listId := container.AssociatedBulk.List
+		msg.BulkListId = &listId
+	}
+	return msg
+}
+
+/**
+build the message giving the current status of the job
+*/
+func jobMessage(container *models.JobContainer) Message {
+	msg := newMessage(MESSAGE_JOB, container)
+	msg.Status = models.JobStatusToString(container.Status)
+	msg.ErrorMessage = container.ErrorMessage
+	msg.CompletedSteps = container.CompletedSteps
+	msg.TotalSteps = len(container.Steps)
+	return msg
+}
+
+/**
+publish the current status of the job
+*/
+func JobChanged(container *models.JobContainer, redisClient redis.Cmdable) {
+	Publish(jobMessage(container), redisClient)
+}
+
+/**
+publish the current status of the given step of the job
+*/
+func StepChanged(container *models.JobContainer, stepId uuid.UUID, redisClient redis.Cmdable) {
+	step := container.FindStepById(stepId)
+	if step == nil {
+		log.Printf("WARNING livestream job %s has no step %s to publish", container.Id, stepId)
+		return
+	}
+	msg := newMessage(MESSAGE_STEP, container)
+	msg.StepId = &stepId
+	msg.Status = models.JobStatusToString((*step).Status())
+	msg.ErrorMessage = (*step).ErrorMessage()
+	Publish(msg, redisClient)
+}
+
+/**
+publish that the job has gone onto or come off the given queue. `stepId` is given for the running queue, where each step
+has its own entry. `queue` is empty if the job was taken off all of the queues
+*/
+func QueueChanged(container *models.JobContainer, queue models.QueueName, stepId *uuid.UUID, change QueueChange, redisClient redis.Cmdable) {
+	msg := newMessage(MESSAGE_QUEUE, container)
+	msg.StepId = stepId
+	msg.Status = models.JobStatusToString(container.Status)
+	msg.Queue = queue
+	msg.QueueChange = change
+	Publish(msg, redisClient)
+}
+
+/**
+publish a progress update from the wrapper. The job is looked up so that the update reaches clients that are following
+its bulk list; if that fails then it is still published for the job
+*/
+func ProgressReceived(progress models.TranscodeProgress, redisClient *redis.Client) {
+	jobId := progress.JobContainerId
+	stepId := progress.JobStepId
+	msg := Message{
+		Type:      MESSAGE_PROGRESS,
+		Timestamp: time.Now(),
+		JobId:     &jobId,
+		StepId:    &stepId,
+		Progress:  &progress,
+	}
+	container, getErr := models.JobContainerForId(jobId, redisClient)
+	if getErr != nil {
+		log.Printf("WARNING livestream could not look up job %s for progress update: %s", jobId, getErr)
+	} else if container.AssociatedBulk != nil {
+		listId := container.AssociatedBulk.List
+		msg.BulkListId = &listId
+	}
+	Publish(msg, redisClient)
+}
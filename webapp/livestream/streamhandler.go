@@ -0,0 +1,181 @@
+package livestream
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/webapp/bulkprocessor"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+/**
+how often a comment is sent on an idle stream, so that proxies and load balancers don't close it
+*/
+var keepaliveInterval = 15 * time.Second
+
+type StreamHandler struct {
+	redisClient *redis.Client
+	hub         *Hub
+}
+
+/**
+returns the uuid in the given query parameter, nil if it is not set or an error response if it is not a valid uuid
+*/
+func uuidParam(requestUrl *url.URL, name string) (*uuid.UUID, *helpers.GenericErrorResponse) {
+	value := requestUrl.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, parseErr := uuid.Parse(value)
+	if parseErr != nil {
+		return nil, &helpers.GenericErrorResponse{
+			Status: "bad_request",
+			Detail: fmt.Sprintf("%s is not a valid uuid", name),
+		}
+	}
+	return &parsed, nil
+}
+
+func writeEvent(w http.ResponseWriter, flusher http.Flusher, msg Message) error {
+	encoded, marshalErr := json.Marshal(msg)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	_, writeErr := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, encoded)
+	if writeErr != nil {
+		return writeErr
+	}
+	flusher.Flush()
+	return nil
+}
+
+/**
+stream live updates as server-sent events. Takes either ?jobId={job-id} or ?bulkListId={bulk-id} to only get the
+updates for that job or bulk list, or neither to get everything.
+each event is named after the type of message and has the message as json in its data. When following a job, its current
+status is sent first
+*/
+func (h StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "GET") {
+		return
+	}
+
+	requestUrl, urlErr := url.ParseRequestURI(r.RequestURI)
+	if urlErr != nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "bad_request",
+			Detail: "could not understand the request url",
+		}, w, 400)
+		return
+	}
+
+	var filter Filter
+	var errResponse *helpers.GenericErrorResponse
+	filter.JobId, errResponse = uuidParam(requestUrl, "jobId")
+	if errResponse == nil {
+		filter.BulkListId, errResponse = uuidParam(requestUrl, "bulkListId")
+	}
+	if errResponse == nil && filter.JobId != nil && filter.BulkListId != nil {
+		errResponse = &helpers.GenericErrorResponse{
+			Status: "bad_request",
+			Detail: "give either jobId or bulkListId, not both",
+		}
+	}
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	flusher, canFlush := w.(http.Flusher)
+	if !canFlush {
+		log.Printf("ERROR StreamHandler the response writer does not support streaming")
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "error",
+			Detail: "streaming is not supported",
+		}, w, 500)
+		return
+	}
+
+	//subscribe before looking up the current state, so that nothing is missed in between
+	sub := h.hub.subscribe(filter)
+	defer h.hub.unsubscribe(sub)
+
+	var initial *Message
+	if filter.JobId != nil {
+		container, getErr := models.JobContainerForId(*filter.JobId, h.redisClient)
+		if getErr == redis.Nil {
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{
+				Status: "not_found",
+				Detail: "no job with that id",
+			}, w, 404)
+			return
+		} else if getErr != nil {
+			log.Printf("ERROR StreamHandler could not get job %s: %s", *filter.JobId, getErr)
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{
+				Status: "db_error",
+				Detail: "could not retrieve job",
+			}, w, 500)
+			return
+		}
+		msg := jobMessage(container)
+		initial = &msg
+	} else if filter.BulkListId != nil {
+		_, getErr := bulkprocessor.BulkListForId(*filter.BulkListId, h.redisClient)
+		if getErr == redis.Nil {
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{
+				Status: "not_found",
+				Detail: "no bulk list with that id",
+			}, w, 404)
+			return
+		} else if getErr != nil {
+			log.Printf("ERROR StreamHandler could not get bulk list %s: %s", *filter.BulkListId, getErr)
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{
+				Status: "db_error",
+				Detail: "could not retrieve bulk list",
+			}, w, 500)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") //stop nginx from buffering the stream
+	w.WriteHeader(200)
+	flusher.Flush()
+
+	if initial != nil {
+		if writeErr := writeEvent(w, flusher, *initial); writeErr != nil {
+			return
+		}
+	}
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-sub.messages:
+			if writeErr := writeEvent(w, flusher, msg); writeErr != nil {
+				log.Printf("INFO StreamHandler client has gone away: %s", writeErr)
+				return
+			}
+		case <-keepalive.C:
+			if _, writeErr := fmt.Fprint(w, ": keepalive\n\n"); writeErr != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -2,24 +2,34 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"github.com/go-redis/redis/v7"
 	"github.com/guardian/mediaflipper/common/helpers"
 	models2 "github.com/guardian/mediaflipper/common/models"
 	"github.com/guardian/mediaflipper/webapp/analysis"
 	"github.com/guardian/mediaflipper/webapp/bulkprocessor"
+	"github.com/guardian/mediaflipper/webapp/configreload"
 	"github.com/guardian/mediaflipper/webapp/files"
 	"github.com/guardian/mediaflipper/webapp/initiator"
 	"github.com/guardian/mediaflipper/webapp/jobrunner"
 	"github.com/guardian/mediaflipper/webapp/jobs"
 	"github.com/guardian/mediaflipper/webapp/jobtemplate"
+	"github.com/guardian/mediaflipper/webapp/livestream"
+	"github.com/guardian/mediaflipper/webapp/qc"
 	"github.com/guardian/mediaflipper/webapp/thumbnail"
 	transcode2 "github.com/guardian/mediaflipper/webapp/transcode"
 	"github.com/guardian/mediaflipper/webapp/transcodesettings"
+	"github.com/guardian/mediaflipper/webapp/validation"
+	"github.com/guardian/mediaflipper/webapp/webhooks"
 	"k8s.io/client-go/kubernetes"
 	"log"
 	"net/http"
+	"os"
 )
 
+const configFilePath = "config/serverconfig.yaml"
+const templateFilePath = "config/standardjobtemplate.yaml"
+
 type MyHttpApp struct {
 	index       IndexHandler
 	healthcheck HealthcheckHandler
@@ -34,6 +44,10 @@ type MyHttpApp struct {
 	transcode   transcode2.TranscodeEndpoints
 	bulk        bulkprocessor.BulkEndpoints
 	runner      jobrunner.JobRunnerEndpoints
+	qc          qc.QCEndpoints
+	config      configreload.ConfigReloadEndpoints
+	webhooks    webhooks.WebhookEndpoints
+	live        livestream.LiveStreamEndpoints
 }
 
 func SetupRedis(config *helpers.Config) (*redis.Client, error) {
@@ -77,19 +91,45 @@ func main() {
 
 	kubeConfigPath := flag.String("kubeconfig", "", ".kubeconfig file for running out of cluster. If not specified then in-cluster initialisation will be tried")
 	noProcessor := flag.Bool("noprocessor", false, "set this option to disable background processing of jobs.")
+	validateOnly := flag.Bool("validate", false, "check the templates, transcode settings and kubernetes job templates, print any problems and exit")
+	strictValidation := flag.Bool("strict", false, "refuse to start if there are any problems with the templates, transcode settings or kubernetes job templates")
 	flag.Parse()
 
 	/*
 		read in config and establish connection to persistence layer
 	*/
 	log.Printf("Reading config from serverconfig.yaml")
-	config, configReadErr := helpers.ReadConfig("config/serverconfig.yaml")
+	config, configReadErr := helpers.ReadConfig(configFilePath)
 	log.Print("Done.")
 
 	if configReadErr != nil {
 		log.Fatal("No configuration, can't continue")
 	}
 
+	/*
+		check that the templates, settings and kubernetes job templates all fit together before anything uses them
+	*/
+	validationReport := validation.ValidateConfig(config.SettingsPath, templateFilePath)
+	if *validateOnly {
+		for _, problem := range validationReport.Problems {
+			fmt.Println(problem)
+		}
+		if !validationReport.IsValid() {
+			fmt.Printf("Found %d problems with the configuration\n", len(validationReport.Problems))
+			os.Exit(1)
+		}
+		fmt.Println("The configuration is valid")
+		os.Exit(0)
+	}
+	if !validationReport.IsValid() {
+		if *strictValidation || config.StrictValidation {
+			validationReport.Log("ERROR")
+			log.Fatalf("Found %d problems with the configuration and strict validation is on, can't continue", len(validationReport.Problems))
+		}
+		validationReport.Log("WARNING")
+		log.Printf("WARNING: Found %d problems with the configuration, jobs that use the templates or settings concerned will fail", len(validationReport.Problems))
+	}
+
 	redisClient, redisErr := SetupRedis(config)
 	if redisErr != nil {
 		log.Fatal("Could not connect to redis")
@@ -100,37 +140,69 @@ func main() {
 	if mgrLoadErr != nil {
 		log.Fatal("Could not load in any transcode settings: ", mgrLoadErr)
 	}
+	settingsMgr.UseDatastore(redisClient)
 
 	k8Client, _ := GetK8Client(kubeConfigPath)
 
-	templateMgr, mgrLoadErr := models2.NewJobTemplateManager("config/standardjobtemplate.yaml", settingsMgr)
+	templateMgr, mgrLoadErr := models2.NewJobTemplateManager(templateFilePath, settingsMgr)
 
 	if mgrLoadErr != nil {
-		log.Printf("Could not initialise template manager: %s", mgrLoadErr)
+		log.Fatal("Could not initialise template manager: ", mgrLoadErr)
 	}
+	templateMgr.UseDatastore(redisClient, func(fileName string) error {
+		_, loadErr := jobrunner.LoadFromTemplate(fileName)
+		return loadErr
+	})
 
 	if config.MaxJobs == 0 {
 		config.MaxJobs = 10
 	}
 
 	log.Printf("INFO: MaxJobs is set to %d", config.MaxJobs)
-	runner := jobrunner.NewJobRunner(redisClient, k8Client, templateMgr, int32(config.MaxJobs), !(*noProcessor))
+
+	executor, executorErr := jobrunner.NewJobExecutor(&config.Executor, k8Client)
+	if executorErr != nil {
+		if !(*noProcessor) {
+			log.Fatal("Could not set up job executor: ", executorErr)
+		}
+		log.Printf("WARNING: Could not set up job executor: %s", executorErr)
+	}
+
+	notifier := webhooks.NewNotifier(redisClient)
+	go notifier.DeliveryLoop(webhooks.DefaultDeliveryInterval)
+
+	limits := jobrunner.NewConcurrencyLimits(&config.Concurrency)
+	runner := jobrunner.NewJobRunner(redisClient, executor, templateMgr, int32(config.MaxJobs), limits, notifier, !(*noProcessor))
+
+	liveHub := livestream.NewHub(redisClient)
+	go liveHub.Run()
+
+	/*
+		pick up changes to the config, settings and templates without a restart, on SIGHUP or when the files change
+	*/
+	reloader := configreload.NewConfigReloader(configFilePath, templateFilePath, config, settingsMgr, templateMgr, runner)
+	reloader.HandleSignals()
+	go reloader.Watch(configreload.DefaultWatchInterval)
 
 	app.index.filePath = "static/index.html"
 	app.index.contentType = "text/html"
 	app.healthcheck.redisClient = redisClient
 	app.static.basePath = "static"
 	app.static.uriTrim = 2
-	app.initiators = initiator.NewInitiatorEndpoints(config, redisClient, &runner)
-	app.jobs = jobs.NewJobsEndpoints(redisClient, k8Client, templateMgr)
+	app.initiators = initiator.NewInitiatorEndpoints(config, redisClient, runner)
+	app.jobs = jobs.NewJobsEndpoints(redisClient, k8Client, templateMgr, runner)
 	app.analysers = analysis.NewAnalysisEndpoints(redisClient)
 	app.templates = jobtemplate.NewTemplateEndpoints(templateMgr)
 	app.thumbnails = thumbnail.NewThumbnailEndpoints(redisClient)
 	app.files = files.NewFilesEndpoints(redisClient)
-	app.tsettings = transcodesettings.NewTranscodeSettingsEndpoints(settingsMgr)
+	app.tsettings = transcodesettings.NewTranscodeSettingsEndpoints(settingsMgr, templateMgr)
 	app.transcode = transcode2.NewTranscodeEndpoints(redisClient)
 	app.bulk = bulkprocessor.NewBulkEndpoints(redisClient, templateMgr)
-	app.runner = jobrunner.NewJobRunnerEndpoints(redisClient, templateMgr, &runner, k8Client)
+	app.runner = jobrunner.NewJobRunnerEndpoints(redisClient, templateMgr, runner, executor)
+	app.qc = qc.NewQCEndpoints(redisClient)
+	app.config = configreload.NewConfigReloadEndpoints(reloader)
+	app.webhooks = webhooks.NewWebhookEndpoints(redisClient)
+	app.live = livestream.NewLiveStreamEndpoints(redisClient, liveHub)
 
 	http.Handle("/", app.index)
 	http.Handle("/healthcheck", app.healthcheck)
@@ -146,6 +218,10 @@ func main() {
 	app.transcode.WireUp("/api/transcode")
 	app.bulk.WireUp("/api/bulk")
 	app.runner.WireUp("/api/jobrunner")
+	app.qc.WireUp("/api/qc")
+	app.config.WireUp("/api/admin/config")
+	app.webhooks.WireUp("/api/webhook")
+	app.live.WireUp("/api/live")
 
 	log.Printf("Starting server on port 9000")
 	startServerErr := http.ListenAndServe(":9000", nil)
@@ -0,0 +1,23 @@
+package qc
+
+import (
+	"github.com/go-redis/redis/v7"
+	"net/http"
+)
+
+type QCEndpoints struct {
+	receiveData ReceiveData
+	getReport   GetReport
+}
+
+func NewQCEndpoints(client *redis.Client) QCEndpoints {
+	return QCEndpoints{
+		receiveData: ReceiveData{redisClient: client},
+		getReport:   GetReport{redisClient: client},
+	}
+}
+
+func (e QCEndpoints) WireUp(baseUrl string) {
+	http.Handle(baseUrl+"/result", e.receiveData)
+	http.Handle(baseUrl+"/get", e.getReport)
+}
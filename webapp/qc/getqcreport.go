@@ -0,0 +1,104 @@
+package qc
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type GetReport struct {
+	redisClient *redis.Client
+}
+
+/**
+returns QC reports. Either pass forId={report-id} to get a single report, or forJob={job-id} to get the reports from every
+QC step of that job that has finished
+*/
+func (h GetReport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !helpers.AssertHttpMethod(r, w, "GET") {
+		return
+	}
+
+	requestUrl, _ := url.ParseRequestURI(r.RequestURI)
+	if requestUrl.Query().Get("forJob") != "" {
+		h.reportsForJob(w, requestUrl.Query().Get("forJob"))
+		return
+	}
+
+	reportId, uuidErr := uuid.Parse(requestUrl.Query().Get("forId"))
+	if uuidErr != nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "error",
+			Detail: "you must specify a valid forId or forJob",
+		}, w, 400)
+		return
+	}
+
+	report, getErr := models.GetQCReport(reportId, h.redisClient)
+	if getErr != nil {
+		if strings.Contains(getErr.Error(), "redis: nil") {
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{
+				Status: "not_found",
+				Detail: "no QC report with that id",
+			}, w, 404)
+		} else {
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{
+				Status: "db_error",
+				Detail: "Could not read content from datastore",
+			}, w, 500)
+		}
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{
+		"status": "ok",
+		"entry":  report,
+	}, w, 200)
+}
+
+func (h GetReport) reportsForJob(w http.ResponseWriter, jobIdString string) {
+	jobId, uuidErr := uuid.Parse(jobIdString)
+	if uuidErr != nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "error",
+			Detail: "Invalid job ID",
+		}, w, 400)
+		return
+	}
+
+	container, getErr := models.JobContainerForId(jobId, h.redisClient)
+	if getErr != nil {
+		log.Printf("ERROR GetReport could not retrieve job %s: %s", jobId, getErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "not_found",
+			Detail: "no job with that id",
+		}, w, 404)
+		return
+	}
+
+	reports := make([]*models.QCReport, 0)
+	for _, step := range container.Steps {
+		qcStep, isQc := step.(*models.JobStepQC)
+		if !isQc || qcStep.ReportId == nil {
+			continue
+		}
+		report, reportErr := models.GetQCReport(*qcStep.ReportId, h.redisClient)
+		if reportErr != nil {
+			log.Printf("ERROR GetReport could not retrieve QC report %s for job %s: %s", *qcStep.ReportId, jobId, reportErr)
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{
+		"status":    "ok",
+		"flagged":   container.QCFlagged,
+		"entries":   reports,
+		"itemCount": len(reports),
+	}, w, 200)
+}
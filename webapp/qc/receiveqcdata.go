@@ -0,0 +1,180 @@
+package qc
+
+import (
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/common/results"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type ReceiveData struct {
+	redisClient *redis.Client
+}
+
+/**
+receives the events found by a QC step, evaluates them against the step's settings and saves the report.
+if the limits of a detector whose action is fail were exceeded then the job is failed; if only the limits of detectors
+whose action is flag were exceeded then the job is flagged and carries on
+*/
+func (h ReceiveData) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if r.Method != "POST" {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "error",
+			Detail: "expected POST",
+		}, w, 405)
+		return
+	}
+
+	_, jobContainerId, jobStepId, paramsErr := helpers.GetReceiverJobIds(r.RequestURI)
+	if paramsErr != nil {
+		//paramsErr is NOT a golang error object, but a premade GenericErrorResponse
+		helpers.WriteJsonContent(paramsErr, w, 400)
+		return
+	}
+
+	var incoming results.QCResult
+	readErr := helpers.ReadJsonBody(r.Body, &incoming)
+	if readErr != nil {
+		log.Printf("ERROR: Could not parse incoming data to ReceiveQCData: %s", readErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "error",
+			Detail: "could not read json",
+		}, w, 400)
+		return
+	}
+
+	completionChan := make(chan bool)
+	errorChan := make(chan error)
+
+	//the following block is only run when the queue is not busy, so we know that job completion notifications
+	//won't overwrite our updates
+	whenQueueReady := func(waitErr error) {
+		if waitErr != nil {
+			log.Printf("queue wait failed: %s", waitErr)
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", "queue wait failed, see the logs"}, w, 500)
+			errorChan <- waitErr
+			return
+		}
+
+		jobContainerInfo, containerGetErr := models.JobContainerForId(*jobContainerId, h.redisClient)
+		if containerGetErr != nil {
+			log.Printf("Could not retrieve job container for %s: %s", jobContainerId.String(), containerGetErr)
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", "Invalid job id"}, w, 400)
+			errorChan <- nil
+			return
+		}
+
+		jobStepCopyPtr := jobContainerInfo.FindStepById(*jobStepId)
+		if jobStepCopyPtr == nil {
+			log.Printf("Job container %s does not have any step with the id %s", jobContainerId.String(), jobStepId.String())
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{"not_found", "no jobstep with that id in the given job"}, w, 404)
+			errorChan <- nil
+			return
+		}
+
+		qcStep, isQc := (*jobStepCopyPtr).(*models.JobStepQC)
+		if !isQc {
+			log.Printf("Expected step %s of job %s to be qc type but got %s", jobStepId, jobContainerId, reflect.TypeOf(*jobStepCopyPtr))
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{"not_found", "identified jobstep was not qc"}, w, 404)
+			errorChan <- nil
+			return
+		}
+
+		var savedReport *models.QCReport
+		if incoming.ErrorMessage != "" {
+			qcStep.StatusValue = models.JOB_FAILED
+			qcStep.LastError = incoming.ErrorMessage
+		} else {
+			var settings models.QCSettings
+			if qcStep.QCSettings != nil {
+				settings = *qcStep.QCSettings
+			}
+			report := models.QCReport{
+				Id:             uuid.New(),
+				JobContainerId: *jobContainerId,
+				JobStepId:      *jobStepId,
+				Input:          settings.EffectiveInput(),
+				MediaFile:      incoming.MediaFile,
+				Duration:       incoming.Duration,
+				Events:         incoming.Events,
+				CreatedAt:      time.Now(),
+			}
+			if report.Events == nil {
+				report.Events = make([]models.QCEvent, 0)
+			}
+			report.Evaluate(settings)
+
+			putErr := models.PutQCReport(&report, h.redisClient)
+			if putErr != nil {
+				helpers.WriteJsonContent(helpers.GenericErrorResponse{
+					Status: "db_error",
+					Detail: "Could not save record",
+				}, w, 500)
+				errorChan <- nil
+				return
+			}
+			savedReport = &report
+
+			qcStep.ReportId = &report.Id
+			qcStep.Outcome = report.Outcome
+			qcStep.StatusValue = models.JOB_COMPLETED
+		}
+
+		updateErr := jobContainerInfo.UpdateStepById(*jobStepId, qcStep)
+		if updateErr != nil {
+			log.Printf("Could not set jobstep info for %s in job %s: %s", jobStepId, jobContainerId, updateErr)
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{
+				Status: "error",
+				Detail: updateErr.Error(),
+			}, w, 500)
+			errorChan <- nil
+			return
+		}
+
+		if savedReport != nil {
+			switch savedReport.Outcome {
+			case models.QC_OUTCOME_FAILED:
+				log.Printf("INFO: QC step %s failed job %s: %s", jobStepId, jobContainerId, strings.Join(savedReport.Problems, "; "))
+				jobContainerInfo.FailStepById(*jobStepId, fmt.Sprintf("QC failed: %s", strings.Join(savedReport.Problems, "; ")))
+			case models.QC_OUTCOME_FLAGGED:
+				log.Printf("INFO: QC step %s flagged job %s: %s", jobStepId, jobContainerId, strings.Join(savedReport.Problems, "; "))
+				jobContainerInfo.QCFlagged = true
+			}
+		}
+
+		jobSaveErr := jobContainerInfo.Store(h.redisClient)
+		if jobSaveErr != nil {
+			log.Printf("Could not save job container %s: %s", jobContainerId, jobSaveErr)
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{
+				Status: "db_error",
+				Detail: "Could not save record",
+			}, w, 500)
+			errorChan <- nil
+			return
+		}
+
+		if savedReport != nil {
+			helpers.WriteJsonContent(map[string]string{"status": "ok", "detail": "Report saved", "entryId": savedReport.Id.String(), "outcome": savedReport.Outcome}, w, 200)
+		} else {
+			helpers.WriteJsonContent(map[string]string{"status": "ok", "detail": "Error recorded"}, w, 200)
+		}
+		completionChan <- true
+	}
+
+	models.WhenQueueAvailable(h.redisClient, models.RUNNING_QUEUE, whenQueueReady, true)
+	//we need to wait for completion or error, otherwise something below us writes out an empty response before the async function can
+	select {
+	case <-completionChan:
+		log.Printf("async completed")
+	case <-errorChan:
+		log.Printf("async failed")
+	}
+}
@@ -0,0 +1,154 @@
+package qc
+
+import (
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sendQCResult(t *testing.T, testClient *redis.Client, jobMasterId uuid.UUID, jobStepId uuid.UUID, body string) map[string]interface{} {
+	mockBody := helpers.NewMockReadCloser()
+	mockBody.DataToRead = []byte(body)
+
+	mockRequest := http.Request{
+		Method:     "POST",
+		RequestURI: "https://myserver.com/api/qc/result?forJob=" + jobMasterId.String() + "&stepId=" + jobStepId.String(),
+		Proto:      "https",
+		ProtoMajor: 1,
+		ProtoMinor: 0,
+		Body:       mockBody,
+	}
+
+	toTest := ReceiveData{redisClient: testClient}
+	mockWriter := helpers.NewMockResponseWriter()
+	toTest.ServeHTTP(mockWriter, &mockRequest)
+
+	if mockWriter.State.WrittenStatusCode == nil || *mockWriter.State.WrittenStatusCode != 200 {
+		t.Fatalf("expected a 200 response, got %v: %s", mockWriter.State.WrittenStatusCode, string(mockWriter.State.LastWrittenBytes))
+	}
+	jsonContent, jsonErr := mockWriter.LastWrittenJson()
+	if jsonErr != nil {
+		t.Fatalf("Method did not output json: %s", jsonErr)
+	}
+	return jsonContent
+}
+
+func makeQCJob(jobMasterId uuid.UUID, jobStepId uuid.UUID) models.JobContainer {
+	startTime := time.Now()
+	return models.JobContainer{
+		Id: jobMasterId,
+		Steps: []models.JobStep{
+			models.JobStepQC{
+				JobStepType: "qc",
+				JobStepId:   jobStepId,
+				StatusValue: models.JOB_STARTED,
+				QCSettings: &models.QCSettings{
+					Black:   &models.QCDetectorSettings{MaxTotalSeconds: 5, Action: models.QC_ACTION_FAIL},
+					Silence: &models.QCDetectorSettings{MaxEvents: 1},
+				},
+			},
+		},
+		Status:        models.JOB_STARTED,
+		JobTemplateId: uuid.New(),
+		StartTime:     &startTime,
+	}
+}
+
+/*
+ServeHTTP should save an evaluated report, link it to the step and flag the job if only flag limits were exceeded
+*/
+func TestReceiveData_ServeHTTP_Flagged(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+	defer func() {
+		testClient.Close()
+		s.Close()
+	}()
+
+	jobMasterId := uuid.New()
+	jobStepId := uuid.New()
+	fakeJobContainer := makeQCJob(jobMasterId, jobStepId)
+	fakeJobContainer.Store(testClient)
+
+	jsonContent := sendQCResult(t, testClient, jobMasterId, jobStepId, `{"mediaFile":"/path/to/test.mp4","duration":60,"events":[
+{"detector":"black","start":0,"end":2,"startTimecode":"00:00:00.000","endTimecode":"00:00:02.000"},
+{"detector":"silence","start":10,"end":13},{"detector":"silence","start":40,"end":45}]}`)
+	if jsonContent["outcome"] != models.QC_OUTCOME_FLAGGED {
+		t.Errorf("expected the report to be flagged, got %v", jsonContent["outcome"])
+	}
+
+	report, getErr := models.GetQCReport(uuid.MustParse(jsonContent["entryId"].(string)), testClient)
+	if getErr != nil {
+		t.Fatalf("could not retrieve the saved report: %s", getErr)
+	}
+	if len(report.Events) != 3 || report.Events[0].Severity != models.QC_SEVERITY_INFO || report.Events[1].Severity != models.QC_SEVERITY_WARNING {
+		t.Errorf("saved report had unexpected events %v", report.Events)
+	}
+	if report.MediaFile != "/path/to/test.mp4" || report.Input != models.QC_INPUT_SOURCE || report.JobContainerId != jobMasterId {
+		t.Errorf("saved report had unexpected details %v", report)
+	}
+
+	updatedJob, getErr := models.JobContainerForId(jobMasterId, testClient)
+	if getErr != nil {
+		t.Fatalf("could not retrieve saved job: %s", getErr)
+	}
+	if !updatedJob.QCFlagged || updatedJob.Status != models.JOB_STARTED {
+		t.Errorf("job should be flagged and still running, got flagged=%t status=%d", updatedJob.QCFlagged, updatedJob.Status)
+	}
+	qcStep := (*updatedJob.FindStepById(jobStepId)).(*models.JobStepQC)
+	if qcStep.ReportId == nil || *qcStep.ReportId != report.Id || qcStep.Outcome != models.QC_OUTCOME_FLAGGED {
+		t.Errorf("report was not linked to the step, got %v", qcStep)
+	}
+}
+
+/*
+ServeHTTP should fail the job if a fail limit was exceeded, and the job should stay failed when the step completes
+*/
+func TestReceiveData_ServeHTTP_Failed(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+	defer func() {
+		testClient.Close()
+		s.Close()
+	}()
+
+	jobMasterId := uuid.New()
+	jobStepId := uuid.New()
+	fakeJobContainer := makeQCJob(jobMasterId, jobStepId)
+	fakeJobContainer.Store(testClient)
+
+	jsonContent := sendQCResult(t, testClient, jobMasterId, jobStepId, `{"mediaFile":"/path/to/test.mp4","duration":60,"events":[
+{"detector":"black","start":0,"end":30}]}`)
+	if jsonContent["outcome"] != models.QC_OUTCOME_FAILED {
+		t.Errorf("expected the report to fail, got %v", jsonContent["outcome"])
+	}
+
+	updatedJob, getErr := models.JobContainerForId(jobMasterId, testClient)
+	if getErr != nil {
+		t.Fatalf("could not retrieve saved job: %s", getErr)
+	}
+	if updatedJob.Status != models.JOB_FAILED || !strings.Contains(updatedJob.ErrorMessage, "black") {
+		t.Errorf("job should have failed because of the black, got status %d '%s'", updatedJob.Status, updatedJob.ErrorMessage)
+	}
+
+	updatedJob.CompleteStepById(jobStepId)
+	if updatedJob.Status != models.JOB_FAILED || (*updatedJob.FindStepById(jobStepId)).Status() != models.JOB_FAILED {
+		t.Error("completing the external job should not undo the QC failure")
+	}
+}
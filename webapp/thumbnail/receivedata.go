@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
 	"github.com/guardian/mediaflipper/common/helpers"
 	models2 "github.com/guardian/mediaflipper/common/models"
 	"io/ioutil"
@@ -52,9 +53,35 @@ func (h ReceiveData) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", "could not get file info"}, w, 500)
 			return
 		}
+		f.Checksums = incoming.Checksums.For(*incoming.OutPath)
 		fileEntry = f
 	}
 
+	//scrubbing thumbnails are a set of sprite sheets with an index
+	var spriteIndexEntry *models2.FileEntry
+	spriteSheetEntries := make([]models2.FileEntry, 0, len(incoming.SpriteSheets))
+	if incoming.SpriteIndex != nil {
+		indexEntry, indexErr := models2.NewSpriteIndexFileEntry(*incoming.SpriteIndex, *jobContainerId)
+		if indexErr != nil {
+			log.Printf("Could not get information for incoming sprite index %s: %s", *incoming.SpriteIndex, indexErr)
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", "could not get file info"}, w, 500)
+			return
+		}
+		indexEntry.Checksums = incoming.Checksums.For(*incoming.SpriteIndex)
+		spriteIndexEntry = &indexEntry
+
+		for _, sheetPath := range incoming.SpriteSheets {
+			sheetEntry, sheetErr := models2.NewFileEntry(sheetPath, *jobContainerId, models2.TYPE_SPRITE_SHEET)
+			if sheetErr != nil {
+				log.Printf("Could not get information for incoming sprite sheet %s: %s", sheetPath, sheetErr)
+				helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", "could not get file info"}, w, 500)
+				return
+			}
+			sheetEntry.Checksums = incoming.Checksums.For(sheetPath)
+			spriteSheetEntries = append(spriteSheetEntries, sheetEntry)
+		}
+	}
+
 	completionChan := make(chan bool)
 
 	whenQueueReady := func(waitErr error) {
@@ -107,6 +134,46 @@ func (h ReceiveData) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			jobContainerInfo.ThumbnailId = &fileEntry.Id
 		}
 
+		if spriteIndexEntry != nil {
+			sheetIds := make([]uuid.UUID, len(spriteSheetEntries))
+			for i, sheetEntry := range spriteSheetEntries {
+				storErr := sheetEntry.Store(h.redisClient)
+				if storErr != nil {
+					log.Printf("Could not store new file entry: %s", storErr)
+					helpers.WriteJsonContent(helpers.GenericErrorResponse{
+						Status: "db_error",
+						Detail: "could not write file entry to database",
+					}, w, 500)
+					completionChan <- false
+					return
+				}
+				sheetIds[i] = sheetEntry.Id
+			}
+			storErr := spriteIndexEntry.Store(h.redisClient)
+			if storErr != nil {
+				log.Printf("Could not store new file entry: %s", storErr)
+				helpers.WriteJsonContent(helpers.GenericErrorResponse{
+					Status: "db_error",
+					Detail: "could not write file entry to database",
+				}, w, 500)
+				completionChan <- false
+				return
+			}
+			thumbStep.SpriteSheetIds = sheetIds
+			thumbStep.SpriteIndexId = &spriteIndexEntry.Id
+			jobContainerInfo.SpriteIndexId = &spriteIndexEntry.Id
+		}
+
+		if incoming.Provenance != nil {
+			thumbStep.Provenance = incoming.Provenance.WithSettingsFrom(thumbStep.TranscodeSettings)
+		}
+
+		if incoming.FrameSeconds != nil {
+			thumbStep.ChosenFrameSeconds = incoming.FrameSeconds
+			thumbStep.ChosenFrameTimecode = incoming.FrameTimecode
+			thumbStep.FrameSelection = incoming.FrameSelection
+		}
+
 		var updatedStep models2.JobStep
 		if incoming.ErrorMessage == nil || *incoming.ErrorMessage == "" {
 			updatedStep = thumbStep.WithNewStatus(models2.JOB_COMPLETED, nil)
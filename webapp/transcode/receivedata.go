@@ -2,8 +2,10 @@ package transcode
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
 	"github.com/guardian/mediaflipper/common/helpers"
 	models2 "github.com/guardian/mediaflipper/common/models"
 	"github.com/guardian/mediaflipper/common/results"
@@ -11,6 +13,7 @@ import (
 	"log"
 	"net/http"
 	"reflect"
+	"strings"
 )
 
 type ReceiveData struct {
@@ -41,6 +44,7 @@ func (h ReceiveData) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//the file entries are only stored once the job step has been checked, so that a bad request leaves nothing behind
 	var fileEntry *models2.FileEntry = nil
 	if incoming.OutFile != "" {
 		f, fileEntryErr := models2.NewFileEntry(incoming.OutFile, *jobContainerId, models2.TYPE_TRANSCODE)
@@ -49,13 +53,32 @@ func (h ReceiveData) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", "could not get file info"}, w, 500)
 			return
 		}
+		f.Checksums = incoming.Checksums.For(incoming.OutFile)
 		fileEntry = &f
-		storErr := fileEntry.Store(h.redisClient)
-		if storErr != nil {
-			log.Printf("Could not store file entry: %s", storErr)
-			helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", storErr.Error()}, w, 500)
+	}
+
+	renditionEntries := make([]models2.FileEntry, 0, len(incoming.Renditions))
+	for _, rendition := range incoming.Renditions {
+		f, fileEntryErr := models2.NewFileEntry(rendition.OutFile, *jobContainerId, models2.TYPE_TRANSCODE)
+		if fileEntryErr != nil {
+			log.Printf("Could not get information for incoming rendition %s: %s", spew.Sprint(rendition), fileEntryErr)
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", "could not get file info"}, w, 500)
+			return
+		}
+		f.Checksums = incoming.Checksums.For(rendition.OutFile)
+		renditionEntries = append(renditionEntries, f)
+	}
+
+	packageEntries := make([]models2.FileEntry, 0, len(incoming.Packages))
+	for _, pkg := range incoming.Packages {
+		f, fileEntryErr := models2.NewStreamFileEntry(pkg.ManifestFile, *jobContainerId, pkg.Format)
+		if fileEntryErr != nil {
+			log.Printf("Could not get information for incoming package %s: %s", spew.Sprint(pkg), fileEntryErr)
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{"error", "could not get file info"}, w, 500)
 			return
 		}
+		f.Checksums = incoming.Checksums.For(pkg.ManifestFile)
+		packageEntries = append(packageEntries, f)
 	}
 
 	completionChan := make(chan bool)
@@ -95,10 +118,58 @@ func (h ReceiveData) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		toStore := make([]models2.FileEntry, 0, 1+len(renditionEntries)+len(packageEntries))
+		if fileEntry != nil {
+			toStore = append(toStore, *fileEntry)
+		}
+		toStore = append(toStore, renditionEntries...)
+		toStore = append(toStore, packageEntries...)
+		for _, entry := range toStore {
+			storErr := entry.Store(h.redisClient)
+			if storErr != nil {
+				log.Printf("Could not store file entry for %s: %s", entry.ServerPath, storErr)
+				helpers.WriteJsonContent(helpers.GenericErrorResponse{"db_error", storErr.Error()}, w, 500)
+				completionChan <- false
+				return
+			}
+		}
+
+		renditionOutputs := make([]models2.RenditionOutput, len(renditionEntries))
+		for i, entry := range renditionEntries {
+			renditionOutputs[i] = models2.RenditionOutput{Name: incoming.Renditions[i].Name, FileId: entry.Id}
+		}
+		packageIds := make([]uuid.UUID, len(packageEntries))
+		for i, entry := range packageEntries {
+			packageIds[i] = entry.Id
+		}
+
 		if fileEntry != nil {
 			tcStep.ResultId = &(fileEntry.Id)
 			jobContainerInfo.TranscodedMediaId = &(fileEntry.Id)
 		}
+		if len(renditionOutputs) > 0 {
+			jobContainerInfo.ReplaceRenditions(tcStep.RenditionResults, renditionOutputs)
+			tcStep.RenditionResults = renditionOutputs
+			//later steps that only want one transcoded file get the first rendition, which is normally the highest quality
+			jobContainerInfo.TranscodedMediaId = &(renditionOutputs[0].FileId)
+		}
+
+		if len(packageIds) > 0 {
+			tcStep.PackageIds = packageIds
+		}
+		//the analysis step measures the loudness of the source too; if there was one then keep its results
+		if incoming.SourceLoudness != nil && jobContainerInfo.SourceLoudness == nil {
+			jobContainerInfo.SourceLoudness = incoming.SourceLoudness
+		}
+
+		tcStep.Quality = incoming.Quality
+		if incoming.Provenance != nil {
+			tcStep.Provenance = incoming.Provenance.WithSettingsFrom(tcStep.TranscodeSettings)
+		}
+		var qualityProblems []string
+		if tcStep.QualitySettings != nil && incoming.ErrorMessage == "" {
+			qualityProblems = tcStep.QualitySettings.ThresholdProblems(incoming.Quality)
+		}
 
 		var updatedStep models2.JobStep
 		if incoming.ErrorMessage != "" {
@@ -115,6 +186,12 @@ func (h ReceiveData) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		//the output is kept so that it can be looked at, but the job does not go any further
+		if len(qualityProblems) > 0 {
+			log.Printf("INFO: transcode step %s of job %s did not meet the quality minimums: %s", jobStepId, jobContainerId, strings.Join(qualityProblems, "; "))
+			jobContainerInfo.FailStepById(updatedStep.StepId(), fmt.Sprintf("Transcode quality too low: %s", strings.Join(qualityProblems, "; ")))
+		}
+
 		log.Printf("Storing updated container...")
 
 		storErr := jobContainerInfo.Store(h.redisClient)
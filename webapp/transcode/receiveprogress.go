@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/guardian/mediaflipper/common/helpers"
 	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/webapp/livestream"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -48,5 +49,6 @@ func (h ReceiveProgress) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Score:  float64(progressUpdate.Timestamp),
 		Member: string(rawContent), //no point re-marshalling the object as we know it's good if we got here
 	})
+	livestream.ProgressReceived(progressUpdate, h.redisClient)
 	helpers.WriteJsonContent(helpers.GenericErrorResponse{"ok", "data stored"}, w, 201)
 }
@@ -0,0 +1,56 @@
+package transcodesettings
+
+import (
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"net/http"
+)
+
+type CreateTranscodeSettings struct {
+	mgr *models.TranscodeSettingsManager
+}
+
+/**
+save a new set of transcode settings, given as json in the request body. If there is no settingsid in the body then
+one is made up. Returns the saved settings with their version number
+*/
+func (h CreateTranscodeSettings) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "POST") {
+		return
+	}
+
+	settings, errResponse := readSettingsBody(r.Body, nil)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	if h.mgr.GetSetting(settings.GetId()) != nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "conflict",
+			Detail: "there are already settings with that ID, use update to change them",
+		}, w, 409)
+		return
+	}
+
+	record, saveErr := h.mgr.SaveSetting(settings)
+	if saveErr != nil {
+		log.Printf("ERROR CreateTranscodeSettings could not save settings %s: %s", settings.GetId(), saveErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "db_error",
+			Detail: "could not save settings",
+		}, w, 500)
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{
+		"status":  "ok",
+		"entry":   settings,
+		"version": record.Version,
+	}, w, 200)
+}
@@ -0,0 +1,77 @@
+package transcodesettings
+
+import (
+	"fmt"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type DeleteTranscodeSettings struct {
+	mgr         *models.TranscodeSettingsManager
+	templateMgr *models.JobTemplateManager
+}
+
+/**
+remove stored transcode settings. Expects ?forId={settings-id}. The history of the settings is kept.
+presets from the settings directory can't be removed, and neither can settings that a job template uses
+*/
+func (h DeleteTranscodeSettings) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "DELETE") {
+		return
+	}
+
+	_, forId, errResponse := helpers.GetForId(r.RequestURI)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	if h.mgr.IsReadOnly(*forId) {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "forbidden",
+			Detail: "these settings are a preset and can't be removed",
+		}, w, 403)
+		return
+	}
+	if h.mgr.GetSetting(*forId) == nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "not_found",
+			Detail: "nothing found with that ID",
+		}, w, 404)
+		return
+	}
+
+	if h.templateMgr != nil {
+		usedBy := h.templateMgr.TemplatesUsingSettings(*forId)
+		if len(usedBy) > 0 {
+			names := make([]string, len(usedBy))
+			for i, templateDef := range usedBy {
+				names[i] = templateDef.JobTypeName
+			}
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{
+				Status: "conflict",
+				Detail: fmt.Sprintf("these settings are used by %s", strings.Join(names, ", ")),
+			}, w, 409)
+			return
+		}
+	}
+
+	deleteErr := h.mgr.DeleteSetting(*forId)
+	if deleteErr != nil {
+		log.Printf("ERROR DeleteTranscodeSettings could not remove settings %s: %s", *forId, deleteErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "db_error",
+			Detail: "could not remove settings",
+		}, w, 500)
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{"status": "ok", "settingsId": *forId}, w, 200)
+}
@@ -6,18 +6,30 @@ import (
 )
 
 type TranscodeSettingsEndpoints struct {
-	getEndpoint  GetTranscodeSettings
-	listEndpoint ListTranscodeSettings
+	getEndpoint     GetTranscodeSettings
+	listEndpoint    ListTranscodeSettings
+	createEndpoint  CreateTranscodeSettings
+	updateEndpoint  UpdateTranscodeSettings
+	deleteEndpoint  DeleteTranscodeSettings
+	historyEndpoint TranscodeSettingsHistory
 }
 
-func NewTranscodeSettingsEndpoints(mgr *models.TranscodeSettingsManager) TranscodeSettingsEndpoints {
+func NewTranscodeSettingsEndpoints(mgr *models.TranscodeSettingsManager, templateMgr *models.JobTemplateManager) TranscodeSettingsEndpoints {
 	return TranscodeSettingsEndpoints{
-		getEndpoint:  GetTranscodeSettings{mgr: mgr},
-		listEndpoint: ListTranscodeSettings{mgr: mgr},
+		getEndpoint:     GetTranscodeSettings{mgr: mgr},
+		listEndpoint:    ListTranscodeSettings{mgr: mgr},
+		createEndpoint:  CreateTranscodeSettings{mgr: mgr},
+		updateEndpoint:  UpdateTranscodeSettings{mgr: mgr},
+		deleteEndpoint:  DeleteTranscodeSettings{mgr: mgr, templateMgr: templateMgr},
+		historyEndpoint: TranscodeSettingsHistory{mgr: mgr},
 	}
 }
 
 func (t TranscodeSettingsEndpoints) WireUp(baseUrl string) {
 	http.Handle(baseUrl+"/get", t.getEndpoint)
+	http.Handle(baseUrl+"/create", t.createEndpoint)
+	http.Handle(baseUrl+"/update", t.updateEndpoint)
+	http.Handle(baseUrl+"/delete", t.deleteEndpoint)
+	http.Handle(baseUrl+"/history", t.historyEndpoint)
 	http.Handle(baseUrl, t.listEndpoint)
 }
@@ -43,7 +43,8 @@ func (h GetTranscodeSettings) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 
 	helpers.WriteJsonContent(map[string]interface{}{
-		"status": "ok",
-		"entry":  s,
+		"status":   "ok",
+		"entry":    s,
+		"readOnly": h.mgr.IsReadOnly(sId),
 	}, w, 200)
 }
@@ -0,0 +1,112 @@
+package transcodesettings
+
+import (
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v7"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"net/http"
+	"testing"
+)
+
+const testSettingsBody = `{
+	"name": "lowres",
+	"description": "small mp4 for testing",
+	"wrapper": {"format": "mp4"},
+	"video": {"codec": "h264", "bitrate": 500000},
+	"audio": {"codec": "aac", "bitrate": 96000, "channels": 2, "samplerate": 44100}
+}`
+
+func callHandler(handler http.Handler, method string, uri string, body string) (int, map[string]interface{}) {
+	mockBody := helpers.NewMockReadCloser()
+	mockBody.DataToRead = []byte(body)
+
+	mockRequest := http.Request{
+		Method:     method,
+		RequestURI: "https://myserver.com/api/transcodesettings" + uri,
+		Proto:      "https",
+		ProtoMajor: 1,
+		ProtoMinor: 0,
+		Body:       mockBody,
+	}
+	mockWriter := helpers.NewMockResponseWriter()
+	handler.ServeHTTP(mockWriter, &mockRequest)
+
+	jsonContent, _ := mockWriter.LastWrittenJson()
+	if mockWriter.State.WrittenStatusCode == nil {
+		return 200, jsonContent
+	}
+	return *mockWriter.State.WrittenStatusCode, jsonContent
+}
+
+func TestTranscodeSettingsHandlers(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testClient := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	mgr, loadErr := models.NewTranscodeSettingsManager("../config/settings")
+	if loadErr != nil {
+		t.Fatalf("Couldn't initialise: %s", loadErr)
+	}
+	mgr.UseDatastore(testClient)
+	endpoints := NewTranscodeSettingsEndpoints(mgr, nil)
+
+	status, content := callHandler(endpoints.createEndpoint, "POST", "/create", testSettingsBody)
+	if status != 200 {
+		t.Fatalf("create returned %d: %v", status, content)
+	}
+	entry := content["entry"].(map[string]interface{})
+	newId, _ := entry["settingsid"].(string)
+	if newId == "" {
+		t.Fatalf("create did not give the settings an id: %v", content)
+	}
+	if content["version"] != float64(1) {
+		t.Errorf("expected version 1 from create, got %v", content["version"])
+	}
+
+	status, content = callHandler(endpoints.createEndpoint, "POST", "/create", `{"name": "broken", "wrapper": {"format": "mp4"}}`)
+	if status != 400 {
+		t.Errorf("expected 400 for incomplete settings, got %d: %v", status, content)
+	}
+
+	status, content = callHandler(endpoints.updateEndpoint, "PUT", "/update?forId="+newId, testSettingsBody)
+	if status != 200 {
+		t.Fatalf("update returned %d: %v", status, content)
+	}
+	if content["version"] != float64(2) {
+		t.Errorf("expected version 2 from update, got %v", content["version"])
+	}
+
+	presetId := "5C7B1E94-2D6A-4F83-A0B9-8E3D6C1F7A24"
+	status, _ = callHandler(endpoints.updateEndpoint, "PUT", "/update?forId="+presetId, testSettingsBody)
+	if status != 403 {
+		t.Errorf("expected 403 updating a preset, got %d", status)
+	}
+	status, _ = callHandler(endpoints.deleteEndpoint, "DELETE", "/delete?forId="+presetId, "")
+	if status != 403 {
+		t.Errorf("expected 403 deleting a preset, got %d", status)
+	}
+
+	status, content = callHandler(endpoints.deleteEndpoint, "DELETE", "/delete?forId="+newId, "")
+	if status != 200 {
+		t.Fatalf("delete returned %d: %v", status, content)
+	}
+	status, _ = callHandler(endpoints.getEndpoint, "GET", "/get?forId="+newId, "")
+	if status != 404 {
+		t.Errorf("expected 404 getting deleted settings, got %d", status)
+	}
+
+	status, content = callHandler(endpoints.historyEndpoint, "GET", "/history?forId="+newId, "")
+	if status != 200 {
+		t.Fatalf("history returned %d: %v", status, content)
+	}
+	if content["count"] != float64(3) {
+		t.Errorf("expected 3 versions in the history, got %v", content["count"])
+	}
+}
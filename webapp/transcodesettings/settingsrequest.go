@@ -0,0 +1,39 @@
+package transcodesettings
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"io"
+	"log"
+)
+
+/**
+reads in settings from the request body and makes sure that they are fit to save.
+if forId is given then the settings are given that id, otherwise a new one is made up if the body does not have one
+*/
+func readSettingsBody(body io.Reader, forId *uuid.UUID) (models.TranscodeTypeSettings, *helpers.GenericErrorResponse) {
+	var rawSettings map[string]interface{}
+	readErr := helpers.ReadJsonBody(body, &rawSettings)
+	if readErr != nil || rawSettings == nil {
+		log.Printf("ERROR transcodesettings could not read request body: %s", readErr)
+		return nil, &helpers.GenericErrorResponse{Status: "bad_request", Detail: "expected a json object"}
+	}
+
+	if forId != nil {
+		rawSettings["settingsid"] = forId.String()
+	} else if existingId, haveId := rawSettings["settingsid"]; !haveId || existingId == "" {
+		rawSettings["settingsid"] = uuid.New().String()
+	}
+
+	settings, decodeErr := models.TranscodeSettingsFromMap(rawSettings)
+	if decodeErr != nil {
+		return nil, &helpers.GenericErrorResponse{Status: "bad_request", Detail: fmt.Sprintf("these are not recognised transcode settings: %s", decodeErr)}
+	}
+	validationErr := models.ValidateTranscodeSettings(settings)
+	if validationErr != nil {
+		return nil, &helpers.GenericErrorResponse{Status: "invalid", Detail: validationErr.Error()}
+	}
+	return settings, nil
+}
@@ -0,0 +1,49 @@
+package transcodesettings
+
+import (
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"net/http"
+)
+
+type TranscodeSettingsHistory struct {
+	mgr *models.TranscodeSettingsManager
+}
+
+/**
+list every version of stored transcode settings, oldest first. Expects ?forId={settings-id}.
+presets from the settings directory have no history so get an empty list
+*/
+func (h TranscodeSettingsHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "GET") {
+		return
+	}
+
+	_, forId, errResponse := helpers.GetForId(r.RequestURI)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	versions, historyErr := h.mgr.SettingHistory(*forId)
+	if historyErr != nil {
+		log.Printf("ERROR TranscodeSettingsHistory could not get history for %s: %s", *forId, historyErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "db_error",
+			Detail: "could not retrieve history",
+		}, w, 500)
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{
+		"status":   "ok",
+		"readOnly": h.mgr.IsReadOnly(*forId),
+		"count":    len(versions),
+		"entries":  versions,
+	}, w, 200)
+}
@@ -0,0 +1,69 @@
+package transcodesettings
+
+import (
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"net/http"
+)
+
+type UpdateTranscodeSettings struct {
+	mgr *models.TranscodeSettingsManager
+}
+
+/**
+save a new version of existing transcode settings. Expects ?forId={settings-id} and the complete settings as json in the
+request body; any settingsid in the body is ignored. Presets from the settings directory can't be changed
+*/
+func (h UpdateTranscodeSettings) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "PUT") {
+		return
+	}
+
+	_, forId, errResponse := helpers.GetForId(r.RequestURI)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	if h.mgr.IsReadOnly(*forId) {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "forbidden",
+			Detail: "these settings are a preset and can't be changed",
+		}, w, 403)
+		return
+	}
+	if h.mgr.GetSetting(*forId) == nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "not_found",
+			Detail: "nothing found with that ID",
+		}, w, 404)
+		return
+	}
+
+	settings, errResponse := readSettingsBody(r.Body, forId)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	record, saveErr := h.mgr.SaveSetting(settings)
+	if saveErr != nil {
+		log.Printf("ERROR UpdateTranscodeSettings could not save settings %s: %s", *forId, saveErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "db_error",
+			Detail: "could not save settings",
+		}, w, 500)
+		return
+	}
+
+	helpers.WriteJsonContent(map[string]interface{}{
+		"status":  "ok",
+		"entry":   settings,
+		"version": record.Version,
+	}, w, 200)
+}
@@ -0,0 +1,47 @@
+package validation
+
+import (
+	"github.com/guardian/mediaflipper/webapp/jobrunner"
+	"io/ioutil"
+	"os"
+)
+
+/**
+checks that the given kubernetes template file exists, is a Job manifest that jobrunner.LoadFromTemplate can read, and
+has at least one container with an image to run. A missing file is reported where it is referred to
+*/
+func checkKubernetesTemplate(ref kubernetesReference, report *Report) {
+	content, readErr := ioutil.ReadFile(ref.fileName)
+	if readErr != nil {
+		if os.IsNotExist(readErr) {
+			report.add(ref.referencedAt.file, ref.referencedAt.line, "kubernetes template %s does not exist", ref.fileName)
+		} else {
+			report.add(ref.referencedAt.file, ref.referencedAt.line, "kubernetes template %s could not be read: %s", ref.fileName, readErr)
+		}
+		return
+	}
+
+	job, loadErr := jobrunner.LoadFromTemplate(ref.fileName)
+	if loadErr != nil {
+		report.add(ref.fileName, lineFromYamlError(loadErr), "is not a usable kubernetes Job: %s", loadErr)
+		return
+	}
+
+	lines := splitYamlLines(content)
+	containersLine := lines.keyLine("containers", 1, 0)
+	containers := job.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		report.add(ref.fileName, containersLine, "the job has no containers to run")
+		return
+	}
+
+	containerItems := make([]int, 0)
+	if containersLine > 0 {
+		containerItems = lines.listItems(containersLine+1, lines.blockEnd(containersLine))
+	}
+	for i, container := range containers {
+		if container.Image == "" {
+			report.add(ref.fileName, itemLine(containerItems, i, containersLine), "container '%s' has no image", container.Name)
+		}
+	}
+}
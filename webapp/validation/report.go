@@ -0,0 +1,58 @@
+package validation
+
+import (
+	"fmt"
+	"log"
+)
+
+/**
+something that is wrong with one of the configuration files
+*/
+type Problem struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"` //line in the file that the problem is at, or 0 if it is not known
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.File, p.Message)
+}
+
+/**
+where something is in a file, used to point at the first of two things that clash
+*/
+type location struct {
+	file string
+	line int
+}
+
+func (l location) String() string {
+	return fmt.Sprintf("%s:%d", l.file, l.line)
+}
+
+/**
+everything that was found to be wrong with the configuration, in the order it was found
+*/
+type Report struct {
+	Problems []Problem `json:"problems"`
+}
+
+func (r *Report) add(file string, line int, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, Problem{File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) IsValid() bool {
+	return len(r.Problems) == 0
+}
+
+/**
+write every problem to the log with the given prefix, e.g. "WARNING" or "ERROR"
+*/
+func (r *Report) Log(prefix string) {
+	for _, problem := range r.Problems {
+		log.Printf("%s: %s", prefix, problem)
+	}
+}
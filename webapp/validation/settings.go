@@ -0,0 +1,70 @@
+package validation
+
+import (
+	"github.com/guardian/mediaflipper/common/models"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+/**
+checks every file in the settings directory, in the same way that NewTranscodeSettingsManager reads them. Every entry
+must be recognisable settings that pass ValidateTranscodeSettings, and no settings id may be used twice.
+*/
+func checkSettingsDirectory(settingsPath string, report *Report) {
+	files, listErr := ioutil.ReadDir(settingsPath)
+	if listErr != nil {
+		report.add(settingsPath, 0, "could not read the settings directory: %s", listErr)
+		return
+	}
+
+	seenIds := make(map[string]location)
+	for _, fileInfo := range files {
+		if fileInfo.IsDir() {
+			continue
+		}
+		checkSettingsFile(path.Join(settingsPath, fileInfo.Name()), seenIds, report)
+	}
+}
+
+func checkSettingsFile(fileName string, seenIds map[string]location, report *Report) {
+	content, readErr := ioutil.ReadFile(fileName)
+	if readErr != nil {
+		report.add(fileName, 0, "could not be read: %s", readErr)
+		return
+	}
+
+	var settingsList []map[string]interface{}
+	unmarshalErr := yaml.Unmarshal(content, &settingsList)
+	if unmarshalErr != nil {
+		report.add(fileName, lineFromYamlError(unmarshalErr), "is not a valid list of settings: %s", unmarshalErr)
+		return
+	}
+
+	lines := splitYamlLines(content)
+	items := lines.listItems(1, 0)
+	for i, rawSetting := range settingsList {
+		itemStart := itemLine(items, i, 0)
+		settings, decodeErr := models.TranscodeSettingsFromMap(rawSetting)
+		if decodeErr != nil {
+			report.add(fileName, itemStart, "settings %d could not be understood: %s", i+1, decodeErr)
+			continue
+		}
+
+		idString := strings.ToUpper(settings.GetId().String())
+		idLine := lines.keyLine("settingsid", itemStart, itemEndLine(items, i))
+		if idLine == 0 {
+			idLine = itemStart
+		}
+		if firstUse, isDuplicate := seenIds[idString]; isDuplicate {
+			report.add(fileName, idLine, "settings id %s is already used at %s", idString, firstUse)
+		} else {
+			seenIds[idString] = location{fileName, idLine}
+		}
+
+		if validationErr := models.ValidateTranscodeSettings(settings); validationErr != nil {
+			report.add(fileName, itemStart, "settings %s are not valid: %s", idString, validationErr)
+		}
+	}
+}
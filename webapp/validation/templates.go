@@ -0,0 +1,94 @@
+package validation
+
+import (
+	"github.com/guardian/mediaflipper/common/models"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+)
+
+/**
+a KubernetesTemplateFile that a template step refers to, and where the first reference to it is
+*/
+type kubernetesReference struct {
+	fileName     string
+	referencedAt location
+}
+
+/**
+checks every template in the template file against the given transcode settings, see JobTemplateManager.TemplateProblems,
+and that no template id is used twice.
+returns the kubernetes template files that the steps refer to, in the order they are first referred to, so that they can
+be checked too
+*/
+func checkTemplateFile(templatePath string, settingsMgr *models.TranscodeSettingsManager, report *Report) []kubernetesReference {
+	content, readErr := ioutil.ReadFile(templatePath)
+	if readErr != nil {
+		report.add(templatePath, 0, "could not be read: %s", readErr)
+		return nil
+	}
+
+	//the manager keeps its templates in a map, so read them again here to know which one is where in the file
+	var templates []models.JobTemplateDefinition
+	unmarshalErr := yaml.Unmarshal(content, &templates)
+	if unmarshalErr != nil {
+		report.add(templatePath, lineFromYamlError(unmarshalErr), "is not a valid list of templates: %s", unmarshalErr)
+		return nil
+	}
+
+	templateMgr, mgrErr := models.NewJobTemplateManager(templatePath, settingsMgr)
+	if mgrErr != nil {
+		report.add(templatePath, 0, "could not be loaded: %s", mgrErr)
+		return nil
+	}
+
+	lines := splitYamlLines(content)
+	items := lines.listItems(1, 0)
+	seenIds := make(map[string]location)
+	seenFiles := make(map[string]bool)
+	references := make([]kubernetesReference, 0)
+
+	for i, tpl := range templates {
+		tplStart := itemLine(items, i, 0)
+		tplEnd := itemEndLine(items, i)
+
+		idString := tpl.Id.String()
+		if firstUse, isDuplicate := seenIds[idString]; isDuplicate {
+			report.add(templatePath, tplStart, "template id %s is already used at %s", idString, firstUse)
+		} else {
+			seenIds[idString] = location{templatePath, tplStart}
+		}
+
+		stepsLine := lines.keyLine("Steps", tplStart, tplEnd)
+		stepItems := make([]int, 0)
+		if stepsLine > 0 {
+			stepsEnd := lines.blockEnd(stepsLine)
+			if stepsEnd == 0 || (tplEnd > 0 && stepsEnd > tplEnd) {
+				stepsEnd = tplEnd
+			}
+			stepItems = lines.listItems(stepsLine+1, stepsEnd)
+		}
+
+		for _, problem := range templateMgr.TemplateProblems(tpl) {
+			problemLine := tplStart
+			if problem.StepIndex >= 0 {
+				problemLine = itemLine(stepItems, problem.StepIndex, tplStart)
+			}
+			report.add(templatePath, problemLine, "template '%s': %s", tpl.JobTypeName, problem)
+		}
+
+		for stepIdx, step := range tpl.Steps {
+			if step.KubernetesTemplateFile == "" || seenFiles[step.KubernetesTemplateFile] {
+				continue
+			}
+			seenFiles[step.KubernetesTemplateFile] = true
+
+			stepStart := itemLine(stepItems, stepIdx, tplStart)
+			fileLine := lines.keyLine("KubernetesTemplateFile", stepStart, itemEndLine(stepItems, stepIdx))
+			if fileLine == 0 {
+				fileLine = stepStart
+			}
+			references = append(references, kubernetesReference{step.KubernetesTemplateFile, location{templatePath, fileLine}})
+		}
+	}
+	return references
+}
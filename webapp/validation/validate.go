@@ -0,0 +1,24 @@
+package validation
+
+import "github.com/guardian/mediaflipper/common/models"
+
+/**
+cross-checks the transcode settings in settingsPath, the job templates in templatePath and the kubernetes templates that
+they refer to, and returns everything that is wrong with them.
+kubernetes template paths are relative to the current directory, as they are when jobs are run
+*/
+func ValidateConfig(settingsPath string, templatePath string) *Report {
+	report := &Report{Problems: make([]Problem, 0)}
+
+	checkSettingsDirectory(settingsPath, report)
+
+	//if this fails then checkSettingsDirectory has reported it already. The templates are still checked with a nil
+	//manager, which reports every step that needs transcode settings
+	settingsMgr, _ := models.NewTranscodeSettingsManager(settingsPath)
+
+	references := checkTemplateFile(templatePath, settingsMgr, report)
+	for _, ref := range references {
+		checkKubernetesTemplate(ref, report)
+	}
+	return report
+}
@@ -0,0 +1,190 @@
+package validation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+/*
+*
+the configuration that ships with the webapp should have nothing wrong with it
+*/
+func TestValidateConfigShipped(t *testing.T) {
+	//kubernetes template paths are relative to the webapp directory
+	wd, _ := os.Getwd()
+	chdirErr := os.Chdir("..")
+	if chdirErr != nil {
+		t.Fatal(chdirErr)
+	}
+	defer os.Chdir(wd)
+
+	report := ValidateConfig("config/settings", "config/standardjobtemplate.yaml")
+	if !report.IsValid() {
+		for _, problem := range report.Problems {
+			t.Errorf("unexpected problem: %s", problem)
+		}
+	}
+}
+
+const testSettingsFile = `---
+- settingsid: "5C7B1E94-2D6A-4F83-A0B9-8E3D6C1F7A24"
+  name: mp3proxy
+  format: mp3
+  audio:
+    codec: libmp3lame
+    bitrate: 192000
+    channels: 2
+    samplerate: 44100
+- settingsid: "E8A3D5F1-6B2C-4E97-8D14-3F9A7C0B5E62"
+  name: aacproxy
+  format: adts
+  audio:
+    codec: aac
+    bitrate: 128000
+    channels: 12
+    samplerate: 48000
+`
+
+const testDuplicateSettingsFile = `---
+- settingsid: "5C7B1E94-2D6A-4F83-A0B9-8E3D6C1F7A24"
+  name: another mp3proxy
+  format: mp3
+  audio:
+    codec: libmp3lame
+    bitrate: 128000
+    channels: 2
+    samplerate: 44100
+`
+
+const testBrokenSettingsFile = `---
+- settingsid: "0B6E5F3A-8C41-4D92-B7E3-5A1C9F2D8E70"
+  name: broken
+  audio: [
+`
+
+const testTemplateFile = `---
+- Id: 846F823E-C0D3-4AF0-AD51-0F9573379057
+  Name: Audio proxy
+  Steps:
+    - Id: 702DBDC5-CE51-4760-82E4-01BC1FB4771E
+      PredeterminedType: analysis
+      KubernetesTemplateFile: %s
+    - Id: 6FF216B6-A395-4237-A9F2-2FEB3F24823E
+      PredeterminedType: transcode
+      KubernetesTemplateFile: %s
+      TranscodeSettingsId: 7FEC2963-6A1D-46A2-8DE1-62DF939F6755
+      DependsOn:
+        - 702DBDC5-CE51-4760-82E4-01BC1FB4771E
+- Id: 846F823E-C0D3-4AF0-AD51-0F9573379057
+  Name: Same id
+  Steps:
+    - Id: 702DBDC5-CE51-4760-82E4-01BC1FB4771E
+      PredeterminedType: analysis
+      KubernetesTemplateFile: %s
+`
+
+const testKubernetesTemplate = `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: test-job-template
+spec:
+  template:
+    spec:
+      containers:
+        - name: analyser
+          image: guardianmultimedia/mediaflipper:22
+        - name: sidecar
+          command: ["/bin/true"]
+      restartPolicy: Never
+`
+
+func writeTestFile(t *testing.T, fileName string, content string) {
+	writeErr := ioutil.WriteFile(fileName, []byte(content), 0644)
+	if writeErr != nil {
+		t.Fatalf("Could not write %s: %s", fileName, writeErr)
+	}
+}
+
+/*
+*
+every problem in a broken configuration should be reported, with the file and line that it is at
+*/
+func TestValidateConfigProblems(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "validatetest")
+	if dirErr != nil {
+		t.Fatal(dirErr)
+	}
+	defer os.RemoveAll(dir)
+
+	settingsDir := path.Join(dir, "settings")
+	os.Mkdir(settingsDir, 0755)
+	writeTestFile(t, path.Join(settingsDir, "a.yaml"), testSettingsFile)
+	writeTestFile(t, path.Join(settingsDir, "b.yaml"), testDuplicateSettingsFile)
+	writeTestFile(t, path.Join(settingsDir, "c.yaml"), testBrokenSettingsFile)
+
+	jobFile := path.Join(dir, "job.yaml")
+	missingFile := path.Join(dir, "missing.yaml")
+	writeTestFile(t, jobFile, testKubernetesTemplate)
+	templateFile := path.Join(dir, "templates.yaml")
+	writeTestFile(t, templateFile, fmt.Sprintf(testTemplateFile, jobFile, missingFile, jobFile))
+
+	report := ValidateConfig(settingsDir, templateFile)
+	if report.IsValid() {
+		t.Fatal("expected the configuration to have problems")
+	}
+
+	expectedLines := []struct {
+		file string
+		line int
+	}{
+		{path.Join(settingsDir, "a.yaml"), 10}, //aacproxy has too many channels
+		{path.Join(settingsDir, "b.yaml"), 2},  //settings id is used in a.yaml
+		{path.Join(settingsDir, "c.yaml"), 4},  //yaml syntax error
+		{templateFile, 8},                      //transcode settings do not exist
+		{templateFile, 14},                     //template id is used twice
+		{jobFile, 11},                          //sidecar has no image
+		{templateFile, 10},                     //kubernetes template does not exist
+	}
+	if len(report.Problems) != len(expectedLines) {
+		for _, problem := range report.Problems {
+			t.Logf("got problem: %s", problem)
+		}
+		t.Fatalf("expected %d problems, got %d", len(expectedLines), len(report.Problems))
+	}
+	for i, expected := range expectedLines {
+		problem := report.Problems[i]
+		if problem.File != expected.file || problem.Line != expected.line {
+			t.Errorf("expected problem %d to be at %s:%d, got %s", i, expected.file, expected.line, problem)
+		}
+	}
+}
+
+func TestYamlLines(t *testing.T) {
+	lines := splitYamlLines([]byte(fmt.Sprintf(testTemplateFile, "a.yaml", "b.yaml", "c.yaml")))
+
+	items := lines.listItems(1, 0)
+	if len(items) != 2 || items[0] != 2 || items[1] != 14 {
+		t.Errorf("expected templates at lines 2 and 14, got %v", items)
+	}
+
+	stepsLine := lines.keyLine("Steps", items[0], itemEndLine(items, 0))
+	if stepsLine != 4 {
+		t.Errorf("expected Steps at line 4, got %d", stepsLine)
+	}
+	if lines.blockEnd(stepsLine) != 13 {
+		t.Errorf("expected Steps to end at line 13, got %d", lines.blockEnd(stepsLine))
+	}
+	steps := lines.listItems(stepsLine+1, lines.blockEnd(stepsLine))
+	if len(steps) != 2 || steps[0] != 5 || steps[1] != 8 {
+		t.Errorf("expected steps at lines 5 and 8, got %v", steps)
+	}
+	if lines.keyLine("TranscodeSettingsId", steps[1], 0) != 11 {
+		t.Errorf("expected TranscodeSettingsId at line 11, got %d", lines.keyLine("TranscodeSettingsId", steps[1], 0))
+	}
+	if lines.blockEnd(items[1]) != 0 {
+		t.Errorf("expected the last template to run to the end of the file, got %d", lines.blockEnd(items[1]))
+	}
+}
@@ -0,0 +1,142 @@
+package validation
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/**
+yaml.v2 does not tell us where in the file a value came from, so these work out the line numbers of the parts of a
+file from its text. Line numbers start at 1, and 0 means that the line could not be found
+*/
+type yamlLines []string
+
+func splitYamlLines(content []byte) yamlLines {
+	return strings.Split(string(content), "\n")
+}
+
+func (l yamlLines) lastLine(to int) int {
+	if to <= 0 || to > len(l) {
+		return len(l)
+	}
+	return to
+}
+
+/**
+returns the line numbers of the items of the outermost list between the given lines (inclusive). to=0 means the end
+of the file
+*/
+func (l yamlLines) listItems(from int, to int) []int {
+	if from < 1 {
+		from = 1
+	}
+	to = l.lastLine(to)
+
+	type item struct {
+		line   int
+		indent int
+	}
+	found := make([]item, 0)
+	minIndent := -1
+	for lineNum := from; lineNum <= to; lineNum++ {
+		line := l[lineNum-1]
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed != "-" && !strings.HasPrefix(trimmed, "- ") {
+			continue
+		}
+		indent := indentOf(line)
+		if minIndent < 0 || indent < minIndent {
+			minIndent = indent
+		}
+		found = append(found, item{line: lineNum, indent: indent})
+	}
+
+	rtn := make([]int, 0, len(found))
+	for _, candidate := range found {
+		if candidate.indent == minIndent {
+			rtn = append(rtn, candidate.line)
+		}
+	}
+	return rtn
+}
+
+/**
+returns the line number of the first line between the given lines that sets the given key, either on its own or as the
+first key of a list item
+*/
+func (l yamlLines) keyLine(key string, from int, to int) int {
+	if from < 1 {
+		from = 1
+	}
+	to = l.lastLine(to)
+	for lineNum := from; lineNum <= to; lineNum++ {
+		trimmed := strings.TrimPrefix(strings.TrimLeft(l[lineNum-1], " "), "- ")
+		if strings.HasPrefix(trimmed, key+":") {
+			return lineNum
+		}
+	}
+	return 0
+}
+
+/**
+returns the last line of the block that is started by the key on the given line, i.e. the line before the next line
+that is indented no further than the key. 0 means the end of the file
+*/
+func (l yamlLines) blockEnd(keyLine int) int {
+	if keyLine < 1 || keyLine > len(l) {
+		return 0
+	}
+	keyIndent := indentOf(l[keyLine-1])
+	for lineNum := keyLine + 1; lineNum <= len(l); lineNum++ {
+		trimmed := strings.TrimSpace(l[lineNum-1])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := indentOf(l[lineNum-1])
+		//a list can be written at the same indent as the key that holds it
+		if indent < keyIndent || (indent == keyIndent && !strings.HasPrefix(trimmed, "- ") && trimmed != "-") {
+			return lineNum - 1
+		}
+	}
+	return 0
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+/**
+returns the line of the nth item of the given list, or fallback if there are not that many items
+*/
+func itemLine(items []int, n int, fallback int) int {
+	if n < len(items) {
+		return items[n]
+	}
+	return fallback
+}
+
+/**
+returns the end line of the nth item of the given list, i.e. the line before the next item starts. 0 means the end of
+the file
+*/
+func itemEndLine(items []int, n int) int {
+	if n+1 < len(items) {
+		return items[n+1] - 1
+	}
+	return 0
+}
+
+var yamlErrorLineMatcher = regexp.MustCompile(`line (\d+)`)
+
+/**
+yaml syntax errors include the line number in their message, this gets it out
+*/
+func lineFromYamlError(err error) int {
+	matches := yamlErrorLineMatcher.FindStringSubmatch(err.Error())
+	if matches == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(matches[1])
+	return line
+}
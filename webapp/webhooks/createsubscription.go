@@ -0,0 +1,72 @@
+package webhooks
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"log"
+	"net/http"
+	"time"
+)
+
+type CreateSubscriptionHandler struct {
+	redisClient *redis.Client
+}
+
+/**
+subscribe to webhook events. Expects a json object with a url and optionally a secret, a list of events and a
+templateId or bulkListId to filter on. If no secret is given then one is made up.
+the response includes the secret, it is not returned again after this
+*/
+func (h CreateSubscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "POST") {
+		return
+	}
+
+	var sub Subscription
+	readErr := helpers.ReadJsonBody(r.Body, &sub)
+	if readErr != nil {
+		log.Printf("ERROR CreateSubscriptionHandler could not read request body: %s", readErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "bad_request",
+			Detail: "expected a json object",
+		}, w, 400)
+		return
+	}
+
+	sub.Id = uuid.New()
+	sub.CreatedAt = time.Now()
+	if sub.Secret == "" {
+		secret, secretErr := NewSecret()
+		if secretErr != nil {
+			log.Printf("ERROR CreateSubscriptionHandler could not make a secret: %s", secretErr)
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{
+				Status: "error",
+				Detail: "could not make a secret",
+			}, w, 500)
+			return
+		}
+		sub.Secret = secret
+	}
+	if validationErr := sub.Validate(); validationErr != nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "invalid",
+			Detail: validationErr.Error(),
+		}, w, 400)
+		return
+	}
+
+	putErr := PutSubscription(sub, h.redisClient)
+	if putErr != nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "db_error",
+			Detail: "could not save subscription",
+		}, w, 500)
+		return
+	}
+	helpers.WriteJsonContent(map[string]interface{}{"status": "ok", "entry": sub}, w, 200)
+}
@@ -0,0 +1,68 @@
+package webhooks
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type DeadLetterHandler struct {
+	redisClient *redis.Client
+}
+
+/**
+list the deliveries that ran out of attempts, newest first. Takes an optional ?limit=n, which defaults to 100
+*/
+func (h DeadLetterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "GET") {
+		return
+	}
+
+	requestUrl, urlErr := url.ParseRequestURI(r.RequestURI)
+	if urlErr != nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "bad_request",
+			Detail: "could not understand the request url",
+		}, w, 400)
+		return
+	}
+	var limit int64 = 100
+	if limitString := requestUrl.Query().Get("limit"); limitString != "" {
+		parsed, parseErr := strconv.ParseInt(limitString, 10, 64)
+		if parseErr != nil || parsed < 1 {
+			helpers.WriteJsonContent(helpers.GenericErrorResponse{
+				Status: "bad_request",
+				Detail: "limit must be a positive number",
+			}, w, 400)
+			return
+		}
+		limit = parsed
+	}
+
+	entries, listErr := ListDeadLetters(limit, h.redisClient)
+	if listErr == nil {
+		var total int64
+		total, listErr = CountDeadLetters(h.redisClient)
+		if listErr == nil {
+			helpers.WriteJsonContent(map[string]interface{}{
+				"status":  "ok",
+				"total":   total,
+				"count":   len(entries),
+				"entries": entries,
+			}, w, 200)
+			return
+		}
+	}
+	log.Printf("ERROR DeadLetterHandler could not list dead letters: %s", listErr)
+	helpers.WriteJsonContent(helpers.GenericErrorResponse{
+		Status: "db_error",
+		Detail: "could not list dead letters",
+	}, w, 500)
+}
@@ -0,0 +1,47 @@
+package webhooks
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"net/http"
+)
+
+type DeleteSubscriptionHandler struct {
+	redisClient *redis.Client
+}
+
+/**
+remove a webhook subscription. Expects ?forId={subscription-id}. Any deliveries that are still waiting for it are dropped
+*/
+func (h DeleteSubscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "DELETE") {
+		return
+	}
+
+	_, forId, errResponse := helpers.GetForId(r.RequestURI)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	removed, removeErr := RemoveSubscription(*forId, h.redisClient)
+	if removeErr != nil {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "db_error",
+			Detail: "could not remove subscription",
+		}, w, 500)
+		return
+	}
+	if !removed {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "not_found",
+			Detail: "nothing found with that ID",
+		}, w, 404)
+		return
+	}
+	helpers.WriteJsonContent(map[string]interface{}{"status": "ok", "subscriptionId": *forId}, w, 200)
+}
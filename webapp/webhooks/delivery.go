@@ -0,0 +1,256 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	retryQueueKey = "mediaflipper:webhook:retryqueue" //sorted set of delivery ids, scored by when they are next due
+	deadLetterKey = "mediaflipper:webhook:deadletter" //sorted set of delivery ids that ran out of attempts, scored by when they did
+)
+
+func keyForDelivery(deliveryId uuid.UUID) string {
+	return fmt.Sprintf("mediaflipper:webhook:delivery:%s", deliveryId)
+}
+
+/**
+how often a delivery is tried before it is given up on and put into the dead letters: after 30s, 1m, 2m and so on,
+about two hours in all
+*/
+var deliveryRetryPolicy = models.RetryPolicy{MaxAttempts: 8, BackoffSeconds: 30, BackoffMultiplier: 2}
+
+/**
+an event that is waiting to be sent to a subscriber. Deliveries are kept in the datastore until they succeed or are
+dead-lettered, so that none are lost if the server restarts
+*/
+type Delivery struct {
+	Id             uuid.UUID  `json:"id"`
+	SubscriptionId uuid.UUID  `json:"subscriptionId"`
+	Url            string     `json:"url"`
+	Event          Event      `json:"event"`
+	Attempts       int        `json:"attempts"`
+	NextAttempt    *time.Time `json:"nextAttempt"`
+	LastError      string     `json:"lastError"`
+	DeadLetteredAt *time.Time `json:"deadLetteredAt"`
+}
+
+/**
+returns the signature for a payload, the hex HMAC-SHA256 of "{timestamp}.{body}" keyed with the subscription secret.
+the timestamp is sent alongside so that the receiver can refuse old payloads that are replayed
+*/
+func SignPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+/**
+save the delivery and put it onto the retry queue to be sent at the given time
+*/
+func queueDelivery(d Delivery, at time.Time, redisClient redis.Cmdable) error {
+	d.NextAttempt = &at
+	encoded, encodeErr := json.Marshal(d)
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	pipe := redisClient.TxPipeline()
+	pipe.Set(keyForDelivery(d.Id), string(encoded), 0)
+	pipe.ZAdd(retryQueueKey, &redis.Z{Score: float64(at.Unix()), Member: d.Id.String()})
+	_, execErr := pipe.Exec()
+	if execErr != nil {
+		log.Printf("ERROR webhooks could not queue delivery %s: %s", d.Id, execErr)
+	}
+	return execErr
+}
+
+/**
+returns nil, nil if there is no such delivery
+*/
+func getDelivery(deliveryId uuid.UUID, redisClient redis.Cmdable) (*Delivery, error) {
+	content, getErr := redisClient.Get(keyForDelivery(deliveryId)).Result()
+	if getErr == redis.Nil {
+		return nil, nil
+	} else if getErr != nil {
+		return nil, getErr
+	}
+
+	var d Delivery
+	unmarshalErr := json.Unmarshal([]byte(content), &d)
+	if unmarshalErr != nil {
+		log.Printf("Corrupted webhook delivery in the datastore for %s: %s", deliveryId, unmarshalErr)
+		return nil, unmarshalErr
+	}
+	return &d, nil
+}
+
+/**
+forget about a delivery that has been sent, or that can't be sent any more
+*/
+func removeDelivery(deliveryId uuid.UUID, redisClient redis.Cmdable) error {
+	pipe := redisClient.TxPipeline()
+	pipe.ZRem(retryQueueKey, deliveryId.String())
+	pipe.Del(keyForDelivery(deliveryId))
+	_, execErr := pipe.Exec()
+	return execErr
+}
+
+/**
+how long a claimed delivery is held for before it is considered abandoned and becomes due again. This must be longer
+than it can take to send one
+*/
+const deliveryClaimTimeout = 5 * time.Minute
+
+/**
+claimScript expects to be called with 1 key and 3 arguments:
+- KEYS[1] - the retry queue
+- ARGV[1] - id of the delivery to claim
+- ARGV[2] - the current time
+- ARGV[3] - the time that the claim runs out
+it moves the delivery to the time that the claim runs out if it is still due, and returns 1 if it did so
+*/
+const claimScript = `local score = redis.call("zscore",KEYS[1],ARGV[1])
+if score == false or tonumber(score) > tonumber(ARGV[2]) then
+	return 0
+end
+redis.call("zadd",KEYS[1],ARGV[3],ARGV[1])
+return 1
+`
+
+/**
+claim every delivery that is due on the retry queue. A claimed delivery stays on the queue but is moved to
+`deliveryClaimTimeout` in the future, so that nothing else picks it up while it is being sent. Once it has been sent
+it is removed, or if it fails it is moved to its next retry time. If the server goes away part-way through, the claim
+runs out and the delivery is sent again.
+the check and the move happen in one script, so more than one server can send deliveries at once without sending any twice
+*/
+func claimDueDeliveries(now time.Time, redisClient redis.Cmdable) ([]Delivery, error) {
+	nowString := strconv.FormatInt(now.Unix(), 10)
+	dueIds, rangeErr := redisClient.ZRangeByScore(retryQueueKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: nowString,
+	}).Result()
+	if rangeErr != nil {
+		return nil, rangeErr
+	}
+
+	claimUntil := strconv.FormatInt(now.Add(deliveryClaimTimeout).Unix(), 10)
+	claimed := make([]Delivery, 0, len(dueIds))
+	for _, idString := range dueIds {
+		result, claimErr := redisClient.Eval(claimScript, []string{retryQueueKey}, idString, nowString, claimUntil).Int()
+		if claimErr != nil {
+			log.Printf("ERROR webhooks could not claim delivery %s: %s", idString, claimErr)
+			continue
+		}
+		if result == 0 { //someone else got it first
+			continue
+		}
+
+		deliveryId, parseErr := uuid.Parse(idString)
+		if parseErr != nil {
+			log.Printf("ERROR webhooks dropping invalid delivery id %s from the queue", idString)
+			redisClient.ZRem(retryQueueKey, idString)
+			continue
+		}
+		d, getErr := getDelivery(deliveryId, redisClient)
+		if getErr != nil {
+			log.Printf("ERROR webhooks delivery %s was on the queue but could not be retrieved, will try again later: %s", idString, getErr)
+			continue
+		}
+		if d == nil {
+			log.Printf("ERROR webhooks dropping delivery %s from the queue, it does not exist", idString)
+			redisClient.ZRem(retryQueueKey, idString)
+			continue
+		}
+		claimed = append(claimed, *d)
+	}
+	return claimed, nil
+}
+
+/**
+give up on the delivery and keep it in the dead letters, where it can be looked at and sent again
+*/
+func deadLetter(d Delivery, redisClient redis.Cmdable) error {
+	now := time.Now()
+	d.NextAttempt = nil
+	d.DeadLetteredAt = &now
+	encoded, encodeErr := json.Marshal(d)
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	pipe := redisClient.TxPipeline()
+	pipe.Set(keyForDelivery(d.Id), string(encoded), 0)
+	pipe.ZRem(retryQueueKey, d.Id.String())
+	pipe.ZAdd(deadLetterKey, &redis.Z{Score: float64(now.Unix()), Member: d.Id.String()})
+	_, execErr := pipe.Exec()
+	if execErr != nil {
+		log.Printf("ERROR webhooks could not dead-letter delivery %s: %s", d.Id, execErr)
+	}
+	return execErr
+}
+
+/**
+returns the most recent dead-lettered deliveries, newest first
+*/
+func ListDeadLetters(limit int64, redisClient redis.Cmdable) ([]Delivery, error) {
+	ids, rangeErr := redisClient.ZRevRange(deadLetterKey, 0, limit-1).Result()
+	if rangeErr != nil {
+		log.Printf("Could not list dead-lettered webhook deliveries: %s", rangeErr)
+		return nil, rangeErr
+	}
+
+	rtn := make([]Delivery, 0, len(ids))
+	for _, idString := range ids {
+		deliveryId, parseErr := uuid.Parse(idString)
+		if parseErr != nil {
+			continue
+		}
+		d, getErr := getDelivery(deliveryId, redisClient)
+		if getErr != nil || d == nil {
+			continue
+		}
+		rtn = append(rtn, *d)
+	}
+	return rtn, nil
+}
+
+func CountDeadLetters(redisClient redis.Cmdable) (int64, error) {
+	return redisClient.ZCard(deadLetterKey).Result()
+}
+
+/**
+take a delivery out of the dead letters and send it again straight away, with a fresh set of attempts.
+returns false if there is no such dead-lettered delivery
+*/
+func Redeliver(deliveryId uuid.UUID, redisClient redis.Cmdable) (bool, error) {
+	removed, remErr := redisClient.ZRem(deadLetterKey, deliveryId.String()).Result()
+	if remErr != nil {
+		return false, remErr
+	}
+	if removed == 0 {
+		return false, nil
+	}
+
+	d, getErr := getDelivery(deliveryId, redisClient)
+	if getErr != nil {
+		return false, getErr
+	}
+	if d == nil {
+		return false, nil
+	}
+	d.Attempts = 0
+	d.DeadLetteredAt = nil
+	return true, queueDelivery(*d, time.Now(), redisClient)
+}
@@ -0,0 +1,32 @@
+package webhooks
+
+import (
+	"github.com/go-redis/redis/v7"
+	"net/http"
+)
+
+type WebhookEndpoints struct {
+	listEndpoint       ListSubscriptionsHandler
+	createEndpoint     CreateSubscriptionHandler
+	deleteEndpoint     DeleteSubscriptionHandler
+	deadLetterEndpoint DeadLetterHandler
+	redeliverEndpoint  RedeliverHandler
+}
+
+func NewWebhookEndpoints(redisClient *redis.Client) WebhookEndpoints {
+	return WebhookEndpoints{
+		listEndpoint:       ListSubscriptionsHandler{redisClient: redisClient},
+		createEndpoint:     CreateSubscriptionHandler{redisClient: redisClient},
+		deleteEndpoint:     DeleteSubscriptionHandler{redisClient: redisClient},
+		deadLetterEndpoint: DeadLetterHandler{redisClient: redisClient},
+		redeliverEndpoint:  RedeliverHandler{redisClient: redisClient},
+	}
+}
+
+func (e WebhookEndpoints) WireUp(baseUrl string) {
+	http.Handle(baseUrl+"/create", e.createEndpoint)
+	http.Handle(baseUrl+"/delete", e.deleteEndpoint)
+	http.Handle(baseUrl+"/deadletter", e.deadLetterEndpoint)
+	http.Handle(baseUrl+"/redeliver", e.redeliverEndpoint)
+	http.Handle(baseUrl, e.listEndpoint)
+}
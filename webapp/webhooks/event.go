@@ -0,0 +1,99 @@
+package webhooks
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"time"
+)
+
+type EventType string
+
+const (
+	EVENT_JOB_CREATED        EventType = "job.created" //the job has been put onto the queue for processing
+	EVENT_JOB_STARTED        EventType = "job.started" //the first step of the job has started running
+	EVENT_JOB_STEP_COMPLETED EventType = "job.step_completed"
+	EVENT_JOB_COMPLETED      EventType = "job.completed"
+	EVENT_JOB_FAILED         EventType = "job.failed"    //the job failed or was lost
+	EVENT_JOB_CANCELLED      EventType = "job.cancelled" //the job was cancelled before it finished
+	EVENT_BULK_FINISHED      EventType = "bulk.finished" //every item in the bulk list has completed, failed or been cancelled
+)
+
+var ALL_EVENT_TYPES = []EventType{EVENT_JOB_CREATED, EVENT_JOB_STARTED, EVENT_JOB_STEP_COMPLETED, EVENT_JOB_COMPLETED, EVENT_JOB_FAILED, EVENT_JOB_CANCELLED, EVENT_BULK_FINISHED}
+
+func isKnownEventType(eventType EventType) bool {
+	for _, known := range ALL_EVENT_TYPES {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+the payload that is sent to the subscribers. Which of the optional fields are set depends on the type of event
+*/
+type Event struct {
+	EventId       uuid.UUID        `json:"eventId"`
+	Type          EventType        `json:"type"`
+	Timestamp     time.Time        `json:"timestamp"`
+	JobId         *uuid.UUID       `json:"jobId,omitempty"`
+	TemplateId    *uuid.UUID       `json:"templateId,omitempty"`
+	BulkListId    *uuid.UUID       `json:"bulkListId,omitempty"`
+	StepId        *uuid.UUID       `json:"stepId,omitempty"` //job.step_completed only
+	Status        string           `json:"status"`
+	ErrorMessage  string           `json:"errorMessage,omitempty"`
+	OutputFileIds []uuid.UUID      `json:"outputFileIds,omitempty"` //ids of the FileEntry records that the job has made so far, not including the original
+	ItemCounts    map[string]int64 `json:"itemCounts,omitempty"`    //bulk.finished only, the number of items in each state
+}
+
+/**
+returns the ids of the files that the given job has made, leaving out the original media
+*/
+func outputFileIds(jobId uuid.UUID, redisClient *redis.Client) []uuid.UUID {
+	rtn := make([]uuid.UUID, 0)
+	fileIds, idsErr := models.FileIdsForJobContainer(jobId, redisClient)
+	if idsErr != nil || len(*fileIds) == 0 { //FilesForJobContainer treats a job with no files as an error
+		return rtn
+	}
+
+	entries, getErr := models.FilesForJobContainer(jobId, redisClient)
+	if getErr != nil {
+		log.Printf("ERROR webhooks could not get the output files for job %s: %s", jobId, getErr)
+		return rtn
+	}
+	for _, entry := range *entries {
+		if entry.FileType != models.TYPE_ORIGINAL && entry.Id != uuid.Nil {
+			rtn = append(rtn, entry.Id)
+		}
+	}
+	return rtn
+}
+
+/**
+build the event for something that has happened to the given job. `stepId` is only used for job.step_completed.
+the output file ids are only looked up for job.step_completed and job.completed, as that is when there are new ones
+*/
+func newJobEvent(eventType EventType, container *models.JobContainer, stepId *uuid.UUID, redisClient *redis.Client) Event {
+	jobId := container.Id
+	templateId := container.JobTemplateId
+	event := Event{
+		EventId:      uuid.New(),
+		Type:         eventType,
+		Timestamp:    time.Now(),
+		JobId:        &jobId,
+		TemplateId:   &templateId,
+		StepId:       stepId,
+		Status:       models.JobStatusToString(container.Status),
+		ErrorMessage: container.ErrorMessage,
+	}
+	if container.AssociatedBulk != nil {
+		listId := container.AssociatedBulk.List
+		event.BulkListId = &listId
+	}
+	if eventType == EVENT_JOB_STEP_COMPLETED || eventType == EVENT_JOB_COMPLETED {
+		event.OutputFileIds = outputFileIds(container.Id, redisClient)
+	}
+	return event
+}
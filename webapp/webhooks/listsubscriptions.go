@@ -0,0 +1,45 @@
+package webhooks
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"log"
+	"net/http"
+)
+
+type ListSubscriptionsHandler struct {
+	redisClient *redis.Client
+}
+
+/**
+list every webhook subscription. The secrets are not included
+*/
+func (h ListSubscriptionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "GET") {
+		return
+	}
+
+	subs, listErr := ListSubscriptions(h.redisClient)
+	if listErr != nil {
+		log.Printf("ERROR ListSubscriptionsHandler could not list subscriptions: %s", listErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "db_error",
+			Detail: "could not list subscriptions",
+		}, w, 500)
+		return
+	}
+
+	entries := make([]Subscription, len(subs))
+	for i, sub := range subs {
+		entries[i] = sub.WithoutSecret()
+	}
+	helpers.WriteJsonContent(map[string]interface{}{
+		"status":  "ok",
+		"count":   len(entries),
+		"entries": entries,
+	}, w, 200)
+}
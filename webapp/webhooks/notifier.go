@@ -0,0 +1,319 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/webapp/bulkprocessor"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/**
+how often the retry queue is checked for deliveries that are due
+*/
+const DefaultDeliveryInterval = 1 * time.Second
+
+/**
+how long the record of which events have been sent for a job is kept
+*/
+const notifiedExpiry = 7 * 24 * time.Hour
+
+func keyForNotified(forId uuid.UUID) string {
+	return fmt.Sprintf("mediaflipper:webhook:notified:%s", forId)
+}
+
+/**
+turns job and bulk list changes into events, queues them for each subscription that wants them and sends them.
+all of the methods can be called on a nil Notifier, in which case they do nothing; this is so that the job runner works
+the same whether or not it has one
+*/
+type Notifier struct {
+	redisClient *redis.Client
+	httpClient  *http.Client
+}
+
+func NewNotifier(redisClient *redis.Client) *Notifier {
+	return &Notifier{
+		redisClient: redisClient,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+/**
+queue the given event for every subscription that it matches. Returns the number of deliveries that were queued
+*/
+func (n *Notifier) Publish(event Event) (int, error) {
+	subs, listErr := ListSubscriptions(n.redisClient)
+	if listErr != nil {
+		return 0, listErr
+	}
+
+	queued := 0
+	for _, sub := range subs {
+		if !sub.Matches(event) {
+			continue
+		}
+		d := Delivery{
+			Id:             uuid.New(),
+			SubscriptionId: sub.Id,
+			Url:            sub.Url,
+			Event:          event,
+		}
+		queueErr := queueDelivery(d, time.Now(), n.redisClient)
+		if queueErr != nil {
+			return queued, queueErr
+		}
+		queued += 1
+	}
+	return queued, nil
+}
+
+/**
+returns true if `marker` has not been recorded against the given job or bulk list yet, and records it. This stops the
+same event being sent twice when more than one step of a job finishes after it has stopped
+*/
+func (n *Notifier) firstTime(forId uuid.UUID, marker string) bool {
+	pipe := n.redisClient.TxPipeline()
+	added := pipe.SAdd(keyForNotified(forId), marker)
+	pipe.Expire(keyForNotified(forId), notifiedExpiry)
+	_, execErr := pipe.Exec()
+	if execErr != nil {
+		log.Printf("ERROR webhooks could not check whether %s has been sent for %s, sending it anyway: %s", marker, forId, execErr)
+		return true
+	}
+	return added.Val() > 0
+}
+
+func (n *Notifier) publishJobEvent(eventType EventType, container *models.JobContainer, stepId *uuid.UUID) {
+	_, publishErr := n.Publish(newJobEvent(eventType, container, stepId, n.redisClient))
+	if publishErr != nil {
+		log.Printf("ERROR webhooks could not queue %s for job %s: %s", eventType, container.Id, publishErr)
+	}
+}
+
+/**
+send job.created for a job that has just been put onto the request queue
+*/
+func (n *Notifier) JobCreated(container *models.JobContainer) {
+	if n == nil || !n.firstTime(container.Id, string(EVENT_JOB_CREATED)) {
+		return
+	}
+	n.publishJobEvent(EVENT_JOB_CREATED, container, nil)
+}
+
+/**
+send job.started the first time that one of the job's steps is seen running
+*/
+func (n *Notifier) JobStarted(container *models.JobContainer) {
+	if n == nil || !n.firstTime(container.Id, string(EVENT_JOB_STARTED)) {
+		return
+	}
+	n.publishJobEvent(EVENT_JOB_STARTED, container, nil)
+}
+
+/**
+send job.step_completed for the given step
+*/
+func (n *Notifier) StepCompleted(container *models.JobContainer, stepId uuid.UUID) {
+	if n == nil {
+		return
+	}
+	n.publishJobEvent(EVENT_JOB_STEP_COMPLETED, container, &stepId)
+}
+
+/**
+send job.completed, job.failed or job.cancelled if the job has finished. Lost jobs count as failed. Does nothing if the job is still
+going
+*/
+func (n *Notifier) JobFinished(container *models.JobContainer) {
+	if n == nil {
+		return
+	}
+	var eventType EventType
+	switch container.Status {
+	case models.JOB_COMPLETED:
+		eventType = EVENT_JOB_COMPLETED
+	case models.JOB_FAILED, models.JOB_LOST:
+		eventType = EVENT_JOB_FAILED
+	case models.JOB_ABORTED:
+		eventType = EVENT_JOB_CANCELLED
+	default:
+		return
+	}
+	if n.firstTime(container.Id, string(eventType)) {
+		n.publishJobEvent(eventType, container, nil)
+	}
+}
+
+/**
+forget which events have been sent for the given job, so that they are sent again when a resumed job gets that far
+*/
+func (n *Notifier) ForgetJob(jobId uuid.UUID) {
+	if n == nil {
+		return
+	}
+	delErr := n.redisClient.Del(keyForNotified(jobId)).Err()
+	if delErr != nil {
+		log.Printf("ERROR webhooks could not reset the events sent for job %s: %s", jobId, delErr)
+	}
+}
+
+var itemStateNames = map[bulkprocessor.BulkItemState]string{
+	bulkprocessor.ITEM_STATE_PENDING:    "pending",
+	bulkprocessor.ITEM_STATE_ACTIVE:     "active",
+	bulkprocessor.ITEM_STATE_COMPLETED:  "completed",
+	bulkprocessor.ITEM_STATE_FAILED:     "failed",
+	bulkprocessor.ITEM_STATE_ABORTED:    "aborted",
+	bulkprocessor.ITEM_STATE_NOT_QUEUED: "notqueued",
+}
+
+/**
+called when an item of the given bulk list completes or fails. If that was the last one that was waiting or running
+then bulk.finished is sent. If more items are added and processed later then it is sent again when they finish
+*/
+func (n *Notifier) BulkItemFinished(bulkListId uuid.UUID) {
+	if n == nil {
+		return
+	}
+	list, getErr := bulkprocessor.BulkListForId(bulkListId, n.redisClient)
+	if getErr != nil {
+		log.Printf("ERROR webhooks could not get bulk list %s: %s", bulkListId, getErr)
+		return
+	}
+	counts, countErr := list.CountForAllStates(n.redisClient)
+	if countErr != nil {
+		log.Printf("ERROR webhooks could not count the items in bulk list %s: %s", bulkListId, countErr)
+		return
+	}
+	if counts[bulkprocessor.ITEM_STATE_PENDING] > 0 || counts[bulkprocessor.ITEM_STATE_ACTIVE] > 0 {
+		return
+	}
+
+	var total int64
+	itemCounts := make(map[string]int64, len(counts))
+	for state, count := range counts {
+		itemCounts[itemStateNames[state]] = count
+		total += count
+	}
+	if !n.firstTime(bulkListId, "bulk:"+strconv.FormatInt(total, 10)) {
+		return
+	}
+
+	status := "completed"
+	if counts[bulkprocessor.ITEM_STATE_FAILED] > 0 {
+		status = "completed_with_failures"
+	}
+	_, publishErr := n.Publish(Event{
+		EventId:    uuid.New(),
+		Type:       EVENT_BULK_FINISHED,
+		Timestamp:  time.Now(),
+		BulkListId: &bulkListId,
+		Status:     status,
+		ItemCounts: itemCounts,
+	})
+	if publishErr != nil {
+		log.Printf("ERROR webhooks could not queue %s for bulk list %s: %s", EVENT_BULK_FINISHED, bulkListId, publishErr)
+	}
+}
+
+/**
+POST the delivery to its subscriber. If that fails then it goes back onto the retry queue, or into the dead letters if
+it has run out of attempts. Deliveries for subscriptions that have been removed are dropped
+*/
+func (n *Notifier) attempt(d Delivery) {
+	sub, getErr := GetSubscription(d.SubscriptionId, n.redisClient)
+	if getErr != nil {
+		log.Printf("ERROR webhooks could not look up subscription %s, will try delivery %s again: %s", d.SubscriptionId, d.Id, getErr)
+		queueDelivery(d, time.Now().Add(deliveryRetryPolicy.BackoffFor(1)), n.redisClient)
+		return
+	}
+	if sub == nil {
+		log.Printf("INFO webhooks subscription %s has been removed, dropping delivery %s", d.SubscriptionId, d.Id)
+		removeDelivery(d.Id, n.redisClient)
+		return
+	}
+
+	d.Attempts += 1
+	sendErr := n.send(*sub, d)
+	if sendErr == nil {
+		removeDelivery(d.Id, n.redisClient)
+		return
+	}
+
+	d.LastError = sendErr.Error()
+	if deliveryRetryPolicy.ShouldRetry(d.Attempts, "") {
+		retryAt := time.Now().Add(deliveryRetryPolicy.BackoffFor(d.Attempts))
+		log.Printf("WARNING webhooks delivery %s to %s failed on attempt %d, trying again at %s: %s", d.Id, sub.Url, d.Attempts, retryAt.Format(time.RFC3339), sendErr)
+		queueDelivery(d, retryAt, n.redisClient)
+	} else {
+		log.Printf("ERROR webhooks delivery %s to %s failed %d times, giving up: %s", d.Id, sub.Url, d.Attempts, sendErr)
+		deadLetter(d, n.redisClient)
+	}
+}
+
+func (n *Notifier) send(sub Subscription, d Delivery) error {
+	body, marshalErr := json.Marshal(d.Event)
+	if marshalErr != nil {
+		return marshalErr
+	}
+
+	req, reqErr := http.NewRequest("POST", sub.Url, bytes.NewReader(body))
+	if reqErr != nil {
+		return reqErr
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Mediaflipper-Event", string(d.Event.Type))
+	req.Header.Set("X-Mediaflipper-Delivery", d.Id.String())
+	req.Header.Set("X-Mediaflipper-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Mediaflipper-Signature", "sha256="+SignPayload(sub.Secret, timestamp, body))
+
+	response, sendErr := n.httpClient.Do(req)
+	if sendErr != nil {
+		return sendErr
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return errors.New(fmt.Sprintf("subscriber returned %s", response.Status))
+	}
+	return nil
+}
+
+/**
+send every delivery that is due. They are sent at the same time so that one slow subscriber does not hold up the others
+*/
+func (n *Notifier) deliverDue() {
+	due, claimErr := claimDueDeliveries(time.Now(), n.redisClient)
+	if claimErr != nil {
+		log.Printf("ERROR webhooks could not check the retry queue: %s", claimErr)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range due {
+		wg.Add(1)
+		go func(d Delivery) {
+			defer wg.Done()
+			n.attempt(d)
+		}(d)
+	}
+	wg.Wait()
+}
+
+/**
+send deliveries as they become due, checking every `interval`. This does not return, so should be run as a goroutine
+*/
+func (n *Notifier) DeliveryLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		n.deliverDue()
+	}
+}
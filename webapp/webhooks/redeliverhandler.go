@@ -0,0 +1,49 @@
+package webhooks
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"log"
+	"net/http"
+)
+
+type RedeliverHandler struct {
+	redisClient *redis.Client
+}
+
+/**
+send a dead-lettered delivery again. Expects ?forId={delivery-id}
+*/
+func (h RedeliverHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if !helpers.AssertHttpMethod(r, w, "POST") {
+		return
+	}
+
+	_, forId, errResponse := helpers.GetForId(r.RequestURI)
+	if errResponse != nil {
+		helpers.WriteJsonContent(errResponse, w, 400)
+		return
+	}
+
+	found, redeliverErr := Redeliver(*forId, h.redisClient)
+	if redeliverErr != nil {
+		log.Printf("ERROR RedeliverHandler could not requeue delivery %s: %s", *forId, redeliverErr)
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "db_error",
+			Detail: "could not requeue delivery",
+		}, w, 500)
+		return
+	}
+	if !found {
+		helpers.WriteJsonContent(helpers.GenericErrorResponse{
+			Status: "not_found",
+			Detail: "no dead-lettered delivery found with that ID",
+		}, w, 404)
+		return
+	}
+	helpers.WriteJsonContent(map[string]interface{}{"status": "ok", "deliveryId": *forId}, w, 200)
+}
@@ -0,0 +1,159 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"log"
+	"net/url"
+	"time"
+)
+
+const subscriptionsKey = "mediaflipper:webhook:subscriptions"
+
+/**
+an external system that wants to be told about jobs. Events, TemplateId and BulkListId narrow down what it is sent;
+if they are not set then it gets everything
+*/
+type Subscription struct {
+	Id         uuid.UUID   `json:"id"`
+	Url        string      `json:"url"`
+	Secret     string      `json:"secret,omitempty"` //used to sign the payloads. Only returned when the subscription is made
+	Events     []EventType `json:"events"`
+	TemplateId *uuid.UUID  `json:"templateId"` //only send events for jobs made from this template
+	BulkListId *uuid.UUID  `json:"bulkListId"` //only send events for jobs in this bulk list, and when it finishes
+	CreatedAt  time.Time   `json:"createdAt"`
+}
+
+/**
+returns nil if the subscription can be saved, or an error saying what is wrong with it
+*/
+func (s Subscription) Validate() error {
+	parsed, parseErr := url.Parse(s.Url)
+	if parseErr != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New(fmt.Sprintf("'%s' is not a valid http or https url", s.Url))
+	}
+	if s.Secret == "" {
+		return errors.New("a secret is required to sign the payloads")
+	}
+	for _, eventType := range s.Events {
+		if !isKnownEventType(eventType) {
+			return errors.New(fmt.Sprintf("event type '%s' is not recognised", eventType))
+		}
+	}
+	return nil
+}
+
+/**
+returns true if the given event should be sent to this subscription
+*/
+func (s Subscription) Matches(event Event) bool {
+	if len(s.Events) > 0 {
+		wanted := false
+		for _, eventType := range s.Events {
+			if eventType == event.Type {
+				wanted = true
+				break
+			}
+		}
+		if !wanted {
+			return false
+		}
+	}
+	if s.TemplateId != nil && (event.TemplateId == nil || *event.TemplateId != *s.TemplateId) {
+		return false
+	}
+	if s.BulkListId != nil && (event.BulkListId == nil || *event.BulkListId != *s.BulkListId) {
+		return false
+	}
+	return true
+}
+
+/**
+returns a copy of the subscription without its secret, for listing
+*/
+func (s Subscription) WithoutSecret() Subscription {
+	s.Secret = ""
+	return s
+}
+
+/**
+make a random secret for a subscription that was not given one
+*/
+func NewSecret() (string, error) {
+	raw := make([]byte, 32)
+	_, readErr := rand.Read(raw)
+	if readErr != nil {
+		return "", readErr
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+func PutSubscription(sub Subscription, redisClient redis.Cmdable) error {
+	encoded, encodeErr := json.Marshal(sub)
+	if encodeErr != nil {
+		return encodeErr
+	}
+	_, setErr := redisClient.HSet(subscriptionsKey, sub.Id.String(), string(encoded)).Result()
+	if setErr != nil {
+		log.Printf("Could not save webhook subscription %s: %s", sub.Id, setErr)
+	}
+	return setErr
+}
+
+/**
+returns nil, nil if there is no subscription with the given id
+*/
+func GetSubscription(subId uuid.UUID, redisClient redis.Cmdable) (*Subscription, error) {
+	content, getErr := redisClient.HGet(subscriptionsKey, subId.String()).Result()
+	if getErr == redis.Nil {
+		return nil, nil
+	} else if getErr != nil {
+		log.Printf("Could not retrieve webhook subscription %s: %s", subId, getErr)
+		return nil, getErr
+	}
+
+	var sub Subscription
+	unmarshalErr := json.Unmarshal([]byte(content), &sub)
+	if unmarshalErr != nil {
+		log.Printf("Corrupted webhook subscription in the datastore for %s: %s", subId, unmarshalErr)
+		return nil, unmarshalErr
+	}
+	return &sub, nil
+}
+
+func ListSubscriptions(redisClient redis.Cmdable) ([]Subscription, error) {
+	allContent, getErr := redisClient.HGetAll(subscriptionsKey).Result()
+	if getErr != nil {
+		log.Printf("Could not list webhook subscriptions: %s", getErr)
+		return nil, getErr
+	}
+
+	rtn := make([]Subscription, 0, len(allContent))
+	for idString, content := range allContent {
+		var sub Subscription
+		unmarshalErr := json.Unmarshal([]byte(content), &sub)
+		if unmarshalErr != nil {
+			log.Printf("Corrupted webhook subscription in the datastore for %s: %s", idString, unmarshalErr)
+			continue
+		}
+		rtn = append(rtn, sub)
+	}
+	return rtn, nil
+}
+
+/**
+remove the given subscription. Returns false if there was no such subscription
+*/
+func RemoveSubscription(subId uuid.UUID, redisClient redis.Cmdable) (bool, error) {
+	removed, delErr := redisClient.HDel(subscriptionsKey, subId.String()).Result()
+	if delErr != nil {
+		log.Printf("Could not remove webhook subscription %s: %s", subId, delErr)
+		return false, delErr
+	}
+	return removed > 0, nil
+}
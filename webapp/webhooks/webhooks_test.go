@@ -0,0 +1,328 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/helpers"
+	"github.com/guardian/mediaflipper/common/models"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("could not start miniredis: %s", err)
+	}
+	return s, redis.NewClient(&redis.Options{Addr: s.Addr()})
+}
+
+func callHandler(handler http.Handler, method string, uri string, body string) (int, map[string]interface{}) {
+	mockBody := helpers.NewMockReadCloser()
+	mockBody.DataToRead = []byte(body)
+
+	mockRequest := http.Request{
+		Method:     method,
+		RequestURI: "https://myserver.com/api/webhook" + uri,
+		Proto:      "https",
+		ProtoMajor: 1,
+		ProtoMinor: 0,
+		Body:       mockBody,
+	}
+	mockWriter := helpers.NewMockResponseWriter()
+	handler.ServeHTTP(mockWriter, &mockRequest)
+
+	jsonContent, _ := mockWriter.LastWrittenJson()
+	if mockWriter.State.WrittenStatusCode == nil {
+		return 200, jsonContent
+	}
+	return *mockWriter.State.WrittenStatusCode, jsonContent
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+/**
+a subscriber that records what it is sent and replies with `status`
+*/
+type testSubscriber struct {
+	lock     sync.Mutex
+	status   int
+	received []receivedRequest
+}
+
+func (s *testSubscriber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.received = append(s.received, receivedRequest{r.Header, body})
+	w.WriteHeader(s.status)
+}
+
+func TestSubscriptionMatches(t *testing.T) {
+	templateId := uuid.New()
+	otherTemplateId := uuid.New()
+	listId := uuid.New()
+
+	event := Event{Type: EVENT_JOB_COMPLETED, TemplateId: &templateId, BulkListId: &listId}
+
+	if !(Subscription{}).Matches(event) {
+		t.Error("a subscription with no filters should match everything")
+	}
+	if !(Subscription{Events: []EventType{EVENT_JOB_FAILED, EVENT_JOB_COMPLETED}}).Matches(event) {
+		t.Error("subscription should match an event type that it lists")
+	}
+	if (Subscription{Events: []EventType{EVENT_JOB_FAILED}}).Matches(event) {
+		t.Error("subscription should not match an event type that it does not list")
+	}
+	if !(Subscription{TemplateId: &templateId}).Matches(event) {
+		t.Error("subscription should match its own template")
+	}
+	if (Subscription{TemplateId: &otherTemplateId}).Matches(event) {
+		t.Error("subscription should not match another template")
+	}
+	if !(Subscription{BulkListId: &listId}).Matches(event) {
+		t.Error("subscription should match its own bulk list")
+	}
+	if (Subscription{BulkListId: &listId}).Matches(Event{Type: EVENT_JOB_COMPLETED}) {
+		t.Error("bulk list subscription should not match a job that is not in a bulk list")
+	}
+}
+
+func TestSubscriptionValidate(t *testing.T) {
+	good := Subscription{Url: "https://cms.example.com/hook", Secret: "abc", Events: []EventType{EVENT_BULK_FINISHED}}
+	if err := good.Validate(); err != nil {
+		t.Errorf("expected a valid subscription, got %s", err)
+	}
+
+	badUrl := good
+	badUrl.Url = "ftp://cms.example.com/hook"
+	if badUrl.Validate() == nil {
+		t.Error("expected a non-http url to be rejected")
+	}
+
+	badEvent := good
+	badEvent.Events = []EventType{"job.exploded"}
+	if badEvent.Validate() == nil {
+		t.Error("expected an unknown event type to be rejected")
+	}
+
+	noSecret := good
+	noSecret.Secret = ""
+	if noSecret.Validate() == nil {
+		t.Error("expected a subscription without a secret to be rejected")
+	}
+}
+
+/**
+a delivery that succeeds should be signed with the subscription's secret and should carry the job details
+*/
+func TestDeliverySigned(t *testing.T) {
+	s, client := newTestClient(t)
+	defer s.Close()
+
+	subscriber := &testSubscriber{status: 200}
+	server := httptest.NewServer(subscriber)
+	defer server.Close()
+
+	sub := Subscription{Id: uuid.New(), Url: server.URL, Secret: "topsecret"}
+	if err := PutSubscription(sub, client); err != nil {
+		t.Fatal(err)
+	}
+
+	n := NewNotifier(client)
+	container := &models.JobContainer{Id: uuid.New(), JobTemplateId: uuid.New(), Status: models.JOB_COMPLETED}
+	n.JobFinished(container)
+	n.deliverDue()
+
+	if len(subscriber.received) != 1 {
+		t.Fatalf("expected 1 delivery, got %d", len(subscriber.received))
+	}
+	req := subscriber.received[0]
+	if req.header.Get("X-Mediaflipper-Event") != "job.completed" {
+		t.Errorf("wrong event header '%s'", req.header.Get("X-Mediaflipper-Event"))
+	}
+	timestamp, _ := strconv.ParseInt(req.header.Get("X-Mediaflipper-Timestamp"), 10, 64)
+	expectedSig := "sha256=" + SignPayload("topsecret", timestamp, req.body)
+	if req.header.Get("X-Mediaflipper-Signature") != expectedSig {
+		t.Errorf("signature %s did not match expected %s", req.header.Get("X-Mediaflipper-Signature"), expectedSig)
+	}
+
+	var event Event
+	if err := json.Unmarshal(req.body, &event); err != nil {
+		t.Fatalf("could not parse payload: %s", err)
+	}
+	if event.JobId == nil || *event.JobId != container.Id {
+		t.Errorf("payload had wrong job id %v", event.JobId)
+	}
+	if event.Status != "completed" {
+		t.Errorf("payload had wrong status %s", event.Status)
+	}
+
+	if queued, _ := client.ZCard(retryQueueKey).Result(); queued != 0 {
+		t.Errorf("expected the sent delivery to be removed from the queue, got %d waiting", queued)
+	}
+
+	//a cancelled job should say so, rather than that it failed
+	cancelled := &models.JobContainer{Id: uuid.New(), JobTemplateId: uuid.New(), Status: models.JOB_ABORTED}
+	n.JobFinished(cancelled)
+	n.deliverDue()
+	if len(subscriber.received) != 2 || subscriber.received[1].header.Get("X-Mediaflipper-Event") != "job.cancelled" {
+		t.Errorf("expected a job.cancelled event, got %d deliveries", len(subscriber.received))
+	}
+
+	//the same job finishing again should not send another event
+	n.JobFinished(container)
+	n.deliverDue()
+	if len(subscriber.received) != 2 {
+		t.Errorf("expected the repeated event to be dropped, got %d deliveries", len(subscriber.received))
+	}
+}
+
+/**
+a failed delivery should be retried later, and dead-lettered once it runs out of attempts. Redeliver should send it again
+*/
+func TestDeliveryRetryAndDeadLetter(t *testing.T) {
+	s, client := newTestClient(t)
+	defer s.Close()
+
+	subscriber := &testSubscriber{status: 500}
+	server := httptest.NewServer(subscriber)
+	defer server.Close()
+
+	sub := Subscription{Id: uuid.New(), Url: server.URL, Secret: "topsecret"}
+	if err := PutSubscription(sub, client); err != nil {
+		t.Fatal(err)
+	}
+
+	n := NewNotifier(client)
+	queued, pubErr := n.Publish(Event{EventId: uuid.New(), Type: EVENT_JOB_FAILED})
+	if pubErr != nil || queued != 1 {
+		t.Fatalf("expected 1 delivery to be queued, got %d (%v)", queued, pubErr)
+	}
+
+	n.deliverDue()
+	if len(subscriber.received) != 1 {
+		t.Fatalf("expected 1 attempt, got %d", len(subscriber.received))
+	}
+	retryCount, _ := client.ZCard(retryQueueKey).Result()
+	if retryCount != 1 {
+		t.Errorf("expected the failed delivery to be waiting for a retry, got %d", retryCount)
+	}
+	n.deliverDue()
+	if len(subscriber.received) != 1 {
+		t.Error("the retry should not be sent before its backoff has passed")
+	}
+
+	//make it due now, on its last attempt
+	due, _ := claimDueDeliveries(time.Now().Add(time.Hour), client)
+	if len(due) != 1 {
+		t.Fatalf("expected 1 delivery to be due after the backoff, got %d", len(due))
+	}
+	due[0].Attempts = deliveryRetryPolicy.MaxAttempts - 1
+	queueDelivery(due[0], time.Now(), client)
+	n.deliverDue()
+
+	deadCount, _ := CountDeadLetters(client)
+	if deadCount != 1 {
+		t.Fatalf("expected 1 dead letter, got %d", deadCount)
+	}
+	dead, _ := ListDeadLetters(10, client)
+	if len(dead) != 1 || dead[0].LastError == "" || dead[0].Attempts != deliveryRetryPolicy.MaxAttempts {
+		t.Errorf("unexpected dead letters: %v", dead)
+	}
+
+	subscriber.status = 200
+	found, redeliverErr := Redeliver(dead[0].Id, client)
+	if !found || redeliverErr != nil {
+		t.Fatalf("expected redelivery to be queued, got %t, %v", found, redeliverErr)
+	}
+	n.deliverDue()
+	if len(subscriber.received) != 3 {
+		t.Errorf("expected the redelivery to be sent, got %d requests", len(subscriber.received))
+	}
+	deadCount, _ = CountDeadLetters(client)
+	if deadCount != 0 {
+		t.Errorf("expected no dead letters after redelivery, got %d", deadCount)
+	}
+}
+
+/**
+a claimed delivery should not be claimed again while it is being sent, but should come back if it is never finished with
+*/
+func TestClaimDueDeliveries(t *testing.T) {
+	s, client := newTestClient(t)
+	defer s.Close()
+
+	d := Delivery{Id: uuid.New(), SubscriptionId: uuid.New(), Event: Event{EventId: uuid.New(), Type: EVENT_JOB_STARTED}}
+	now := time.Now()
+	if err := queueDelivery(d, now, client); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, claimErr := claimDueDeliveries(now, client)
+	if claimErr != nil || len(claimed) != 1 || claimed[0].Id != d.Id {
+		t.Fatalf("expected to claim the delivery, got %v (%v)", claimed, claimErr)
+	}
+	claimed, _ = claimDueDeliveries(now, client)
+	if len(claimed) != 0 {
+		t.Errorf("expected a claimed delivery not to be claimed again, got %d", len(claimed))
+	}
+	if queued, _ := client.ZCard(retryQueueKey).Result(); queued != 1 {
+		t.Errorf("expected the claimed delivery to stay on the queue, got %d waiting", queued)
+	}
+
+	//whoever claimed it went away without sending it
+	claimed, _ = claimDueDeliveries(now.Add(deliveryClaimTimeout+time.Second), client)
+	if len(claimed) != 1 || claimed[0].Id != d.Id {
+		t.Errorf("expected the abandoned delivery to be claimed again, got %v", claimed)
+	}
+}
+
+func TestSubscriptionHandlers(t *testing.T) {
+	s, client := newTestClient(t)
+	defer s.Close()
+
+	endpoints := NewWebhookEndpoints(client)
+
+	status, created := callHandler(endpoints.createEndpoint, "POST", "/create", `{"url":"https://cms.example.com/hook","events":["job.completed"]}`)
+	if status != 200 {
+		t.Fatalf("create returned %d: %v", status, created)
+	}
+	entry := created["entry"].(map[string]interface{})
+	if entry["secret"] == nil || entry["secret"].(string) == "" {
+		t.Error("expected a secret to be made up for the new subscription")
+	}
+
+	status, _ = callHandler(endpoints.createEndpoint, "POST", "/create", `{"url":"not a url"}`)
+	if status != 400 {
+		t.Errorf("expected an invalid subscription to be rejected, got %d", status)
+	}
+
+	status, listed := callHandler(endpoints.listEndpoint, "GET", "", "")
+	if status != 200 || listed["count"].(float64) != 1 {
+		t.Fatalf("expected 1 subscription, got %d: %v", status, listed)
+	}
+	listedEntry := listed["entries"].([]interface{})[0].(map[string]interface{})
+	if _, hasSecret := listedEntry["secret"]; hasSecret {
+		t.Error("the secret should not be listed")
+	}
+
+	status, _ = callHandler(endpoints.deleteEndpoint, "DELETE", "/delete?forId="+entry["id"].(string), "")
+	if status != 200 {
+		t.Errorf("expected delete to succeed, got %d", status)
+	}
+	status, _ = callHandler(endpoints.deleteEndpoint, "DELETE", "/delete?forId="+entry["id"].(string), "")
+	if status != 404 {
+		t.Errorf("expected a second delete to give 404, got %d", status)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"github.com/davecgh/go-spew/spew"
+	"github.com/guardian/mediaflipper/common/models"
 	"log"
 	"os/exec"
 )
@@ -26,6 +27,37 @@ func RunAnalysis(fileName string) (*AnalysisResult, error) {
 
 	log.Printf("DEBUG: analysis result was: %s", spew.Sdump(rawOutput))
 	log.Printf("DEBUG: format result was: %s", spew.Sdump(rawOutput["format"]))
-	return &AnalysisResult{Success: true, Format: FormatAnalysisFromMap(rawOutput["format"].(map[string]interface{}))}, nil
+	rawStreams, _ := rawOutput["streams"].([]interface{})
+	streams := StreamsAnalysisFromList(rawStreams)
+	return &AnalysisResult{
+		Success:  true,
+		Format:   FormatAnalysisFromMap(rawOutput["format"].(map[string]interface{})),
+		Streams:  streams,
+		Loudness: analyseLoudness(fileName, streams),
+	}, nil
 
 }
+
+/**
+measure the loudness of the file if it has any audio. A failed measurement is not treated as a failed analysis, as the
+rest of the results are still useful; nil is returned instead
+*/
+func analyseLoudness(fileName string, streams []models.StreamAnalysis) *models.LoudnessAnalysis {
+	haveAudio := false
+	for _, stream := range streams {
+		if stream.CodecType == "audio" {
+			haveAudio = true
+			break
+		}
+	}
+	if !haveAudio {
+		return nil
+	}
+
+	loudness, measureErr := MeasureLoudness(fileName, nil)
+	if measureErr != nil {
+		log.Printf("WARNING: could not measure loudness of %s: %s", fileName, measureErr)
+		return nil
+	}
+	return loudness
+}
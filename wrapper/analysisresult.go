@@ -2,7 +2,10 @@ package main
 
 import (
 	"errors"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
 	"strconv"
+	"strings"
 )
 
 type FormatAnalysis struct {
@@ -58,8 +61,123 @@ func FormatAnalysisFromMap(from map[string]interface{}) FormatAnalysis {
 	}
 }
 
+/**
+ffprobe gives frame rates as a fraction, e.g. 30000/1001. Convert this into a number of frames per second.
+returns 0 if there is no frame rate, which ffprobe gives as 0/0
+*/
+func parseFrameRate(rational string) float64 {
+	parts := strings.Split(rational, "/")
+	numerator, numErr := strconv.ParseFloat(parts[0], 64)
+	if numErr != nil {
+		return 0
+	}
+	if len(parts) == 1 {
+		return numerator
+	}
+	denominator, denomErr := strconv.ParseFloat(parts[1], 64)
+	if denomErr != nil || denominator == 0 {
+		return 0
+	}
+	return numerator / denominator
+}
+
+func safeGetString(from map[string]interface{}, key string) string {
+	stringVal, _ := from[key].(string)
+	return stringVal
+}
+
+func safeGetNumber(from map[string]interface{}, key string) float64 {
+	numberVal, _ := from[key].(float64)
+	return numberVal
+}
+
+/**
+get the rotation of a video stream. Older versions of ffprobe give this as a "rotate" tag, newer ones in a display matrix
+in the side data
+*/
+func streamRotation(from map[string]interface{}, tags map[string]interface{}) float64 {
+	if rotateTag, haveTag := tags["rotate"].(string); haveTag {
+		rotation, parseErr := strconv.ParseFloat(rotateTag, 64)
+		if parseErr != nil {
+			log.Printf("WARNING: could not understand rotate tag '%s': %s", rotateTag, parseErr)
+		}
+		return rotation
+	}
+	sideDataList, haveSideData := from["side_data_list"].([]interface{})
+	if haveSideData {
+		for _, rawSideData := range sideDataList {
+			sideData, isMap := rawSideData.(map[string]interface{})
+			if isMap && sideData["side_data_type"] == "Display Matrix" {
+				return safeGetNumber(sideData, "rotation")
+			}
+		}
+	}
+	return 0
+}
+
+/**
+convert one entry of the "streams" list from ffprobe. As with FormatAnalysisFromMap, some of the numeric fields come
+through as strings; there are also fields that only apply to some types of stream, so anything missing is left empty
+*/
+func StreamAnalysisFromMap(from map[string]interface{}) models.StreamAnalysis {
+	tags, haveTags := from["tags"].(map[string]interface{})
+	if !haveTags {
+		tags = map[string]interface{}{}
+	}
+	sampleRate, _ := safeParseInt(from, "sample_rate", 0)
+	bitRate, _ := safeParseFloat(from, "bit_rate", 0)
+	duration, _ := safeParseFloat(from, "duration", 0)
+
+	//avg_frame_rate is the real rate for variable frame rate content, r_frame_rate is the lowest rate that can show every frame
+	frameRate := parseFrameRate(safeGetString(from, "avg_frame_rate"))
+	if frameRate == 0 {
+		frameRate = parseFrameRate(safeGetString(from, "r_frame_rate"))
+	}
+
+	return models.StreamAnalysis{
+		Index:         int32(safeGetNumber(from, "index")),
+		CodecType:     safeGetString(from, "codec_type"),
+		CodecName:     safeGetString(from, "codec_name"),
+		CodecLongName: safeGetString(from, "codec_long_name"),
+		Profile:       safeGetString(from, "profile"),
+		Width:         int32(safeGetNumber(from, "width")),
+		Height:        int32(safeGetNumber(from, "height")),
+		FrameRate:     frameRate,
+		RawFrameRate:  safeGetString(from, "r_frame_rate"),
+		FieldOrder:    safeGetString(from, "field_order"),
+		PixFmt:        safeGetString(from, "pix_fmt"),
+		Channels:      int32(safeGetNumber(from, "channels")),
+		ChannelLayout: safeGetString(from, "channel_layout"),
+		SampleRate:    int32(sampleRate),
+		BitRate:       bitRate,
+		Duration:      duration,
+		Language:      safeGetString(tags, "language"),
+		Timecode:      safeGetString(tags, "timecode"),
+		Rotation:      streamRotation(from, tags),
+	}
+}
+
+/**
+convert the "streams" list from ffprobe
+*/
+func StreamsAnalysisFromList(from []interface{}) []models.StreamAnalysis {
+	rtn := make([]models.StreamAnalysis, 0, len(from))
+	for _, rawStream := range from {
+		streamMap, isMap := rawStream.(map[string]interface{})
+		if !isMap {
+			log.Printf("WARNING: ignoring stream info that was not an object: %v", rawStream)
+			continue
+		}
+		rtn = append(rtn, StreamAnalysisFromMap(streamMap))
+	}
+	return rtn
+}
+
 type AnalysisResult struct {
-	Success      bool           `json:"successful"`
-	Format       FormatAnalysis `json:"format"`
-	ErrorMessage *string        `json:"errorMessage"`
+	Success      bool                     `json:"successful"`
+	Format       FormatAnalysis           `json:"format"`
+	Streams      []models.StreamAnalysis  `json:"streams"`
+	Loudness     *models.LoudnessAnalysis `json:"loudness"`
+	Checksums    *models.Checksums        `json:"checksums"`
+	ErrorMessage *string                  `json:"errorMessage"`
 }
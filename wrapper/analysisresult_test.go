@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStreamsAnalysisFromList(t *testing.T) {
+	//cut-down output from ffprobe -show_streams
+	rawJson := `[
+{"index":0,"codec_name":"h264","codec_long_name":"H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10","profile":"High","codec_type":"video",
+ "width":1920,"height":1080,"pix_fmt":"yuv420p","field_order":"progressive","r_frame_rate":"30000/1001","avg_frame_rate":"30000/1001",
+ "duration":"12.512500","bit_rate":"8000000","tags":{"language":"und","timecode":"10:00:00;00"},
+ "side_data_list":[{"side_data_type":"Display Matrix","rotation":-90}]},
+{"index":1,"codec_name":"aac","codec_type":"audio","sample_rate":"48000","channels":2,"channel_layout":"stereo",
+ "r_frame_rate":"0/0","avg_frame_rate":"0/0","tags":{"language":"eng"}},
+"not a stream"
+]`
+	var rawStreams []interface{}
+	json.Unmarshal([]byte(rawJson), &rawStreams)
+
+	streams := StreamsAnalysisFromList(rawStreams)
+	if len(streams) != 2 {
+		t.Errorf("expected 2 streams, got %d", len(streams))
+		t.FailNow()
+	}
+
+	video := streams[0]
+	if video.CodecType != "video" || video.CodecName != "h264" || video.Profile != "High" {
+		t.Errorf("got wrong video codec info: %v", video)
+	}
+	if video.Width != 1920 || video.Height != 1080 || video.PixFmt != "yuv420p" || video.FieldOrder != "progressive" {
+		t.Errorf("got wrong video picture info: %v", video)
+	}
+	if video.FrameRate < 29.97 || video.FrameRate > 29.98 || video.RawFrameRate != "30000/1001" {
+		t.Errorf("got wrong frame rate %f (%s)", video.FrameRate, video.RawFrameRate)
+	}
+	if video.Timecode != "10:00:00;00" || video.Rotation != -90 || video.BitRate != 8000000 {
+		t.Errorf("got wrong timecode, rotation or bitrate: %v", video)
+	}
+
+	audio := streams[1]
+	if audio.Index != 1 || audio.CodecType != "audio" || audio.Channels != 2 || audio.ChannelLayout != "stereo" {
+		t.Errorf("got wrong audio info: %v", audio)
+	}
+	if audio.SampleRate != 48000 || audio.Language != "eng" || audio.FrameRate != 0 || audio.Width != 0 {
+		t.Errorf("got wrong audio info: %v", audio)
+	}
+}
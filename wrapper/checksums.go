@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/common/results"
+	"log"
+)
+
+/**
+work out the checksum of the original media. A failure is logged and nil returned, as the rest of the analysis is
+still useful
+*/
+func checksumOriginal(fileName string) *models.Checksums {
+	sums, checksumErr := models.ChecksumFile(fileName)
+	if checksumErr != nil {
+		log.Printf("WARNING: could not checksum %s: %s", fileName, checksumErr)
+		return nil
+	}
+	log.Printf("INFO: %s has md5 %s and sha256 %s", fileName, sums.MD5, sums.SHA256)
+	return &sums
+}
+
+/**
+work out the checksums of each of the given output files. Empty paths are ignored, and files that can't be read are
+logged and left out
+*/
+func checksumOutputs(filePaths ...string) models.FileChecksums {
+	rtn := make(models.FileChecksums, len(filePaths))
+	for _, filePath := range filePaths {
+		if filePath == "" {
+			continue
+		}
+		sums, checksumErr := models.ChecksumFile(filePath)
+		if checksumErr != nil {
+			log.Printf("WARNING: could not checksum output %s: %s", filePath, checksumErr)
+			continue
+		}
+		rtn[filePath] = sums
+	}
+	return rtn
+}
+
+/**
+the files written by a transcode, i.e. the output file, every rendition and the manifest of every package
+*/
+func transcodeOutputPaths(result *results.TranscodeResult) []string {
+	paths := []string{result.OutFile}
+	for _, rendition := range result.Renditions {
+		paths = append(paths, rendition.OutFile)
+	}
+	for _, pkg := range result.Packages {
+		paths = append(paths, pkg.ManifestFile)
+	}
+	return paths
+}
+
+/**
+the files written by a thumbnail step, i.e. the thumbnail or the sprite sheets and their index
+*/
+func thumbnailOutputPaths(result *ThumbnailResult) []string {
+	paths := append([]string{}, result.SpriteSheets...)
+	if result.OutPath != nil {
+		paths = append(paths, *result.OutPath)
+	}
+	if result.SpriteIndex != nil {
+		paths = append(paths, *result.SpriteIndex)
+	}
+	return paths
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+/**
+the loudnorm filter prints its measurements as a json object at the end of its output, with every value as a string
+*/
+type loudnormOutput struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+func parseLoudnormValue(name string, value string) (float64, error) {
+	parsed, parseErr := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if parseErr != nil {
+		return 0, errors.New(fmt.Sprintf("could not understand %s value '%s': %s", name, value, parseErr))
+	}
+	//silence is measured as -inf, which can't be used as a measurement or sent as json
+	if math.IsInf(parsed, 0) || math.IsNaN(parsed) {
+		return 0, errors.New(fmt.Sprintf("%s could not be measured, the audio may be silent", name))
+	}
+	return parsed, nil
+}
+
+/**
+get the measurements out of the stderr output of an ffmpeg run with loudnorm's print_format=json option
+*/
+func ParseLoudnormOutput(stderr []byte) (*models.LoudnessAnalysis, error) {
+	content := string(stderr)
+	start := strings.LastIndex(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start == -1 || end < start {
+		return nil, errors.New("no loudness measurements in the output")
+	}
+
+	var raw loudnormOutput
+	unmarshalErr := json.Unmarshal([]byte(content[start:end+1]), &raw)
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+
+	var result models.LoudnessAnalysis
+	fields := []struct {
+		name  string
+		value string
+		dest  *float64
+	}{
+		{"input_i", raw.InputI, &result.Integrated},
+		{"input_lra", raw.InputLRA, &result.Range},
+		{"input_tp", raw.InputTP, &result.TruePeak},
+		{"input_thresh", raw.InputThresh, &result.Threshold},
+		{"target_offset", raw.TargetOffset, &result.TargetOffset},
+	}
+	for _, field := range fields {
+		parsed, parseErr := parseLoudnormValue(field.name, field.value)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		*field.dest = parsed
+	}
+	return &result, nil
+}
+
+/**
+measure the loudness of the first audio stream in the given file. This reads the whole file, so takes a while for long media.
+the target only affects the TargetOffset in the result; if it is nil then the loudnorm defaults are used
+*/
+func MeasureLoudness(fileName string, target *models.LoudnessSettings) (*models.LoudnessAnalysis, error) {
+	measureFilter := "loudnorm=print_format=json"
+	if target != nil {
+		measureFilter = target.MeasureFilterString()
+	}
+	cmd := exec.Command("/usr/bin/ffmpeg", "-hide_banner", "-nostats", "-i", fileName, "-map", "0:a:0", "-af", measureFilter, "-f", "null", "-")
+
+	_, errContent, runErr := RunCommand(cmd)
+	if runErr != nil {
+		return nil, runErr
+	}
+	return ParseLoudnormOutput(errContent)
+}
+
+/**
+if the settings normalise loudness, measure the input so that the normalisation can be done in a single linear pass.
+if the measurement fails then the settings are returned unchanged and loudnorm normalises dynamically instead.
+*/
+func prepareLoudnessNormalisation(fileName string, settings models.TranscodeTypeSettings) (models.TranscodeTypeSettings, *models.LoudnessAnalysis) {
+	normalised, canNormalise := settings.(models.LoudnessNormalisedSettings)
+	if !canNormalise || normalised.LoudnessNormalisation() == nil {
+		return settings, nil
+	}
+
+	log.Printf("INFO: measuring loudness of %s before normalising", fileName)
+	measured, measureErr := MeasureLoudness(fileName, normalised.LoudnessNormalisation())
+	if measureErr != nil {
+		log.Printf("WARNING: could not measure loudness of %s, falling back to dynamic normalisation: %s", fileName, measureErr)
+		return settings, nil
+	}
+	log.Printf("INFO: measured loudness of %s is %f LUFS, true peak %f dBTP", fileName, measured.Integrated, measured.TruePeak)
+	return normalised.WithMeasuredLoudness(measured), measured
+}
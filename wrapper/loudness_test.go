@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseLoudnormOutput(t *testing.T) {
+	stderr := []byte(`Input #0, wav, from 'test.wav':
+  Duration: 00:00:10.00, bitrate: 1536 kb/s
+    Stream #0:0: Audio: pcm_s16le ([1][0][0][0] / 0x0001), 48000 Hz, stereo, s16, 1536 kb/s
+[Parsed_loudnorm_0 @ 0x55d7c8f0c2c0]
+{
+	"input_i" : "-27.61",
+	"input_tp" : "-4.47",
+	"input_lra" : "18.06",
+	"input_thresh" : "-39.20",
+	"output_i" : "-16.58",
+	"output_tp" : "-1.50",
+	"output_lra" : "14.78",
+	"output_thresh" : "-27.71",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.58"
+}
+`)
+	result, err := ParseLoudnormOutput(stderr)
+	if err != nil {
+		t.Fatalf("ParseLoudnormOutput failed unexpectedly: %s", err)
+	}
+	if result.Integrated != -27.61 || result.TruePeak != -4.47 || result.Range != 18.06 || result.Threshold != -39.2 || result.TargetOffset != 0.58 {
+		t.Errorf("got wrong measurements: %v", result)
+	}
+
+	_, noJsonErr := ParseLoudnormOutput([]byte("Output file is empty, nothing was encoded"))
+	if noJsonErr == nil {
+		t.Error("output without measurements should give an error")
+	}
+
+	_, silentErr := ParseLoudnormOutput([]byte(`{"input_i" : "-inf", "input_tp" : "-inf", "input_lra" : "0.00", "input_thresh" : "-70.00", "target_offset" : "inf"}`))
+	if silentErr == nil {
+		t.Error("silent audio should give an error rather than infinite measurements")
+	}
+}
@@ -1,9 +1,9 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
-	"github.com/davecgh/go-spew/spew"
 	"github.com/google/uuid"
 	"github.com/guardian/mediaflipper/common/models"
 	"github.com/guardian/mediaflipper/common/results"
@@ -86,13 +86,18 @@ func checkInputFile(filename string) (bool, string) {
 
 /**
 we expect the following environment variables to be set:
-WRAPPER_MODE={analyse|thumbnail|transcode}
+WRAPPER_MODE={analyse|thumbnail|transcode|qc}
 JOB_STEP_ID={uuid-string}
 JOB_CONTAINER_ID={uuid-string}
 WEBAPP_BASE={url-string}  [url to contact main webapp]
 MAX_RETRIES={count}
-THUMBNAIL_FRAME={int} [thumbnail only]
+THUMBNAIL_FRAME={number} [thumbnail only, seconds. defaults to 30]
+THUMBNAIL_MODE={fixed|smart} [thumbnail only, optional. smart looks for a representative frame, falling back to THUMBNAIL_FRAME]
+SPRITE_SETTINGS={jsonstring} [thumbnail only, optional. makes sprite sheets instead of a single thumbnail]
 TRANSCODE_SETTINGS={jsonstring} [transcode only]
+QUALITY_SETTINGS={jsonstring} [transcode only, optional. compares the output with the source once the transcode has finished]
+QC_SETTINGS={jsonstring} [qc only]
+CHECKSUM_ORIGINAL={true|false} [analyse only, optional. defaults to true. checksumming reads the whole of the original, which ffprobe does not, so set false to skip it for very large media]
 MEDIA_TYPE={video|audio|image|other}
 OUTPUT_PATH={optional path to output. defaults to same location as incoming media}
 */
@@ -128,11 +133,24 @@ func main() {
 		}
 		EnsureOutputPath(sendUrl, maxTries)
 
+		//ffprobe only reads as much of the file as it needs, so the checksums take a separate pass over it. run that alongside
+		//the analysis rather than after it
+		checksumChan := make(chan *models.Checksums, 1)
+		if os.Getenv("CHECKSUM_ORIGINAL") != "false" {
+			go func() {
+				checksumChan <- checksumOriginal(filename)
+			}()
+		} else {
+			log.Print("INFO: CHECKSUM_ORIGINAL is false, not checksumming the original")
+			checksumChan <- nil
+		}
+
 		result, err := RunAnalysis(filename)
 
 		if err != nil {
 			log.Fatal("Could not run analysis: ", err)
 		}
+		result.Checksums = <-checksumChan
 
 		log.Print("Got analysis result: ", result)
 		sendErr := SendToWebapp(sendUrl, result, 0, maxTries)
@@ -157,17 +175,32 @@ func main() {
 
 		EnsureOutputPath(sendUrl, maxTries)
 
-		var thumbFrame int
+		var thumbFrame float64
 		if os.Getenv("THUMBNAIL_FRAME") != "" {
-			thumbFrame64, _ := strconv.ParseInt(os.Getenv("THUMBNAIL_FRAME"), 10, 32)
-			thumbFrame = int(thumbFrame64)
+			var parseErr error
+			thumbFrame, parseErr = strconv.ParseFloat(os.Getenv("THUMBNAIL_FRAME"), 64)
+			if parseErr != nil {
+				log.Fatalf("Invalid value for THUMBNAIL_FRAME (not a number): %s", parseErr)
+			}
 		} else {
 			thumbFrame = 30
 		}
+		runVideoThumbnail := RunVideoThumbnail
+		if os.Getenv("THUMBNAIL_MODE") == models.THUMBNAIL_MODE_SMART {
+			runVideoThumbnail = RunSmartVideoThumbnail
+		}
 
 		var result *ThumbnailResult
 		rawSettings := os.Getenv("TRANSCODE_SETTINGS")
-		if rawSettings != "" {
+		if os.Getenv("SPRITE_SETTINGS") != "" {
+			var spriteSettings models.SpriteSheetSettings
+			unmarshalErr := json.Unmarshal([]byte(os.Getenv("SPRITE_SETTINGS")), &spriteSettings)
+			if unmarshalErr != nil {
+				log.Fatalf("Could not parse settings from SPRITE_SETTINGS var: %s", unmarshalErr)
+			}
+			log.Printf("Performing sprite sheet thumbnails...")
+			result = RunSpriteSheet(filename, os.Getenv("OUTPUT_PATH"), os.Getenv("JOB_CONTAINER_ID"), spriteSettings)
+		} else if rawSettings != "" {
 			transcodeSettings, settingsErr := ParseSettings(os.Getenv("TRANSCODE_SETTINGS"))
 			if settingsErr != nil {
 				log.Fatalf("Could not parse settings from TRANSCODE_SETTINGS var: %s", settingsErr)
@@ -178,11 +211,16 @@ func main() {
 			}
 			if _, isAV := transcodeSettings.(models.JobSettings); isAV {
 				log.Printf("Performing video thumbnail with provided settings...")
-				result = RunVideoThumbnail(filename, os.Getenv("OUTPUT_PATH"), thumbFrame)
+				result = runVideoThumbnail(filename, os.Getenv("OUTPUT_PATH"), thumbFrame)
+			}
+			if _, isAudio := transcodeSettings.(models.TranscodeAudioSettings); isAudio {
+				errMsg := "Audio settings can't be used to make a thumbnail"
+				log.Print(errMsg)
+				result = &ThumbnailResult{ErrorMessage: &errMsg}
 			}
 		} else {
 			log.Printf("Performing video thumbnail by default with no provided settings...")
-			result = RunVideoThumbnail(filename, os.Getenv("OUTPUT_PATH"), thumbFrame)
+			result = runVideoThumbnail(filename, os.Getenv("OUTPUT_PATH"), thumbFrame)
 		}
 
 		log.Print("Got thumbnail result: ", result)
@@ -193,6 +231,9 @@ func main() {
 				log.Printf("ERROR: could not open permissions on %s: %s", *result.OutPath, chmodErr)
 			}
 		}
+		if result != nil && result.ErrorMessage == nil {
+			result.Checksums = checksumOutputs(thumbnailOutputPaths(result)...)
+		}
 		sendErr := SendToWebapp(sendUrl, result, 0, maxTries)
 		if sendErr != nil {
 			log.Fatalf("Could not send results to %s: %s", sendUrl, sendErr)
@@ -228,27 +269,55 @@ func main() {
 			log.Fatal("Could not parse JOB_STEP_ID as a uuid: ", stepIdErr)
 		}
 
-		var result results.TranscodeResult
-		avSettings, isAv := transcodeSettings.(models.JobSettings)
-		if isAv {
-			result = RunTranscode(filename, os.Getenv("OUTPUT_PATH"), avSettings, jobId, stepId)
-			log.Print("Got transcode result: ", result)
-		} else {
-			log.Printf("Could not recognise settings type for %s", spew.Sdump(transcodeSettings))
-			result = results.TranscodeResult{
-				OutFile:      "",
-				TimeTaken:    0,
-				ErrorMessage: "could not recognise settings as valid for a transcode operation. Maybe you meant thumbnail?",
+		var qualitySettings *models.QualityMetricSettings
+		if os.Getenv("QUALITY_SETTINGS") != "" {
+			qualitySettings = &models.QualityMetricSettings{}
+			unmarshalErr := json.Unmarshal([]byte(os.Getenv("QUALITY_SETTINGS")), qualitySettings)
+			if unmarshalErr != nil {
+				log.Fatalf("Could not parse settings from QUALITY_SETTINGS var: %s", unmarshalErr)
 			}
 		}
 
+		result := TranscodeForSettings(filename, os.Getenv("OUTPUT_PATH"), transcodeSettings, qualitySettings, jobId, stepId, RunTranscode)
+
 		if result.OutFile != "" {
 			chmodErr := os.Chmod(result.OutFile, 0777)
 			if chmodErr != nil {
 				log.Printf("ERROR: could not open permissions on %s: %s", result.OutFile, chmodErr)
 			}
 		}
+		if result.ErrorMessage == "" {
+			result.Checksums = checksumOutputs(transcodeOutputPaths(&result)...)
+		}
+
+		sendErr := SendToWebapp(sendUrl, result, 0, maxTries)
+		if sendErr != nil {
+			log.Fatalf("Could not send results to %s: %s", sendUrl, sendErr)
+		}
+	case "qc":
+		sendUrl := os.Getenv("WEBAPP_BASE") + "/api/qc/result?forJob=" + os.Getenv("JOB_CONTAINER_ID") + "&stepId=" + os.Getenv("JOB_STEP_ID")
+		isOk, checkErr := checkInputFile(filename)
+		if !isOk {
+			log.Print("invalid input file: ", checkErr)
+			result := results.QCResult{
+				MediaFile:    filename,
+				ErrorMessage: checkErr,
+			}
+			sendErr := SendToWebapp(sendUrl, result, 0, maxTries)
+			if sendErr != nil {
+				log.Fatalf("Could not send results to %s: %s", sendUrl, sendErr)
+			}
+			return
+		}
+
+		var qcSettings models.QCSettings
+		unmarshalErr := json.Unmarshal([]byte(os.Getenv("QC_SETTINGS")), &qcSettings)
+		if unmarshalErr != nil {
+			log.Fatalf("Could not parse settings from QC_SETTINGS var: %s", unmarshalErr)
+		}
 
+		result := RunQC(filename, qcSettings)
+		log.Printf("Got QC result with %d events", len(result.Events))
 		sendErr := SendToWebapp(sendUrl, result, 0, maxTries)
 		if sendErr != nil {
 			log.Fatalf("Could not send results to %s: %s", sendUrl, sendErr)
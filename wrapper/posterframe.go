@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/guardian/mediaflipper/common/models"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	POSTER_CANDIDATE_COUNT = 50   //roughly how many frames to consider through the file
+	POSTER_EDGE_FRACTION   = 0.05 //ignore this fraction at each end of the file, where there are usually slates, fades and credits
+	POSTER_MIN_BRIGHTNESS  = 40   //average luma below this is treated as black
+	POSTER_MAX_BRIGHTNESS  = 220  //average luma above this is treated as blank white
+	POSTER_MIN_CONTRAST    = 20   //a spread of luma values below this is treated as a blank or flat frame
+)
+
+/**
+the measurements of one candidate frame. Luma values are on the 8-bit scale
+*/
+type PosterCandidate struct {
+	Time       float64
+	SceneScore float64 //how different the frame is from the previous candidate, 0-1
+	YAvg       float64 //average brightness
+	YLow       float64 //10th percentile of brightness
+	YHigh      float64 //90th percentile of brightness
+}
+
+/**
+returns true if the frame looks black, white or blank, i.e. it would not make a useful poster
+*/
+func (c PosterCandidate) IsBlank() bool {
+	return c.YAvg < POSTER_MIN_BRIGHTNESS || c.YAvg > POSTER_MAX_BRIGHTNESS || c.YHigh-c.YLow < POSTER_MIN_CONTRAST
+}
+
+/**
+scores a candidate frame on how representative it is likely to be. Frames with a good spread of brightness are preferred,
+as are frames at the start of a new shot and frames that are neither very dark nor very bright
+*/
+func (c PosterCandidate) Score() float64 {
+	contrast := math.Min((c.YHigh-c.YLow)/200, 1)
+	exposure := 1 - math.Min(math.Abs(c.YAvg-128)/128, 1)
+	return contrast*0.5 + exposure*0.3 + math.Min(c.SceneScore, 1)*0.2
+}
+
+/**
+parses the output of ffmpeg's metadata filter in print mode, after the scene score and signalstats filters, into a list
+of candidates. The output has a "frame:" line for each frame followed by a key=value line for each measurement.
+*/
+func ParseFrameStats(output []byte) []PosterCandidate {
+	candidates := make([]PosterCandidate, 0)
+	var current *PosterCandidate
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "frame:") {
+			if current != nil {
+				candidates = append(candidates, *current)
+			}
+			current = &PosterCandidate{}
+			for _, field := range strings.Fields(line) {
+				if strings.HasPrefix(field, "pts_time:") {
+					current.Time, _ = strconv.ParseFloat(strings.TrimPrefix(field, "pts_time:"), 64)
+				}
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value, parseErr := strconv.ParseFloat(parts[1], 64)
+		if parseErr != nil {
+			continue
+		}
+		switch parts[0] {
+		case "lavfi.scene_score":
+			current.SceneScore = value
+		case "lavfi.signalstats.YAVG":
+			current.YAvg = value
+		case "lavfi.signalstats.YLOW":
+			current.YLow = value
+		case "lavfi.signalstats.YHIGH":
+			current.YHigh = value
+		}
+	}
+	if current != nil {
+		candidates = append(candidates, *current)
+	}
+	return candidates
+}
+
+/**
+picks the best candidate that is not blank and not too close to the start or end of the file.
+returns false if there isn't one
+*/
+func ChoosePosterFrame(candidates []PosterCandidate, duration float64) (PosterCandidate, bool) {
+	var best PosterCandidate
+	found := false
+	for _, candidate := range candidates {
+		if candidate.Time < duration*POSTER_EDGE_FRACTION || candidate.Time > duration*(1-POSTER_EDGE_FRACTION) {
+			continue
+		}
+		if candidate.IsBlank() {
+			continue
+		}
+		if !found || candidate.Score() > best.Score() {
+			best = candidate
+			found = true
+		}
+	}
+	return best, found
+}
+
+/**
+measure a set of candidate frames spread through the file
+*/
+func measurePosterCandidates(fileName string, duration float64) ([]PosterCandidate, error) {
+	statsFile, tempErr := ioutil.TempFile("", "posterframe")
+	if tempErr != nil {
+		return nil, tempErr
+	}
+	statsFile.Close()
+	defer os.Remove(statsFile.Name())
+
+	interval := math.Max(duration/POSTER_CANDIDATE_COUNT, 1)
+	filterString := fmt.Sprintf("fps=1/%s,scale=320:-2,select=gte(scene\\,0),signalstats,metadata=mode=print:file=%s",
+		strconv.FormatFloat(interval, 'f', 3, 64), statsFile.Name())
+	cmd := exec.Command("ffmpeg", "-i", fileName, "-an", "-sn", "-vf", filterString, "-f", "null", "-")
+	_, errContent, runErr := RunCommand(cmd)
+	if runErr != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", runErr, string(errContent)))
+	}
+
+	output, readErr := ioutil.ReadFile(statsFile.Name())
+	if readErr != nil {
+		return nil, readErr
+	}
+	return ParseFrameStats(output), nil
+}
+
+/**
+make a thumbnail from the most representative frame that can be found. If the file can't be analysed or every candidate
+is blank then the fixed offset is used instead, brought inside the file if it is too short for it.
+the chosen time and how it was chosen are recorded in the result
+*/
+func RunSmartVideoThumbnail(fileName string, outPath string, fallbackSeconds float64) *ThumbnailResult {
+	frameTime := fallbackSeconds
+	selection := models.THUMBNAIL_MODE_FIXED
+
+	_, _, duration, probeErr := probeVideoGeometry(fileName)
+	if probeErr != nil {
+		log.Printf("WARNING: could not get video information for %s, using the fixed offset: %s", fileName, probeErr)
+	} else {
+		candidates, measureErr := measurePosterCandidates(fileName, duration)
+		if measureErr != nil {
+			log.Printf("WARNING: could not measure candidate frames for %s, using the fixed offset: %s", fileName, measureErr)
+		} else if best, found := ChoosePosterFrame(candidates, duration); found {
+			log.Printf("INFO: chose poster frame at %fs from %d candidates, score %f", best.Time, len(candidates), best.Score())
+			frameTime = best.Time
+			selection = models.THUMBNAIL_MODE_SMART
+		} else {
+			log.Printf("WARNING: none of the %d candidate frames of %s were suitable, using the fixed offset", len(candidates), fileName)
+		}
+		if selection == models.THUMBNAIL_MODE_FIXED && frameTime >= duration {
+			frameTime = duration / 2
+		}
+	}
+
+	result := RunVideoThumbnail(fileName, outPath, frameTime)
+	result.FrameSelection = selection
+	return result
+}
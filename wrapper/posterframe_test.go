@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseFrameStats(t *testing.T) {
+	output := []byte(`frame:0    pts:0       pts_time:0
+lavfi.scene_score=0.000000
+lavfi.signalstats.YMIN=16
+lavfi.signalstats.YLOW=16
+lavfi.signalstats.YAVG=16.2
+lavfi.signalstats.YHIGH=17
+lavfi.signalstats.YMAX=20
+frame:1    pts:1       pts_time:12.5
+lavfi.scene_score=0.412000
+lavfi.signalstats.YLOW=35
+lavfi.signalstats.YAVG=110.5
+lavfi.signalstats.YHIGH=190
+`)
+	candidates := ParseFrameStats(output)
+	if len(candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %d", len(candidates))
+	}
+	if candidates[0].Time != 0 || candidates[0].YAvg != 16.2 || !candidates[0].IsBlank() {
+		t.Errorf("first candidate was wrong: %v", candidates[0])
+	}
+	if candidates[1].Time != 12.5 || candidates[1].SceneScore != 0.412 || candidates[1].YLow != 35 || candidates[1].YHigh != 190 {
+		t.Errorf("second candidate was wrong: %v", candidates[1])
+	}
+}
+
+func TestChoosePosterFrame(t *testing.T) {
+	candidates := []PosterCandidate{
+		{Time: 1, SceneScore: 0.9, YAvg: 120, YLow: 20, YHigh: 220},   //good frame, but probably a slate as it's right at the start
+		{Time: 20, SceneScore: 0.1, YAvg: 18, YLow: 16, YHigh: 22},    //black
+		{Time: 40, SceneScore: 0.5, YAvg: 128, YLow: 128, YHigh: 130}, //flat grey
+		{Time: 60, SceneScore: 0.3, YAvg: 90, YLow: 40, YHigh: 150},
+		{Time: 80, SceneScore: 0.6, YAvg: 125, YLow: 30, YHigh: 210},
+		{Time: 99, SceneScore: 0.9, YAvg: 120, YLow: 20, YHigh: 220}, //credits
+	}
+	best, found := ChoosePosterFrame(candidates, 100)
+	if !found {
+		t.Fatal("expected a frame to be chosen")
+	}
+	if best.Time != 80 {
+		t.Errorf("expected the frame at 80s to be chosen, got %f", best.Time)
+	}
+
+	_, found = ChoosePosterFrame(candidates[1:3], 100)
+	if found {
+		t.Error("no frame should be chosen if they are all blank")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/guardian/mediaflipper/common/models"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+/**
+gets the version of a tool from the first line of its version output. ffmpeg and ffprobe give e.g.
+"ffmpeg version 4.3.1 Copyright ..." and ImageMagick gives "Version: ImageMagick 6.9.10-23 Q16 ...".
+returns an empty string if the output is not understood
+*/
+func ParseToolVersion(output []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	if !scanner.Scan() {
+		return ""
+	}
+	fields := strings.Fields(scanner.Text())
+	for i, field := range fields {
+		if field == "version" && i+1 < len(fields) {
+			return fields[i+1]
+		}
+		if field == "Version:" && i+2 < len(fields) {
+			return fields[i+1] + " " + fields[i+2]
+		}
+	}
+	return ""
+}
+
+/**
+asks the tool for its version. returns an empty string if it can't be found out
+*/
+func toolVersion(executable string) string {
+	outContent, _, runErr := RunCommand(exec.Command(executable, "-version"))
+	if runErr != nil {
+		log.Printf("WARNING: could not get the version of %s: %s", executable, runErr)
+		return ""
+	}
+	return ParseToolVersion(outContent)
+}
+
+/**
+turns a settings object into a generic snapshot of itself, via json
+*/
+func snapshotSettings(settings interface{}) map[string]interface{} {
+	if settings == nil {
+		return nil
+	}
+	content, marshalErr := json.Marshal(settings)
+	if marshalErr != nil {
+		log.Printf("WARNING: could not take a snapshot of the settings: %s", marshalErr)
+		return nil
+	}
+	var snapshot map[string]interface{}
+	unmarshalErr := json.Unmarshal(content, &snapshot)
+	if unmarshalErr != nil {
+		log.Printf("WARNING: could not take a snapshot of the settings: %s", unmarshalErr)
+		return nil
+	}
+	return snapshot
+}
+
+/**
+record how an output was made by the given command, which finished just now. The settings can be nil if there were none
+*/
+func newProvenance(cmd *exec.Cmd, settings interface{}, startTime time.Time) *models.Provenance {
+	endTime := time.Now()
+	hostname, hostErr := os.Hostname()
+	if hostErr != nil {
+		log.Printf("WARNING: could not get the hostname: %s", hostErr)
+	}
+
+	versions := make(map[string]string)
+	tool := path.Base(cmd.Path)
+	if version := toolVersion(cmd.Path); version != "" {
+		versions[tool] = version
+	}
+	if tool != "ffprobe" {
+		//everything is probed with ffprobe before it is processed, so that is always relevant
+		if version := toolVersion("ffprobe"); version != "" {
+			versions["ffprobe"] = version
+		}
+	}
+
+	return &models.Provenance{
+		Settings:     snapshotSettings(settings),
+		CommandLine:  append([]string{}, cmd.Args...),
+		ToolVersions: versions,
+		Hostname:     hostname,
+		StartTime:    &startTime,
+		EndTime:      &endTime,
+	}
+}
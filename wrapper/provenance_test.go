@@ -0,0 +1,52 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"testing"
+)
+
+/**
+ParseToolVersion should get the version from the first line of ffmpeg and ImageMagick output
+*/
+func TestParseToolVersion(t *testing.T) {
+	ffmpegOutput := []byte("ffmpeg version 4.3.1 Copyright (c) 2000-2020 the FFmpeg developers\nbuilt with gcc 9.3.0 (Alpine 9.3.0)\n")
+	if version := ParseToolVersion(ffmpegOutput); version != "4.3.1" {
+		t.Errorf("Got wrong ffmpeg version '%s'", version)
+	}
+
+	convertOutput := []byte("Version: ImageMagick 6.9.10-23 Q16 x86_64 20190101 https://imagemagick.org\nCopyright: (C) 1999-2019 ImageMagick Studio LLC\n")
+	if version := ParseToolVersion(convertOutput); version != "ImageMagick 6.9.10-23" {
+		t.Errorf("Got wrong ImageMagick version '%s'", version)
+	}
+
+	if version := ParseToolVersion([]byte("something else entirely\n")); version != "" {
+		t.Errorf("Expected no version for unrecognised output, got '%s'", version)
+	}
+	if version := ParseToolVersion([]byte{}); version != "" {
+		t.Errorf("Expected no version for empty output, got '%s'", version)
+	}
+}
+
+/**
+snapshotSettings should give a generic copy of the settings as they would be sent in json
+*/
+func TestSnapshotSettings(t *testing.T) {
+	settings := models.JobSettings{
+		SettingsId: uuid.MustParse("87230B0F-8E75-474D-B8D0-5C421C9D4E56"),
+		Name:       "test",
+		Wrapper:    models.WrapperSettings{Format: "mp4"},
+	}
+	snapshot := snapshotSettings(settings)
+	if snapshot["name"] != "test" {
+		t.Errorf("Got wrong name in snapshot: %v", snapshot)
+	}
+	wrapper, isMap := snapshot["wrapper"].(map[string]interface{})
+	if !isMap || wrapper["format"] != "mp4" {
+		t.Errorf("Got wrong wrapper settings in snapshot: %v", snapshot["wrapper"])
+	}
+
+	if snapshotSettings(nil) != nil {
+		t.Error("Expected no snapshot when there are no settings")
+	}
+}
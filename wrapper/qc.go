@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/common/results"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var blackDetectMatcher = regexp.MustCompile(`black_start:\s*([\d.]+)\s+black_end:\s*([\d.]+)`)
+var silenceStartMatcher = regexp.MustCompile(`silence_start:\s*(-?[\d.]+)`)
+var silenceEndMatcher = regexp.MustCompile(`silence_end:\s*([\d.]+)`)
+var freezeStartMatcher = regexp.MustCompile(`freeze_start:\s*([\d.]+)`)
+var freezeEndMatcher = regexp.MustCompile(`freeze_end:\s*([\d.]+)`)
+
+func newQCEvent(detector string, start float64, end float64, detail string) models.QCEvent {
+	if start < 0 { //silencedetect can report a start slightly before zero
+		start = 0
+	}
+	return models.QCEvent{
+		Detector:      detector,
+		Start:         start,
+		End:           end,
+		StartTimecode: FormatTimestamp(start),
+		EndTimecode:   FormatTimestamp(end),
+		Detail:        detail,
+	}
+}
+
+/**
+parses the log output of ffmpeg's blackdetect, silencedetect and freezedetect filters into a list of events.
+silence or freezes that are still going on at the end of the file don't get an end line, so they are taken to last until
+the given duration
+*/
+func ParseDetectorOutput(stderr []byte, duration float64) []models.QCEvent {
+	events := make([]models.QCEvent, 0)
+	var silenceStart *float64
+	var freezeStart *float64
+
+	scanner := bufio.NewScanner(bytes.NewReader(stderr))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if matches := blackDetectMatcher.FindStringSubmatch(line); matches != nil {
+			start, _ := strconv.ParseFloat(matches[1], 64)
+			end, _ := strconv.ParseFloat(matches[2], 64)
+			events = append(events, newQCEvent(models.QC_DETECTOR_BLACK, start, end, fmt.Sprintf("black picture for %.2fs", end-start)))
+		} else if matches := silenceStartMatcher.FindStringSubmatch(line); matches != nil {
+			start, _ := strconv.ParseFloat(matches[1], 64)
+			silenceStart = &start
+		} else if matches := silenceEndMatcher.FindStringSubmatch(line); matches != nil && silenceStart != nil {
+			end, _ := strconv.ParseFloat(matches[1], 64)
+			events = append(events, newQCEvent(models.QC_DETECTOR_SILENCE, *silenceStart, end, fmt.Sprintf("silence for %.2fs", end-*silenceStart)))
+			silenceStart = nil
+		} else if matches := freezeStartMatcher.FindStringSubmatch(line); matches != nil {
+			start, _ := strconv.ParseFloat(matches[1], 64)
+			freezeStart = &start
+		} else if matches := freezeEndMatcher.FindStringSubmatch(line); matches != nil && freezeStart != nil {
+			end, _ := strconv.ParseFloat(matches[1], 64)
+			events = append(events, newQCEvent(models.QC_DETECTOR_FREEZE, *freezeStart, end, fmt.Sprintf("frozen picture for %.2fs", end-*freezeStart)))
+			freezeStart = nil
+		}
+	}
+
+	if silenceStart != nil && duration > *silenceStart {
+		events = append(events, newQCEvent(models.QC_DETECTOR_SILENCE, *silenceStart, duration, fmt.Sprintf("silence for %.2fs, to the end of the file", duration-*silenceStart)))
+	}
+	if freezeStart != nil && duration > *freezeStart {
+		events = append(events, newQCEvent(models.QC_DETECTOR_FREEZE, *freezeStart, duration, fmt.Sprintf("frozen picture for %.2fs, to the end of the file", duration-*freezeStart)))
+	}
+	return events
+}
+
+/**
+parses the per-frame audio peak levels written by ffmpeg's ametadata filter in print mode and joins together runs of
+frames at or above the clipping level into events. Each frame is taken to last until the start of the next one.
+runs shorter than minDuration are dropped
+*/
+func ParseClippingOutput(output []byte, level float64, minDuration float64, duration float64) []models.QCEvent {
+	type framePeak struct {
+		time float64
+		peak float64
+	}
+	frames := make([]framePeak, 0)
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "frame:") {
+			frame := framePeak{peak: -1000}
+			for _, field := range strings.Fields(line) {
+				if strings.HasPrefix(field, "pts_time:") {
+					frame.time, _ = strconv.ParseFloat(strings.TrimPrefix(field, "pts_time:"), 64)
+				}
+			}
+			frames = append(frames, frame)
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && parts[0] == "lavfi.astats.Overall.Peak_level" && len(frames) > 0 {
+			if value, parseErr := strconv.ParseFloat(parts[1], 64); parseErr == nil {
+				frames[len(frames)-1].peak = value
+			}
+		}
+	}
+
+	events := make([]models.QCEvent, 0)
+	runStart := -1
+	maxPeak := -1000.0
+	endOfFrame := func(idx int) float64 {
+		if idx+1 < len(frames) {
+			return frames[idx+1].time
+		}
+		return duration
+	}
+	finishRun := func(lastIdx int) {
+		start := frames[runStart].time
+		end := endOfFrame(lastIdx)
+		if end-start >= minDuration {
+			events = append(events, newQCEvent(models.QC_DETECTOR_CLIPPING, start, end, fmt.Sprintf("audio peaking at %.2f dBFS for %.2fs", maxPeak, end-start)))
+		}
+		runStart = -1
+		maxPeak = -1000
+	}
+
+	for i, frame := range frames {
+		if frame.peak >= level {
+			if runStart < 0 {
+				runStart = i
+			}
+			if frame.peak > maxPeak {
+				maxPeak = frame.peak
+			}
+		} else if runStart >= 0 {
+			finishRun(i - 1)
+		}
+	}
+	if runStart >= 0 {
+		finishRun(len(frames) - 1)
+	}
+	return events
+}
+
+/**
+find out whether the file has video and audio, and how long it is
+*/
+func probeStreamTypes(fileName string) (bool, bool, float64, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-show_entries", "stream=codec_type:format=duration", "-of", "json", fileName)
+	outContent, _, runErr := RunCommand(cmd)
+	if runErr != nil {
+		return false, false, 0, runErr
+	}
+
+	var rawOutput struct {
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+		} `json:"streams"`
+		Format map[string]interface{} `json:"format"`
+	}
+	unmarshalErr := json.Unmarshal(outContent, &rawOutput)
+	if unmarshalErr != nil {
+		return false, false, 0, unmarshalErr
+	}
+	hasVideo := false
+	hasAudio := false
+	for _, stream := range rawOutput.Streams {
+		switch stream.CodecType {
+		case "video":
+			hasVideo = true
+		case "audio":
+			hasAudio = true
+		}
+	}
+	duration, durErr := safeParseFloat(rawOutput.Format, "duration", 0)
+	if durErr != nil {
+		return false, false, 0, errors.New("could not get the duration of the file")
+	}
+	return hasVideo, hasAudio, duration, nil
+}
+
+func formatQCNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+/**
+run the enabled QC detectors over the file in a single pass. Video detectors are skipped if there is no video and audio
+detectors if there is no audio. The events are returned without severities, they are worked out by the webapp
+*/
+func RunQC(fileName string, settings models.QCSettings) results.QCResult {
+	startTime := time.Now()
+
+	failed := func(msg string) results.QCResult {
+		log.Print(msg)
+		return results.QCResult{
+			MediaFile:    fileName,
+			ErrorMessage: msg,
+			TimeTaken:    float64(time.Now().UnixNano()-startTime.UnixNano()) / 1e9,
+		}
+	}
+
+	hasVideo, hasAudio, duration, probeErr := probeStreamTypes(fileName)
+	if probeErr != nil {
+		return failed(fmt.Sprintf("Could not get stream information for %s: %s", fileName, probeErr))
+	}
+
+	videoFilters := make([]string, 0)
+	audioFilters := make([]string, 0)
+	if black := settings.ForDetector(models.QC_DETECTOR_BLACK); black != nil && hasVideo {
+		videoFilters = append(videoFilters, fmt.Sprintf("blackdetect=d=%s:pix_th=%s", formatQCNumber(black.MinDuration), formatQCNumber(black.Level)))
+	}
+	if freeze := settings.ForDetector(models.QC_DETECTOR_FREEZE); freeze != nil && hasVideo {
+		videoFilters = append(videoFilters, fmt.Sprintf("freezedetect=n=%sdB:d=%s", formatQCNumber(freeze.Level), formatQCNumber(freeze.MinDuration)))
+	}
+	if silence := settings.ForDetector(models.QC_DETECTOR_SILENCE); silence != nil && hasAudio {
+		audioFilters = append(audioFilters, fmt.Sprintf("silencedetect=n=%sdB:d=%s", formatQCNumber(silence.Level), formatQCNumber(silence.MinDuration)))
+	}
+
+	clipping := settings.ForDetector(models.QC_DETECTOR_CLIPPING)
+	var peaksFileName string
+	if clipping != nil && hasAudio {
+		peaksFile, tempErr := ioutil.TempFile("", "qcpeaks")
+		if tempErr != nil {
+			return failed(fmt.Sprintf("Could not create a temporary file for audio peaks: %s", tempErr))
+		}
+		peaksFile.Close()
+		defer os.Remove(peaksFile.Name())
+		peaksFileName = peaksFile.Name()
+		audioFilters = append(audioFilters, "astats=metadata=1:reset=1", "ametadata=mode=print:key=lavfi.astats.Overall.Peak_level:file="+peaksFileName)
+	}
+
+	events := make([]models.QCEvent, 0)
+	if len(videoFilters) > 0 || len(audioFilters) > 0 {
+		args := []string{"-hide_banner", "-nostats", "-i", fileName}
+		if len(videoFilters) > 0 {
+			args = append(args, "-map", "0:v:0", "-vf", strings.Join(videoFilters, ","))
+		}
+		if len(audioFilters) > 0 {
+			args = append(args, "-map", "0:a:0", "-af", strings.Join(audioFilters, ","))
+		}
+		args = append(args, "-f", "null", "-")
+
+		_, errContent, runErr := RunCommand(exec.Command("ffmpeg", args...))
+		if runErr != nil {
+			return failed(fmt.Sprintf("Could not run QC detectors: %s", string(errContent)))
+		}
+		events = append(events, ParseDetectorOutput(errContent, duration)...)
+
+		if peaksFileName != "" {
+			peaksContent, readErr := ioutil.ReadFile(peaksFileName)
+			if readErr != nil {
+				return failed(fmt.Sprintf("Could not read audio peaks: %s", readErr))
+			}
+			events = append(events, ParseClippingOutput(peaksContent, clipping.Level, clipping.MinDuration, duration)...)
+		}
+	} else {
+		log.Printf("WARNING: none of the enabled QC detectors apply to %s", fileName)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start < events[j].Start
+	})
+
+	return results.QCResult{
+		MediaFile: fileName,
+		Duration:  duration,
+		Events:    events,
+		TimeTaken: float64(time.Now().UnixNano()-startTime.UnixNano()) / 1e9,
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"github.com/guardian/mediaflipper/common/models"
+	"testing"
+)
+
+func TestParseDetectorOutput(t *testing.T) {
+	stderr := []byte(`Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'test.mp4':
+  Duration: 00:01:00.00, start: 0.000000, bitrate: 1205 kb/s
+[blackdetect @ 0x5581c3a0b940] black_start:0 black_end:2.04 black_duration:2.04
+[silencedetect @ 0x5581c3a12c00] silence_start: -0.00133333
+[silencedetect @ 0x5581c3a12c00] silence_end: 3.5 | silence_duration: 3.50133
+[freezedetect @ 0x5581c3a0d5c0] lavfi.freezedetect.freeze_start: 20.52
+[freezedetect @ 0x5581c3a0d5c0] lavfi.freezedetect.freeze_duration: 4.48
+[freezedetect @ 0x5581c3a0d5c0] lavfi.freezedetect.freeze_end: 25
+[silencedetect @ 0x5581c3a12c00] silence_start: 55
+`)
+	events := ParseDetectorOutput(stderr, 60)
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d: %v", len(events), events)
+	}
+	if events[0].Detector != models.QC_DETECTOR_BLACK || events[0].End != 2.04 || events[0].EndTimecode != "00:00:02.040" {
+		t.Errorf("got unexpected black event %v", events[0])
+	}
+	if events[1].Detector != models.QC_DETECTOR_SILENCE || events[1].Start != 0 || events[1].End != 3.5 {
+		t.Errorf("got unexpected silence event %v", events[1])
+	}
+	if events[2].Detector != models.QC_DETECTOR_FREEZE || events[2].Start != 20.52 || events[2].StartTimecode != "00:00:20.520" {
+		t.Errorf("got unexpected freeze event %v", events[2])
+	}
+	if events[3].Detector != models.QC_DETECTOR_SILENCE || events[3].Start != 55 || events[3].End != 60 {
+		t.Errorf("silence at the end of the file should last until the end, got %v", events[3])
+	}
+}
+
+func TestParseClippingOutput(t *testing.T) {
+	output := []byte(`frame:0    pts:0       pts_time:0
+lavfi.astats.Overall.Peak_level=-6.020600
+frame:1    pts:1024    pts_time:0.021333
+lavfi.astats.Overall.Peak_level=0.000000
+frame:2    pts:2048    pts_time:0.042667
+lavfi.astats.Overall.Peak_level=-0.050000
+frame:3    pts:3072    pts_time:0.064
+lavfi.astats.Overall.Peak_level=-3.000000
+frame:4    pts:4096    pts_time:0.085333
+lavfi.astats.Overall.Peak_level=0.000000
+`)
+	events := ParseClippingOutput(output, -0.1, 0.01, 0.1)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 clipping events, got %d: %v", len(events), events)
+	}
+	if events[0].Start != 0.021333 || events[0].End != 0.064 {
+		t.Errorf("consecutive clipped frames should be joined, got %v", events[0])
+	}
+	if events[1].Start != 0.085333 || events[1].End != 0.1 {
+		t.Errorf("the last frame should last until the end of the file, got %v", events[1])
+	}
+
+	if len(ParseClippingOutput(output, -0.1, 0.03, 0.1)) != 1 {
+		t.Error("clipping shorter than the minimum duration should be dropped")
+	}
+}
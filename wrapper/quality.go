@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/common/results"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+/**
+parses the stats file written by ffmpeg's psnr filter, returning the average PSNR of each frame in order.
+frames that are identical to the source have an infinite PSNR, these are given MAX_PSNR instead
+*/
+func ParsePSNRStats(content []byte) []float64 {
+	return parseFrameStatsField(content, "psnr_avg:", func(value string) (float64, error) {
+		if value == "inf" {
+			return models.MAX_PSNR, nil
+		}
+		psnr, parseErr := strconv.ParseFloat(value, 64)
+		return math.Min(psnr, models.MAX_PSNR), parseErr
+	})
+}
+
+/**
+parses the stats file written by ffmpeg's ssim filter, returning the overall SSIM of each frame in order
+*/
+func ParseSSIMStats(content []byte) []float64 {
+	return parseFrameStatsField(content, "All:", func(value string) (float64, error) {
+		return strconv.ParseFloat(value, 64)
+	})
+}
+
+func parseFrameStatsField(content []byte, prefix string, parse func(string) (float64, error)) []float64 {
+	values := make([]float64, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		for _, field := range strings.Fields(scanner.Text()) {
+			if strings.HasPrefix(field, prefix) {
+				value, parseErr := parse(strings.TrimPrefix(field, prefix))
+				if parseErr != nil {
+					log.Printf("WARNING: could not parse %s from '%s': %s", prefix, scanner.Text(), parseErr)
+				} else {
+					values = append(values, value)
+				}
+			}
+		}
+	}
+	return values
+}
+
+/**
+parses the json log written by ffmpeg's libvmaf filter, returning the VMAF score of each frame in order
+*/
+func ParseVMAFLog(content []byte) ([]float64, error) {
+	var vmafLog struct {
+		Frames []struct {
+			FrameNum int                `json:"frameNum"`
+			Metrics  map[string]float64 `json:"metrics"`
+		} `json:"frames"`
+	}
+	unmarshalErr := json.Unmarshal(content, &vmafLog)
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	values := make([]float64, len(vmafLog.Frames))
+	for i, frame := range vmafLog.Frames {
+		score, haveScore := frame.Metrics["vmaf"]
+		if !haveScore {
+			return nil, errors.New(fmt.Sprintf("frame %d of the VMAF log has no vmaf score", frame.FrameNum))
+		}
+		values[i] = score
+	}
+	return values, nil
+}
+
+func meanOf(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	mean := total / float64(len(values))
+	return &mean
+}
+
+func sliceOrEmpty(values []float64, start int, end int) []float64 {
+	if start >= len(values) {
+		return nil
+	}
+	if end > len(values) {
+		end = len(values)
+	}
+	return values[start:end]
+}
+
+/**
+works out the aggregate scores and the scores of each segment from the per-frame scores of each metric.
+metrics that were not measured should be passed as nil
+*/
+func SummariseQuality(psnr []float64, ssim []float64, vmaf []float64, frameRate float64, segmentSeconds float64) (models.QualityScores, []models.QualitySegment) {
+	aggregate := models.QualityScores{
+		PSNR: meanOf(psnr),
+		SSIM: meanOf(ssim),
+		VMAF: meanOf(vmaf),
+	}
+
+	frameCount := len(psnr)
+	if len(ssim) > frameCount {
+		frameCount = len(ssim)
+	}
+	if len(vmaf) > frameCount {
+		frameCount = len(vmaf)
+	}
+	framesPerSegment := int(math.Round(frameRate * segmentSeconds))
+	if framesPerSegment < 1 {
+		framesPerSegment = 1
+	}
+
+	segments := make([]models.QualitySegment, 0)
+	for start := 0; start < frameCount; start += framesPerSegment {
+		end := start + framesPerSegment
+		segmentEnd := float64(end) / frameRate
+		if end > frameCount {
+			segmentEnd = float64(frameCount) / frameRate
+		}
+		segments = append(segments, models.QualitySegment{
+			Start: float64(start) / frameRate,
+			End:   segmentEnd,
+			Scores: models.QualityScores{
+				PSNR: meanOf(sliceOrEmpty(psnr, start, end)),
+				SSIM: meanOf(sliceOrEmpty(ssim, start, end)),
+				VMAF: meanOf(sliceOrEmpty(vmaf, start, end)),
+			},
+		})
+	}
+	return aggregate, segments
+}
+
+/**
+returns true if the ffmpeg build includes the given filter
+*/
+func ffmpegHasFilter(filterName string) bool {
+	outContent, _, runErr := RunCommand(exec.Command("ffmpeg", "-hide_banner", "-filters"))
+	if runErr != nil {
+		log.Printf("WARNING: could not list the ffmpeg filters: %s", runErr)
+		return false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(outContent))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 1 && fields[1] == filterName {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+get the frame rate of the first video stream
+*/
+func probeFrameRate(fileName string) (float64, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0", "-show_entries", "stream=r_frame_rate", "-of", "default=noprint_wrappers=1:nokey=1", fileName)
+	outContent, _, runErr := RunCommand(cmd)
+	if runErr != nil {
+		return 0, runErr
+	}
+	parts := strings.SplitN(strings.TrimSpace(string(outContent)), "/", 2)
+	numerator, numErr := strconv.ParseFloat(parts[0], 64)
+	if numErr != nil {
+		return 0, numErr
+	}
+	denominator := 1.0
+	if len(parts) == 2 {
+		var denErr error
+		denominator, denErr = strconv.ParseFloat(parts[1], 64)
+		if denErr != nil {
+			return 0, denErr
+		}
+	}
+	if numerator <= 0 || denominator <= 0 {
+		return 0, errors.New(fmt.Sprintf("invalid frame rate %s", string(outContent)))
+	}
+	return numerator / denominator, nil
+}
+
+func qualityTempFile(prefix string) (string, error) {
+	tempFile, tempErr := ioutil.TempFile("", prefix)
+	if tempErr != nil {
+		return "", tempErr
+	}
+	tempFile.Close()
+	return tempFile.Name(), nil
+}
+
+/**
+compare the transcoded file with its source using the metrics in the settings. The transcode is scaled up to the size
+of the source, and the source is brought to the frame rate of the transcode, so that the frames line up.
+any problem with the comparison is recorded in the ErrorMessage of the result rather than failing the transcode
+*/
+func MeasureQuality(sourceFile string, transcodedFile string, settings models.QualityMetricSettings) *models.QualityMetrics {
+	settings = settings.WithDefaults()
+	result := &models.QualityMetrics{ComparedFile: transcodedFile}
+
+	failed := func(msg string) *models.QualityMetrics {
+		log.Print(msg)
+		result.ErrorMessage = msg
+		return result
+	}
+
+	sourceWidth, sourceHeight, _, probeErr := probeVideoGeometry(sourceFile)
+	if probeErr != nil {
+		return failed(fmt.Sprintf("Could not get video information for %s: %s", sourceFile, probeErr))
+	}
+	frameRate, rateErr := probeFrameRate(transcodedFile)
+	if rateErr != nil {
+		return failed(fmt.Sprintf("Could not get the frame rate of %s: %s", transcodedFile, rateErr))
+	}
+
+	type metricPass struct {
+		name     string
+		filter   string
+		statFile string
+	}
+	passes := make([]metricPass, 0)
+	for _, metric := range []string{models.QUALITY_METRIC_PSNR, models.QUALITY_METRIC_SSIM, models.QUALITY_METRIC_VMAF} {
+		if !settings.Wants(metric) {
+			continue
+		}
+		if metric == models.QUALITY_METRIC_VMAF {
+			result.VMAFAvailable = ffmpegHasFilter("libvmaf")
+			if !result.VMAFAvailable {
+				log.Printf("WARNING: this ffmpeg build does not support VMAF, it won't be measured")
+				continue
+			}
+		}
+		statFile, tempErr := qualityTempFile("quality" + metric)
+		if tempErr != nil {
+			return failed(fmt.Sprintf("Could not create a temporary file for %s: %s", metric, tempErr))
+		}
+		defer os.Remove(statFile)
+
+		var filter string
+		switch metric {
+		case models.QUALITY_METRIC_PSNR:
+			filter = "psnr=stats_file=" + statFile
+		case models.QUALITY_METRIC_SSIM:
+			filter = "ssim=stats_file=" + statFile
+		case models.QUALITY_METRIC_VMAF:
+			filter = "libvmaf=log_fmt=json:log_path=" + statFile
+		}
+		passes = append(passes, metricPass{name: metric, filter: filter, statFile: statFile})
+	}
+	if len(passes) == 0 {
+		return failed("None of the requested quality metrics can be measured")
+	}
+
+	//the transcode is the first input and the source is the second, which is the order that libvmaf expects
+	distortedLabels := ""
+	referenceLabels := ""
+	metricFilters := make([]string, len(passes))
+	for i, pass := range passes {
+		distortedLabels += fmt.Sprintf("[d%d]", i)
+		referenceLabels += fmt.Sprintf("[r%d]", i)
+		metricFilters[i] = fmt.Sprintf("[d%d][r%d]%s", i, i, pass.filter)
+	}
+	filterGraph := fmt.Sprintf("[0:v]scale=%d:%d:flags=bicubic,setpts=PTS-STARTPTS,split=%d%s;[1:v]fps=%s,setpts=PTS-STARTPTS,split=%d%s;%s",
+		sourceWidth, sourceHeight, len(passes), distortedLabels,
+		strconv.FormatFloat(frameRate, 'f', -1, 64), len(passes), referenceLabels,
+		strings.Join(metricFilters, ";"))
+
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-nostats", "-i", transcodedFile, "-i", sourceFile, "-lavfi", filterGraph, "-f", "null", "-")
+	_, errContent, runErr := RunCommand(cmd)
+	if runErr != nil {
+		return failed(fmt.Sprintf("Could not compare %s with %s: %s", transcodedFile, sourceFile, string(errContent)))
+	}
+
+	var psnr, ssim, vmaf []float64
+	for _, pass := range passes {
+		content, readErr := ioutil.ReadFile(pass.statFile)
+		if readErr != nil {
+			return failed(fmt.Sprintf("Could not read the %s results: %s", pass.name, readErr))
+		}
+		switch pass.name {
+		case models.QUALITY_METRIC_PSNR:
+			psnr = ParsePSNRStats(content)
+		case models.QUALITY_METRIC_SSIM:
+			ssim = ParseSSIMStats(content)
+		case models.QUALITY_METRIC_VMAF:
+			var parseErr error
+			vmaf, parseErr = ParseVMAFLog(content)
+			if parseErr != nil {
+				return failed(fmt.Sprintf("Could not understand the VMAF results: %s", parseErr))
+			}
+		}
+	}
+
+	result.Aggregate, result.Segments = SummariseQuality(psnr, ssim, vmaf, frameRate, settings.SegmentSeconds)
+	return result
+}
+
+/**
+measure the quality of a successful transcode and add it to the result. Multi-rendition transcodes are measured on the
+first rendition, which is normally the highest quality. Streaming packages can't be measured
+*/
+func addQualityMetrics(sourceFile string, result *results.TranscodeResult, settings models.QualityMetricSettings) {
+	if result.ErrorMessage != "" {
+		return
+	}
+	var transcodedFile string
+	if result.OutFile != "" {
+		transcodedFile = result.OutFile
+	} else if len(result.Renditions) > 0 {
+		transcodedFile = result.Renditions[0].OutFile
+	} else {
+		result.Quality = &models.QualityMetrics{ErrorMessage: "quality can't be measured for streaming packages"}
+		return
+	}
+	log.Printf("INFO: measuring the quality of %s against %s", transcodedFile, sourceFile)
+	result.Quality = MeasureQuality(sourceFile, transcodedFile, settings)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/guardian/mediaflipper/common/models"
+	"testing"
+)
+
+func TestParsePSNRStats(t *testing.T) {
+	content := []byte(`n:1 mse_avg:0.52 mse_y:0.61 mse_u:0.31 mse_v:0.33 psnr_avg:50.95 psnr_y:50.27 psnr_u:53.22 psnr_v:52.93
+n:2 mse_avg:0.00 mse_y:0.00 mse_u:0.00 mse_v:0.00 psnr_avg:inf psnr_y:inf psnr_u:inf psnr_v:inf
+n:3 mse_avg:4.10 mse_y:4.90 mse_u:2.41 mse_v:2.52 psnr_avg:42.00 psnr_y:41.23 psnr_u:44.31 psnr_v:44.11
+`)
+	values := ParsePSNRStats(content)
+	if len(values) != 3 || values[0] != 50.95 || values[1] != models.MAX_PSNR || values[2] != 42 {
+		t.Errorf("got unexpected PSNR values %v", values)
+	}
+}
+
+func TestParseSSIMStats(t *testing.T) {
+	content := []byte(`n:1 Y:0.995421 U:0.993102 V:0.992841 All:0.994581 (22.658941)
+n:2 Y:0.901234 U:0.950000 V:0.950000 All:0.917489 (10.821234)
+`)
+	values := ParseSSIMStats(content)
+	if len(values) != 2 || values[0] != 0.994581 || values[1] != 0.917489 {
+		t.Errorf("got unexpected SSIM values %v", values)
+	}
+}
+
+func TestParseVMAFLog(t *testing.T) {
+	content := []byte(`{"version": "2.3.1", "fps": 120.5,
+"frames": [{"frameNum": 0, "metrics": {"integer_adm2": 0.98, "vmaf": 95.5}}, {"frameNum": 1, "metrics": {"vmaf": 91.25}}],
+"pooled_metrics": {"vmaf": {"min": 91.25, "max": 95.5, "mean": 93.375}}}`)
+	values, err := ParseVMAFLog(content)
+	if err != nil {
+		t.Fatalf("ParseVMAFLog failed unexpectedly: %s", err)
+	}
+	if len(values) != 2 || values[0] != 95.5 || values[1] != 91.25 {
+		t.Errorf("got unexpected VMAF values %v", values)
+	}
+
+	_, noScoreErr := ParseVMAFLog([]byte(`{"frames": [{"frameNum": 0, "metrics": {"psnr_y": 40}}]}`))
+	if noScoreErr == nil {
+		t.Error("a log without vmaf scores should give an error")
+	}
+}
+
+func TestSummariseQuality(t *testing.T) {
+	psnr := []float64{40, 42, 30, 32, 50}
+	ssim := []float64{0.9, 1, 0.8, 0.8, 0.95}
+	aggregate, segments := SummariseQuality(psnr, ssim, nil, 2, 1)
+
+	if aggregate.PSNR == nil || *aggregate.PSNR != 38.8 || aggregate.VMAF != nil {
+		t.Errorf("got unexpected aggregate scores %v", aggregate)
+	}
+	if len(segments) != 3 {
+		t.Fatalf("expected 3 segments of 2 frames, got %d", len(segments))
+	}
+	if *segments[0].Scores.PSNR != 41 || *segments[0].Scores.SSIM != 0.95 || segments[0].End != 1 {
+		t.Errorf("got unexpected first segment %v", segments[0])
+	}
+	if *segments[2].Scores.PSNR != 50 || segments[2].Start != 2 || segments[2].End != 2.5 {
+		t.Errorf("the last segment should only cover the last frame, got %v", segments[2])
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/guardian/mediaflipper/common/models"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SPRITE_SHEET_PATTERN = "sprites_%03d.jpg"
+
+/**
+get the dimensions of the first video stream and the duration of the file, which are needed to lay out the sprite sheets
+*/
+func probeVideoGeometry(fileName string) (int32, int32, float64, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0", "-show_entries", "stream=width,height:format=duration", "-of", "json", fileName)
+	outContent, _, runErr := RunCommand(cmd)
+	if runErr != nil {
+		return 0, 0, 0, runErr
+	}
+
+	var rawOutput struct {
+		Streams []struct {
+			Width  int32 `json:"width"`
+			Height int32 `json:"height"`
+		} `json:"streams"`
+		Format map[string]interface{} `json:"format"`
+	}
+	unmarshalErr := json.Unmarshal(outContent, &rawOutput)
+	if unmarshalErr != nil {
+		return 0, 0, 0, unmarshalErr
+	}
+	if len(rawOutput.Streams) == 0 || rawOutput.Streams[0].Width == 0 || rawOutput.Streams[0].Height == 0 {
+		return 0, 0, 0, errors.New("the file has no video to take frames from")
+	}
+	duration, durErr := safeParseFloat(rawOutput.Format, "duration", 0)
+	if durErr != nil || duration <= 0 {
+		return 0, 0, 0, errors.New("could not get the duration of the file")
+	}
+	return rawOutput.Streams[0].Width, rawOutput.Streams[0].Height, duration, nil
+}
+
+/**
+returns the height of a tile of the given width that keeps the aspect ratio of the video. ffmpeg needs this to be even
+*/
+func spriteTileHeight(tileWidth int32, videoWidth int32, videoHeight int32) int32 {
+	height := int32(math.Round(float64(tileWidth)*float64(videoHeight)/float64(videoWidth)/2)) * 2
+	if height < 2 {
+		return 2
+	}
+	return height
+}
+
+/**
+builds the WebVTT index for a set of sprite sheets. There is one cue for each frame, covering the time from that frame
+to the next one, which points to the frame's tile as a media fragment of its sheet, e.g. sprites_001.jpg#xywh=160,0,160,90.
+the sheet names are given relative to the index
+*/
+func BuildSpriteIndex(settings models.SpriteSheetSettings, duration float64, tileHeight int32, sheetNames []string) string {
+	settings = settings.WithDefaults()
+	perSheet := settings.TilesPerSheet()
+	frameCount := int(math.Ceil(duration / settings.IntervalSeconds))
+	if frameCount > perSheet*len(sheetNames) {
+		frameCount = perSheet * len(sheetNames)
+	}
+
+	var builder strings.Builder
+	builder.WriteString("WEBVTT\n")
+	for i := 0; i < frameCount; i++ {
+		startTime := float64(i) * settings.IntervalSeconds
+		endTime := math.Min(startTime+settings.IntervalSeconds, duration)
+		positionOnSheet := int32(i % perSheet)
+		x := (positionOnSheet % settings.Columns) * settings.TileWidth
+		y := (positionOnSheet / settings.Columns) * tileHeight
+
+		builder.WriteString(fmt.Sprintf("\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n", FormatTimestamp(startTime), FormatTimestamp(endTime), sheetNames[i/perSheet], x, y, settings.TileWidth, tileHeight))
+	}
+	return builder.String()
+}
+
+/**
+take frames at a regular interval through the file and tile them into sprite sheets, then write a WebVTT index for them.
+everything is written into a directory of its own so that the index can refer to the sheets by relative path.
+*/
+func RunSpriteSheet(fileName string, maybeOutPath string, jobContainerId string, settings models.SpriteSheetSettings) *ThumbnailResult {
+	startTime := time.Now()
+	settings = settings.WithDefaults()
+
+	failed := func(msg string) *ThumbnailResult {
+		log.Print(msg)
+		duration := time.Now().UnixNano() - startTime.UnixNano()
+		return &ThumbnailResult{
+			ErrorMessage: &msg,
+			TimeTaken:    float64(duration) / 1e9,
+		}
+	}
+
+	videoWidth, videoHeight, duration, probeErr := probeVideoGeometry(fileName)
+	if probeErr != nil {
+		return failed(fmt.Sprintf("Could not get video information for %s: %s", fileName, probeErr))
+	}
+	tileHeight := spriteTileHeight(settings.TileWidth, videoWidth, videoHeight)
+
+	outDir := GetOutputPackageDir(maybeOutPath, fileName, jobContainerId, "sprites")
+	mkdirErr := os.MkdirAll(outDir, 0777)
+	if mkdirErr != nil {
+		return failed(fmt.Sprintf("Could not create output directory %s: %s", outDir, mkdirErr))
+	}
+
+	filterString := fmt.Sprintf("fps=1/%s,scale=%d:%d,tile=%dx%d",
+		strconv.FormatFloat(settings.IntervalSeconds, 'f', -1, 64), settings.TileWidth, tileHeight, settings.Columns, settings.Rows)
+	cmd := exec.Command("ffmpeg", "-i", fileName, "-an", "-sn", "-vf", filterString, "-vsync", "vfr", "-q:v", "5", "-y", path.Join(outDir, SPRITE_SHEET_PATTERN))
+	_, errContent, runErr := RunCommand(cmd)
+	provenance := newProvenance(cmd, settings, startTime)
+	if runErr != nil {
+		result := failed(fmt.Sprintf("Could not make sprite sheets: %s", string(errContent)))
+		result.Provenance = provenance
+		return result
+	}
+
+	sheetPaths, globErr := filepath.Glob(path.Join(outDir, "sprites_*.jpg"))
+	if globErr != nil || len(sheetPaths) == 0 {
+		return failed(fmt.Sprintf("ffmpeg did not output any sprite sheets to %s", outDir))
+	}
+	sort.Strings(sheetPaths)
+	sheetNames := make([]string, len(sheetPaths))
+	for i, sheetPath := range sheetPaths {
+		sheetNames[i] = path.Base(sheetPath)
+	}
+
+	indexPath := path.Join(outDir, models.SPRITE_INDEX_NAME)
+	writeErr := ioutil.WriteFile(indexPath, []byte(BuildSpriteIndex(settings, duration, tileHeight, sheetNames)), 0777)
+	if writeErr != nil {
+		return failed(fmt.Sprintf("Could not write sprite index %s: %s", indexPath, writeErr))
+	}
+
+	for _, outputFile := range append(sheetPaths, indexPath) {
+		chmodErr := os.Chmod(outputFile, 0777)
+		if chmodErr != nil {
+			log.Printf("WARNING: could not open permissions on %s: %s", outputFile, chmodErr)
+		}
+	}
+
+	timeTaken := time.Now().UnixNano() - startTime.UnixNano()
+	return &ThumbnailResult{
+		TimeTaken:    float64(timeTaken) / 1e9,
+		SpriteSheets: sheetPaths,
+		SpriteIndex:  &indexPath,
+		Provenance:   provenance,
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/guardian/mediaflipper/common/models"
+	"strings"
+	"testing"
+)
+
+func TestBuildSpriteIndex(t *testing.T) {
+	settings := models.SpriteSheetSettings{IntervalSeconds: 10, Columns: 2, Rows: 2, TileWidth: 160}
+	result := BuildSpriteIndex(settings, 45, 90, []string{"sprites_001.jpg", "sprites_002.jpg"})
+
+	expected := `WEBVTT
+
+00:00:00.000 --> 00:00:10.000
+sprites_001.jpg#xywh=0,0,160,90
+
+00:00:10.000 --> 00:00:20.000
+sprites_001.jpg#xywh=160,0,160,90
+
+00:00:20.000 --> 00:00:30.000
+sprites_001.jpg#xywh=0,90,160,90
+
+00:00:30.000 --> 00:00:40.000
+sprites_001.jpg#xywh=160,90,160,90
+
+00:00:40.000 --> 00:00:45.000
+sprites_002.jpg#xywh=0,0,160,90
+`
+	if result != expected {
+		t.Errorf("got unexpected index:\n%s", result)
+	}
+
+	//if ffmpeg gave fewer sheets than expected then there should not be cues pointing to sheets that don't exist
+	truncated := BuildSpriteIndex(settings, 45, 90, []string{"sprites_001.jpg"})
+	if strings.Count(truncated, "-->") != 4 || strings.Contains(truncated, "sprites_002.jpg") {
+		t.Errorf("index should only refer to the sheets that exist, got:\n%s", truncated)
+	}
+}
+
+func TestSpriteTileHeight(t *testing.T) {
+	if spriteTileHeight(160, 1920, 1080) != 90 {
+		t.Errorf("expected 90 for 16:9, got %d", spriteTileHeight(160, 1920, 1080))
+	}
+	if spriteTileHeight(160, 720, 576) != 128 {
+		t.Errorf("expected 128 for 5:4, got %d", spriteTileHeight(160, 720, 576))
+	}
+	if spriteTileHeight(160, 1080, 1920)%2 != 0 {
+		t.Error("tile height should always be even")
+	}
+}
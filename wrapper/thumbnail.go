@@ -1,20 +1,24 @@
 package main
 
 import (
-	"fmt"
 	"github.com/guardian/mediaflipper/common/models"
 	"log"
 	"os"
 	"os/exec"
+	"strconv"
 	"time"
 )
 
-func RunVideoThumbnail(fileName string, outPath string, atFrame int) *ThumbnailResult {
+func RunVideoThumbnail(fileName string, outPath string, atSeconds float64) *ThumbnailResult {
 	outFileName := GetOutputFilenameThumb(outPath, fileName)
 
-	cmd := exec.Command("ffmpeg", "-i", fileName, "-vframes", "1", "-an", "-y", "-ss", fmt.Sprint(atFrame), outFileName)
+	cmd := exec.Command("ffmpeg", "-i", fileName, "-vframes", "1", "-an", "-y", "-ss", strconv.FormatFloat(atSeconds, 'f', -1, 64), outFileName)
 
-	return runThumbnailWrapper(cmd, outFileName)
+	result := runThumbnailWrapper(cmd, nil, outFileName)
+	result.FrameSeconds = &atSeconds
+	result.FrameTimecode = FormatTimestamp(atSeconds)
+	result.FrameSelection = models.THUMBNAIL_MODE_FIXED
+	return result
 }
 
 func RunImageThumbnail(fileName string, outPath string, settings models.TranscodeTypeSettings) *ThumbnailResult {
@@ -57,14 +61,14 @@ func RunImageThumbnail(fileName string, outPath string, settings models.Transcod
 	commandArgs = append(commandArgs, outFileName)
 	cmd := exec.Command("/usr/bin/convert", commandArgs...)
 
-	result := runThumbnailWrapper(cmd, outFileName)
+	result := runThumbnailWrapper(cmd, settings, outFileName)
 	if removeOnSuccess && result.ErrorMessage == nil {
 		os.Remove(updatedFileName)
 	}
 	return result
 }
 
-func runThumbnailWrapper(cmd *exec.Cmd, outFileName string) *ThumbnailResult {
+func runThumbnailWrapper(cmd *exec.Cmd, settings models.TranscodeTypeSettings, outFileName string) *ThumbnailResult {
 	startTime := time.Now()
 	_, errContent, err := RunCommand(cmd)
 
@@ -84,6 +88,7 @@ func runThumbnailWrapper(cmd *exec.Cmd, outFileName string) *ThumbnailResult {
 			OutPath:      nil,
 			ErrorMessage: &errContentString,
 			TimeTaken:    float64(duration) / 1e9,
+			Provenance:   newProvenance(cmd, settings, startTime),
 		}
 	}
 
@@ -91,5 +96,6 @@ func runThumbnailWrapper(cmd *exec.Cmd, outFileName string) *ThumbnailResult {
 		OutPath:      &outFileName,
 		ErrorMessage: nil,
 		TimeTaken:    float64(duration) / 1e9,
+		Provenance:   newProvenance(cmd, settings, startTime),
 	}
 }
@@ -1,7 +1,16 @@
 package main
 
+import "github.com/guardian/mediaflipper/common/models"
+
 type ThumbnailResult struct {
-	OutPath      *string `json:"outPath"`
-	ErrorMessage *string `json:"errorMessage"`
-	TimeTaken    float64 `json:"timeTaken"`
+	OutPath        *string              `json:"outPath"`
+	ErrorMessage   *string              `json:"errorMessage"`
+	TimeTaken      float64              `json:"timeTaken"`
+	SpriteSheets   []string             `json:"spriteSheets"` //only set for sprite sheet thumbnails, in which case OutPath is nil
+	SpriteIndex    *string              `json:"spriteIndex"`
+	FrameSeconds   *float64             `json:"frameSeconds"`   //the time the thumbnail was taken from, for video thumbnails
+	FrameTimecode  string               `json:"frameTimecode"`  //the same time as HH:MM:SS.mmm
+	FrameSelection string               `json:"frameSelection"` //how the frame was chosen, "smart" or "fixed"
+	Checksums      models.FileChecksums `json:"checksums"`      //for each file that was written
+	Provenance     *models.Provenance   `json:"provenance"`
 }
@@ -4,12 +4,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/davecgh/go-spew/spew"
 	"github.com/google/uuid"
 	"github.com/guardian/mediaflipper/common/models"
 	"github.com/guardian/mediaflipper/common/results"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"strings"
 	"time"
 )
@@ -31,7 +34,13 @@ func ParseSettings(rawString string) (models.TranscodeTypeSettings, error) {
 		return imgSettings, nil
 	}
 
-	return nil, errors.New(fmt.Sprintf("could not translate settings: %s and %s", marshalErr, imgMarshalErr))
+	var audioSettings models.TranscodeAudioSettings
+	audioMarshalErr := json.Unmarshal([]byte(rawString), &audioSettings)
+	if audioMarshalErr == nil && audioSettings.IsValid() {
+		return audioSettings, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("could not translate settings: %s, %s and %s", marshalErr, imgMarshalErr, audioMarshalErr))
 }
 
 /**
@@ -68,29 +77,133 @@ func monitorOutput(stdOutChan chan string, stdErrChan chan string, closeChan cha
 	}
 }
 
-func RunTranscode(fileName string, maybeOutPath string, settings models.TranscodeTypeSettings, jobContainerId uuid.UUID, jobStepId uuid.UUID) results.TranscodeResult {
-	outFileName := GetOutputFileTransc(maybeOutPath, fileName, settings.GetLikelyExtension())
+/**
+build the ffmpeg arguments for a settings object that has renditions. Every rendition is output from the same command,
+so the input only has to be read and decoded once.
+returns the arguments and the (not yet existing) output files that they will produce
+*/
+func buildRenditionArgs(fileName string, maybeOutPath string, settings models.JobSettings) ([]string, []results.RenditionResult) {
+	commandArgs := []string{"-i", fileName}
+	outputs := make([]results.RenditionResult, len(settings.Renditions))
+	for i, rendition := range settings.Renditions {
+		outFileName := GetOutputFileRendition(maybeOutPath, fileName, rendition.Name, settings.GetLikelyExtension())
+		log.Printf("INFO: RunTranscode output file for %s is %s", rendition.Name, outFileName)
+		commandArgs = append(commandArgs, settings.RenditionToArray(rendition)...)
+		commandArgs = append(commandArgs, "-y", outFileName)
+		outputs[i] = results.RenditionResult{
+			Name:    rendition.Name,
+			OutFile: outFileName,
+		}
+	}
+	return commandArgs, outputs
+}
 
-	log.Printf("INFO: RunTranscode output file is %s", outFileName)
+/**
+build the ffmpeg arguments for a settings object that has packaging, and create the directories for each package.
+As with renditions, every package is output from the same command.
+returns the arguments and the manifests that they will produce, or an error if a directory could not be created
+*/
+func buildPackageArgs(fileName string, maybeOutPath string, settings models.JobSettings, jobContainerId uuid.UUID) ([]string, []results.PackageResult, error) {
 	commandArgs := []string{"-i", fileName}
-	commandArgs = append(commandArgs, settings.MarshalToArray()...)
-	commandArgs = append(commandArgs, "-y", outFileName)
+	packages := make([]results.PackageResult, len(settings.Wrapper.Packaging.Formats))
+	for i, format := range settings.Wrapper.Packaging.Formats {
+		outDir := GetOutputPackageDir(maybeOutPath, fileName, jobContainerId.String(), format)
+		mkdirErr := os.MkdirAll(outDir, 0777)
+		if mkdirErr != nil {
+			log.Printf("Could not create output directory %s: %s", outDir, mkdirErr)
+			return nil, nil, mkdirErr
+		}
+		manifestFile := path.Join(outDir, models.PackageManifestName(format))
+		log.Printf("INFO: RunTranscode %s manifest is %s", format, manifestFile)
+		commandArgs = append(commandArgs, settings.PackageToArray(format, outDir)...)
+		commandArgs = append(commandArgs, "-y", manifestFile)
+		packages[i] = results.PackageResult{
+			Format:       format,
+			ManifestFile: manifestFile,
+		}
+	}
+	return commandArgs, packages, nil
+}
+
+/**
+run a transcode with the given settings, using `runTranscode` (normally RunTranscode). Video and audio settings can be
+transcoded, image settings are for thumbnails. If `qualitySettings` is set then the output is compared with the source
+afterwards, for video only.
+*/
+func TranscodeForSettings(fileName string, maybeOutPath string, settings models.TranscodeTypeSettings, qualitySettings *models.QualityMetricSettings, jobContainerId uuid.UUID, jobStepId uuid.UUID, runTranscode func(string, string, models.TranscodeTypeSettings, uuid.UUID, uuid.UUID) results.TranscodeResult) results.TranscodeResult {
+	switch settings.(type) {
+	case models.JobSettings:
+		result := runTranscode(fileName, maybeOutPath, settings, jobContainerId, jobStepId)
+		log.Print("Got transcode result: ", result)
+		if qualitySettings != nil {
+			addQualityMetrics(fileName, &result, *qualitySettings)
+		}
+		return result
+	case models.TranscodeAudioSettings:
+		result := runTranscode(fileName, maybeOutPath, settings, jobContainerId, jobStepId)
+		log.Print("Got transcode result: ", result)
+		return result
+	default:
+		log.Printf("Could not recognise settings type for %s", spew.Sdump(settings))
+		return results.TranscodeResult{
+			OutFile:      "",
+			TimeTaken:    0,
+			ErrorMessage: "could not recognise settings as valid for a transcode operation. Maybe you meant thumbnail?",
+		}
+	}
+}
+
+func RunTranscode(fileName string, maybeOutPath string, settings models.TranscodeTypeSettings, jobContainerId uuid.UUID, jobStepId uuid.UUID) results.TranscodeResult {
+	var outFileName string
+	var commandArgs []string
+	var renditions []results.RenditionResult
+	var packages []results.PackageResult
+
+	settings, sourceLoudness := prepareLoudnessNormalisation(fileName, settings)
+
+	avSettings, isAv := settings.(models.JobSettings)
+	if isAv && avSettings.IsPackaged() {
+		var buildErr error
+		commandArgs, packages, buildErr = buildPackageArgs(fileName, maybeOutPath, avSettings, jobContainerId)
+		if buildErr != nil {
+			return results.TranscodeResult{
+				OutFile:      "",
+				TimeTaken:    0,
+				ErrorMessage: fmt.Sprintf("Could not create output directory: %s", buildErr),
+			}
+		}
+	} else if isAv && avSettings.HasRenditions() {
+		commandArgs, renditions = buildRenditionArgs(fileName, maybeOutPath, avSettings)
+	} else {
+		outFileName = GetOutputFileTransc(maybeOutPath, fileName, settings.GetLikelyExtension())
+
+		log.Printf("INFO: RunTranscode output file is %s", outFileName)
+		commandArgs = []string{"-i", fileName}
+		commandArgs = append(commandArgs, settings.MarshalToArray()...)
+		commandArgs = append(commandArgs, "-y", outFileName)
+	}
 
 	startTime := time.Now()
 
 	cmd := exec.Command("/usr/bin/ffmpeg", commandArgs...)
 
+	//every outcome from here on records how it was made, so that failures can be looked into as well
+	withProvenance := func(result results.TranscodeResult) results.TranscodeResult {
+		result.Provenance = newProvenance(cmd, settings, startTime)
+		return result
+	}
+
 	closeChan := make(chan bool)
 	stdOutChan, stdErrChan, runErr := RunCommandStreaming(cmd)
 	if runErr != nil {
 		endTime := time.Now()
 		duration := endTime.UnixNano() - startTime.UnixNano()
 		log.Printf("Could not execute command: %s", runErr)
-		return results.TranscodeResult{
+		return withProvenance(results.TranscodeResult{
 			OutFile:      "",
 			TimeTaken:    float64(duration) / 1e9,
 			ErrorMessage: fmt.Sprintf("Could not execute command: %s", runErr),
-		}
+		})
 	}
 
 	go monitorOutput(stdOutChan, stdErrChan, closeChan, jobContainerId, jobStepId)
@@ -103,31 +216,107 @@ func RunTranscode(fileName string, maybeOutPath string, settings models.Transcod
 	duration := endTime.UnixNano() - startTime.UnixNano()
 	if waitErr != nil {
 		log.Printf("Could not execute command: %s", waitErr)
-		return results.TranscodeResult{
+		return withProvenance(results.TranscodeResult{
 			OutFile:      "",
 			TimeTaken:    float64(duration) / 1e9,
 			ErrorMessage: fmt.Sprintf("Could not execute command: %s", waitErr),
+		})
+	}
+
+	if packages != nil {
+		for _, pkg := range packages {
+			checkErr := checkPackageOutput(pkg.ManifestFile)
+			if checkErr != nil {
+				return withProvenance(results.TranscodeResult{
+					OutFile:      "",
+					TimeTaken:    float64(duration) / 1e9,
+					ErrorMessage: fmt.Sprintf("Package %s: %s", pkg.Format, checkErr),
+				})
+			}
 		}
+		return withProvenance(results.TranscodeResult{
+			OutFile:        "",
+			TimeTaken:      float64(duration) / 1e9,
+			ErrorMessage:   "",
+			Packages:       packages,
+			SourceLoudness: sourceLoudness,
+		})
 	}
 
+	if renditions != nil {
+		for _, rendition := range renditions {
+			checkErr := checkOutputFile(rendition.OutFile)
+			if checkErr != nil {
+				return withProvenance(results.TranscodeResult{
+					OutFile:      "",
+					TimeTaken:    float64(duration) / 1e9,
+					ErrorMessage: fmt.Sprintf("Rendition %s: %s", rendition.Name, checkErr),
+				})
+			}
+		}
+		return withProvenance(results.TranscodeResult{
+			OutFile:        "",
+			TimeTaken:      float64(duration) / 1e9,
+			ErrorMessage:   "",
+			Renditions:     renditions,
+			SourceLoudness: sourceLoudness,
+		})
+	}
+
+	checkErr := checkOutputFile(outFileName)
+	if checkErr != nil {
+		return withProvenance(results.TranscodeResult{
+			OutFile:      "",
+			TimeTaken:    float64(duration) / 1e9,
+			ErrorMessage: checkErr.Error(),
+		})
+	}
+	return withProvenance(results.TranscodeResult{
+		OutFile:        outFileName,
+		TimeTaken:      float64(duration) / 1e9,
+		ErrorMessage:   "",
+		SourceLoudness: sourceLoudness,
+	})
+}
+
+/**
+make sure that the transcode output exists and make it accessible to the other components
+*/
+func checkOutputFile(outFileName string) error {
 	_, statErr := os.Stat(outFileName)
 
 	if statErr != nil {
 		log.Printf("Transcode completed but could not find output file: %s", statErr)
-		return results.TranscodeResult{
-			OutFile:      "",
-			TimeTaken:    float64(duration) / 1e9,
-			ErrorMessage: fmt.Sprintf("Transcode completed but could not find output file: %s", statErr),
-		}
+		return errors.New(fmt.Sprintf("Transcode completed but could not find output file: %s", statErr))
 	}
 
 	modErr := os.Chmod(outFileName, 0777)
 	if modErr != nil {
 		log.Printf("WARNING: Could not change permissions on output file: %s", modErr)
 	}
-	return results.TranscodeResult{
-		OutFile:      outFileName,
-		TimeTaken:    float64(duration) / 1e9,
-		ErrorMessage: "",
+	return nil
+}
+
+/**
+make sure that a streaming package manifest exists and make the manifest and its segments accessible to the other components
+*/
+func checkPackageOutput(manifestFile string) error {
+	checkErr := checkOutputFile(manifestFile)
+	if checkErr != nil {
+		return checkErr
+	}
+
+	packageDir := path.Dir(manifestFile)
+	dirContent, readErr := ioutil.ReadDir(packageDir)
+	if readErr != nil {
+		log.Printf("WARNING: Could not list package directory %s: %s", packageDir, readErr)
+		return nil
+	}
+	for _, info := range dirContent {
+		modErr := os.Chmod(path.Join(packageDir, info.Name()), 0777)
+		if modErr != nil {
+			log.Printf("WARNING: Could not change permissions on package file: %s", modErr)
+		}
 	}
+	return nil
 }
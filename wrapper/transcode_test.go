@@ -1,6 +1,12 @@
 package main
 
 import (
+	"github.com/google/uuid"
+	"github.com/guardian/mediaflipper/common/models"
+	"github.com/guardian/mediaflipper/common/results"
+	"io/ioutil"
+	"os"
+	"path"
 	"strings"
 	"testing"
 )
@@ -30,3 +36,132 @@ func TestParseSettings(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildRenditionArgs(t *testing.T) {
+	ladderSettingsString := `{"name":"sampleladder","settingsId":"2C882CAA-386D-4963-91E1-FAA50DC84AED","wrapper":{"format":"mp4"},"audio":{"codec":"aac","bitrate":128000,"channels":2,"samplerate":48000},"renditions":[{"name":"720p","video":{"codec":"h264","bitrate":3000000}},{"name":"360p","video":{"codec":"h264","bitrate":800000}}]}`
+
+	settings, err := ParseSettings(ladderSettingsString)
+	if err != nil {
+		t.Error("ParseSettings unexpectedly failed: ", err)
+		t.FailNow()
+	}
+
+	args, outputs := buildRenditionArgs("/path/to/media.mxf", "/output", settings.(models.JobSettings))
+	if len(outputs) != 2 {
+		t.Errorf("expected 2 outputs, got %d", len(outputs))
+		t.FailNow()
+	}
+	if outputs[0].Name != "720p" || outputs[0].OutFile != "/output/media_transc_720p.mp4" {
+		t.Errorf("got wrong first output: %v", outputs[0])
+	}
+	if outputs[1].Name != "360p" || outputs[1].OutFile != "/output/media_transc_360p.mp4" {
+		t.Errorf("got wrong second output: %v", outputs[1])
+	}
+
+	argString := strings.Join(args, " ")
+	if strings.Count(argString, "-i ") != 1 {
+		t.Errorf("expected the input to be read once, got '%s'", argString)
+	}
+	if !strings.Contains(argString, "-b:v 3000000 -acodec aac -b:a 128000 -ac 2 -ar 48000 -f mp4 -y /output/media_transc_720p.mp4") {
+		t.Errorf("720p output options were wrong, got '%s'", argString)
+	}
+	if !strings.HasSuffix(argString, "-b:v 800000 -acodec aac -b:a 128000 -ac 2 -ar 48000 -f mp4 -y /output/media_transc_360p.mp4") {
+		t.Errorf("360p output options were wrong, got '%s'", argString)
+	}
+}
+
+func TestBuildPackageArgs(t *testing.T) {
+	outPath, _ := ioutil.TempDir("", "transcode_test")
+	defer os.RemoveAll(outPath)
+
+	packageSettingsString := `{"name":"samplepackage","settingsId":"2C882CAA-386D-4963-91E1-FAA50DC84AED","video":{"codec":"h264","bitrate":1000000},"audio":{"codec":"aac","bitrate":128000,"channels":2,"samplerate":48000},"wrapper":{"packaging":{"formats":["hls","dash"]}}}`
+	settings, err := ParseSettings(packageSettingsString)
+	if err != nil {
+		t.Error("ParseSettings unexpectedly failed: ", err)
+		t.FailNow()
+	}
+
+	jobId := uuid.MustParse("0F2A6C3E-5B8D-4E1F-9A7C-3D6B2E8F1A45")
+	args, packages, buildErr := buildPackageArgs("/path/to/media.mxf", outPath, settings.(models.JobSettings), jobId)
+	if buildErr != nil {
+		t.Error("buildPackageArgs unexpectedly failed: ", buildErr)
+		t.FailNow()
+	}
+	if len(packages) != 2 {
+		t.Errorf("expected 2 packages, got %d", len(packages))
+		t.FailNow()
+	}
+
+	jobDir := path.Join(outPath, "media_0f2a6c3e-5b8d-4e1f-9a7c-3d6b2e8f1a45")
+	if packages[0].Format != "hls" || packages[0].ManifestFile != path.Join(jobDir, "hls", "index.m3u8") {
+		t.Errorf("got wrong hls package: %v", packages[0])
+	}
+	if packages[1].Format != "dash" || packages[1].ManifestFile != path.Join(jobDir, "dash", "manifest.mpd") {
+		t.Errorf("got wrong dash package: %v", packages[1])
+	}
+	for _, pkg := range packages {
+		if _, statErr := os.Stat(path.Dir(pkg.ManifestFile)); statErr != nil {
+			t.Errorf("directory for %s package was not created: %s", pkg.Format, statErr)
+		}
+	}
+
+	argString := strings.Join(args, " ")
+	if !strings.Contains(argString, "-f hls -hls_time 6 -hls_playlist_type vod -hls_segment_filename "+path.Join(jobDir, "hls", "segment_%05d.ts")+" -y "+packages[0].ManifestFile) {
+		t.Errorf("hls output options were wrong, got '%s'", argString)
+	}
+	if !strings.HasSuffix(argString, "-f dash -seg_duration 6 -y "+packages[1].ManifestFile) {
+		t.Errorf("dash output options were wrong, got '%s'", argString)
+	}
+}
+
+func TestParseSettingsAudio(t *testing.T) {
+	audioSettingsString := `{"name":"sampleaudio","settingsId":"2C882CAA-386D-4963-91E1-FAA50DC84AED","format":"mp3","audio":{"codec":"libmp3lame","bitrate":192000,"channels":2,"samplerate":44100}}`
+
+	result, err := ParseSettings(audioSettingsString)
+	if err != nil {
+		t.Error("ParseSettings unexpectedly failed: ", err)
+		t.FailNow()
+	}
+	if _, isAudio := result.(models.TranscodeAudioSettings); !isAudio {
+		t.Errorf("expected audio settings, got %v", result)
+	}
+	stringOut := result.MarshalToString()
+	if !strings.HasPrefix(stringOut, "-vn ") || strings.Contains(stringOut, "-vcodec") {
+		t.Errorf("audio settings should drop the video, got '%s'", stringOut)
+	}
+}
+
+/**
+TranscodeForSettings should transcode video and audio settings, only measure quality for video, and refuse image settings
+*/
+func TestTranscodeForSettings(t *testing.T) {
+	var transcoded []models.TranscodeTypeSettings
+	fakeTranscode := func(fileName string, maybeOutPath string, settings models.TranscodeTypeSettings, jobContainerId uuid.UUID, jobStepId uuid.UUID) results.TranscodeResult {
+		transcoded = append(transcoded, settings)
+		return results.TranscodeResult{OutFile: "/path/to/output"}
+	}
+	quality := &models.QualityMetricSettings{MinPSNR: 30}
+
+	audioSettings, _ := ParseSettings(`{"name":"sampleaudio","settingsId":"2C882CAA-386D-4963-91E1-FAA50DC84AED","format":"mp3","audio":{"codec":"libmp3lame","bitrate":192000,"channels":2,"samplerate":44100}}`)
+	audioResult := TranscodeForSettings("/path/to/input", "", audioSettings, quality, uuid.New(), uuid.New(), fakeTranscode)
+	if len(transcoded) != 1 || audioResult.ErrorMessage != "" || audioResult.OutFile != "/path/to/output" {
+		t.Errorf("expected audio settings to be transcoded, got %v", audioResult)
+	}
+	if audioResult.Quality != nil {
+		t.Error("quality should not be measured for audio")
+	}
+
+	videoSettings := models.JobSettings{Wrapper: models.WrapperSettings{Format: "mp4"}}
+	videoResult := TranscodeForSettings("/path/to/input", "", videoSettings, quality, uuid.New(), uuid.New(), fakeTranscode)
+	if len(transcoded) != 2 || videoResult.OutFile != "/path/to/output" {
+		t.Errorf("expected video settings to be transcoded, got %v", videoResult)
+	}
+	if videoResult.Quality == nil {
+		t.Error("expected quality to be measured for video")
+	}
+
+	imageResult := TranscodeForSettings("/path/to/input", "", models.TranscodeImageSettings{}, nil, uuid.New(), uuid.New(), fakeTranscode)
+	if len(transcoded) != 2 || imageResult.ErrorMessage == "" {
+		t.Errorf("expected image settings to be refused, got %v", imageResult)
+	}
+}
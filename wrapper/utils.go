@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"math"
 	"path"
 	"regexp"
 	"strings"
@@ -55,3 +56,30 @@ func GetOutputFilenameThumb(maybeOutPath string, inPath string) string {
 func GetOutputFileTransc(maybeOutPath string, inPath string, xtn string) string {
 	return GetOutputFilenameFull(maybeOutPath, inPath, "transc", xtn)
 }
+
+func GetOutputFileRendition(maybeOutPath string, inPath string, renditionName string, xtn string) string {
+	return GetOutputFilenameFull(maybeOutPath, inPath, "transc_"+renditionName, xtn)
+}
+
+/**
+returns the directory to write a streaming package of the given format into. Each job gets a directory of its own
+alongside the other outputs, named after the incoming file, with a subdirectory for each format
+*/
+func GetOutputPackageDir(maybeOutPath string, inPath string, jobContainerId string, format string) string {
+	var baseDir string
+	if maybeOutPath != "" {
+		baseDir = maybeOutPath
+	} else {
+		baseDir = path.Dir(inPath)
+	}
+	jobDirName := fmt.Sprintf("%s_%s", RemoveExtension(path.Base(inPath)), jobContainerId)
+	return path.Join(baseDir, jobDirName, format)
+}
+
+/**
+formats a time in seconds as HH:MM:SS.mmm, as used by WebVTT
+*/
+func FormatTimestamp(seconds float64) string {
+	millis := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", millis/3600000, (millis/60000)%60, (millis/1000)%60, millis%1000)
+}
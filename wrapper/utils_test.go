@@ -39,3 +39,12 @@ func TestGetOutputFilenameFull(t *testing.T) {
 		t.Errorf("GetOutputFilenameFile returned unexpected value: got %s expected %s", noXtnResult, "/path/to/input/myfile_blah")
 	}
 }
+
+func TestFormatTimestamp(t *testing.T) {
+	if FormatTimestamp(3725.5) != "01:02:05.500" {
+		t.Errorf("got wrong timestamp %s", FormatTimestamp(3725.5))
+	}
+	if FormatTimestamp(0) != "00:00:00.000" {
+		t.Errorf("got wrong timestamp %s", FormatTimestamp(0))
+	}
+}